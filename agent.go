@@ -0,0 +1,110 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+// AgentTool is a tool available to an [Agent]: its definition, sent to the
+// model, and the function executing it.
+type AgentTool struct {
+	Tool ToolParam
+	Run  ToolFunc
+}
+
+// AgentConfig configures an [Agent].
+type AgentConfig struct {
+	Model Model
+	// MaxTokens is the max_tokens of each request. Defaults to 1024.
+	MaxTokens int64
+	// System is the system prompt, if any.
+	System string
+	Tools  []AgentTool
+	// RunnerOpts configures the tool-execution loop of each turn.
+	RunnerOpts ToolRunnerOpts
+	// RequestOptions are applied to every request.
+	RequestOptions []option.RequestOption
+}
+
+// Agent is a conversation with a model which can call tools. It keeps the
+// history of the conversation and runs the tool-execution loop of a
+// [ToolRunner] for each user message. See [MessageService.NewAgent].
+//
+// An Agent must not be used from several goroutines at once.
+type Agent struct {
+	cfg     AgentConfig
+	runner  *ToolRunner
+	history []MessageParam
+}
+
+// NewAgent returns an Agent with an empty history.
+//
+//	agent := client.Messages.NewAgent(anthropic.AgentConfig{
+//		Model:  anthropic.ModelClaudeSonnet4_5_20250929,
+//		System: "You are a helpful travel assistant.",
+//		Tools:  []anthropic.AgentTool{{Tool: weatherTool, Run: getWeather}},
+//	})
+//	reply, err := agent.Chat(ctx, "What should I pack for Oslo?")
+func (r *MessageService) NewAgent(cfg AgentConfig) *Agent {
+	if cfg.MaxTokens == 0 {
+		cfg.MaxTokens = 1024
+	}
+	tools := make(map[string]ToolFunc, len(cfg.Tools))
+	for _, tool := range cfg.Tools {
+		tools[tool.Tool.Name] = tool.Run
+	}
+	return &Agent{cfg: cfg, runner: r.NewToolRunner(tools, cfg.RunnerOpts)}
+}
+
+// Chat sends userText and runs the turn until the model replies without calling
+// a tool, returning its final message. The user message, assistant turns and
+// tool results are added to the history.
+//
+// If the turn is stopped early by [ToolRunnerOpts.OnIteration] or
+// [ToolRunnerOpts.MaxIterations], the tool uses left unanswered are recorded
+// as not run, so that the conversation can continue. If a request fails, the
+// history is left unchanged.
+func (a *Agent) Chat(ctx context.Context, userText string) (*Message, error) {
+	messages := slices.Clone(a.history)
+	// The history ends with a user message if the previous turn was stopped
+	// with tool uses unanswered, in which case the text joins their results.
+	if n := len(messages); n > 0 && messages[n-1].Role == MessageParamRoleUser {
+		last := messages[n-1]
+		last.Content = append(slices.Clone(last.Content), NewTextBlock(userText))
+		messages[n-1] = last
+	} else {
+		messages = append(messages, NewUserMessage(NewTextBlock(userText)))
+	}
+
+	params := MessageNewParams{
+		Model:     a.cfg.Model,
+		MaxTokens: a.cfg.MaxTokens,
+		Messages:  messages,
+	}
+	if a.cfg.System != "" {
+		params.System = []TextBlockParam{{Text: a.cfg.System}}
+	}
+	for _, tool := range a.cfg.Tools {
+		params.Tools = append(params.Tools, ToolUnionParam{OfTool: &tool.Tool})
+	}
+
+	message, transcript, err := a.runner.Run(ctx, params, a.cfg.RequestOptions...)
+	if err != nil && !errors.Is(err, ErrMaxToolIterations) {
+		return nil, err
+	}
+	a.history = RepairToolPairing(transcript)
+	return message, err
+}
+
+// History returns the conversation so far.
+func (a *Agent) History() []MessageParam {
+	return slices.Clone(a.history)
+}
+
+// Reset clears the history, to start a new conversation.
+func (a *Agent) Reset() {
+	a.history = nil
+}
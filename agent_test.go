@@ -0,0 +1,89 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/tidwall/gjson"
+)
+
+func agentConfig(opts anthropic.ToolRunnerOpts) anthropic.AgentConfig {
+	return anthropic.AgentConfig{
+		Model:  anthropic.ModelClaudeSonnet4_5_20250929,
+		System: "You are a travel assistant.",
+		Tools: []anthropic.AgentTool{{
+			Tool: anthropic.ToolParam{Name: "get_weather", InputSchema: anthropic.ToolInputSchemaParam{}},
+			Run: func(ctx context.Context, input json.RawMessage) (string, error) {
+				return "snowing in " + gjson.GetBytes(input, "city").String(), nil
+			},
+		}},
+		RunnerOpts: opts,
+	}
+}
+
+func TestAgent(t *testing.T) {
+	var requests []string
+	client := scriptedClient([]string{
+		`[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Oslo"}}]`,
+		`[{"type":"text","text":"Pack a warm coat."}]`,
+		`[{"type":"text","text":"You're welcome."}]`,
+	}, &requests)
+	agent := client.Messages.NewAgent(agentConfig(anthropic.ToolRunnerOpts{}))
+
+	message, err := agent.Chat(context.Background(), "What should I pack for Oslo?")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if message.Content[0].Text != "Pack a warm coat." {
+		t.Errorf("unexpected reply: %s", message.RawJSON())
+	}
+	if _, err := agent.Chat(context.Background(), "Thanks!"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if history := agent.History(); len(history) != 6 {
+		t.Errorf("expected 6 messages in the history, got %d", len(history))
+	}
+	last := gjson.Parse(requests[2])
+	if last.Get("system.0.text").String() != "You are a travel assistant." || last.Get("tools.0.name").String() != "get_weather" {
+		t.Errorf("expected the system prompt and tools to be sent, got %s", requests[2])
+	}
+	if n := len(last.Get("messages").Array()); n != 5 || last.Get("messages.2.content.0.content.0.text").String() != "snowing in Oslo" {
+		t.Errorf("expected the history to be sent, got %s", last.Get("messages").Raw)
+	}
+
+	agent.Reset()
+	if len(agent.History()) != 0 {
+		t.Errorf("expected the history to be cleared")
+	}
+}
+
+func TestAgentStoppedTurn(t *testing.T) {
+	var requests []string
+	client := scriptedClient([]string{
+		`[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Oslo"}}]`,
+		`[{"type":"text","text":"Sorry, here is what I know."}]`,
+	}, &requests)
+	agent := client.Messages.NewAgent(agentConfig(anthropic.ToolRunnerOpts{MaxIterations: 1}))
+
+	message, err := agent.Chat(context.Background(), "What should I pack for Oslo?")
+	if !errors.Is(err, anthropic.ErrMaxToolIterations) || message == nil {
+		t.Fatalf("expected the turn to stop after one iteration, got %v", err)
+	}
+	history := agent.History()
+	if len(history) != 3 || !history[2].Content[0].OfToolResult.IsError.Value {
+		t.Fatalf("expected an error result for the unanswered tool use, got %+v", history)
+	}
+
+	if _, err := agent.Chat(context.Background(), "Answer without the tool."); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The text joins the tool result rather than adding a second user message.
+	messages := gjson.Get(requests[1], "messages").Array()
+	if len(messages) != 3 || messages[2].Get("content.1.text").String() != "Answer without the tool." {
+		t.Errorf("expected the text to join the tool result, got %s", gjson.Get(requests[1], "messages").Raw)
+	}
+}
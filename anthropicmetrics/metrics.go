@@ -0,0 +1,209 @@
+// Package anthropicmetrics records Prometheus metrics for the requests made by
+// an Anthropic client.
+//
+//	registry := prometheus.NewRegistry()
+//	client := anthropic.NewClient(
+//	    option.WithMiddleware(anthropicmetrics.NewMiddleware(registry)),
+//	)
+//
+// The following metrics are recorded:
+//
+//   - anthropic_requests_total: requests by method, path and status code, or
+//     "error" if no response was received
+//   - anthropic_request_duration_seconds: time until the response headers
+//     arrive, by method and path
+//   - anthropic_retries_total: retried requests, by method and path
+//   - anthropic_tokens_total: tokens used, by model and type (input, output,
+//     cache_creation_input, cache_read_input)
+//   - anthropic_errors_total: failed requests, by error type, such as
+//     rate_limit_error, or connection_error if no response was received
+//
+// Paths are normalized so that IDs do not create new series: a path such as
+// /v1/messages/batches/msgbatch_123 is recorded as /v1/messages/batches/:id.
+package anthropicmetrics
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+)
+
+type metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	retries  *prometheus.CounterVec
+	tokens   *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+}
+
+// NewMiddleware returns a middleware which records metrics for every request
+// attempt in registry. Calling it again with the same registry, for example
+// for several clients, reuses the collectors registered the first time.
+func NewMiddleware(registry *prometheus.Registry) option.Middleware {
+	m := &metrics{
+		requests: register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "anthropic_requests_total",
+			Help: "Requests made to the Anthropic API.",
+		}, []string{"method", "path", "status"})),
+		duration: register(registry, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "anthropic_request_duration_seconds",
+			Help:    "Time until the response headers of a request to the Anthropic API arrive.",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+		}, []string{"method", "path"})),
+		retries: register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "anthropic_retries_total",
+			Help: "Requests to the Anthropic API which were retries of a failed attempt.",
+		}, []string{"method", "path"})),
+		tokens: register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "anthropic_tokens_total",
+			Help: "Tokens used by requests to the Anthropic API.",
+		}, []string{"model", "type"})),
+		errors: register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "anthropic_errors_total",
+			Help: "Failed requests to the Anthropic API.",
+		}, []string{"type"})),
+	}
+	return m.middleware
+}
+
+// register registers c, or returns the equivalent collector already
+// registered.
+func register[C prometheus.Collector](registry *prometheus.Registry, c C) C {
+	if err := registry.Register(c); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			if existing, ok := already.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+func (m *metrics) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	path := normalizePath(req.URL.Path)
+	if n, _ := strconv.Atoi(req.Header.Get("X-Stainless-Retry-Count")); n > 0 {
+		m.retries.WithLabelValues(req.Method, path).Inc()
+	}
+
+	start := time.Now()
+	res, err := next(req)
+	m.duration.WithLabelValues(req.Method, path).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.requests.WithLabelValues(req.Method, path, "error").Inc()
+		m.errors.WithLabelValues("connection_error").Inc()
+		return res, err
+	}
+	m.requests.WithLabelValues(req.Method, path, strconv.Itoa(res.StatusCode)).Inc()
+	if res.Body == nil {
+		return res, err
+	}
+
+	switch {
+	case strings.HasPrefix(res.Header.Get("content-type"), "text/event-stream"):
+		res.Body = &usageBody{rc: res.Body, br: bufio.NewReader(res.Body), metrics: m}
+	case res.StatusCode >= 400 || strings.Contains(res.Header.Get("content-type"), "json"):
+		body, readErr := io.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		if readErr != nil {
+			return res, err
+		}
+		if res.StatusCode >= 400 {
+			errType := gjson.GetBytes(body, "error.type").String()
+			if errType == "" {
+				errType = "http_" + strconv.Itoa(res.StatusCode)
+			}
+			m.errors.WithLabelValues(errType).Inc()
+		} else {
+			m.recordUsage(gjson.GetBytes(body, "model").String(), gjson.GetBytes(body, "usage"), tokenTypes...)
+		}
+	}
+	return res, err
+}
+
+var tokenTypes = []string{"input", "output", "cache_creation_input", "cache_read_input"}
+
+// recordUsage adds the tokens of the given types from usage to the tokens
+// counter.
+func (m *metrics) recordUsage(model string, usage gjson.Result, kinds ...string) {
+	if !usage.Exists() || model == "" {
+		return
+	}
+	for _, kind := range kinds {
+		if n := usage.Get(kind + "_tokens").Float(); n > 0 {
+			m.tokens.WithLabelValues(model, kind).Add(n)
+		}
+	}
+}
+
+// usageBody passes an event stream through, recording the token usage reported
+// by its message_start and message_delta events, and the errors it reports.
+type usageBody struct {
+	rc      io.ReadCloser
+	br      *bufio.Reader
+	metrics *metrics
+	model   string
+	pending []byte
+}
+
+func (b *usageBody) Read(p []byte) (int, error) {
+	if len(b.pending) == 0 {
+		line, err := b.br.ReadBytes('\n')
+		b.scanLine(line)
+		if len(line) == 0 {
+			return 0, err
+		}
+		b.pending = line
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+func (b *usageBody) scanLine(line []byte) {
+	data, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r\n"), []byte("data:"))
+	if !ok {
+		return
+	}
+	event := gjson.ParseBytes(data)
+	switch event.Get("type").String() {
+	case "message_start":
+		b.model = event.Get("message.model").String()
+		// The output tokens are counted once the message is complete, as
+		// message_delta reports the total for the message.
+		b.metrics.recordUsage(b.model, event.Get("message.usage"), "input", "cache_creation_input", "cache_read_input")
+	case "message_delta":
+		b.metrics.recordUsage(b.model, event.Get("usage"), "output")
+	case "error":
+		b.metrics.errors.WithLabelValues(event.Get("error.type").String()).Inc()
+	}
+}
+
+func (b *usageBody) Close() error {
+	return b.rc.Close()
+}
+
+// normalizePath replaces the segments of a path which hold IDs, recognized by
+// containing a digit, with :id. The leading version segment is kept.
+func normalizePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment == "v1" || !strings.ContainsFunc(segment, unicode.IsDigit) {
+			continue
+		}
+		segments[i] = ":id"
+	}
+	return strings.Join(segments, "/")
+}
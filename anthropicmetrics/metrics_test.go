@@ -0,0 +1,140 @@
+package anthropicmetrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/anthropicmetrics"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+type closureTransport struct {
+	fn func(req *http.Request) (*http.Response, error)
+}
+
+func (t *closureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.fn(req)
+}
+
+// metricValue returns the value of the counter, or the sample count of the
+// histogram, with the given name and labels.
+func metricValue(t *testing.T, registry *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			if h := metric.GetHistogram(); h != nil {
+				return float64(h.GetSampleCount())
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestMiddleware(t *testing.T) {
+	responses := []*http.Response{
+		{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"Content-Type": {"application/json"}, "Retry-After-Ms": {"1"}},
+			Body:       io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)),
+		},
+		{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body: io.NopCloser(strings.NewReader(`{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929",` +
+				`"stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":100}}`)),
+		},
+		{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/event-stream"}},
+			Body: io.NopCloser(strings.NewReader(
+				"event: message_start\n" +
+					`data: {"type":"message_start","message":{"id":"msg_2","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":20,"output_tokens":1}}}` + "\n\n" +
+					"event: message_delta\n" +
+					`data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":7}}` + "\n\n" +
+					"event: message_stop\n" + `data: {"type":"message_stop"}` + "\n\n",
+			)),
+		},
+		{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"id":"claude-sonnet-4-5-20250929","type":"model","display_name":"Claude Sonnet 4.5","created_at":"2025-09-29T00:00:00Z"}`)),
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithMaxRetries(1),
+		option.WithMiddleware(anthropicmetrics.NewMiddleware(registry)),
+		// A second middleware on the same registry shares its collectors.
+		option.WithMiddleware(anthropicmetrics.NewMiddleware(registry)),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					res := responses[0]
+					responses = responses[1:]
+					return res, nil
+				},
+			},
+		}),
+	)
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hi"))},
+	}
+
+	if _, err := client.Messages.New(context.Background(), params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stream := client.Messages.NewStreaming(context.Background(), params)
+	for stream.Next() {
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	if _, err := client.Models.Get(context.Background(), "claude-sonnet-4-5", anthropic.ModelGetParams{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	model := "claude-sonnet-4-5-20250929"
+	cases := []struct {
+		name     string
+		labels   map[string]string
+		expected float64
+	}{
+		{"anthropic_requests_total", map[string]string{"method": "POST", "path": "/v1/messages", "status": "429"}, 2},
+		{"anthropic_requests_total", map[string]string{"method": "POST", "path": "/v1/messages", "status": "200"}, 4},
+		{"anthropic_requests_total", map[string]string{"method": "GET", "path": "/v1/models/:id", "status": "200"}, 2},
+		{"anthropic_request_duration_seconds", map[string]string{"method": "POST", "path": "/v1/messages"}, 6},
+		{"anthropic_retries_total", map[string]string{"method": "POST", "path": "/v1/messages"}, 2},
+		{"anthropic_errors_total", map[string]string{"type": "rate_limit_error"}, 2},
+		{"anthropic_tokens_total", map[string]string{"model": model, "type": "input"}, 60},
+		{"anthropic_tokens_total", map[string]string{"model": model, "type": "output"}, 24},
+		{"anthropic_tokens_total", map[string]string{"model": model, "type": "cache_read_input"}, 200},
+	}
+	for _, c := range cases {
+		if got := metricValue(t, registry, c.name, c.labels); got != c.expected {
+			t.Errorf("%s%v: expected %v, got %v", c.name, c.labels, c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+// Package anthropictest provides a client for tests of code calling the
+// Anthropic API, which returns scripted responses without making requests.
+//
+//	mock := anthropictest.NewMockClient(
+//	    anthropictest.TextResponse("Hello!"),
+//	    anthropictest.RateLimited(time.Second),
+//	)
+//	reply, err := greet(ctx, &mock.Client)
+//	params, _ := mock.Calls()[0].Params()
+//
+// Each request consumes the next queued [MockResponse], whether it is made by
+// Messages.New, Messages.NewStreaming or the beta API. Retries are disabled by
+// default, so that each call consumes exactly one response; pass
+// option.WithMaxRetries to a call to test how it retries.
+package anthropictest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// MockResponse is the scripted response to one request.
+type MockResponse struct {
+	// Message is returned by a request which does not stream. A streaming
+	// request receives the events which build it, unless Events is set. Empty
+	// fields are filled in: the model from the request, the role and type, and
+	// a stop reason of tool_use if the message has tool calls, or else
+	// end_turn.
+	Message *anthropic.Message
+	// Events are the events streamed to a streaming request, in order. Each is
+	// either an event read from the API, such as a
+	// [anthropic.BetaRawMessageStreamEventUnion], whose raw JSON is sent, or a
+	// value encoded to JSON, such as a map. The event type is taken from its
+	// "type" field.
+	Events []any
+	// StatusCode, if 400 or more, makes the request fail with an API error of
+	// type ErrorType, or the type for the status if it is empty, and
+	// ErrorMessage.
+	StatusCode   int
+	ErrorType    string
+	ErrorMessage string
+	// Header is added to the headers of the response.
+	Header http.Header
+	// Err, if set, is returned by the transport, as for a connection error.
+	Err error
+	// Delay is waited before responding, or until the request is cancelled.
+	Delay time.Duration
+}
+
+// TextResponse returns a response with a message holding text.
+func TextResponse(text string) MockResponse {
+	return MockResponse{Message: &anthropic.Message{Content: []anthropic.ContentBlockUnion{{Type: "text", Text: text}}}}
+}
+
+// RateLimited returns a response which fails with a rate_limit_error, asking
+// to retry after retryAfter.
+func RateLimited(retryAfter time.Duration) MockResponse {
+	return MockResponse{
+		StatusCode:   http.StatusTooManyRequests,
+		ErrorMessage: "Number of request tokens has exceeded your per-minute rate limit",
+		Header:       http.Header{"Retry-After": {strconv.Itoa(int(retryAfter.Round(time.Second).Seconds()))}},
+	}
+}
+
+// MockCall records a request received by a [MockClient].
+type MockCall struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+	// Stream reports whether the request asked for a streaming response.
+	Stream bool
+}
+
+// Params decodes the body of a call to Messages.New or Messages.NewStreaming.
+func (c MockCall) Params() (params anthropic.MessageNewParams, err error) {
+	err = params.UnmarshalJSON(c.Body)
+	return params, err
+}
+
+// BetaParams decodes the body of a call to Beta.Messages.New or
+// Beta.Messages.NewStreaming. The betas are read from the anthropic-beta
+// header.
+func (c MockCall) BetaParams() (params anthropic.BetaMessageNewParams, err error) {
+	err = params.UnmarshalJSON(c.Body)
+	for _, beta := range c.Header.Values("anthropic-beta") {
+		for _, b := range strings.Split(beta, ",") {
+			params.Betas = append(params.Betas, anthropic.AnthropicBeta(strings.TrimSpace(b)))
+		}
+	}
+	return params, err
+}
+
+// MockClient is a client whose requests are answered with queued responses.
+// Its methods may be called concurrently with requests.
+type MockClient struct {
+	anthropic.Client
+
+	mu        sync.Mutex
+	responses []MockResponse
+	calls     []MockCall
+}
+
+// NewMockClient returns a client which answers its requests with responses,
+// in order. A request made once the responses are used up fails.
+func NewMockClient(responses ...MockResponse) *MockClient {
+	m := &MockClient{responses: responses}
+	m.Client = anthropic.NewClient(
+		option.WithAPIKey("anthropictest"),
+		option.WithBaseURL("https://api.anthropic.com/"),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(&http.Client{Transport: m}),
+	)
+	return m
+}
+
+// Enqueue adds responses to answer the following requests.
+func (m *MockClient) Enqueue(responses ...MockResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses = append(m.responses, responses...)
+}
+
+// Calls returns the requests received so far.
+func (m *MockClient) Calls() []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockCall(nil), m.calls...)
+}
+
+// Pending returns the number of responses not consumed yet, so that a test can
+// check every scripted response was used.
+func (m *MockClient) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.responses)
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (m *MockClient) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	call := MockCall{Method: req.Method, Path: req.URL.Path, Header: req.Header.Clone(), Body: body, Stream: gjson.GetBytes(body, "stream").Bool()}
+
+	m.mu.Lock()
+	m.calls = append(m.calls, call)
+	n := len(m.calls)
+	if len(m.responses) == 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("anthropictest: no response queued for request %d, %s %s", n, req.Method, req.URL.Path)
+	}
+	mock := m.responses[0]
+	m.responses = m.responses[1:]
+	m.mu.Unlock()
+
+	if mock.Delay > 0 {
+		select {
+		case <-time.After(mock.Delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if mock.Err != nil {
+		return nil, mock.Err
+	}
+
+	res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Request: req}
+	for name, values := range mock.Header {
+		res.Header[name] = values
+	}
+	res.Header.Set("Request-Id", fmt.Sprintf("req_mock_%d", n))
+	var data []byte
+	var err error
+	switch {
+	case mock.StatusCode >= 400:
+		res.StatusCode = mock.StatusCode
+		res.Header.Set("Content-Type", "application/json")
+		data, err = errorBody(mock)
+	case call.Stream:
+		res.Header.Set("Content-Type", "text/event-stream")
+		data, err = streamBody(mock, n, gjson.GetBytes(body, "model").String())
+	default:
+		res.Header.Set("Content-Type", "application/json")
+		data, err = messageJSON(mock.Message, n, gjson.GetBytes(body, "model").String())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("anthropictest: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(data))
+	res.ContentLength = int64(len(data))
+	return res, nil
+}
+
+// errorTypes are the types of the API errors by status.
+var errorTypes = map[int]string{
+	http.StatusBadRequest:            "invalid_request_error",
+	http.StatusUnauthorized:          "authentication_error",
+	http.StatusPaymentRequired:       "billing_error",
+	http.StatusForbidden:             "permission_error",
+	http.StatusNotFound:              "not_found_error",
+	http.StatusRequestEntityTooLarge: "request_too_large",
+	http.StatusTooManyRequests:       "rate_limit_error",
+	http.StatusGatewayTimeout:        "timeout_error",
+	529:                              "overloaded_error",
+}
+
+func errorBody(mock MockResponse) ([]byte, error) {
+	errType := mock.ErrorType
+	if errType == "" {
+		errType = errorTypes[mock.StatusCode]
+	}
+	if errType == "" {
+		errType = "api_error"
+	}
+	return json.Marshal(map[string]any{
+		"type":  "error",
+		"error": map[string]string{"type": errType, "message": mock.ErrorMessage},
+	})
+}
+
+// messageJSON encodes message, the response to the nth request, as the API
+// would, filling in its empty fields.
+func messageJSON(message *anthropic.Message, n int, model string) ([]byte, error) {
+	if message == nil {
+		return nil, fmt.Errorf("response has no message")
+	}
+	m := map[string]any{
+		"id":            message.ID,
+		"type":          "message",
+		"role":          "assistant",
+		"model":         string(message.Model),
+		"stop_reason":   string(message.StopReason),
+		"stop_sequence": nil,
+		"usage": map[string]any{
+			"input_tokens":                message.Usage.InputTokens,
+			"output_tokens":               message.Usage.OutputTokens,
+			"cache_creation_input_tokens": message.Usage.CacheCreationInputTokens,
+			"cache_read_input_tokens":     message.Usage.CacheReadInputTokens,
+		},
+	}
+	if message.ID == "" {
+		m["id"] = fmt.Sprintf("msg_mock_%d", n)
+	}
+	if message.Model == "" {
+		m["model"] = model
+	}
+	if message.StopSequence != "" {
+		m["stop_sequence"] = message.StopSequence
+	}
+	content := []json.RawMessage{}
+	for _, block := range message.Content {
+		b, err := blockJSON(block)
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, b)
+		if message.StopReason == "" && block.Type == "tool_use" {
+			m["stop_reason"] = "tool_use"
+		}
+	}
+	m["content"] = content
+	if m["stop_reason"] == "" {
+		m["stop_reason"] = "end_turn"
+	}
+	return json.Marshal(m)
+}
+
+// blockJSON encodes a content block, which is either read from the API or
+// built by a test with only the fields of its type.
+func blockJSON(block anthropic.ContentBlockUnion) (json.RawMessage, error) {
+	if raw := block.RawJSON(); raw != "" {
+		return json.RawMessage(raw), nil
+	}
+	var b map[string]any
+	switch block.Type {
+	case "text":
+		b = map[string]any{"type": "text", "text": block.Text}
+	case "tool_use":
+		input := block.Input
+		if len(input) == 0 {
+			input = json.RawMessage(`{}`)
+		}
+		b = map[string]any{"type": "tool_use", "id": block.ID, "name": block.Name, "input": input}
+	case "thinking":
+		b = map[string]any{"type": "thinking", "thinking": block.Thinking, "signature": block.Signature}
+	case "redacted_thinking":
+		b = map[string]any{"type": "redacted_thinking", "data": block.Data}
+	default:
+		return nil, fmt.Errorf("content block of type %q must be decoded from JSON", block.Type)
+	}
+	return json.Marshal(b)
+}
+
+func streamBody(mock MockResponse, n int, model string) ([]byte, error) {
+	events := mock.Events
+	if events == nil {
+		var err error
+		if events, err = messageEvents(mock.Message, n, model); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	for _, event := range events {
+		var data []byte
+		if raw, ok := event.(interface{ RawJSON() string }); ok && raw.RawJSON() != "" {
+			data = []byte(raw.RawJSON())
+		} else {
+			var err error
+			if data, err = json.Marshal(event); err != nil {
+				return nil, err
+			}
+		}
+		fmt.Fprintf(&buf, "event: %s\ndata: %s\n\n", gjson.GetBytes(data, "type").String(), data)
+	}
+	return buf.Bytes(), nil
+}
+
+// messageEvents returns the events of a stream which builds message, with the
+// content of each block in a single delta.
+func messageEvents(message *anthropic.Message, n int, model string) ([]any, error) {
+	full, err := messageJSON(message, n, model)
+	if err != nil {
+		return nil, err
+	}
+	start, _ := sjson.SetRawBytes(full, "content", []byte(`[]`))
+	start, _ = sjson.SetRawBytes(start, "stop_reason", []byte(`null`))
+	start, _ = sjson.SetBytes(start, "usage.output_tokens", 0)
+	events := []any{map[string]any{"type": "message_start", "message": json.RawMessage(start)}}
+
+	for i, block := range gjson.GetBytes(full, "content").Array() {
+		var initial, delta string
+		var err error
+		switch block.Get("type").String() {
+		case "text":
+			initial, err = sjson.Set(block.Raw, "text", "")
+			delta = fmt.Sprintf(`{"type":"text_delta","text":%s}`, block.Get("text").Raw)
+		case "tool_use":
+			initial, err = sjson.SetRaw(block.Raw, "input", "{}")
+			delta, _ = sjson.Set(`{"type":"input_json_delta"}`, "partial_json", block.Get("input").Raw)
+		case "thinking":
+			initial, err = sjson.Set(block.Raw, "thinking", "")
+			delta = fmt.Sprintf(`{"type":"thinking_delta","thinking":%s}`, block.Get("thinking").Raw)
+		default:
+			initial = block.Raw
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, map[string]any{"type": "content_block_start", "index": i, "content_block": json.RawMessage(initial)})
+		if delta != "" {
+			events = append(events, map[string]any{"type": "content_block_delta", "index": i, "delta": json.RawMessage(delta)})
+		}
+		events = append(events, map[string]any{"type": "content_block_stop", "index": i})
+	}
+
+	events = append(events,
+		map[string]any{
+			"type":  "message_delta",
+			"delta": map[string]any{"stop_reason": gjson.GetBytes(full, "stop_reason").String(), "stop_sequence": json.RawMessage(gjson.GetBytes(full, "stop_sequence").Raw)},
+			"usage": map[string]any{"output_tokens": gjson.GetBytes(full, "usage.output_tokens").Int()},
+		},
+		map[string]any{"type": "message_stop"},
+	)
+	return events, nil
+}
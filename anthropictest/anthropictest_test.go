@@ -0,0 +1,166 @@
+package anthropictest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/anthropictest"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+var params = anthropic.MessageNewParams{
+	Model:     anthropic.ModelClaude3_7SonnetLatest,
+	MaxTokens: 1024,
+	Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hi"))},
+}
+
+func TestMockClient(t *testing.T) {
+	ctx := context.Background()
+	toolUse := anthropic.Message{Content: []anthropic.ContentBlockUnion{
+		{Type: "text", Text: "Let me check."},
+		{Type: "tool_use", ID: "toolu_1", Name: "get_weather", Input: []byte(`{"city":"Paris"}`)},
+	}}
+	mock := anthropictest.NewMockClient(
+		anthropictest.TextResponse("Hello!"),
+		anthropictest.MockResponse{Message: &toolUse},
+	)
+
+	message, err := mock.Messages.New(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Content[0].Text != "Hello!" || message.StopReason != anthropic.StopReasonEndTurn || message.Model != params.Model {
+		t.Errorf("got %s", message.RawJSON())
+	}
+
+	stream := mock.Messages.NewStreaming(ctx, params)
+	accumulated := anthropic.Message{}
+	for stream.Next() {
+		if err := accumulated.Accumulate(stream.Current()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(accumulated.Content) != 2 || accumulated.Content[0].Text != "Let me check." || string(accumulated.Content[1].Input) != `{"city":"Paris"}` {
+		t.Errorf("got content %+v", accumulated.Content)
+	}
+	if accumulated.StopReason != anthropic.StopReasonToolUse {
+		t.Errorf("got stop reason %q", accumulated.StopReason)
+	}
+
+	calls := mock.Calls()
+	if len(calls) != 2 || calls[0].Stream || !calls[1].Stream || calls[0].Path != "/v1/messages" {
+		t.Fatalf("got calls %+v", calls)
+	}
+	got, err := calls[1].Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != params.Model || got.MaxTokens != 1024 || got.Messages[0].Content[0].OfText.Text != "Hi" {
+		t.Errorf("got params %+v", got)
+	}
+
+	if _, err := mock.Messages.New(ctx, params); err == nil || !strings.Contains(err.Error(), "no response queued") {
+		t.Errorf("got error %v, want no response queued", err)
+	}
+	mock.Enqueue(anthropictest.TextResponse("Again"))
+	if mock.Pending() != 1 {
+		t.Errorf("got %d pending responses, want 1", mock.Pending())
+	}
+	if message, err := mock.Messages.New(ctx, params); err != nil || message.Content[0].Text != "Again" {
+		t.Errorf("got %v, %v", message, err)
+	}
+}
+
+func TestMockClientEvents(t *testing.T) {
+	mock := anthropictest.NewMockClient(anthropictest.MockResponse{Events: []any{
+		map[string]any{"type": "message_start", "message": map[string]any{"id": "msg_1", "type": "message", "role": "assistant", "content": []any{}, "model": "claude", "usage": map[string]any{"input_tokens": 3}}},
+		map[string]any{"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "text", "text": ""}},
+		map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "text_delta", "text": "Hel"}},
+		map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "text_delta", "text": "lo"}},
+		map[string]any{"type": "content_block_stop", "index": 0},
+		map[string]any{"type": "message_stop"},
+	}})
+	stream := mock.Beta.Messages.NewStreaming(context.Background(), anthropic.BetaMessageNewParams{
+		Model:     params.Model,
+		MaxTokens: 10,
+		Messages:  []anthropic.BetaMessageParam{anthropic.NewBetaUserMessage(anthropic.NewBetaTextBlock("Hi"))},
+		Betas:     []anthropic.AnthropicBeta{anthropic.AnthropicBetaTokenCounting2024_11_01},
+	})
+	var deltas []string
+	for stream.Next() {
+		if event := stream.Current(); event.Type == "content_block_delta" {
+			deltas = append(deltas, event.Delta.Text)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(deltas, "|") != "Hel|lo" {
+		t.Errorf("got deltas %q", deltas)
+	}
+	got, err := mock.Calls()[0].BetaParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Betas) != 1 || got.Betas[0] != anthropic.AnthropicBetaTokenCounting2024_11_01 {
+		t.Errorf("got betas %q", got.Betas)
+	}
+}
+
+func TestMockClientErrors(t *testing.T) {
+	ctx := context.Background()
+	errReset := errors.New("connection reset")
+	mock := anthropictest.NewMockClient(
+		anthropictest.RateLimited(30*time.Second),
+		anthropictest.MockResponse{StatusCode: 529, ErrorMessage: "Overloaded"},
+		anthropictest.MockResponse{Err: errReset},
+		anthropictest.MockResponse{Delay: time.Minute},
+	)
+
+	_, err := mock.Messages.New(ctx, params)
+	var rateLimit *anthropic.RateLimitError
+	var apiErr *anthropic.Error
+	if !errors.As(err, &rateLimit) || !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want a rate limit error", err)
+	}
+	if d := apiErr.RetryAfter(); d != 30*time.Second {
+		t.Errorf("got retry after %v", d)
+	}
+
+	_, err = mock.Messages.New(ctx, params)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 529 || !strings.Contains(apiErr.Error(), "overloaded_error") {
+		t.Errorf("got %v, want an overloaded error", err)
+	}
+
+	if _, err = mock.Messages.New(ctx, params); !errors.Is(err, errReset) {
+		t.Errorf("got %v, want %v", err, errReset)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err = mock.Messages.New(ctx, params); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the deadline to be exceeded", err)
+	}
+}
+
+func TestMockClientRetries(t *testing.T) {
+	mock := anthropictest.NewMockClient(
+		anthropictest.MockResponse{StatusCode: http.StatusInternalServerError},
+		anthropictest.TextResponse("Hello!"),
+	)
+	message, err := mock.Messages.New(context.Background(), params, option.WithMaxRetries(1), option.WithRequestTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Content[0].Text != "Hello!" || len(mock.Calls()) != 2 {
+		t.Errorf("got %s after %d calls", message.RawJSON(), len(mock.Calls()))
+	}
+}
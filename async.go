@@ -0,0 +1,76 @@
+package anthropic
+
+import (
+	"context"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+// Future is the pending result of a request started in the background with
+// [NewAsync], [MessageService.NewAsync] or [BetaMessageService.NewAsync].
+//
+// A Future is safe for concurrent use.
+type Future[T any] struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	res    T
+	err    error
+}
+
+// NewAsync runs fn in a new goroutine and returns a [Future] for its result.
+// fn receives a context derived from ctx which is cancelled when either ctx is
+// done or [Future.Cancel] is called.
+//
+//	fut := anthropic.NewAsync(ctx, func(ctx context.Context) (*anthropic.Message, error) {
+//		return client.Messages.New(ctx, params)
+//	})
+//	msg, err := fut.Get()
+func NewAsync[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) *Future[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	f := &Future[T]{
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	go func() {
+		defer close(f.done)
+		defer cancel()
+		f.res, f.err = fn(ctx)
+	}()
+	return f
+}
+
+// Get blocks until the request completes and returns its result. If the future
+// was cancelled before the request finished, the error is [context.Canceled].
+func (f *Future[T]) Get() (T, error) {
+	<-f.done
+	return f.res, f.err
+}
+
+// Done returns a channel which is closed once the result is available.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel cancels the context of this request only, leaving other futures
+// untouched. Any in-flight HTTP request is aborted and its connection closed,
+// so the request stops being processed. Calling Cancel after the request has
+// completed, or more than once, has no effect.
+func (f *Future[T]) Cancel() {
+	f.cancel()
+}
+
+// NewAsync starts [MessageService.New] in the background and returns a [Future]
+// for the resulting message.
+func (r *MessageService) NewAsync(ctx context.Context, body MessageNewParams, opts ...option.RequestOption) *Future[*Message] {
+	return NewAsync(ctx, func(ctx context.Context) (*Message, error) {
+		return r.New(ctx, body, opts...)
+	})
+}
+
+// NewAsync starts [BetaMessageService.New] in the background and returns a
+// [Future] for the resulting message.
+func (r *BetaMessageService) NewAsync(ctx context.Context, params BetaMessageNewParams, opts ...option.RequestOption) *Future[*BetaMessage] {
+	return NewAsync(ctx, func(ctx context.Context) (*BetaMessage, error) {
+		return r.New(ctx, params, opts...)
+	})
+}
@@ -0,0 +1,67 @@
+package anthropic_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+func TestFutureCancel(t *testing.T) {
+	aborted := make(chan struct{})
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					if req.Header.Get("X-Slow") == "" {
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Header:     http.Header{"Content-Type": []string{"application/json"}},
+							Body:       io.NopCloser(strings.NewReader(`{"id":"msg_fast","type":"message","role":"assistant","content":[]}`)),
+						}, nil
+					}
+					<-req.Context().Done()
+					close(aborted)
+					return nil, req.Context().Err()
+				},
+			},
+		}),
+	)
+
+	params := anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}
+	slow := client.Messages.NewAsync(context.Background(), params, option.WithHeader("X-Slow", "1"))
+	fast := client.Messages.NewAsync(context.Background(), params)
+
+	msg, err := fast.Get()
+	if err != nil || msg.ID != "msg_fast" {
+		t.Fatalf("expected fast future to complete, got %v, %v", msg, err)
+	}
+
+	slow.Cancel()
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("expected cancelling the future to abort the in-flight request")
+	}
+	if _, err := slow.Get(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// Cancelling a completed future is a no-op.
+	fast.Cancel()
+	if msg, err := fast.Get(); err != nil || msg.ID != "msg_fast" {
+		t.Fatalf("expected completed future to keep its result, got %v, %v", msg, err)
+	}
+}
@@ -0,0 +1,34 @@
+package anthropic
+
+// NewMessageBatchRequest returns a request of a message batch, for
+// [MessageBatchService.New], which sends params under customID. The custom ID
+// identifies the result of the request in the results of the batch, from
+// [MessageBatchService.ResultsStreaming].
+//
+//	batch, err := client.Messages.Batches.New(ctx, anthropic.MessageBatchNewParams{
+//		Requests: []anthropic.MessageBatchNewParamsRequest{
+//			anthropic.NewMessageBatchRequest("summary-1", params1),
+//			anthropic.NewMessageBatchRequest("summary-2", params2),
+//		},
+//	})
+func NewMessageBatchRequest(customID string, params MessageNewParams) MessageBatchNewParamsRequest {
+	return MessageBatchNewParamsRequest{
+		CustomID: customID,
+		Params: MessageBatchNewParamsRequestParams{
+			MaxTokens:     params.MaxTokens,
+			Messages:      params.Messages,
+			Model:         params.Model,
+			Temperature:   params.Temperature,
+			TopK:          params.TopK,
+			TopP:          params.TopP,
+			Metadata:      params.Metadata,
+			OutputConfig:  params.OutputConfig,
+			ServiceTier:   string(params.ServiceTier),
+			StopSequences: params.StopSequences,
+			System:        params.System,
+			Thinking:      params.Thinking,
+			ToolChoice:    params.ToolChoice,
+			Tools:         params.Tools,
+		},
+	}
+}
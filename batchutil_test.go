@@ -0,0 +1,35 @@
+package anthropic_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/tidwall/sjson"
+)
+
+func TestNewMessageBatchRequest(t *testing.T) {
+	params := anthropic.MessageNewParams{
+		Model:         anthropic.ModelClaudeSonnet4_5,
+		MaxTokens:     1024,
+		System:        []anthropic.TextBlockParam{{Text: "Be brief."}},
+		Messages:      []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Summarize this."))},
+		Temperature:   anthropic.Float(0.2),
+		ServiceTier:   anthropic.MessageNewParamsServiceTierStandardOnly,
+		StopSequences: []string{"END"},
+	}
+	request := anthropic.NewMessageBatchRequest("summary-1", params)
+
+	want, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, _ = sjson.SetRawBytes([]byte(`{"custom_id":"summary-1"}`), "params", want)
+	got, err := json.Marshal(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if normalizeJSON(t, got) != normalizeJSON(t, want) {
+		t.Errorf("expected the request to carry the params:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream/eventstreamapi"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/smithy-go/auth/bearer"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -203,6 +204,27 @@ func WithConfig(cfg aws.Config) option.RequestOption {
 		credentialErr = fmt.Errorf("expected AWS credentials to be set")
 	}
 
+	return withConfig(cfg, credentialErr)
+}
+
+// WithStaticCredentials is like [WithConfig], for an AWS access key rather
+// than a loaded config. sessionToken may be empty for long-term credentials.
+// Requests are signed with SigV4, even if the AWS_BEARER_TOKEN_BEDROCK
+// environment variable is set.
+//
+//	client := anthropic.NewClient(bedrock.WithStaticCredentials("us-east-1", accessKeyID, secretAccessKey, ""))
+func WithStaticCredentials(region, accessKeyID, secretAccessKey, sessionToken string) option.RequestOption {
+	var credentialErr error
+	if accessKeyID == "" || secretAccessKey == "" {
+		credentialErr = fmt.Errorf("expected an AWS access key ID and secret access key")
+	}
+	return withConfig(aws.Config{
+		Region:      region,
+		Credentials: credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, sessionToken),
+	}, credentialErr)
+}
+
+func withConfig(cfg aws.Config, credentialErr error) option.RequestOption {
 	signer := v4.NewSigner()
 	middleware := bedrockMiddleware(signer, cfg)
 
@@ -210,6 +232,7 @@ func WithConfig(cfg aws.Config) option.RequestOption {
 		if credentialErr != nil {
 			return credentialErr
 		}
+		rc.CustomAuth = true
 		return rc.Apply(
 			option.WithBaseURL(fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", cfg.Region)),
 			option.WithMiddleware(middleware),
@@ -2,15 +2,20 @@ package bedrock
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
 )
 
 func TestBedrockURLEncoding(t *testing.T) {
@@ -252,3 +257,38 @@ func TestBedrockBearerToken(t *testing.T) {
 		t.Fatalf("Middleware failed: %v", err)
 	}
 }
+
+func TestBedrockStaticCredentials(t *testing.T) {
+	t.Setenv("AWS_BEARER_TOKEN_BEDROCK", "ignored-bearer-token")
+	var got *http.Request
+	client := anthropic.NewClient(
+		WithStaticCredentials("eu-west-1", "AKIDEXAMPLE", "secret", "session-token"),
+		option.WithMaxRetries(0),
+		option.WithMiddleware(func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			got = r
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: io.NopCloser(bytes.NewReader([]byte(`{}`)))}, nil
+		}),
+	)
+	_, err := client.Messages.New(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hello"))},
+		Model:     "anthropic.claude-sonnet-4-5-20250929-v1:0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Host != "bedrock-runtime.eu-west-1.amazonaws.com" || got.URL.Path != "/model/anthropic.claude-sonnet-4-5-20250929-v1:0/invoke" {
+		t.Errorf("unexpected URL %s", got.URL)
+	}
+	if auth := got.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+		t.Errorf("expected a SigV4 signature with the static key, got %q", auth)
+	}
+	if got.Header.Get("X-Amz-Security-Token") != "session-token" {
+		t.Errorf("expected the session token to be sent")
+	}
+
+	client = anthropic.NewClient(WithStaticCredentials("eu-west-1", "", "", ""))
+	if _, err := client.Messages.New(context.Background(), anthropic.MessageNewParams{}); err == nil {
+		t.Error("expected an error without credentials")
+	}
+}
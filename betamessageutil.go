@@ -2,7 +2,10 @@ package anthropic
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/sofianhadi1983/anthropic-sdk-go/internal/paramutil"
 	"github.com/sofianhadi1983/anthropic-sdk-go/packages/param"
@@ -12,10 +15,14 @@ import (
 // any other Message, except with the caveat that the Message.JSON field which normally can be used to inspect
 // the JSON sent over the network may not be populated fully.
 //
-//	message := anthropic.Message{}
+// An [*EventOrderError] is returned for an event received out of order. See [Message.Accumulate].
+//
+//	message := anthropic.BetaMessage{}
 //	for stream.Next() {
 //		event := stream.Current()
-//		message.Accumulate(event)
+//		if err := message.Accumulate(event); err != nil {
+//			return err
+//		}
 //	}
 func (acc *BetaMessage) Accumulate(event BetaRawMessageStreamEventUnion) error {
 	if acc == nil {
@@ -26,21 +33,30 @@ func (acc *BetaMessage) Accumulate(event BetaRawMessageStreamEventUnion) error {
 	case BetaRawMessageStartEvent:
 		*acc = event.Message
 	case BetaRawMessageDeltaEvent:
+		if acc.JSON.raw == "" {
+			return &EventOrderError{Type: string(event.Type), Index: -1, Reason: "before message_start"}
+		}
 		acc.StopReason = event.Delta.StopReason
 		acc.StopSequence = event.Delta.StopSequence
 		acc.Usage.OutputTokens = event.Usage.OutputTokens
 		acc.ContextManagement = event.ContextManagement
 	case BetaRawContentBlockStartEvent:
+		if acc.JSON.raw == "" {
+			return &EventOrderError{Type: string(event.Type), Index: event.Index, Reason: "before message_start"}
+		}
+		if event.Index != int64(len(acc.Content)) {
+			return &EventOrderError{Type: string(event.Type), Index: event.Index, Reason: fmt.Sprintf("after %d content blocks", len(acc.Content))}
+		}
 		acc.Content = append(acc.Content, BetaContentBlockUnion{})
 		err := acc.Content[len(acc.Content)-1].UnmarshalJSON([]byte(event.ContentBlock.RawJSON()))
 		if err != nil {
 			return err
 		}
 	case BetaRawContentBlockDeltaEvent:
-		if len(acc.Content) == 0 {
-			return fmt.Errorf("received event of type %s but there was no content block", event.Type)
+		if event.Index < 0 || event.Index >= int64(len(acc.Content)) {
+			return &EventOrderError{Type: string(event.Type), Index: event.Index, Reason: "for a content block which was not started"}
 		}
-		cb := &acc.Content[len(acc.Content)-1]
+		cb := &acc.Content[event.Index]
 		switch delta := event.Delta.AsAny().(type) {
 		case BetaTextDelta:
 			cb.Text += delta.Text
@@ -65,18 +81,33 @@ func (acc *BetaMessage) Accumulate(event BetaRawMessageStreamEventUnion) error {
 			cb.Citations = append(cb.Citations, citation)
 		}
 	case BetaRawMessageStopEvent:
-		accJson, err := json.Marshal(acc)
+		if acc.JSON.raw == "" {
+			return &EventOrderError{Type: string(event.Type), Index: -1, Reason: "before message_start"}
+		}
+		// The raw JSON of a tool use cut short by max_tokens holds its input
+		// repaired. See [BetaMessage.TruncatedToolUses].
+		var inputs []*json.RawMessage
+		for i := range acc.Content {
+			if acc.Content[i].Type == "tool_use" {
+				inputs = append(inputs, &acc.Content[i].Input)
+			}
+		}
+		accJson, err := marshalRepaired(acc, inputs...)
 		if err != nil {
 			return fmt.Errorf("error converting content block to JSON: %w", err)
 		}
 		acc.JSON.raw = string(accJson)
 
 	case BetaRawContentBlockStopEvent:
-		if len(acc.Content) == 0 {
-			return fmt.Errorf("received event of type %s but there was no content block", event.Type)
+		if event.Index < 0 || event.Index >= int64(len(acc.Content)) {
+			return &EventOrderError{Type: string(event.Type), Index: event.Index, Reason: "for a content block which was not started"}
+		}
+		contentBlock := &acc.Content[event.Index]
+		var inputs []*json.RawMessage
+		if contentBlock.Type == "tool_use" {
+			inputs = append(inputs, &contentBlock.Input)
 		}
-		contentBlock := &acc.Content[len(acc.Content)-1]
-		cbJson, err := json.Marshal(contentBlock)
+		cbJson, err := marshalRepaired(contentBlock, inputs...)
 		if err != nil {
 			return fmt.Errorf("error converting content block to JSON: %w", err)
 		}
@@ -86,6 +117,240 @@ func (acc *BetaMessage) Accumulate(event BetaRawMessageStreamEventUnion) error {
 	return nil
 }
 
+// Text returns the text of the message: the text blocks concatenated in order,
+// ignoring tool use, thinking and other blocks. See [Message.Text].
+func (r BetaMessage) Text() string {
+	var sb strings.Builder
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	return sb.String()
+}
+
+// HasText reports whether the message has a text block with any text. See
+// [Message.HasText].
+func (r BetaMessage) HasText() bool {
+	return slices.ContainsFunc(r.Content, func(block BetaContentBlockUnion) bool {
+		return block.Type == "text" && block.Text != ""
+	})
+}
+
+// EndedForToolUse reports whether generation stopped because the model called
+// tools. See [Message.EndedForToolUse].
+func (r BetaMessage) EndedForToolUse() bool {
+	return r.StopReason == BetaStopReasonToolUse
+}
+
+// Coalesce returns a copy of the message in which consecutive text blocks with
+// the same citations are merged into a single block. See [Message.Coalesce].
+func (r BetaMessage) Coalesce() BetaMessage {
+	merged := r
+	merged.Content = make([]BetaContentBlockUnion, 0, len(r.Content))
+	for _, block := range r.Content {
+		n := len(merged.Content)
+		if n == 0 || block.Type != "text" || merged.Content[n-1].Type != "text" || !sameBetaCitations(merged.Content[n-1].Citations, block.Citations) {
+			merged.Content = append(merged.Content, block)
+			continue
+		}
+		prev := &merged.Content[n-1]
+		prev.Text += block.Text
+		if cbJson, err := json.Marshal(prev); err == nil {
+			prev.JSON.raw = string(cbJson)
+		}
+	}
+	if len(merged.Content) == len(r.Content) {
+		return r
+	}
+
+	if msgJson, err := json.Marshal(merged); err == nil {
+		merged.JSON.raw = string(msgJson)
+	}
+	return merged
+}
+
+// StripEchoes returns a copy of the message in which fragments of the system
+// prompt repeated verbatim in the text blocks are removed. See
+// [Message.StripEchoes].
+func (r BetaMessage) StripEchoes(system string) BetaMessage {
+	stripped := r
+	stripped.Content = make([]BetaContentBlockUnion, 0, len(r.Content))
+	changed := false
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			if text, ok := stripEchoes(block.Text, system); ok {
+				changed = true
+				if text == "" {
+					continue
+				}
+				block.Text = text
+				if cbJson, err := json.Marshal(block); err == nil {
+					block.JSON.raw = string(cbJson)
+				}
+			}
+		}
+		stripped.Content = append(stripped.Content, block)
+	}
+	if !changed {
+		return r
+	}
+
+	if msgJson, err := json.Marshal(stripped); err == nil {
+		stripped.JSON.raw = string(msgJson)
+	}
+	return stripped
+}
+
+func sameBetaCitations(a, b []BetaTextCitationUnion) bool {
+	return slices.EqualFunc(a, b, func(x, y BetaTextCitationUnion) bool {
+		return x.RawJSON() == y.RawJSON()
+	})
+}
+
+// InputValid returns the input of the tool use if it is valid JSON. An error
+// wrapping [ErrIncompleteToolInput] is returned if the input was truncated. See
+// [ToolUseBlock.InputValid].
+func (r BetaToolUseBlock) InputValid() (json.RawMessage, error) {
+	switch input := r.Input.(type) {
+	case json.RawMessage:
+		return validToolInput(input)
+	case []byte:
+		return validToolInput(input)
+	case string:
+		return validToolInput(json.RawMessage(input))
+	}
+	// Any other input was decoded from complete JSON or built by the caller.
+	input, err := json.Marshal(r.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tool input: %w", err)
+	}
+	return input, nil
+}
+
+// ToolUses returns the tool_use blocks of the message, with their inputs as
+// accumulated from a stream. Blocks whose input is not valid JSON are left out
+// and reported in the returned error. See [Message.ToolUses].
+func (r BetaMessage) ToolUses() ([]BetaToolUseBlock, error) {
+	var toolUses []BetaToolUseBlock
+	var errs []error
+	for _, block := range r.Content {
+		if block.Type != "tool_use" {
+			continue
+		}
+		toolUse := block.AsToolUse()
+		toolUse.Input = block.Input
+		if _, err := toolUse.InputValid(); err != nil {
+			errs = append(errs, fmt.Errorf("tool_use %s: %w", toolUse.ID, err))
+			continue
+		}
+		toolUses = append(toolUses, toolUse)
+	}
+	return toolUses, errors.Join(errs...)
+}
+
+// TruncatedToolUses returns the tool_use blocks whose input was cut short
+// because the message reached max_tokens. See [Message.TruncatedToolUses].
+func (r BetaMessage) TruncatedToolUses() []BetaToolUseBlock {
+	if r.StopReason != BetaStopReasonMaxTokens {
+		return nil
+	}
+	var truncated []BetaToolUseBlock
+	for _, block := range r.Content {
+		if block.Type != "tool_use" {
+			continue
+		}
+		if incompleteToolInput(block.Input) {
+			toolUse := block.AsToolUse()
+			toolUse.Input = block.Input
+			truncated = append(truncated, toolUse)
+		}
+	}
+	return truncated
+}
+
+// Unmarshal decodes the input of the first tool_use block of message calling
+// the tool named toolName into a T, for example a tool created with
+// [BetaToolFromSchema] and forced with tool_choice. An error is returned if
+// message is nil or has no such block, or if its input is incomplete or does
+// not decode into T. See [Extract] for the stable API.
+//
+//	weather, err := anthropic.Unmarshal[Weather](message, "record_weather")
+func Unmarshal[T any](message *BetaMessage, toolName string) (T, error) {
+	var v T
+	if message == nil {
+		return v, fmt.Errorf("no message to decode the input of %s from", toolName)
+	}
+	for _, block := range message.Content {
+		if block.Type != "tool_use" || block.Name != toolName {
+			continue
+		}
+		input, err := validToolInput(block.Input)
+		if err != nil {
+			return v, fmt.Errorf("tool_use %s: %w", block.ID, err)
+		}
+		if err := json.Unmarshal(input, &v); err != nil {
+			return v, fmt.Errorf("tool_use %s: decoding input of %s: %w", block.ID, toolName, err)
+		}
+		return v, nil
+	}
+	return v, fmt.Errorf("message has no tool_use block for %s", toolName)
+}
+
+// ImageBlocksFromServerResult returns an image block param for every file
+// produced by a server tool result, so that images generated by one tool (for
+// example a chart rendered by the code execution tool) can be passed on to a
+// later message or tool_result. Each block references the output by its file
+// id, so no image data is copied.
+//
+// The code execution and bash code execution tool results are supported. Server
+// tools do not report the media type of their outputs; use
+// [BetaFileService.GetMetadata] to check that a file is an image if the tool may
+// also produce other files.
+func ImageBlocksFromServerResult(block BetaContentBlockUnion) ([]BetaImageBlockParam, error) {
+	var fileIDs []string
+	switch variant := block.AsAny().(type) {
+	case BetaCodeExecutionToolResultBlock:
+		if variant.Content.JSON.ErrorCode.Valid() {
+			return nil, fmt.Errorf("server tool result %s is an error: %s", variant.ToolUseID, variant.Content.ErrorCode)
+		}
+		for _, output := range variant.Content.Content {
+			fileIDs = append(fileIDs, output.FileID)
+		}
+	case BetaBashCodeExecutionToolResultBlock:
+		if variant.Content.JSON.ErrorCode.Valid() {
+			return nil, fmt.Errorf("server tool result %s is an error: %s", variant.ToolUseID, variant.Content.ErrorCode)
+		}
+		for _, output := range variant.Content.Content {
+			fileIDs = append(fileIDs, output.FileID)
+		}
+	default:
+		return nil, fmt.Errorf("content block of type %q does not contain server tool outputs", block.Type)
+	}
+
+	images := make([]BetaImageBlockParam, 0, len(fileIDs))
+	for _, id := range fileIDs {
+		images = append(images, BetaImageBlockParam{
+			Source: BetaImageBlockParamSourceUnion{OfFile: &BetaFileImageSourceParam{FileID: id}},
+		})
+	}
+	return images, nil
+}
+
+// ImageBlockFromServerResult is like [ImageBlocksFromServerResult] but returns
+// only the first image, as a content block param ready to be added to a
+// message. It returns an error if the result produced no files.
+func ImageBlockFromServerResult(block BetaContentBlockUnion) (BetaContentBlockParamUnion, error) {
+	images, err := ImageBlocksFromServerResult(block)
+	if err != nil {
+		return BetaContentBlockParamUnion{}, err
+	}
+	if len(images) == 0 {
+		return BetaContentBlockParamUnion{}, fmt.Errorf("server tool result of type %q produced no files", block.Type)
+	}
+	return BetaContentBlockParamUnion{OfImage: &images[0]}, nil
+}
+
 // Param converters
 
 func (r BetaContentBlockUnion) ToParam() BetaContentBlockParamUnion {
@@ -264,6 +529,31 @@ func (r BetaToolUseBlock) ToParam() BetaToolUseBlockParam {
 	return p
 }
 
+// NewBetaTextToolResultBlock returns a tool_result block answering the tool use
+// with the given ID with text content, like [NewToolResultBlock] does for the
+// stable API. [NewBetaToolResultBlock] returns a result without content.
+func NewBetaTextToolResultBlock(toolUseID string, content string, isError bool) BetaContentBlockParamUnion {
+	toolResult := BetaToolResultBlockParam{
+		ToolUseID: toolUseID,
+		Content: []BetaToolResultBlockParamContentUnion{
+			{OfText: &BetaTextBlockParam{Text: content}},
+		},
+		IsError: Bool(isError),
+	}
+	return BetaContentBlockParamUnion{OfToolResult: &toolResult}
+}
+
+// ResultBlock returns a tool_result block answering the tool use with content.
+func (r BetaToolUseBlock) ResultBlock(content string) BetaContentBlockParamUnion {
+	return NewBetaTextToolResultBlock(r.ID, content, false)
+}
+
+// ErrorResultBlock returns a tool_result block reporting that the tool use
+// failed, with content describing the error.
+func (r BetaToolUseBlock) ErrorResultBlock(content string) BetaContentBlockParamUnion {
+	return NewBetaTextToolResultBlock(r.ID, content, true)
+}
+
 func (r BetaWebSearchResultBlock) ToParam() BetaWebSearchResultBlockParam {
 	var p BetaWebSearchResultBlockParam
 	p.Type = r.Type
@@ -0,0 +1,163 @@
+package anthropic_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func unmarshalBetaContentBlock(t *testing.T, jsonData string) anthropic.BetaContentBlockUnion {
+	var block anthropic.BetaContentBlockUnion
+	if err := json.Unmarshal([]byte(jsonData), &block); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	return block
+}
+
+func TestImageBlockFromServerResult(t *testing.T) {
+	t.Run("code execution outputs", func(t *testing.T) {
+		block := unmarshalBetaContentBlock(t, `{"type":"code_execution_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"code_execution_result","return_code":0,"stdout":"","stderr":"","content":[{"type":"code_execution_output","file_id":"file_a"},{"type":"code_execution_output","file_id":"file_b"}]}}`)
+
+		images, err := anthropic.ImageBlocksFromServerResult(block)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(images) != 2 || images[0].Source.OfFile.FileID != "file_a" || images[1].Source.OfFile.FileID != "file_b" {
+			t.Fatalf("unexpected images: %+v", images)
+		}
+
+		first, err := anthropic.ImageBlockFromServerResult(block)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data, _ := json.Marshal(first)
+		if string(data) != `{"source":{"file_id":"file_a","type":"file"},"type":"image"}` {
+			t.Errorf("unexpected serialized block: %s", data)
+		}
+	})
+
+	t.Run("bash code execution error", func(t *testing.T) {
+		block := unmarshalBetaContentBlock(t, `{"type":"bash_code_execution_tool_result","tool_use_id":"srvtoolu_2","content":{"type":"bash_code_execution_tool_result_error","error_code":"unavailable"}}`)
+		if _, err := anthropic.ImageBlocksFromServerResult(block); err == nil {
+			t.Fatal("expected an error for an errored tool result")
+		}
+	})
+
+	t.Run("unsupported block", func(t *testing.T) {
+		block := unmarshalBetaContentBlock(t, `{"type":"text","text":"hi"}`)
+		if _, err := anthropic.ImageBlockFromServerResult(block); err == nil {
+			t.Fatal("expected an error for a text block")
+		}
+	})
+}
+
+func TestBetaMessageText(t *testing.T) {
+	var message anthropic.BetaMessage
+	err := message.UnmarshalJSON([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[
+		{"type":"text","text":"Hello"},
+		{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}},
+		{"type":"text","text":", world"}
+	],"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text := message.Text(); text != "Hello, world" {
+		t.Errorf("unexpected text %q", text)
+	}
+	if text := (anthropic.BetaMessage{}).Text(); text != "" {
+		t.Errorf("expected no text for a message without content, got %q", text)
+	}
+	if !message.EndedForToolUse() || (anthropic.BetaMessage{StopReason: anthropic.BetaStopReasonEndTurn}).EndedForToolUse() {
+		t.Errorf("expected only the message stopped for tool use to report it")
+	}
+}
+
+func TestBetaToolUseBlockResultBlock(t *testing.T) {
+	var block anthropic.BetaToolUseBlock
+	if err := block.UnmarshalJSON([]byte(`{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tc := range []struct {
+		result   anthropic.BetaContentBlockParamUnion
+		expected string
+	}{
+		{block.ResultBlock("Sunny"), `{"tool_use_id":"toolu_01","is_error":false,"content":[{"text":"Sunny","type":"text"}],"type":"tool_result"}`},
+		{block.ErrorResultBlock("unknown city"), `{"tool_use_id":"toolu_01","is_error":true,"content":[{"text":"unknown city","type":"text"}],"type":"tool_result"}`},
+		{anthropic.NewBetaTextToolResultBlock("toolu_02", "Rainy", false), `{"tool_use_id":"toolu_02","is_error":false,"content":[{"text":"Rainy","type":"text"}],"type":"tool_result"}`},
+	} {
+		b, err := json.Marshal(tc.result)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(b) != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, b)
+		}
+	}
+}
+
+func TestBetaMessageToolUsesIncompleteInput(t *testing.T) {
+	var message anthropic.BetaMessage
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":10,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"city\":\"Paris\"}"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_02","name":"delete_file","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":\"/tm"}}`,
+	}
+	for _, data := range events {
+		var event anthropic.BetaRawMessageStreamEventUnion
+		if err := event.UnmarshalJSON([]byte(data)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := message.Accumulate(event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	toolUses, err := message.ToolUses()
+	if !errors.Is(err, anthropic.ErrIncompleteToolInput) || !strings.Contains(err.Error(), "toolu_02") {
+		t.Errorf("expected an incomplete input error for toolu_02, got %v", err)
+	}
+	if len(toolUses) != 1 || toolUses[0].ID != "toolu_01" {
+		t.Fatalf("expected only the complete tool use, got %+v", toolUses)
+	}
+	if input, err := toolUses[0].InputValid(); err != nil || string(input) != `{"city":"Paris"}` {
+		t.Errorf("expected the accumulated input, got %s, %v", input, err)
+	}
+
+	if _, err := (anthropic.BetaToolUseBlock{Input: json.RawMessage(`{"a":`)}).InputValid(); !errors.Is(err, anthropic.ErrIncompleteToolInput) {
+		t.Errorf("expected an incomplete input error, got %v", err)
+	}
+	if input, err := (anthropic.BetaToolUseBlock{Input: map[string]any{"a": 1}}).InputValid(); err != nil || string(input) != `{"a":1}` {
+		t.Errorf("expected a decoded input to be valid, got %s, %v", input, err)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var message anthropic.BetaMessage
+	err := message.UnmarshalJSON([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[
+		{"type":"tool_use","id":"toolu_01","name":"record_weather","input":{"city":"Paris","temp_c":21.5}}
+	],"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type weather struct {
+		City  string  `json:"city"`
+		TempC float64 `json:"temp_c"`
+	}
+	got, err := anthropic.Unmarshal[weather](&message, "record_weather")
+	if err != nil || got.City != "Paris" || got.TempC != 21.5 {
+		t.Errorf("unexpected result %+v: %v", got, err)
+	}
+	if _, err := anthropic.Unmarshal[weather](&message, "get_time"); err == nil {
+		t.Errorf("expected an error when the tool was not called")
+	}
+	if _, err := anthropic.Unmarshal[weather](nil, "record_weather"); err == nil {
+		t.Errorf("expected an error for a nil message")
+	}
+}
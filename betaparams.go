@@ -0,0 +1,196 @@
+package anthropic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// ErrBetaOnlyParams is returned by [FromBeta] when the params use a feature
+// which the stable API does not have.
+var ErrBetaOnlyParams = errors.New("params use a beta-only feature")
+
+// ToBeta returns params for [BetaMessageService.New] equivalent to params, so
+// that the construction of params can be shared by code targeting both APIs.
+// Every field of the stable params has a beta equivalent. The conversion goes
+// through the JSON encoding of the params, so the result is identical on the
+// wire.
+//
+// ToBeta panics if params cannot be encoded, which only happens for invalid
+// params, such as a union with several variants set.
+func ToBeta(params MessageNewParams) BetaMessageNewParams {
+	var beta BetaMessageNewParams
+	if err := convertParams(params, &beta); err != nil {
+		panic(fmt.Sprintf("anthropic: converting params to beta: %v", err))
+	}
+	return beta
+}
+
+// FromBeta returns params for [MessageService.New] equivalent to params, to
+// fall back on the stable API when no beta feature is needed. An error wrapping
+// [ErrBetaOnlyParams] is returned if params use betas, a field at any depth, a
+// tool type or a content block type which the stable API does not have.
+func FromBeta(params BetaMessageNewParams) (MessageNewParams, error) {
+	var stable MessageNewParams
+	if len(params.Betas) > 0 {
+		return stable, fmt.Errorf("%w: betas %v", ErrBetaOnlyParams, params.Betas)
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return stable, fmt.Errorf("converting params from beta: %w", err)
+	}
+	if err := checkStableParams(gjson.ParseBytes(body)); err != nil {
+		return stable, err
+	}
+	if err := stable.UnmarshalJSON(body); err != nil {
+		return stable, fmt.Errorf("converting params from beta: %w", err)
+	}
+	// Fields nested in the objects the stable API has are dropped silently by
+	// the conversion, so the stable params must encode as the beta ones.
+	converted, err := json.Marshal(stable)
+	if err != nil {
+		return stable, fmt.Errorf("converting params from beta: %w", err)
+	}
+	if path, ok := jsonDiff(body, converted); !ok {
+		return stable, fmt.Errorf("%w: field %s", ErrBetaOnlyParams, path)
+	}
+	return stable, nil
+}
+
+// jsonDiff reports whether the JSON values a and b are equal, and the path of
+// the first difference if not. Numbers are compared as written.
+func jsonDiff(a, b []byte) (path string, equal bool) {
+	va, vb := decodeJSONNumbers(a), decodeJSONNumbers(b)
+	path, equal = jsonValueDiff(va, vb, "")
+	return strings.TrimPrefix(path, "."), equal
+}
+
+func decodeJSONNumbers(b []byte) any {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if dec.Decode(&v) != nil {
+		return string(b)
+	}
+	return v
+}
+
+func jsonValueDiff(a, b any, path string) (string, bool) {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok {
+			return path, false
+		}
+		keys := slices.Sorted(maps.Keys(a))
+		for key := range b {
+			if _, ok := a[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			if path, ok := jsonValueDiff(a[key], b[key], path+"."+key); !ok {
+				return path, false
+			}
+		}
+		return "", true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return path, false
+		}
+		for i := range a {
+			if path, ok := jsonValueDiff(a[i], b[i], fmt.Sprintf("%s.%d", path, i)); !ok {
+				return path, false
+			}
+		}
+		return "", true
+	default:
+		return path, a == b
+	}
+}
+
+func convertParams(from any, to json.Unmarshaler) error {
+	body, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return to.UnmarshalJSON(body)
+}
+
+// stableTypes holds the fields of the stable params and the types of the
+// tools and content blocks the stable API has, from the variants of the param
+// unions.
+var stableTypes = sync.OnceValue(func() (types struct {
+	fields, tools, blocks, toolResultBlocks map[string]bool
+}) {
+	types.fields = map[string]bool{}
+	for _, field := range reflect.VisibleFields(reflect.TypeFor[MessageNewParams]()) {
+		if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+			types.fields[name] = true
+		}
+	}
+	types.tools = unionVariantTypes(reflect.TypeFor[ToolUnionParam]())
+	// Custom tools may omit their type.
+	types.tools[""], types.tools["custom"] = true, true
+	types.blocks = unionVariantTypes(reflect.TypeFor[ContentBlockParamUnion]())
+	types.toolResultBlocks = unionVariantTypes(reflect.TypeFor[ToolResultBlockParamContentUnion]())
+	return types
+})
+
+// unionVariantTypes returns the type of each variant of a param union, as
+// encoded in the JSON of the zero value of the variant.
+func unionVariantTypes(union reflect.Type) map[string]bool {
+	types := map[string]bool{}
+	for _, field := range reflect.VisibleFields(union) {
+		if !strings.HasPrefix(field.Name, "Of") || field.Type.Kind() != reflect.Pointer || field.Type.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		if b, err := json.Marshal(reflect.New(field.Type.Elem()).Interface()); err == nil {
+			types[gjson.GetBytes(b, "type").String()] = true
+		}
+	}
+	return types
+}
+
+// checkStableParams checks that the encoded params only use fields, tools and
+// content blocks which the stable API has.
+func checkStableParams(params gjson.Result) error {
+	types := stableTypes()
+	var err error
+	params.ForEach(func(key, _ gjson.Result) bool {
+		if !types.fields[key.String()] {
+			err = fmt.Errorf("%w: field %s", ErrBetaOnlyParams, key.String())
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, tool := range params.Get("tools").Array() {
+		if typ := tool.Get("type").String(); !types.tools[typ] {
+			return fmt.Errorf("%w: tool type %s", ErrBetaOnlyParams, typ)
+		}
+	}
+	for i, message := range params.Get("messages").Array() {
+		for _, block := range message.Get("content").Array() {
+			if typ := block.Get("type").String(); !types.blocks[typ] {
+				return fmt.Errorf("%w: content block type %s in messages[%d]", ErrBetaOnlyParams, typ, i)
+			}
+			for _, nested := range block.Get("content").Array() {
+				if typ := nested.Get("type").String(); block.Get("type").String() == "tool_result" && !types.toolResultBlocks[typ] {
+					return fmt.Errorf("%w: tool result content type %s in messages[%d]", ErrBetaOnlyParams, typ, i)
+				}
+			}
+		}
+	}
+	return nil
+}
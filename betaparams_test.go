@@ -0,0 +1,95 @@
+package anthropic_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestToBetaFromBeta(t *testing.T) {
+	params := anthropic.MessageNewParams{
+		MaxTokens:     1024,
+		Model:         anthropic.ModelClaudeSonnet4_5_20250929,
+		System:        []anthropic.TextBlockParam{{Text: "Be brief."}},
+		Temperature:   anthropic.Float(0.5),
+		StopSequences: []string{"END"},
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock("What's the weather?")),
+			anthropic.NewAssistantMessage(anthropic.NewToolUseBlock("toolu_1", map[string]any{"city": "Paris"}, "get_weather")),
+			anthropic.NewUserMessage(anthropic.NewToolResultBlock("toolu_1", "Sunny", false)),
+		},
+		Tools: []anthropic.ToolUnionParam{
+			{OfTool: &anthropic.ToolParam{Name: "get_weather", InputSchema: anthropic.ToolInputSchemaParam{Properties: map[string]any{"city": map[string]any{"type": "string"}}}}},
+			{OfWebSearchTool20250305: &anthropic.WebSearchTool20250305Param{}},
+		},
+	}
+	want, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	beta := anthropic.ToBeta(params)
+	got, err := json.Marshal(beta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if normalizeJSON(t, got) != normalizeJSON(t, want) {
+		t.Errorf("ToBeta encodes as %s, want %s", got, want)
+	}
+
+	stable, err := anthropic.FromBeta(beta)
+	if err != nil {
+		t.Fatalf("FromBeta: %v", err)
+	}
+	if got, _ = json.Marshal(stable); normalizeJSON(t, got) != normalizeJSON(t, want) {
+		t.Errorf("FromBeta encodes as %s, want %s", got, want)
+	}
+}
+
+func TestFromBetaOnlyFeatures(t *testing.T) {
+	base := func() anthropic.BetaMessageNewParams {
+		return anthropic.BetaMessageNewParams{
+			MaxTokens: 1024,
+			Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+			Messages:  []anthropic.BetaMessageParam{anthropic.NewBetaUserMessage(anthropic.NewBetaTextBlock("Hi"))},
+		}
+	}
+	tests := map[string]func(*anthropic.BetaMessageNewParams){
+		"betas": func(p *anthropic.BetaMessageNewParams) {
+			p.Betas = []anthropic.AnthropicBeta{anthropic.AnthropicBetaComputerUse2025_01_24}
+		},
+		"field": func(p *anthropic.BetaMessageNewParams) {
+			p.MCPServers = []anthropic.BetaRequestMCPServerURLDefinitionParam{{Name: "docs", URL: "https://example.com/mcp"}}
+		},
+		"tool": func(p *anthropic.BetaMessageNewParams) {
+			p.Tools = []anthropic.BetaToolUnionParam{{OfCodeExecutionTool20250825: &anthropic.BetaCodeExecutionTool20250825Param{}}}
+		},
+		"content block": func(p *anthropic.BetaMessageNewParams) {
+			p.Messages = append(p.Messages, anthropic.BetaMessageParam{
+				Role:    anthropic.BetaMessageParamRoleUser,
+				Content: []anthropic.BetaContentBlockParamUnion{{OfContainerUpload: &anthropic.BetaContainerUploadBlockParam{FileID: "file_1"}}},
+			})
+		},
+		"nested field": func(p *anthropic.BetaMessageNewParams) {
+			p.Tools = []anthropic.BetaToolUnionParam{{OfTool: &anthropic.BetaToolParam{Name: "get_weather", DeferLoading: anthropic.Bool(true)}}}
+		},
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			params := base()
+			modify(&params)
+			if _, err := anthropic.FromBeta(params); !errors.Is(err, anthropic.ErrBetaOnlyParams) {
+				t.Errorf("FromBeta error = %v, want ErrBetaOnlyParams", err)
+			} else if name == "nested field" && !strings.Contains(err.Error(), "tools.0.defer_loading") {
+				t.Errorf("FromBeta error = %v, want the path of the field", err)
+			}
+		})
+	}
+
+	if _, err := anthropic.FromBeta(base()); err != nil {
+		t.Errorf("FromBeta without beta features: %v", err)
+	}
+}
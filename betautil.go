@@ -0,0 +1,36 @@
+package anthropic
+
+import (
+	"context"
+	"slices"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/betacompat"
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+// Raw makes a request to an arbitrary beta endpoint, such as one released
+// before this SDK has typed methods for it. The request goes through the same
+// plumbing as the typed methods: the base URL, authentication, retries, beta
+// headers and other options of the client apply, and errors are returned as
+// [*Error]. Betas are enabled with the anthropic-beta header:
+//
+//	var out map[string]any
+//	err := client.Beta.Raw(ctx, http.MethodPost, "v1/new_feature", body, &out,
+//		option.WithHeaderAdd("anthropic-beta", "new-feature-2025-01-01"),
+//	)
+//
+// The body and out arguments are handled as in [Client.Execute].
+func (r *BetaService) Raw(ctx context.Context, method string, path string, body any, out any, opts ...option.RequestOption) error {
+	opts = slices.Concat(r.Options, opts)
+	return requestconfig.ExecuteNewRequest(ctx, method, path, body, out, opts...)
+}
+
+// ValidateBetas checks the beta features in betas against a table of known
+// incompatibilities, such as two versions of the same tool, and prerequisites,
+// such as skills needing code execution, to catch before sending a request what
+// the API would reject with a 400 error. All problems found are returned,
+// joined with [errors.Join]. Betas missing from the table are accepted.
+func ValidateBetas(betas []string) error {
+	return betacompat.Validate(betas)
+}
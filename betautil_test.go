@@ -0,0 +1,70 @@
+package anthropic_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+func TestBetaRaw(t *testing.T) {
+	var path, beta, apiKey string
+	status := http.StatusOK
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					path, beta, apiKey = req.URL.Path, req.Header.Get("anthropic-beta"), req.Header.Get("X-Api-Key")
+					return &http.Response{
+						StatusCode: status,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(`{"id":"feat_1"}`)),
+					}, nil
+				},
+			},
+		}),
+	)
+
+	var out struct {
+		ID string `json:"id"`
+	}
+	err := client.Beta.Raw(context.Background(), http.MethodPost, "v1/new_feature", map[string]any{"x": 1}, &out,
+		option.WithHeaderAdd("anthropic-beta", "new-feature-2025-01-01"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.ID != "feat_1" || path != "/v1/new_feature" || beta != "new-feature-2025-01-01" || apiKey != "my-anthropic-api-key" {
+		t.Errorf("unexpected request or response: %q %q %q %q", out.ID, path, beta, apiKey)
+	}
+
+	status = http.StatusNotFound
+	err = client.Beta.Raw(context.Background(), http.MethodGet, "v1/new_feature", nil, &out)
+	var apiErr *anthropic.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected an API error, got %v", err)
+	}
+}
+
+func TestValidateBetas(t *testing.T) {
+	if err := anthropic.ValidateBetas([]string{anthropic.AnthropicBetaFilesAPI2025_04_14, "some-future-beta"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := anthropic.ValidateBetas([]string{
+		anthropic.AnthropicBetaMCPClient2025_04_04,
+		anthropic.AnthropicBetaMCPClient2025_11_20,
+		anthropic.AnthropicBetaSkills2025_10_02,
+	})
+	expected := "beta mcp-client-2025-04-04 cannot be combined with mcp-client-2025-11-20\n" +
+		"beta skills-2025-10-02 requires code-execution-2025-08-25"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected the conflict and the missing prerequisite, got %v", err)
+	}
+}
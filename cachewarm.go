@@ -0,0 +1,49 @@
+package anthropic
+
+import (
+	"context"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+// WarmCache sends params with max_tokens set to 1 to write its prefix to the
+// prompt cache, so that the first real request, such as the first turn of an
+// agent with a long system prompt and many tools, reads it instead of waiting
+// for it to be processed. It returns the number of input tokens written to the
+// cache, which is 0 if the prefix was already cached.
+//
+//	system := []anthropic.TextBlockParam{
+//		anthropic.TextBlockParam{Text: instructions}.WithCacheControl(),
+//	}
+//	written, err := client.Messages.WarmCache(ctx, anthropic.MessageNewParams{
+//		Model:    model,
+//		System:   system,
+//		Tools:    tools,
+//		Messages: []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("."))},
+//	})
+//
+// Only the prompt up to a block marked with cache control is cached, so params
+// must have at least one cache breakpoint. Extended thinking is disabled for
+// the request, as its budget must be less than max_tokens; this does not
+// invalidate the cached system prompt and tools. The request is billed like
+// any other.
+func (r *MessageService) WarmCache(ctx context.Context, params MessageNewParams, opts ...option.RequestOption) (int64, error) {
+	params.MaxTokens = 1
+	params.Thinking = ThinkingConfigParamUnion{}
+	message, err := r.New(ctx, params, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return message.Usage.CacheCreationInputTokens, nil
+}
+
+// WarmCache is the beta API counterpart of [MessageService.WarmCache].
+func (r *BetaMessageService) WarmCache(ctx context.Context, params BetaMessageNewParams, opts ...option.RequestOption) (int64, error) {
+	params.MaxTokens = 1
+	params.Thinking = BetaThinkingConfigParamUnion{}
+	message, err := r.New(ctx, params, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return message.Usage.CacheCreationInputTokens, nil
+}
@@ -0,0 +1,57 @@
+package anthropic_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+)
+
+func TestMessageWarmCache(t *testing.T) {
+	var body []byte
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					body, _ = io.ReadAll(req.Body)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body: io.NopCloser(strings.NewReader(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"O"}],
+							"stop_reason":"max_tokens","usage":{"input_tokens":4,"output_tokens":1,"cache_creation_input_tokens":2048}}`)),
+					}, nil
+				},
+			},
+		}),
+	)
+
+	written, err := client.Messages.WarmCache(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 4096,
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		System:    []anthropic.TextBlockParam{anthropic.TextBlockParam{Text: "instructions"}.WithCacheControl()},
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("."))},
+		Thinking:  anthropic.ThinkingConfigParamOfEnabled(2048),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if written != 2048 {
+		t.Errorf("got %d tokens written, want 2048", written)
+	}
+	if max := gjson.GetBytes(body, "max_tokens").Int(); max != 1 {
+		t.Errorf("got max_tokens %d, want 1", max)
+	}
+	if gjson.GetBytes(body, "thinking").Exists() {
+		t.Errorf("thinking was not disabled: %s", body)
+	}
+	if gjson.GetBytes(body, "system.0.cache_control.type").String() != "ephemeral" {
+		t.Errorf("cache control was not sent: %s", body)
+	}
+}
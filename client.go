@@ -4,10 +4,12 @@ package anthropic
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
@@ -24,6 +26,8 @@ type Client struct {
 	Messages    MessageService
 	Models      ModelService
 	Beta        BetaService
+
+	inFlight *requestconfig.InFlight
 }
 
 // DefaultClientOptions read from the environment (ANTHROPIC_API_KEY,
@@ -48,9 +52,14 @@ func DefaultClientOptions() []option.RequestOption {
 // option passed in as arguments are applied after these default arguments, and all
 // option will be passed down to the services and requests that this client makes.
 func NewClient(opts ...option.RequestOption) (r Client) {
+	inFlight := &requestconfig.InFlight{}
 	opts = append(DefaultClientOptions(), opts...)
+	opts = append(opts, requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		r.InFlight = inFlight
+		return nil
+	}))
 
-	r = Client{Options: opts}
+	r = Client{Options: opts, inFlight: inFlight}
 
 	r.Completions = NewCompletionService(opts...)
 	r.Messages = NewMessageService(opts...)
@@ -60,6 +69,35 @@ func NewClient(opts ...option.RequestOption) (r Client) {
 	return
 }
 
+// ErrMissingCredentials is returned by [Client.Validate] when the client has
+// neither an API key nor an auth token configured.
+var ErrMissingCredentials = errors.New("anthropic: no API key or auth token configured")
+
+// Validate checks the client configuration without making a network request. It
+// returns an error wrapping [ErrMissingCredentials] if neither an API key nor an
+// auth token was provided, so misconfiguration can be caught at startup rather
+// than surfacing as a 401 on the first request.
+//
+// Clients configured through the bedrock or vertex packages authenticate in
+// middleware and are always considered to have credentials.
+func (r *Client) Validate() error {
+	cfg, err := requestconfig.NewRequestConfig(context.Background(), http.MethodPost, "", nil, nil, r.Options...)
+	if err != nil {
+		return err
+	}
+	if cfg.APIKey != "" || cfg.AuthToken != "" || cfg.CustomAuth {
+		return nil
+	}
+	// Credentials may also have been supplied as raw headers.
+	if cfg.Request.Header.Get("X-Api-Key") != "" {
+		return nil
+	}
+	if token := strings.TrimPrefix(cfg.Request.Header.Get("Authorization"), "Bearer "); strings.TrimSpace(token) != "" {
+		return nil
+	}
+	return fmt.Errorf("%w: set the ANTHROPIC_API_KEY or ANTHROPIC_AUTH_TOKEN environment variable (or ANTHROPIC_OAUTH_ACCESS_TOKEN with oauth.WithLoadEnv), or pass option.WithAPIKey or option.WithAuthToken", ErrMissingCredentials)
+}
+
 // Execute makes a request with the given context, method, URL, request params,
 // response, and request options. This is useful for hitting undocumented endpoints
 // while retaining the base URL, auth, retries, and other options from the client.
@@ -129,6 +167,24 @@ func (r *Client) Delete(ctx context.Context, path string, params any, res any, o
 	return r.Execute(ctx, http.MethodDelete, path, params, res, opts...)
 }
 
+// CancelAll cancels the context of every request made through the client which
+// is still in flight, including streams which have not been closed, and returns
+// how many were cancelled. Cancelled requests fail with an error wrapping
+// [context.Canceled]. It is useful for a graceful shutdown, to stop every
+// request promptly and release their connections:
+//
+//	server.RegisterOnShutdown(func() { client.CancelAll() })
+//
+// CancelAll is safe to call concurrently with requests, and the client remains
+// usable afterwards: requests started after it returns are not cancelled.
+// Copies of the client share their in-flight requests.
+func (r *Client) CancelAll() int {
+	if r.inFlight == nil {
+		return 0
+	}
+	return r.inFlight.CancelAll()
+}
+
 // CalculateNonStreamingTimeout calculates the appropriate timeout for a non-streaming request
 // based on the maximum number of tokens and the model's non-streaming token limit
 func CalculateNonStreamingTimeout(maxTokens int, model Model, opts []option.RequestOption) (time.Duration, error) {
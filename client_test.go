@@ -4,16 +4,26 @@ package anthropic_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/sofianhadi1983/anthropic-sdk-go"
 	"github.com/sofianhadi1983/anthropic-sdk-go/internal"
+	"github.com/sofianhadi1983/anthropic-sdk-go/oauth"
 	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
 )
 
 type closureTransport struct {
@@ -454,3 +464,829 @@ type readerFunc func([]byte) (int, error)
 
 func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
 func (f readerFunc) Close() error               { return nil }
+
+func TestValidateCredentials(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("ANTHROPIC_AUTH_TOKEN", "")
+
+	client := anthropic.NewClient()
+	if err := client.Validate(); !errors.Is(err, anthropic.ErrMissingCredentials) {
+		t.Fatalf("Expected ErrMissingCredentials, got: %v", err)
+	}
+
+	client = anthropic.NewClient(option.WithAuthToken(""))
+	if err := client.Validate(); !errors.Is(err, anthropic.ErrMissingCredentials) {
+		t.Fatalf("Expected ErrMissingCredentials for an empty auth token, got: %v", err)
+	}
+
+	for name, opt := range map[string]option.RequestOption{
+		"api key":    option.WithAPIKey("my-anthropic-api-key"),
+		"auth token": option.WithAuthToken("my-auth-token"),
+		"header":     option.WithHeader("X-Api-Key", "my-anthropic-api-key"),
+	} {
+		client := anthropic.NewClient(opt)
+		if err := client.Validate(); err != nil {
+			t.Errorf("Expected %s to satisfy Validate, got: %v", name, err)
+		}
+	}
+}
+
+func TestWithoutBeta(t *testing.T) {
+	var betas []string
+	client := anthropic.NewClient(
+		oauth.WithAccessToken("my-oauth-token"),
+		option.WithHeaderAdd("anthropic-beta", "custom-beta"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					betas = strings.Split(req.Header.Get("anthropic-beta"), ",")
+					return &http.Response{
+						StatusCode: http.StatusOK,
+					}, nil
+				},
+			},
+		}),
+	)
+	client.Messages.New(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages: []anthropic.MessageParam{{
+			Content: []anthropic.ContentBlockParamUnion{{
+				OfText: &anthropic.TextBlockParam{
+					Text: "x",
+				},
+			}},
+			Role: anthropic.MessageParamRoleUser,
+		}},
+		Model: anthropic.ModelClaudeSonnet4_5_20250929,
+	},
+		option.WithoutBeta("interleaved-thinking-2025-05-14"),
+		option.WithoutBeta("custom-beta"),
+	)
+	if slices.Contains(betas, "interleaved-thinking-2025-05-14") || slices.Contains(betas, "custom-beta") {
+		t.Errorf("Expected betas to be removed, but got: %v", betas)
+	}
+	if !slices.Contains(betas, "oauth-2025-04-20") {
+		t.Errorf("Expected remaining oauth betas to be kept, but got: %v", betas)
+	}
+}
+
+func TestCapturedRequest(t *testing.T) {
+	var sentBody []byte
+	newClient := func() anthropic.Client {
+		return anthropic.NewClient(
+			option.WithAPIKey("my-anthropic-api-key"),
+			option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+				req.Header.Set("X-Added-By-Middleware", "true")
+				return next(req)
+			}),
+			option.WithHTTPClient(&http.Client{
+				Transport: &closureTransport{
+					fn: func(req *http.Request) (*http.Response, error) {
+						sentBody, _ = io.ReadAll(req.Body)
+						return &http.Response{
+							StatusCode: http.StatusOK,
+						}, nil
+					},
+				},
+			}),
+		)
+	}
+	params := anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages: []anthropic.MessageParam{{
+			Content: []anthropic.ContentBlockParamUnion{{
+				OfText: &anthropic.TextBlockParam{
+					Text: "x",
+				},
+			}},
+			Role: anthropic.MessageParamRoleUser,
+		}},
+		Model: anthropic.ModelClaudeSonnet4_5_20250929,
+	}
+
+	client := newClient()
+	var captured *http.Request
+	client.Messages.New(context.Background(), params, option.WithCapturedRequest(&captured))
+	if captured == nil {
+		t.Fatal("Expected request to be captured")
+	}
+	if captured.Header.Get("X-Added-By-Middleware") != "true" {
+		t.Errorf("Expected captured request to include middleware changes")
+	}
+	if got := captured.Header.Get("X-Api-Key"); got != "[REDACTED]" {
+		t.Errorf("Expected api key to be redacted, got: %s", got)
+	}
+	body, _ := io.ReadAll(captured.Body)
+	if len(body) == 0 || string(body) != string(sentBody) {
+		t.Errorf("Expected captured body %s to match sent body %s", body, sentBody)
+	}
+
+	client.Messages.New(context.Background(), params, option.WithCapturedRequestUnredacted(&captured))
+	if got := captured.Header.Get("X-Api-Key"); got != "my-anthropic-api-key" {
+		t.Errorf("Expected api key to be kept, got: %s", got)
+	}
+}
+
+func TestStreamIdleTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		for range 3 {
+			if _, err := io.WriteString(pw, "event: ping\ndata: {\"type\": \"ping\"}\n\n"); err != nil {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		// Stop sending without closing the connection.
+	}()
+
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithStreamIdleTimeout(100*time.Millisecond),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"text/event-stream"}},
+						Body:       pr,
+					}, nil
+				},
+			},
+		}),
+	)
+	stream := client.Messages.NewStreaming(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	})
+	defer stream.Close()
+
+	for stream.Next() {
+		t.Errorf("expected ping events to be consumed, got %+v", stream.Current())
+	}
+	if !errors.Is(stream.Err(), option.ErrStreamIdleTimeout) {
+		t.Errorf("expected an idle timeout error, got %v", stream.Err())
+	}
+}
+
+func TestRequestBodySize(t *testing.T) {
+	requests := 0
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					requests++
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(`{}`)),
+					}, nil
+				},
+			},
+		}),
+	)
+	params := anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock(strings.Repeat("x", 2000)))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}
+
+	var warned int64
+	_, err := client.Messages.New(context.Background(), params, option.WithRequestBodyWarnThreshold(1000, func(size int64) {
+		warned = size
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if warned <= 2000 {
+		t.Errorf("expected a warning with the body size, got %d", warned)
+	}
+
+	_, err = client.Messages.New(context.Background(), params, option.WithRequestBodyLimit(1000))
+	if !errors.Is(err, option.ErrRequestBodyTooLarge) {
+		t.Errorf("expected ErrRequestBodyTooLarge, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected the oversized request not to be sent, got %d requests", requests)
+	}
+
+	// The size of a streamed body is unknown until it is read.
+	client = anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					requests++
+					if _, err := io.ReadAll(req.Body); err != nil {
+						return nil, err
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(`{}`)),
+					}, nil
+				},
+			},
+		}),
+	)
+	body := strings.Repeat("x", 2000)
+	for limit, expectErr := range map[int64]bool{1000: true, 2000: false} {
+		requests = 0
+		err = client.Post(context.Background(), "v1/messages", nil, nil, option.WithRequestBody("application/json", io.MultiReader(strings.NewReader(body))), option.WithRequestBodyLimit(limit))
+		if expectErr != errors.Is(err, option.ErrRequestBodyTooLarge) {
+			t.Errorf("limit %d: expected ErrRequestBodyTooLarge: %v, got %v", limit, expectErr, err)
+		}
+		if requests != 1 {
+			t.Errorf("limit %d: expected the streamed request not to be retried, got %d requests", limit, requests)
+		}
+	}
+}
+
+func TestWithUserAgentMetadata(t *testing.T) {
+	var userAgent string
+	transport := option.WithHTTPClient(&http.Client{
+		Transport: &closureTransport{
+			fn: func(req *http.Request) (*http.Response, error) {
+				userAgent = req.Header.Get("User-Agent")
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"application/json"}},
+					Body:       io.NopCloser(strings.NewReader(`{}`)),
+				}, nil
+			},
+		},
+	})
+	params := anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}
+
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		transport,
+		option.WithUserAgentMetadata(map[string]string{"runtime": "lambda"}),
+	)
+	_, err := client.Messages.New(context.Background(), params, option.WithUserAgentMetadata(map[string]string{"region": "us-east-1"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(userAgent, "Anthropic/Go ") || !strings.HasSuffix(userAgent, " (region=us-east-1; runtime=lambda)") {
+		t.Errorf("unexpected User-Agent %q", userAgent)
+	}
+
+	client = anthropic.NewClient(
+		oauth.WithConfig(oauth.Config{AccessToken: "my-oauth-token", UserAgent: "my-app/1.0.0"}),
+		transport,
+		option.WithUserAgentMetadata(map[string]string{"runtime": "lambda"}),
+	)
+	if _, err := client.Messages.New(context.Background(), params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if userAgent != "my-app/1.0.0 (runtime=lambda)" {
+		t.Errorf("expected the tags to be appended to the oauth User-Agent, got %q", userAgent)
+	}
+}
+
+func TestWithRetryModifier(t *testing.T) {
+	var models []string
+	var attempts []int
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					body, _ := io.ReadAll(req.Body)
+					var params struct {
+						Model string `json:"model"`
+					}
+					json.Unmarshal(body, &params)
+					models = append(models, params.Model)
+					return &http.Response{
+						StatusCode: http.StatusTooManyRequests,
+						Header:     http.Header{http.CanonicalHeaderKey("Retry-After"): []string{"0.01"}},
+						Body:       io.NopCloser(strings.NewReader(`{}`)),
+					}, nil
+				},
+			},
+		}),
+		option.WithRetryModifier(func(req *http.Request, attempt int, lastErr error) {
+			attempts = append(attempts, attempt)
+			if lastErr == nil {
+				t.Error("expected the error of the previous attempt")
+			}
+			// Fall back to a different model on the first retry only; later
+			// retries must be able to re-read the replaced body.
+			if attempt == 1 {
+				req.Body = io.NopCloser(strings.NewReader(`{"model":"fallback"}`))
+			}
+		}),
+	)
+	_, err := client.Messages.New(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	})
+	if err == nil {
+		t.Error("expected an error")
+	}
+
+	if !reflect.DeepEqual(attempts, []int{1, 2}) {
+		t.Errorf("expected the modifier to run before each retry, got %v", attempts)
+	}
+	expected := []string{string(anthropic.ModelClaudeSonnet4_5_20250929), "fallback", "fallback"}
+	if !reflect.DeepEqual(models, expected) {
+		t.Errorf("expected models %v, got %v", expected, models)
+	}
+}
+
+func TestWithRetryPolicy(t *testing.T) {
+	var times []time.Time
+	retryAfter := "30"
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					times = append(times, time.Now())
+					header := http.Header{"Request-Id": {fmt.Sprintf("req_%d", len(times))}}
+					if retryAfter != "" {
+						header.Set("Retry-After", retryAfter)
+					}
+					return &http.Response{
+						StatusCode: 529,
+						Header:     header,
+						Body:       io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)),
+					}, nil
+				},
+			},
+		}),
+		option.WithRetryPolicy(option.RetryPolicy{MaxRetries: 2, InitialBackoff: 20 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}),
+	)
+
+	// The Retry-After delay is capped at MaxBackoff.
+	start := time.Now()
+	_, err := client.Beta.Messages.New(context.Background(), anthropic.BetaMessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.BetaMessageParam{anthropic.NewBetaUserMessage(anthropic.NewBetaTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	})
+	if elapsed := time.Since(start); len(times) != 3 || elapsed > 5*time.Second {
+		t.Fatalf("expected 3 attempts within MaxBackoff of each other, got %d in %s", len(times), elapsed)
+	}
+	var apierr *anthropic.Error
+	if !errors.As(err, &apierr) || apierr.Response.StatusCode != 529 || apierr.Response.Header.Get("Request-Id") != "req_3" {
+		t.Errorf("expected the error for the last response, got %v", err)
+	}
+
+	// Without Retry-After, the delay doubles from InitialBackoff.
+	times, retryAfter = nil, ""
+	client.Messages.New(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	})
+	if len(times) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(times))
+	}
+	if first, second := times[1].Sub(times[0]), times[2].Sub(times[1]); first < 20*time.Millisecond || second < 40*time.Millisecond {
+		t.Errorf("expected delays of at least 20ms and 40ms, got %s and %s", first, second)
+	}
+}
+
+func TestBillingError(t *testing.T) {
+	cases := []struct {
+		status    int
+		body      string
+		isBilling bool
+	}{
+		{http.StatusBadRequest, `{"type":"error","error":{"type":"invalid_request_error","message":"Your credit balance is too low to access the Anthropic API."}}`, true},
+		{http.StatusForbidden, `{"type":"error","error":{"type":"billing_error","message":"Spend limit reached."}}`, true},
+		{http.StatusPaymentRequired, `{"type":"error","error":{"type":"error","message":"Payment required."}}`, true},
+		{http.StatusUnauthorized, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`, false},
+	}
+	for _, c := range cases {
+		client := anthropic.NewClient(
+			option.WithAPIKey("my-anthropic-api-key"),
+			option.WithMaxRetries(0),
+			option.WithHTTPClient(&http.Client{
+				Transport: &closureTransport{
+					fn: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{
+							StatusCode: c.status,
+							Header:     http.Header{"Content-Type": {"application/json"}},
+							Body:       io.NopCloser(strings.NewReader(c.body)),
+						}, nil
+					},
+				},
+			}),
+		)
+		_, err := client.Models.Get(context.Background(), "claude-sonnet-4-5", anthropic.ModelGetParams{})
+
+		var billing *anthropic.BillingError
+		if errors.As(err, &billing) != c.isBilling {
+			t.Errorf("status %d: expected billing error %v, got %v", c.status, c.isBilling, err)
+			continue
+		}
+		var apierr *anthropic.Error
+		if !errors.As(err, &apierr) || apierr.StatusCode != c.status {
+			t.Errorf("status %d: expected an API error, got %v", c.status, err)
+		}
+		if c.isBilling && (billing.Message != gjson.Get(c.body, "error.message").String() || !strings.Contains(billing.Error(), billing.Message)) {
+			t.Errorf("status %d: unexpected billing error %q", c.status, billing.Error())
+		}
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	errorClient := func(status int, errType string, header http.Header) anthropic.Client {
+		return anthropic.NewClient(
+			option.WithAPIKey("my-anthropic-api-key"),
+			option.WithMaxRetries(0),
+			option.WithHTTPClient(&http.Client{
+				Transport: &closureTransport{
+					fn: func(req *http.Request) (*http.Response, error) {
+						header.Set("Content-Type", "application/json")
+						return &http.Response{
+							StatusCode: status,
+							Header:     header,
+							Body:       io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"` + errType + `","message":"details"}}`)),
+						}, nil
+					},
+				},
+			}),
+		)
+	}
+	newMessage := func(client anthropic.Client) error {
+		_, err := client.Messages.New(context.Background(), anthropic.MessageNewParams{
+			MaxTokens: 1024,
+			Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hello"))},
+			Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		})
+		return err
+	}
+
+	err := newMessage(errorClient(http.StatusTooManyRequests, "rate_limit_error", http.Header{"Retry-After": {"7"}}))
+	var rateLimit *anthropic.RateLimitError
+	if !errors.As(err, &rateLimit) || rateLimit.Message != "details" {
+		t.Fatalf("expected a rate limit error, got %v", err)
+	}
+	if rateLimit.Error() != "rate limit error: details" {
+		t.Errorf("unexpected message %q", rateLimit.Error())
+	}
+	var apiErr *anthropic.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter() != 7*time.Second {
+		t.Errorf("expected an API error retrying after 7s, got %v", err)
+	}
+	var overloaded *anthropic.OverloadedError
+	if errors.As(err, &overloaded) {
+		t.Errorf("expected a rate limit error not to be an overloaded error")
+	}
+
+	err = newMessage(errorClient(529, "overloaded_error", http.Header{}))
+	if !errors.As(err, &overloaded) || overloaded.Type != "overloaded_error" {
+		t.Errorf("expected an overloaded error, got %v", err)
+	}
+	var authentication *anthropic.AuthenticationError
+	if err := newMessage(errorClient(http.StatusUnauthorized, "authentication_error", http.Header{})); !errors.As(err, &authentication) {
+		t.Errorf("expected an authentication error, got %v", err)
+	}
+	var invalid *anthropic.InvalidRequestError
+	if err := newMessage(errorClient(http.StatusBadRequest, "invalid_request_error", http.Header{})); !errors.As(err, &invalid) || invalid.Type != "invalid_request_error" {
+		t.Errorf("expected an invalid request error, got %v", err)
+	}
+}
+
+func TestWithTimeouts(t *testing.T) {
+	delay := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-delay:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(delay)
+
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithBaseURL(server.URL),
+		option.WithMaxRetries(0),
+		option.WithTimeouts(time.Second, 50*time.Millisecond),
+	)
+	start := time.Now()
+	_, err := client.Models.Get(context.Background(), "claude-sonnet-4-5", anthropic.ModelGetParams{})
+	if err == nil || !strings.Contains(err.Error(), "timeout awaiting response headers") {
+		t.Fatalf("expected a response header timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the request to fail fast, took %v", elapsed)
+	}
+
+	// The transport in use is copied rather than replaced.
+	var dials atomic.Int32
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials.Add(1)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	client = anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithBaseURL(server.URL),
+		option.WithMaxRetries(0),
+		option.WithHTTPTransport(transport),
+	)
+	_, err = client.Models.Get(context.Background(), "claude-sonnet-4-5", anthropic.ModelGetParams{}, option.WithTimeouts(time.Second, 50*time.Millisecond))
+	if err == nil || !strings.Contains(err.Error(), "timeout awaiting response headers") || dials.Load() != 1 {
+		t.Errorf("expected a response header timeout through the custom dialer, got %v after %d dials", err, dials.Load())
+	}
+
+	_, err = client.Models.Get(context.Background(), "claude-sonnet-4-5", anthropic.ModelGetParams{},
+		option.WithHTTPClient(&http.Client{Transport: &closureTransport{}}), option.WithTimeouts(time.Second, time.Second))
+	if err == nil || !strings.Contains(err.Error(), "WithTimeouts requires the http client to use an *http.Transport") {
+		t.Errorf("expected an error for a custom transport, got %v", err)
+	}
+}
+
+func TestWithPerCallTimeout(t *testing.T) {
+	var attempts int
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					attempts++
+					body, _ := io.ReadAll(req.Body)
+					if !strings.Contains(string(body), `"stream":true`) {
+						<-req.Context().Done()
+						return nil, req.Context().Err()
+					}
+					pr, pw := io.Pipe()
+					go func() {
+						// The first event arrives quickly, the rest after the timeout.
+						pw.Write([]byte("event: message_start\ndata: " + `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":1,"output_tokens":1}}}` + "\n\n"))
+						time.Sleep(100 * time.Millisecond)
+						pw.Write([]byte("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+						pw.Close()
+					}()
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"text/event-stream"}},
+						Body:       pr,
+					}, nil
+				},
+			},
+		}),
+		option.WithMaxRetries(2),
+		option.WithPerCallTimeout(50*time.Millisecond),
+	)
+	params := anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}
+
+	// The timeout spans every attempt of a call which does not stream.
+	start := time.Now()
+	_, err := client.Messages.New(context.Background(), params)
+	if elapsed := time.Since(start); !errors.Is(err, context.DeadlineExceeded) || attempts != 1 || elapsed > time.Second {
+		t.Errorf("expected the call to time out after one attempt, got %d attempts in %s and %v", attempts, elapsed, err)
+	}
+
+	// A stream which has started is not cut short.
+	stream := client.Messages.NewStreaming(context.Background(), params)
+	var events int
+	for stream.Next() {
+		events++
+	}
+	if err := stream.Err(); err != nil || events != 2 {
+		t.Errorf("expected the stream to complete, got %d events and %v", events, err)
+	}
+
+	// The caller's deadline wins when it comes first.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = client.Messages.New(ctx, params, option.WithPerCallTimeout(time.Hour))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context deadline to end the call, got %v", err)
+	}
+}
+
+func TestWithTotalDeadline(t *testing.T) {
+	var attempts int
+	hang := false
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					attempts++
+					if hang {
+						<-req.Context().Done()
+						return nil, req.Context().Err()
+					}
+					time.Sleep(30 * time.Millisecond)
+					return &http.Response{
+						StatusCode: 529,
+						Header:     http.Header{"Content-Type": {"application/json"}, "Retry-After-Ms": {"10"}},
+						Body:       io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)),
+					}, nil
+				},
+			},
+		}),
+		option.WithMaxRetries(10),
+		option.WithTotalDeadline(100*time.Millisecond),
+	)
+	params := anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}
+
+	// A third attempt of 30ms would not fit in the budget, so the error of the
+	// second one is returned right away.
+	start := time.Now()
+	_, err := client.Messages.New(context.Background(), params)
+	var overloaded *anthropic.OverloadedError
+	if elapsed := time.Since(start); !errors.As(err, &overloaded) || attempts != 2 || elapsed >= 100*time.Millisecond {
+		t.Errorf("expected the overloaded error after 2 attempts within the budget, got %d attempts in %s and %v", attempts, elapsed, err)
+	}
+
+	hang, attempts = true, 0
+	_, err = client.Messages.New(context.Background(), params)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "total deadline") || attempts != 1 {
+		t.Errorf("expected the attempt to be cut at the deadline, got %d attempts and %v", attempts, err)
+	}
+}
+
+func TestHTTPTransportOptions(t *testing.T) {
+	// The server and the transport call back from their own goroutines.
+	var mu sync.Mutex
+	var protos []string
+	var conns int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		protos = append(protos, r.Proto)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","content":[]}`))
+	}))
+	server.EnableHTTP2 = true
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	params := anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}
+	var middlewareCalls int
+	// reset returns what was recorded since it was last called.
+	reset := func() ([]string, int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		defer func() { protos, conns, middlewareCalls = nil, 0, 0 }()
+		return protos, conns, middlewareCalls
+	}
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithBaseURL(server.URL),
+		option.WithHTTPClient(server.Client()),
+		option.WithMaxIdleConnsPerHost(64),
+		option.WithDisableHTTP2(),
+		option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			mu.Lock()
+			middlewareCalls++
+			mu.Unlock()
+			return next(req)
+		}),
+	)
+	for range 3 {
+		if _, err := client.Messages.New(context.Background(), params); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if protos, conns, middlewareCalls := reset(); !slices.Equal(protos, []string{"HTTP/1.1", "HTTP/1.1", "HTTP/1.1"}) || conns != 1 || middlewareCalls != 3 {
+		t.Errorf("expected 3 HTTP/1.1 requests over 1 connection through the middleware, got %v over %d connections, %d middleware calls", protos, conns, middlewareCalls)
+	}
+
+	// Options supplied to each request share one copy of the transport too.
+	client = anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithBaseURL(server.URL),
+		option.WithHTTPClient(server.Client()),
+	)
+	for range 3 {
+		if _, err := client.Messages.New(context.Background(), params, option.WithMaxIdleConnsPerHost(8), option.WithDisableHTTP2()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if protos, conns, _ := reset(); !slices.Equal(protos, []string{"HTTP/1.1", "HTTP/1.1", "HTTP/1.1"}) || conns != 1 {
+		t.Errorf("expected 3 HTTP/1.1 requests over 1 connection, got %v over %d connections", protos, conns)
+	}
+
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	client = anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithBaseURL(server.URL),
+		option.WithHTTPTransport(transport),
+	)
+	for range 2 {
+		if _, err := client.Messages.New(context.Background(), params); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if protos, conns, _ := reset(); !slices.Equal(protos, []string{"HTTP/2.0", "HTTP/2.0"}) || conns != 1 {
+		t.Errorf("expected 2 HTTP/2 requests over 1 connection, got %v over %d connections", protos, conns)
+	}
+
+	_, err := client.Messages.New(context.Background(), params, option.WithHTTPClient(&http.Client{Transport: &closureTransport{}}), option.WithDisableHTTP2())
+	if err == nil || !strings.Contains(err.Error(), "WithDisableHTTP2 requires the http client to use an *http.Transport") {
+		t.Errorf("expected an error for a custom transport, got %v", err)
+	}
+}
+
+func TestCancelAll(t *testing.T) {
+	started := make(chan struct{}, 2)
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					body, _ := io.ReadAll(req.Body)
+					if strings.Contains(string(body), `"text":"done"`) {
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Header:     http.Header{"Content-Type": {"application/json"}},
+							Body:       io.NopCloser(strings.NewReader(`{"id":"msg_2","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":1,"output_tokens":1}}`)),
+						}, nil
+					}
+					started <- struct{}{}
+					if !strings.Contains(string(body), `"stream":true`) {
+						<-req.Context().Done()
+						return nil, req.Context().Err()
+					}
+					pr, pw := io.Pipe()
+					go func() {
+						pw.Write([]byte("event: message_start\ndata: " + `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":1,"output_tokens":1}}}` + "\n\n"))
+						<-req.Context().Done()
+						pw.CloseWithError(context.Cause(req.Context()))
+					}()
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"text/event-stream"}},
+						Body:       pr,
+					}, nil
+				},
+			},
+		}),
+	)
+	params := anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := client.Messages.New(context.Background(), params)
+		errs <- err
+	}()
+	stream := client.Messages.NewStreaming(context.Background(), params)
+	if !stream.Next() {
+		t.Fatalf("expected an event, got %v", stream.Err())
+	}
+	<-started
+	<-started
+
+	if n := client.CancelAll(); n != 2 {
+		t.Errorf("expected 2 requests to be cancelled, got %d", n)
+	}
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the request to be cancelled, got %v", err)
+	}
+	for stream.Next() {
+	}
+	if err := stream.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the stream to be cancelled, got %v", err)
+	}
+	stream.Close()
+
+	// The client remains usable, and completed requests are no longer tracked.
+	params.Messages = []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("done"))}
+	if _, err := client.Messages.New(context.Background(), params); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if n := client.CancelAll(); n != 0 {
+		t.Errorf("expected no requests to be cancelled, got %d", n)
+	}
+}
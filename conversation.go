@@ -0,0 +1,84 @@
+package anthropic
+
+import "slices"
+
+// Conversation builds the history of a multi-turn conversation with the beta
+// Messages API, so that each request can be sent with every turn before it:
+//
+//	var conv anthropic.Conversation
+//	conv.AddUserText("What's the weather in Oslo?")
+//	for {
+//		message, err := client.Beta.Messages.New(ctx, conv.Params(anthropic.ModelClaudeSonnet4_5, 1024))
+//		if err != nil {
+//			return err
+//		}
+//		conv.AddAssistantMessage(*message)
+//		if message.StopReason != anthropic.BetaStopReasonToolUse {
+//			break
+//		}
+//		for _, block := range message.Content {
+//			if toolUse, ok := block.AsAny().(anthropic.BetaToolUseBlock); ok {
+//				conv.AddToolResult(toolUse.ID, runTool(toolUse))
+//			}
+//		}
+//	}
+//
+// Text and tool results added one after the other are sent in a single user
+// message, with the tool results first, as the API requires. The zero value is
+// an empty conversation. A Conversation must not be used from several
+// goroutines at once.
+type Conversation struct {
+	messages []BetaMessageParam
+}
+
+// AddUserText adds text from the user.
+func (c *Conversation) AddUserText(text string) {
+	c.addUserBlock(NewBetaTextBlock(text))
+}
+
+// AddAssistantMessage adds a response of the model, with all of its content,
+// including thinking and tool use blocks.
+func (c *Conversation) AddAssistantMessage(message BetaMessage) {
+	c.messages = append(c.messages, message.ToParam())
+}
+
+// AddToolResult adds the result of the tool use with the given ID.
+func (c *Conversation) AddToolResult(toolUseID string, content string) {
+	block := NewBetaToolResultBlock(toolUseID)
+	block.OfToolResult.Content = []BetaToolResultBlockParamContentUnion{{OfText: &BetaTextBlockParam{Text: content}}}
+	c.addUserBlock(block)
+}
+
+func (c *Conversation) addUserBlock(block BetaContentBlockParamUnion) {
+	n := len(c.messages)
+	if n == 0 || c.messages[n-1].Role != BetaMessageParamRoleUser {
+		c.messages = append(c.messages, NewBetaUserMessage(block))
+		return
+	}
+	// Inserting into the clipped content copies it, so that the messages
+	// returned earlier by Params are not modified.
+	last := &c.messages[n-1]
+	i := len(last.Content)
+	if block.OfToolResult != nil {
+		i = slices.IndexFunc(last.Content, func(b BetaContentBlockParamUnion) bool { return b.OfToolResult == nil })
+		if i < 0 {
+			i = len(last.Content)
+		}
+	}
+	last.Content = slices.Insert(slices.Clip(last.Content), i, block)
+}
+
+// Messages returns the messages of the conversation.
+func (c *Conversation) Messages() []BetaMessageParam {
+	return slices.Clone(c.messages)
+}
+
+// Params returns the params of a request continuing the conversation. Other
+// fields, such as System or Tools, can be set on the result before it is sent.
+func (c *Conversation) Params(model Model, maxTokens int64) BetaMessageNewParams {
+	return BetaMessageNewParams{
+		MaxTokens: maxTokens,
+		Messages:  c.Messages(),
+		Model:     model,
+	}
+}
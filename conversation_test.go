@@ -0,0 +1,45 @@
+package anthropic_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestConversation(t *testing.T) {
+	var message anthropic.BetaMessage
+	if err := json.Unmarshal([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","stop_reason":"tool_use","content":[`+
+		`{"type":"text","text":"Let me check."},`+
+		`{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Oslo"}},`+
+		`{"type":"tool_use","id":"toolu_2","name":"get_weather","input":{"city":"Paris"}}]}`), &message); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var conv anthropic.Conversation
+	conv.AddUserText("What's the weather in Oslo and Paris?")
+	first := conv.Params(anthropic.ModelClaudeSonnet4_5, 1024)
+	conv.AddAssistantMessage(message)
+	conv.AddToolResult("toolu_1", "Snow")
+	conv.AddUserText("Be brief.")
+	conv.AddToolResult("toolu_2", "Sun")
+	params := conv.Params(anthropic.ModelClaudeSonnet4_5, 1024)
+
+	b, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"max_tokens":1024,"messages":[` +
+		`{"content":[{"text":"What's the weather in Oslo and Paris?","type":"text"}],"role":"user"},` +
+		`{"content":[{"text":"Let me check.","type":"text"},{"id":"toolu_1","input":{"city":"Oslo"},"name":"get_weather","type":"tool_use"},{"id":"toolu_2","input":{"city":"Paris"},"name":"get_weather","type":"tool_use"}],"role":"assistant"},` +
+		`{"content":[{"tool_use_id":"toolu_1","content":[{"text":"Snow","type":"text"}],"type":"tool_result"},{"tool_use_id":"toolu_2","content":[{"text":"Sun","type":"text"}],"type":"tool_result"},{"text":"Be brief.","type":"text"}],"role":"user"}` +
+		`],"model":"claude-sonnet-4-5"}`
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+
+	conv.AddUserText("Thanks!")
+	if len(first.Messages) != 1 || len(params.Messages[2].Content) != 3 {
+		t.Errorf("expected earlier params not to be modified, got %d messages and %d blocks", len(first.Messages), len(params.Messages[2].Content))
+	}
+}
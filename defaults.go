@@ -0,0 +1,66 @@
+package anthropic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// WithDefaultModel returns a RequestOption which sets the model of requests
+// that do not specify one, so that single-model apps can omit it from their
+// params:
+//
+//	client := anthropic.NewClient(anthropic.WithDefaultModel(anthropic.ModelClaudeSonnet4_5))
+//	message, err := client.Messages.New(ctx, anthropic.MessageNewParams{
+//		MaxTokens: 1024,
+//		Messages:  messages,
+//	})
+//
+// A model set in the params always takes precedence. The default applies to
+// requests with a top-level messages array, such as message creation and token
+// counting, but not to the requests inside a message batch.
+func WithDefaultModel(model Model) option.RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		return r.RewriteJSONBody(func(body []byte) ([]byte, error) {
+			if !gjson.GetBytes(body, "messages").Exists() || gjson.GetBytes(body, "model").String() != "" {
+				return body, nil
+			}
+			return sjson.SetBytes(body, "model", string(model))
+		})
+	})
+}
+
+// WithDefaultMaxTokens returns a RequestOption which sets max_tokens on message
+// creation requests whose max_tokens is zero or absent. Combined with
+// [WithDefaultModel], params can be reduced to the messages:
+//
+//	client := anthropic.NewClient(
+//		anthropic.WithDefaultModel(anthropic.ModelClaudeSonnet4_5),
+//		anthropic.WithDefaultMaxTokens(1024),
+//	)
+//	message, err := client.Messages.New(ctx, anthropic.MessageNewParams{Messages: messages})
+//
+// A max_tokens set in the params always takes precedence. Token counting
+// requests, which do not take max_tokens, are left untouched.
+//
+// WithDefaultMaxTokens panics if n is not positive.
+func WithDefaultMaxTokens(n int64) option.RequestOption {
+	if n <= 0 {
+		panic(fmt.Sprintf("anthropic: default max_tokens must be positive, got %d", n))
+	}
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		if !strings.HasSuffix(r.Request.URL.Path, "v1/messages") {
+			return nil
+		}
+		return r.RewriteJSONBody(func(body []byte) ([]byte, error) {
+			if !gjson.GetBytes(body, "messages").Exists() || gjson.GetBytes(body, "max_tokens").Int() != 0 {
+				return body, nil
+			}
+			return sjson.SetBytes(body, "max_tokens", n)
+		})
+	})
+}
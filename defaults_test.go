@@ -0,0 +1,98 @@
+package anthropic_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+)
+
+func TestWithDefaultModel(t *testing.T) {
+	var body []byte
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					body, _ = io.ReadAll(req.Body)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(`{}`)),
+					}, nil
+				},
+			},
+		}),
+		anthropic.WithDefaultModel(anthropic.ModelClaudeSonnet4_5),
+	)
+
+	params := anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+	}
+	if _, err := client.Messages.New(context.Background(), params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model := gjson.GetBytes(body, "model").String(); model != string(anthropic.ModelClaudeSonnet4_5) {
+		t.Errorf("expected the default model, got %q", model)
+	}
+
+	params.Model = anthropic.ModelClaudeSonnet4_5_20250929
+	if _, err := client.Messages.New(context.Background(), params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model := gjson.GetBytes(body, "model").String(); model != string(anthropic.ModelClaudeSonnet4_5_20250929) {
+		t.Errorf("expected the model from the params, got %q", model)
+	}
+}
+
+func TestWithDefaultMaxTokens(t *testing.T) {
+	var body []byte
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					body, _ = io.ReadAll(req.Body)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(`{}`)),
+					}, nil
+				},
+			},
+		}),
+		anthropic.WithDefaultModel(anthropic.ModelClaudeSonnet4_5),
+		anthropic.WithDefaultMaxTokens(2048),
+	)
+
+	params := anthropic.MessageNewParams{
+		Messages: []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+	}
+	if _, err := client.Messages.New(context.Background(), params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if maxTokens := gjson.GetBytes(body, "max_tokens").Int(); maxTokens != 2048 {
+		t.Errorf("expected the default max_tokens, got %d", maxTokens)
+	}
+
+	params.MaxTokens = 100
+	if _, err := client.Messages.New(context.Background(), params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if maxTokens := gjson.GetBytes(body, "max_tokens").Int(); maxTokens != 100 {
+		t.Errorf("expected the max_tokens from the params, got %d", maxTokens)
+	}
+
+	if _, err := client.Messages.CountTokens(context.Background(), anthropic.MessageCountTokensParams{Messages: params.Messages}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gjson.GetBytes(body, "max_tokens").Exists() {
+		t.Errorf("expected token counting requests to be left untouched, got %s", body)
+	}
+}
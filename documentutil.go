@@ -0,0 +1,45 @@
+package anthropic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// DocumentBlockOpts are the optional settings of a document block built by
+// [NewBetaDocumentBlockFromFile].
+type DocumentBlockOpts struct {
+	// Title is the title of the document, given to the model.
+	Title string
+	// CacheControl sets an ephemeral cache breakpoint on the document, which
+	// is worth it for a large document sent with several requests.
+	CacheControl bool
+	// Citations enables citations of the document in the response, to ground
+	// the answers in its content.
+	Citations bool
+}
+
+// NewBetaDocumentBlockFromFile reads the PDF at path and returns a base64
+// document block for it, with the settings of opts. An error is returned if
+// the file does not start with the %PDF- magic bytes.
+func NewBetaDocumentBlockFromFile(path string, opts DocumentBlockOpts) (BetaContentBlockParamUnion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return BetaContentBlockParamUnion{}, fmt.Errorf("read document: %w", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return BetaContentBlockParamUnion{}, fmt.Errorf("read document %s: not a PDF", path)
+	}
+	block := NewBetaDocumentBlock(BetaBase64PDFSourceParam{Data: base64.StdEncoding.EncodeToString(data)})
+	if opts.Title != "" {
+		block.OfDocument.Title = String(opts.Title)
+	}
+	if opts.CacheControl {
+		block.OfDocument.CacheControl = NewBetaCacheControlEphemeralParam()
+	}
+	if opts.Citations {
+		block.OfDocument.Citations = BetaCitationsConfigParam{Enabled: Bool(true)}
+	}
+	return block, nil
+}
@@ -0,0 +1,51 @@
+package anthropic_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestNewBetaDocumentBlockFromFile(t *testing.T) {
+	dir := t.TempDir()
+	pdf := []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	path := filepath.Join(dir, "doc.pdf")
+	if err := os.WriteFile(path, pdf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := anthropic.NewBetaDocumentBlockFromFile(path, anthropic.DocumentBlockOpts{Title: "Report", CacheControl: true, Citations: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"document","title":"Report","cache_control":{"type":"ephemeral"},"citations":{"enabled":true},` +
+		`"source":{"type":"base64","media_type":"application/pdf","data":"` + base64.StdEncoding.EncodeToString(pdf) + `"}}`
+	if normalizeJSON(t, got) != normalizeJSON(t, []byte(want)) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	block, err = anthropic.NewBetaDocumentBlockFromFile(path, anthropic.DocumentBlockOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := json.Marshal(block); strings.Contains(string(got), "title") || strings.Contains(string(got), "citations") {
+		t.Errorf("expected no optional settings, got %s", got)
+	}
+
+	notPDF := filepath.Join(dir, "doc.txt")
+	if err := os.WriteFile(notPDF, []byte("plain text"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := anthropic.NewBetaDocumentBlockFromFile(notPDF, anthropic.DocumentBlockOpts{}); err == nil {
+		t.Error("expected an error for a file which is not a PDF")
+	}
+}
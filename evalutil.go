@@ -0,0 +1,46 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// EvalExample is a single conversation in an evaluation or fine-tuning dataset.
+type EvalExample struct {
+	// System is the system prompt of the conversation, if any.
+	System string `json:"system,omitempty"`
+	// Messages are the turns of the conversation.
+	Messages []MessageParam `json:"messages"`
+	// Expected is the ideal response to the conversation, if known.
+	Expected string `json:"expected,omitempty"`
+}
+
+// ToEvalJSONL writes each conversation to w as one line of JSON in the format
+// of [EvalExample]. Use [WriteEvalJSONL] to include system prompts and expected
+// outputs.
+func ToEvalJSONL(conversations [][]MessageParam, w io.Writer) error {
+	examples := make([]EvalExample, len(conversations))
+	for i, messages := range conversations {
+		examples[i] = EvalExample{Messages: messages}
+	}
+	return WriteEvalJSONL(w, examples)
+}
+
+// WriteEvalJSONL writes each example to w as one line of JSON, so that
+// conversations built with the SDK can be used directly as offline evaluation
+// or fine-tuning datasets:
+//
+//	{"system":"...","messages":[{"role":"user","content":[...]}],"expected":"..."}
+func WriteEvalJSONL(w io.Writer, examples []EvalExample) error {
+	enc := json.NewEncoder(w)
+	for i, example := range examples {
+		if example.Messages == nil {
+			example.Messages = []MessageParam{}
+		}
+		if err := enc.Encode(example); err != nil {
+			return fmt.Errorf("eval: could not encode example %d: %w", i, err)
+		}
+	}
+	return nil
+}
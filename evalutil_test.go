@@ -0,0 +1,34 @@
+package anthropic_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestWriteEvalJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	err := anthropic.WriteEvalJSONL(&buf, []anthropic.EvalExample{
+		{
+			System:   "Answer in one word.",
+			Messages: []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Capital of France?"))},
+			Expected: "Paris",
+		},
+		{
+			Messages: []anthropic.MessageParam{
+				anthropic.NewUserMessage(anthropic.NewTextBlock("hi")),
+				anthropic.NewAssistantMessage(anthropic.NewTextBlock("Hello")),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `{"system":"Answer in one word.","messages":[{"content":[{"text":"Capital of France?","type":"text"}],"role":"user"}],"expected":"Paris"}` + "\n" +
+		`{"messages":[{"content":[{"text":"hi","type":"text"}],"role":"user"},{"content":[{"text":"Hello","type":"text"}],"role":"assistant"}]}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
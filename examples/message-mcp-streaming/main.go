@@ -39,8 +39,7 @@ func main() {
 		event := stream.Current()
 		err := message.Accumulate(event)
 		if err != nil {
-			fmt.Printf("error accumulating event: %v\n", err)
-			continue
+			panic(err)
 		}
 
 		switch eventVariant := event.AsAny().(type) {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"os"
 
 	"github.com/sofianhadi1983/anthropic-sdk-go"
 )
@@ -24,23 +25,11 @@ func main() {
 
 	print("[assistant]: ")
 
-	for stream.Next() {
-		event := stream.Current()
-
-		switch eventVariant := event.AsAny().(type) {
-		case anthropic.MessageDeltaEvent:
-			print(eventVariant.Delta.StopSequence)
-		case anthropic.ContentBlockDeltaEvent:
-			switch deltaVariant := eventVariant.Delta.AsAny().(type) {
-			case anthropic.TextDelta:
-				print(deltaVariant.Text)
-			}
-		}
+	text := anthropic.NewTextStream(stream)
+	if _, err := text.WriteTextTo(os.Stdout); err != nil {
+		panic(err)
 	}
+	print(text.Accumulated().StopSequence)
 
 	println()
-
-	if stream.Err() != nil {
-		panic(stream.Err())
-	}
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func main() {
+	client := anthropic.NewClient()
+
+	// Prompts are only cached above a minimum length, so use a long system
+	// prompt. The first call writes it to the cache and the second reads it.
+	instructions := "You are a meticulous literary critic. " + strings.Repeat("Consider themes, style, structure and historical context in every answer. ", 300)
+
+	for _, question := range []string{
+		"What makes a good opening line?",
+		"What makes a good ending?",
+	} {
+		println("[user]: " + question)
+
+		message, err := client.Messages.New(context.TODO(), anthropic.MessageNewParams{
+			MaxTokens: 1024,
+			System: []anthropic.TextBlockParam{
+				anthropic.TextBlockParam{Text: instructions}.WithCacheControl(),
+			},
+			Messages: []anthropic.MessageParam{
+				anthropic.NewUserMessage(anthropic.NewTextBlock(question)),
+			},
+			Model: anthropic.ModelClaudeSonnet4_5_20250929,
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		println("[assistant]: " + message.Text())
+		fmt.Printf("[usage]: %d cache write, %d cache read, %d uncached input tokens\n\n",
+			message.Usage.CacheCreationInputTokens, message.Usage.CacheReadInputTokens, message.Usage.InputTokens)
+	}
+}
@@ -0,0 +1,88 @@
+package anthropic
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// DeduplicateHistory returns a copy of messages without the turns which repeat
+// the one before them, as happens in buggy agent loops which append the same
+// message several times, wasting tokens. A message is removed when it has the
+// same role as the previous message kept and:
+//   - the same content, or
+//   - if similarity is more than 0, only text blocks whose words are at least
+//     that similar to those of the previous message, from 0 for no words in
+//     common to 1 for the same words.
+//
+// A similarity of 0 therefore only removes exact duplicates. Messages holding
+// tool_use or tool_result blocks are always kept, so that tool uses stay paired
+// with their results, and the order of the messages is preserved. The input is
+// not modified.
+func DeduplicateHistory(messages []MessageParam, similarity float64) []MessageParam {
+	deduplicated := make([]MessageParam, 0, len(messages))
+	for _, message := range messages {
+		if n := len(deduplicated); n > 0 && duplicateMessage(deduplicated[n-1], message, similarity) {
+			continue
+		}
+		deduplicated = append(deduplicated, message)
+	}
+	return deduplicated
+}
+
+func duplicateMessage(a, b MessageParam, similarity float64) bool {
+	if a.Role != b.Role || hasToolBlocks(a) || hasToolBlocks(b) {
+		return false
+	}
+	contentA, errA := json.Marshal(a.Content)
+	contentB, errB := json.Marshal(b.Content)
+	if errA == nil && errB == nil && bytes.Equal(contentA, contentB) {
+		return true
+	}
+	if similarity <= 0 {
+		return false
+	}
+	textA, okA := messageParamText(a)
+	textB, okB := messageParamText(b)
+	return okA && okB && wordSimilarity(strings.Fields(textA), strings.Fields(textB)) >= similarity
+}
+
+func hasToolBlocks(message MessageParam) bool {
+	toolUses, toolResults := toolPairing(message)
+	return len(toolUses) > 0 || len(toolResults) > 0
+}
+
+// messageParamText returns the text of a message, and false if it has blocks
+// other than text.
+func messageParamText(message MessageParam) (string, bool) {
+	var sb strings.Builder
+	for _, block := range message.Content {
+		if block.OfText == nil {
+			return "", false
+		}
+		sb.WriteString(block.OfText.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String(), true
+}
+
+// wordSimilarity is the Sørensen–Dice coefficient of two texts, counting each
+// occurrence of a word: twice the number of words in common, divided by the
+// total number of words.
+func wordSimilarity(a, b []string) float64 {
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	counts := make(map[string]int, len(a))
+	for _, word := range a {
+		counts[word]++
+	}
+	common := 0
+	for _, word := range b {
+		if counts[word] > 0 {
+			counts[word]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
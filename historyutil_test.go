@@ -0,0 +1,51 @@
+package anthropic_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestDeduplicateHistory(t *testing.T) {
+	toolUse := anthropic.NewAssistantMessage(anthropic.NewToolUseBlock("toolu_01", map[string]any{"path": "main.go"}, "read_file"))
+	toolResult := anthropic.NewUserMessage(anthropic.NewToolResultBlock("toolu_01", "package main", false))
+	messages := []anthropic.MessageParam{
+		anthropic.NewUserMessage(anthropic.NewTextBlock("Fix the build.")),
+		anthropic.NewUserMessage(anthropic.NewTextBlock("Fix the build.")),
+		toolUse,
+		toolResult,
+		toolResult,
+		anthropic.NewAssistantMessage(anthropic.NewTextBlock("I will now run the tests to check the fix.")),
+		anthropic.NewAssistantMessage(anthropic.NewTextBlock("I will now run the tests to verify the fix.")),
+		anthropic.NewUserMessage(anthropic.NewTextBlock("Thanks!")),
+	}
+
+	texts := func(messages []anthropic.MessageParam) []string {
+		var texts []string
+		for _, message := range messages {
+			switch block := message.Content[0]; {
+			case block.OfText != nil:
+				texts = append(texts, block.OfText.Text)
+			case block.OfToolUse != nil:
+				texts = append(texts, "tool_use")
+			case block.OfToolResult != nil:
+				texts = append(texts, "tool_result")
+			}
+		}
+		return texts
+	}
+
+	exact := anthropic.DeduplicateHistory(messages, 0)
+	if len(exact) != 7 || len(messages) != 8 {
+		t.Errorf("expected only the repeated user message to be removed, got %q", texts(exact))
+	}
+	near := anthropic.DeduplicateHistory(messages, 0.8)
+	expected := []string{"Fix the build.", "tool_use", "tool_result", "tool_result", "I will now run the tests to check the fix.", "Thanks!"}
+	if got := texts(near); !slices.Equal(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if strict := anthropic.DeduplicateHistory(messages, 0.95); len(strict) != 7 {
+		t.Errorf("expected messages below the similarity to be kept, got %q", texts(strict))
+	}
+}
@@ -0,0 +1,244 @@
+package anthropic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+)
+
+const (
+	// MaxImageSize is the largest image, in bytes, accepted by the API. The
+	// API limits the base64 encoded data to 5MB, which this many raw bytes
+	// encode to.
+	MaxImageSize = (5 << 20) / 4 * 3
+	// MaxImageDimension is the largest width or height, in pixels, of an image
+	// accepted by the API.
+	MaxImageDimension = 8000
+)
+
+// ErrImageTooLarge is returned by [NewImageBlockFromReader] and
+// [NewImageBlockFromFile] when an image exceeds [MaxImageSize] or
+// [MaxImageDimension].
+var ErrImageTooLarge = errors.New("image exceeds the size limits of the API")
+
+// ImageMediaTypeError is returned by [NewImageBlockBase64Checked] and
+// [NewImageBlockFromReader] when the declared media type of an image does not
+// match its data.
+type ImageMediaTypeError struct {
+	// Declared is empty if the media type was to be detected.
+	Declared Base64ImageSourceMediaType
+	// Detected is the media type sniffed from the data, or empty if the data
+	// is not in one of the formats supported by the API.
+	Detected Base64ImageSourceMediaType
+}
+
+func (e *ImageMediaTypeError) Error() string {
+	if e.Declared == "" {
+		return "image is not in a supported format: JPEG, PNG, GIF or WebP"
+	}
+	if e.Detected == "" {
+		return fmt.Sprintf("image declared as %s is not a supported image format", e.Declared)
+	}
+	return fmt.Sprintf("image declared as %s is actually %s", e.Declared, e.Detected)
+}
+
+// ImageMediaTypePolicy decides what [NewImageBlockBase64Checked] does when the
+// declared media type of an image does not match its data.
+type ImageMediaTypePolicy int
+
+const (
+	// ImageMediaTypeReject returns an [*ImageMediaTypeError].
+	ImageMediaTypeReject ImageMediaTypePolicy = iota
+	// ImageMediaTypeCorrect uses the detected media type instead, and only
+	// returns an error if the format could not be detected.
+	ImageMediaTypeCorrect
+)
+
+// DetectImageMediaType returns the media type of an image from the magic bytes
+// at the start of data, or an empty string if it is not one of the formats
+// supported by the API: JPEG, PNG, GIF and WebP.
+func DetectImageMediaType(data []byte) Base64ImageSourceMediaType {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return Base64ImageSourceMediaTypeImageJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return Base64ImageSourceMediaTypeImagePNG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return Base64ImageSourceMediaTypeImageGIF
+	case len(data) >= 12 && bytes.HasPrefix(data, []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return Base64ImageSourceMediaTypeImageWebP
+	}
+	return ""
+}
+
+// NewImageBlockBase64Checked is like [NewImageBlockBase64], but checks that
+// mediaType matches the format of the image, since the API rejects images
+// whose declared type is wrong. On a mismatch, policy decides whether to return
+// an [*ImageMediaTypeError] or to use the detected type.
+func NewImageBlockBase64Checked(mediaType string, encodedData string, policy ImageMediaTypePolicy) (ContentBlockParamUnion, error) {
+	// The magic bytes of all supported formats fit in the first 12 bytes.
+	prefix := encodedData[:min(len(encodedData), 16)]
+	header, err := base64.StdEncoding.DecodeString(prefix)
+	if err != nil && len(prefix) == 16 {
+		return ContentBlockParamUnion{}, fmt.Errorf("image data is not valid base64: %w", err)
+	}
+
+	declared := Base64ImageSourceMediaType(mediaType)
+	detected := DetectImageMediaType(header)
+	if detected != declared {
+		mismatch := &ImageMediaTypeError{Declared: declared, Detected: detected}
+		if policy != ImageMediaTypeCorrect || detected == "" {
+			return ContentBlockParamUnion{}, mismatch
+		}
+		mediaType = string(detected)
+	}
+	return NewImageBlockBase64(mediaType, encodedData), nil
+}
+
+// NewImageBlockFromFile reads the image at path and returns a base64 image
+// block for it, with the media type detected from its content. See
+// [NewImageBlockFromReader] for the checks made.
+func NewImageBlockFromFile(path string) (ContentBlockParamUnion, error) {
+	mediaType, data, err := readImageFile(path)
+	if err != nil {
+		return ContentBlockParamUnion{}, err
+	}
+	return NewImageBlockBase64(string(mediaType), data), nil
+}
+
+// NewImageBlockFromReader reads an image from r and returns a base64 image
+// block for it. If mediaType is empty, it is detected from the data; otherwise
+// an [*ImageMediaTypeError] is returned if it does not match. Images which are
+// not JPEG, PNG, GIF or WebP, or which exceed [MaxImageSize] or
+// [MaxImageDimension], are rejected before anything is sent, with an error
+// wrapping [ErrImageTooLarge] for the latter. At most [MaxImageSize] bytes and
+// one more are read from r.
+func NewImageBlockFromReader(r io.Reader, mediaType string) (ContentBlockParamUnion, error) {
+	detected, data, err := readImage(r, mediaType)
+	if err != nil {
+		return ContentBlockParamUnion{}, err
+	}
+	return NewImageBlockBase64(string(detected), data), nil
+}
+
+// NewBetaImageBlockFromFile is the beta counterpart of [NewImageBlockFromFile].
+func NewBetaImageBlockFromFile(path string) (BetaContentBlockParamUnion, error) {
+	mediaType, data, err := readImageFile(path)
+	if err != nil {
+		return BetaContentBlockParamUnion{}, err
+	}
+	return NewBetaImageBlock(BetaBase64ImageSourceParam{Data: data, MediaType: BetaBase64ImageSourceMediaType(mediaType)}), nil
+}
+
+// NewBetaImageBlockFromReader is the beta counterpart of
+// [NewImageBlockFromReader].
+func NewBetaImageBlockFromReader(r io.Reader, mediaType string) (BetaContentBlockParamUnion, error) {
+	detected, data, err := readImage(r, mediaType)
+	if err != nil {
+		return BetaContentBlockParamUnion{}, err
+	}
+	return NewBetaImageBlock(BetaBase64ImageSourceParam{Data: data, MediaType: BetaBase64ImageSourceMediaType(detected)}), nil
+}
+
+func readImageFile(path string) (Base64ImageSourceMediaType, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("read image: %w", err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() && info.Size() > MaxImageSize {
+		return "", "", fmt.Errorf("read image %s: %d bytes: %w", path, info.Size(), ErrImageTooLarge)
+	}
+	mediaType, data, err := readImage(f, "")
+	if err != nil {
+		return "", "", fmt.Errorf("read image %s: %w", path, err)
+	}
+	return mediaType, data, nil
+}
+
+// readImage reads and checks an image, and returns its media type and base64
+// encoded data.
+func readImage(r io.Reader, mediaType string) (Base64ImageSourceMediaType, string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxImageSize+1))
+	if err != nil {
+		return "", "", err
+	}
+	if len(data) > MaxImageSize {
+		return "", "", fmt.Errorf("more than %d bytes: %w", MaxImageSize, ErrImageTooLarge)
+	}
+	detected := DetectImageMediaType(data)
+	if detected == "" || (mediaType != "" && Base64ImageSourceMediaType(mediaType) != detected) {
+		return "", "", &ImageMediaTypeError{Declared: Base64ImageSourceMediaType(mediaType), Detected: detected}
+	}
+	// WebP cannot be decoded with the standard library, so its dimensions are
+	// left to the API to check.
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && max(config.Width, config.Height) > MaxImageDimension {
+		return "", "", fmt.Errorf("%dx%d pixels: %w", config.Width, config.Height, ErrImageTooLarge)
+	}
+	return detected, base64.StdEncoding.EncodeToString(data), nil
+}
+
+// ConvertImage re-encodes an image as targetType, one of the media types
+// accepted by the API, so that arbitrary uploads can be normalized before
+// building an image block. JPEG, PNG and GIF are supported as both source and
+// target. WebP cannot be decoded or encoded with the standard library, so it is
+// only accepted when it is already the target type. Data already in the target
+// format is returned as is. An error is returned for any other source format,
+// rather than sending an image the API would reject.
+//
+// Transparent areas are filled with white when converting to JPEG, and only the
+// first frame of an animated GIF is kept.
+func ConvertImage(data []byte, targetType string) ([]byte, error) {
+	source := DetectImageMediaType(data)
+	target := Base64ImageSourceMediaType(targetType)
+	if source == "" {
+		return nil, fmt.Errorf("convert image: unsupported source format")
+	}
+	if source == target {
+		return data, nil
+	}
+
+	var decode func(r io.Reader) (image.Image, error)
+	switch source {
+	case Base64ImageSourceMediaTypeImageJPEG:
+		decode = jpeg.Decode
+	case Base64ImageSourceMediaTypeImagePNG:
+		decode = png.Decode
+	case Base64ImageSourceMediaTypeImageGIF:
+		decode = gif.Decode
+	default:
+		return nil, fmt.Errorf("convert image: cannot decode %s", source)
+	}
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("convert image: decoding %s: %w", source, err)
+	}
+
+	var buf bytes.Buffer
+	switch target {
+	case Base64ImageSourceMediaTypeImageJPEG:
+		opaque := image.NewRGBA(img.Bounds())
+		draw.Draw(opaque, opaque.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(opaque, opaque.Bounds(), img, img.Bounds().Min, draw.Over)
+		err = jpeg.Encode(&buf, opaque, &jpeg.Options{Quality: 90})
+	case Base64ImageSourceMediaTypeImagePNG:
+		err = png.Encode(&buf, img)
+	case Base64ImageSourceMediaTypeImageGIF:
+		err = gif.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("convert image: cannot encode %s", targetType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("convert image: encoding %s: %w", target, err)
+	}
+	return buf.Bytes(), nil
+}
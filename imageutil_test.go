@@ -0,0 +1,131 @@
+package anthropic_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestNewImageBlockBase64Checked(t *testing.T) {
+	jpeg := base64.StdEncoding.EncodeToString([]byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00\x01\x01\x00"))
+	webp := base64.StdEncoding.EncodeToString([]byte("RIFF\x24\x00\x00\x00WEBPVP8 "))
+
+	block, err := anthropic.NewImageBlockBase64Checked("image/webp", webp, anthropic.ImageMediaTypeReject)
+	if err != nil || block.OfImage.Source.OfBase64.MediaType != anthropic.Base64ImageSourceMediaTypeImageWebP {
+		t.Errorf("expected a valid webp block, got %v", err)
+	}
+
+	_, err = anthropic.NewImageBlockBase64Checked("image/png", jpeg, anthropic.ImageMediaTypeReject)
+	var mismatch *anthropic.ImageMediaTypeError
+	if !errors.As(err, &mismatch) || mismatch.Declared != "image/png" || mismatch.Detected != "image/jpeg" {
+		t.Fatalf("expected a media type error, got %v", err)
+	}
+	if mismatch.Error() != "image declared as image/png is actually image/jpeg" {
+		t.Errorf("unexpected error message %q", mismatch.Error())
+	}
+
+	block, err = anthropic.NewImageBlockBase64Checked("image/png", jpeg, anthropic.ImageMediaTypeCorrect)
+	if err != nil || block.OfImage.Source.OfBase64.MediaType != anthropic.Base64ImageSourceMediaTypeImageJPEG || block.OfImage.Source.OfBase64.Data != jpeg {
+		t.Errorf("expected the media type to be corrected, got %v", err)
+	}
+
+	bmp := base64.StdEncoding.EncodeToString([]byte("BM\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"))
+	if _, err := anthropic.NewImageBlockBase64Checked("image/png", bmp, anthropic.ImageMediaTypeCorrect); !errors.As(err, &mismatch) || mismatch.Detected != "" {
+		t.Errorf("expected an unsupported format error, got %v", err)
+	}
+	if _, err := anthropic.NewImageBlockBase64Checked("image/png", "not base64!!!!!!!!", anthropic.ImageMediaTypeCorrect); err == nil {
+		t.Error("expected an error for invalid base64")
+	}
+}
+
+func TestConvertImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.NRGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	pngData := buf.Bytes()
+
+	for _, target := range []string{"image/jpeg", "image/gif", "image/png"} {
+		out, err := anthropic.ConvertImage(pngData, target)
+		if err != nil {
+			t.Fatalf("converting to %s: %v", target, err)
+		}
+		if detected := anthropic.DetectImageMediaType(out); string(detected) != target {
+			t.Errorf("expected %s, got %q", target, detected)
+		}
+		back, err := anthropic.ConvertImage(out, "image/png")
+		if err != nil {
+			t.Fatalf("converting %s back to png: %v", target, err)
+		}
+		if decoded, err := png.Decode(bytes.NewReader(back)); err != nil || decoded.Bounds().Dx() != 4 {
+			t.Errorf("expected a 4x4 png, got %v", err)
+		}
+	}
+
+	webp := []byte("RIFF\x24\x00\x00\x00WEBPVP8 ")
+	if _, err := anthropic.ConvertImage(webp, "image/png"); err == nil {
+		t.Errorf("expected an error decoding webp")
+	}
+	if _, err := anthropic.ConvertImage(pngData, "image/webp"); err == nil {
+		t.Errorf("expected an error encoding webp")
+	}
+	if _, err := anthropic.ConvertImage([]byte("BM not supported"), "image/png"); err == nil {
+		t.Errorf("expected an error for an unsupported source format")
+	}
+}
+
+func TestNewImageBlockFromReader(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	pngData := buf.Bytes()
+
+	path := filepath.Join(t.TempDir(), "pixel")
+	if err := os.WriteFile(path, pngData, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	block, err := anthropic.NewImageBlockFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source := block.OfImage.Source.OfBase64; source.MediaType != anthropic.Base64ImageSourceMediaTypeImagePNG || source.Data != base64.StdEncoding.EncodeToString(pngData) {
+		t.Errorf("unexpected source %+v", source)
+	}
+
+	beta, err := anthropic.NewBetaImageBlockFromReader(bytes.NewReader(pngData), "image/png")
+	if err != nil || beta.OfImage.Source.OfBase64.MediaType != anthropic.BetaBase64ImageSourceMediaTypeImagePNG {
+		t.Errorf("expected a beta png block, got %v", err)
+	}
+
+	var mismatch *anthropic.ImageMediaTypeError
+	if _, err := anthropic.NewImageBlockFromReader(bytes.NewReader(pngData), "image/jpeg"); !errors.As(err, &mismatch) || mismatch.Detected != "image/png" {
+		t.Errorf("expected a media type error, got %v", err)
+	}
+	if _, err := anthropic.NewImageBlockFromReader(strings.NewReader("BM not supported"), ""); !errors.As(err, &mismatch) {
+		t.Errorf("expected an unsupported format error, got %v", err)
+	}
+
+	large := append(bytes.Clone(pngData), make([]byte, anthropic.MaxImageSize)...)
+	if _, err := anthropic.NewImageBlockFromReader(bytes.NewReader(large), ""); !errors.Is(err, anthropic.ErrImageTooLarge) {
+		t.Errorf("expected ErrImageTooLarge for a large file, got %v", err)
+	}
+	buf.Reset()
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, anthropic.MaxImageDimension+1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := anthropic.NewImageBlockFromReader(&buf, ""); !errors.Is(err, anthropic.ErrImageTooLarge) {
+		t.Errorf("expected ErrImageTooLarge for a wide image, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package apierror
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/shared"
+	"github.com/tidwall/gjson"
+)
+
+// As allows errors.As to extract the error of the API by category, so that
+// it can be handled without matching strings:
+//
+//   - a *shared.BillingError when the request failed because the account has
+//     run out of credits or exceeded its spend limit, as opposed to, say, an
+//     invalid API key. This is the case for errors of type billing_error,
+//     responses with status 402, and requests rejected with a message about
+//     the credit balance.
+//   - a *shared.RateLimitError for errors of type rate_limit_error or status
+//     429. See [Error.RetryAfter] for the delay to wait before retrying.
+//   - a *shared.OverloadedError for errors of type overloaded_error or status
+//     529.
+//   - a *shared.AuthenticationError for errors of type authentication_error or
+//     status 401.
+//   - a *shared.InvalidRequestError for errors of type invalid_request_error or
+//     status 400.
+//
+// The status and headers of the response remain available on the Error.
+func (r *Error) As(target any) bool {
+	body := gjson.Get(r.JSON.raw, "error")
+	errType, message := body.Get("type").String(), body.Get("message").String()
+	is := func(typ string, status int) bool { return errType == typ || r.StatusCode == status }
+	switch target := target.(type) {
+	case **shared.BillingError:
+		isBilling := errType == "billing_error" || r.StatusCode == http.StatusPaymentRequired ||
+			((errType == "invalid_request_error" || errType == "permission_error") && strings.Contains(strings.ToLower(message), "credit balance"))
+		if !isBilling {
+			return false
+		}
+		*target = &shared.BillingError{Message: message, Type: "billing_error"}
+	case **shared.RateLimitError:
+		if !is("rate_limit_error", http.StatusTooManyRequests) {
+			return false
+		}
+		*target = &shared.RateLimitError{Message: message, Type: "rate_limit_error"}
+	case **shared.OverloadedError:
+		if !is("overloaded_error", 529) {
+			return false
+		}
+		*target = &shared.OverloadedError{Message: message, Type: "overloaded_error"}
+	case **shared.AuthenticationError:
+		if !is("authentication_error", http.StatusUnauthorized) {
+			return false
+		}
+		*target = &shared.AuthenticationError{Message: message, Type: "authentication_error"}
+	case **shared.InvalidRequestError:
+		if !is("invalid_request_error", http.StatusBadRequest) {
+			return false
+		}
+		*target = &shared.InvalidRequestError{Message: message, Type: "invalid_request_error"}
+	default:
+		return false
+	}
+	return true
+}
+
+// RetryAfter returns the delay to wait before retrying, from the Retry-After-Ms
+// or Retry-After header of the response, or 0 if neither is set.
+func (r *Error) RetryAfter() time.Duration {
+	if r.Response == nil {
+		return 0
+	}
+	header := r.Response.Header
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	retryAfter := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(retryAfter, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := http.ParseTime(retryAfter); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+// Package betacompat holds the compatibility table of the beta features, shared
+// by anthropic.ValidateBetas and the oauth package.
+package betacompat
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// conflicts lists the betas which cannot be enabled together, typically
+// different versions of the same feature.
+var conflicts = [][2]string{
+	{"computer-use-2024-10-22", "computer-use-2025-01-24"},
+	{"mcp-client-2025-04-04", "mcp-client-2025-11-20"},
+	{"code-execution-2025-05-22", "code-execution-2025-08-25"},
+}
+
+// requires maps a beta to the betas it needs, any one of which is enough.
+var requires = map[string][]string{
+	"skills-2025-10-02": {"code-execution-2025-08-25"},
+}
+
+// Validate reports the conflicting betas and the missing prerequisites in
+// betas, joined with [errors.Join]. Unknown betas are not reported.
+func Validate(betas []string) error {
+	enabled := make(map[string]bool, len(betas))
+	for _, beta := range betas {
+		enabled[strings.TrimSpace(beta)] = true
+	}
+
+	var errs []error
+	for _, pair := range conflicts {
+		if enabled[pair[0]] && enabled[pair[1]] {
+			errs = append(errs, fmt.Errorf("beta %s cannot be combined with %s", pair[0], pair[1]))
+		}
+	}
+	// Report missing prerequisites in a stable order.
+	for _, beta := range slices.Sorted(maps.Keys(requires)) {
+		if !enabled[beta] {
+			continue
+		}
+		if !slices.ContainsFunc(requires[beta], func(required string) bool { return enabled[required] }) {
+			errs = append(errs, fmt.Errorf("beta %s requires %s", beta, strings.Join(requires[beta], " or ")))
+		}
+	}
+	return errors.Join(errs...)
+}
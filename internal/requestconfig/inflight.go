@@ -0,0 +1,52 @@
+package requestconfig
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// errCanceledByClient is the cause of the contexts cancelled by
+// [InFlight.CancelAll].
+var errCanceledByClient = fmt.Errorf("%w by the client", context.Canceled)
+
+// InFlight tracks the requests of a client which have not completed, so that
+// they can all be cancelled at once. It is safe for concurrent use.
+type InFlight struct {
+	mu      sync.Mutex
+	next    uint64
+	cancels map[uint64]context.CancelCauseFunc
+}
+
+// track derives a context from ctx which is cancelled by CancelAll until the
+// returned release func is called.
+func (f *InFlight) track(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cancels == nil {
+		f.cancels = map[uint64]context.CancelCauseFunc{}
+	}
+	id := f.next
+	f.next++
+	f.cancels[id] = cancel
+	return ctx, func() {
+		f.mu.Lock()
+		delete(f.cancels, id)
+		f.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// CancelAll cancels the context of every tracked request, and returns how many
+// were cancelled. Requests started afterwards are not affected.
+func (f *InFlight) CancelAll() int {
+	f.mu.Lock()
+	cancels := f.cancels
+	f.cancels = nil
+	f.mu.Unlock()
+	for _, cancel := range cancels {
+		cancel(errCanceledByClient)
+	}
+	return len(cancels)
+}
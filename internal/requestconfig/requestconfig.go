@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -14,14 +15,17 @@ import (
 	"net/http"
 	"net/url"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sofianhadi1983/anthropic-sdk-go/internal"
 	"github.com/sofianhadi1983/anthropic-sdk-go/internal/apierror"
 	"github.com/sofianhadi1983/anthropic-sdk-go/internal/apiform"
 	"github.com/sofianhadi1983/anthropic-sdk-go/internal/apiquery"
+	"github.com/tidwall/gjson"
 )
 
 func getDefaultHeaders() map[string]string {
@@ -214,6 +218,44 @@ type RequestConfig struct {
 	Middlewares    []middleware
 	APIKey         string
 	AuthToken      string
+	// CustomAuth is set by options which authenticate requests in middleware
+	// (e.g. Amazon Bedrock or Google Vertex AI) rather than through APIKey or
+	// AuthToken.
+	CustomAuth bool
+	// OmittedBetas are removed from the anthropic-beta header immediately before
+	// the request is sent, after every middleware has run.
+	OmittedBetas []string
+	// CapturedRequest receives a copy of the final request sent over the wire,
+	// with its body buffered. Credentials are redacted unless
+	// CaptureCredentials is set.
+	CapturedRequest    **http.Request
+	CaptureCredentials bool
+	// StreamIdleTimeout, if non-zero, fails a streaming response when no data
+	// arrives for the given duration.
+	StreamIdleTimeout time.Duration
+	// BodyWarnFunc, if set, is called with the size of the request body when it
+	// exceeds BodyWarnThreshold bytes. Requests whose body exceeds BodyLimit
+	// bytes fail without being sent.
+	BodyWarnThreshold int64
+	BodyWarnFunc      func(size int64)
+	BodyLimit         int64
+	// UserAgentMetadata holds platform tags which are appended to the final
+	// User-Agent header, after every middleware has run.
+	UserAgentMetadata map[string]string
+	// RetryModifier, if set, is called with the request before each retry.
+	RetryModifier func(req *http.Request, attempt int, lastErr error)
+	// RetryBackoff, if set, replaces the default delays between retries.
+	RetryBackoff *RetryBackoff
+	// CallTimeout, if non-zero, bounds the whole call, retries included. For a
+	// streaming response it only runs until the first data of the stream.
+	CallTimeout time.Duration
+	// TotalDeadline, if non-zero, bounds every attempt and the retry delays of
+	// a call, and stops retrying early when the next attempt could not finish
+	// in the remaining time.
+	TotalDeadline time.Duration
+	// InFlight, if set, tracks the request until Execute returns or, if the
+	// response is read elsewhere, until its body is closed.
+	InFlight *InFlight
 	// If ResponseBodyInto not nil, then we will attempt to deserialize into
 	// ResponseBodyInto. If Destination is a []byte, then it will return the body as
 	// is.
@@ -238,6 +280,94 @@ func applyMiddleware(middleware middleware, next middlewareNext) middlewareNext
 	}
 }
 
+// omitBetas wraps next so that the given betas are stripped from the
+// anthropic-beta header. It is installed as the innermost handler so that betas
+// merged in by middleware (such as the oauth defaults) can be removed too.
+func omitBetas(omit []string, next middlewareNext) middlewareNext {
+	return func(req *http.Request) (*http.Response, error) {
+		values := req.Header.Values("anthropic-beta")
+		if len(values) == 0 {
+			return next(req)
+		}
+		var kept []string
+		for _, value := range values {
+			for _, beta := range strings.Split(value, ",") {
+				beta = strings.TrimSpace(beta)
+				if beta != "" && !slices.Contains(omit, beta) {
+					kept = append(kept, beta)
+				}
+			}
+		}
+		if len(kept) == 0 {
+			req.Header.Del("anthropic-beta")
+		} else {
+			req.Header.Set("anthropic-beta", strings.Join(kept, ","))
+		}
+		return next(req)
+	}
+}
+
+// appendUserAgentMetadata wraps next so that the given tags are appended to the
+// User-Agent header as "(key=value; ...)". Like omitBetas it is installed as an
+// innermost handler, so that it also applies to a User-Agent set by middleware.
+func appendUserAgentMetadata(metadata map[string]string, next middlewareNext) middlewareNext {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	tags := make([]string, len(keys))
+	for i, k := range keys {
+		tags[i] = k + "=" + metadata[k]
+	}
+	suffix := "(" + strings.Join(tags, "; ") + ")"
+
+	return func(req *http.Request) (*http.Response, error) {
+		if ua := req.Header.Get("User-Agent"); ua != "" {
+			req.Header.Set("User-Agent", ua+" "+suffix)
+		} else {
+			req.Header.Set("User-Agent", suffix)
+		}
+		return next(req)
+	}
+}
+
+// CredentialHeaders are redacted from captured requests by default.
+var CredentialHeaders = []string{"Authorization", "Proxy-Authorization", "X-Api-Key", "X-Amz-Security-Token"}
+
+// captureRequest wraps next so that a copy of each outgoing request, with a
+// re-readable body, is stored in dst. Each retry overwrites the previous copy.
+func captureRequest(dst **http.Request, withCredentials bool, next middlewareNext) middlewareNext {
+	return func(req *http.Request) (*http.Response, error) {
+		var body []byte
+		if req.Body != nil && req.Body != http.NoBody {
+			var err error
+			body, err = io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		captured := req.Clone(req.Context())
+		if body != nil {
+			captured.Body = io.NopCloser(bytes.NewReader(body))
+			captured.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		}
+		if !withCredentials {
+			for _, h := range CredentialHeaders {
+				if captured.Header.Get(h) != "" {
+					captured.Header.Set(h, "[REDACTED]")
+				}
+			}
+		}
+		*dst = captured
+
+		return next(req)
+	}
+}
+
 func shouldRetry(req *http.Request, res *http.Response) bool {
 	// If there is no way to recover the Body, then we shouldn't retry.
 	if req.Body != nil && req.GetBody == nil {
@@ -355,7 +485,210 @@ func (b *bodyWithTimeout) Close() error {
 	return err
 }
 
-func retryDelay(res *http.Response, retryCount int) time.Duration {
+// bodyWithCallTimeout is the body of a response read after Execute returns,
+// which holds the call timeout. For a stream, the timeout is stopped once the
+// first data arrives. Reads failing because of the timeout return its cause.
+type bodyWithCallTimeout struct {
+	rc     io.ReadCloser
+	ctx    context.Context
+	timer  *time.Timer
+	cancel context.CancelCauseFunc
+	stream bool
+}
+
+func (b *bodyWithCallTimeout) Read(p []byte) (n int, err error) {
+	n, err = b.rc.Read(p)
+	if n > 0 && b.stream {
+		b.timer.Stop()
+	}
+	if err != nil && err != io.EOF && b.ctx.Err() != nil {
+		err = context.Cause(b.ctx)
+	}
+	return n, err
+}
+
+func (b *bodyWithCallTimeout) Close() error {
+	err := b.rc.Close()
+	b.timer.Stop()
+	b.cancel(nil)
+	return err
+}
+
+// ErrRequestBodyTooLarge is returned when the request body exceeds the
+// configured BodyLimit.
+var ErrRequestBodyTooLarge = errors.New("request body too large")
+
+// bodyWithLimit is a request body of unknown size which fails once more than
+// limit bytes have been read from it.
+type bodyWithLimit struct {
+	rc    io.ReadCloser
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (b *bodyWithLimit) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if b.read += int64(n); b.read > b.limit {
+		return 0, NotRetryable(fmt.Errorf("%w: more than %d bytes", ErrRequestBodyTooLarge, b.limit))
+	}
+	return n, err
+}
+
+func (b *bodyWithLimit) Close() error {
+	return b.rc.Close()
+}
+
+// ErrNotRetryable is wrapped by errors returned from middleware which must fail
+// the request rather than be retried like a connection error. See
+// [NotRetryable].
+var ErrNotRetryable = errors.New("not retryable")
+
+// NotRetryable returns an error with the message of err which also wraps
+// [ErrNotRetryable].
+func NotRetryable(err error) error {
+	return notRetryableError{err}
+}
+
+type notRetryableError struct{ err error }
+
+func (e notRetryableError) Error() string   { return e.err.Error() }
+func (e notRetryableError) Unwrap() []error { return []error{e.err, ErrNotRetryable} }
+
+// StopReasonClientOutputCap is the stop reason of streamed messages ended by
+// the hard output cap, shared by the option and anthropic packages.
+const StopReasonClientOutputCap = "client_output_cap"
+
+// ErrStreamIdleTimeout is returned when a stream receives no data, including
+// ping events, within the configured idle timeout.
+var ErrStreamIdleTimeout = errors.New("stream idle timeout")
+
+// bodyWithIdleTimeout is an io.ReadCloser which closes the wrapped body if a
+// single read blocks for longer than timeout. Only time spent waiting on the
+// network counts, so slow consumers do not trip the timeout.
+type bodyWithIdleTimeout struct {
+	rc      io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+
+	// mu guards reading and expired, which the timer shares with Read.
+	mu      sync.Mutex
+	reading bool
+	expired bool
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newBodyWithIdleTimeout(rc io.ReadCloser, timeout time.Duration) *bodyWithIdleTimeout {
+	b := &bodyWithIdleTimeout{rc: rc, timeout: timeout}
+	b.timer = time.AfterFunc(timeout, b.expire)
+	b.timer.Stop()
+	return b
+}
+
+// expire closes the wrapped body to unblock the read in progress. A timer
+// firing just as the read returns is ignored.
+func (b *bodyWithIdleTimeout) expire() {
+	b.mu.Lock()
+	if !b.reading {
+		b.mu.Unlock()
+		return
+	}
+	b.expired = true
+	b.mu.Unlock()
+	b.close()
+}
+
+func (b *bodyWithIdleTimeout) Read(p []byte) (n int, err error) {
+	b.mu.Lock()
+	if b.expired {
+		b.mu.Unlock()
+		return 0, b.expiredErr()
+	}
+	b.reading = true
+	b.timer.Reset(b.timeout)
+	b.mu.Unlock()
+
+	n, err = b.rc.Read(p)
+
+	b.mu.Lock()
+	b.reading = false
+	b.timer.Stop()
+	expired := b.expired
+	b.mu.Unlock()
+	if expired {
+		return n, b.expiredErr()
+	}
+	return n, err
+}
+
+func (b *bodyWithIdleTimeout) expiredErr() error {
+	return fmt.Errorf("%w: no data received for %s", ErrStreamIdleTimeout, b.timeout)
+}
+
+func (b *bodyWithIdleTimeout) Close() error {
+	b.timer.Stop()
+	return b.close()
+}
+
+// close closes the wrapped body once, whether the timer or the caller gets
+// there first.
+func (b *bodyWithIdleTimeout) close() error {
+	b.closeOnce.Do(func() { b.closeErr = b.rc.Close() })
+	return b.closeErr
+}
+
+// modifyRetry calls the RetryModifier with the request for the next attempt. If
+// the modifier replaces the body, it is buffered so that it can be read again on
+// later retries.
+func (cfg *RequestConfig) modifyRetry(attempt int, lastErr error) error {
+	body := cfg.Request.Body
+	cfg.RetryModifier(cfg.Request, attempt, lastErr)
+	if cfg.Request.Body == body || cfg.Request.Body == nil || cfg.Request.Body == http.NoBody {
+		return nil
+	}
+	b, err := io.ReadAll(cfg.Request.Body)
+	cfg.Request.Body.Close()
+	if err != nil {
+		return fmt.Errorf("requestconfig: reading modified request body: %w", err)
+	}
+	cfg.Request.ContentLength = int64(len(b))
+	cfg.Request.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(b)), nil }
+	cfg.Request.Body, _ = cfg.Request.GetBody()
+	return nil
+}
+
+// RetryBackoff configures the delays between retries. The delay before retry
+// n, counting from 0, is Initial * 2^n, capped at Max, reduced by a random
+// fraction of up to Jitter. A Retry-After header is followed instead, capped at
+// Max.
+type RetryBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Jitter  float64
+}
+
+// Delay returns the delay before retry n, counting from 0.
+func (b *RetryBackoff) Delay(n int) time.Duration {
+	return retryDelay(nil, n, b)
+}
+
+func retryDelay(res *http.Response, retryCount int, backoff *RetryBackoff) time.Duration {
+	if backoff != nil {
+		if retryAfterDelay, ok := parseRetryAfterHeader(res); ok && 0 <= retryAfterDelay {
+			return min(retryAfterDelay, backoff.Max)
+		}
+		delay := time.Duration(float64(backoff.Initial) * math.Pow(2, float64(retryCount)))
+		if delay > backoff.Max || delay < 0 {
+			delay = backoff.Max
+		}
+		if jitter := int64(float64(delay) * backoff.Jitter); jitter > 0 {
+			delay -= time.Duration(rand.Int63n(jitter))
+		}
+		return delay
+	}
+
 	// If the API asks us to wait a certain amount of time (and it's a reasonable amount),
 	// just do what it says.
 
@@ -411,10 +744,70 @@ func (cfg *RequestConfig) Execute() (err error) {
 		}
 	}
 
+	if size := cfg.Request.ContentLength; size > 0 {
+		if cfg.BodyLimit > 0 && size > cfg.BodyLimit {
+			return fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", ErrRequestBodyTooLarge, size, cfg.BodyLimit)
+		}
+		if cfg.BodyWarnFunc != nil && size > cfg.BodyWarnThreshold {
+			cfg.BodyWarnFunc(size)
+		}
+	} else if cfg.BodyLimit > 0 && cfg.Request.Body != nil && cfg.Request.Body != http.NoBody {
+		// The size of a streamed body is only known once it has been read.
+		cfg.Request.Body = &bodyWithLimit{rc: cfg.Request.Body, r: io.LimitReader(cfg.Request.Body, cfg.BodyLimit+1), limit: cfg.BodyLimit}
+	}
+
+	// The request is released when Execute returns, unless it is handed off to
+	// the body of a response which is read elsewhere.
+	var release func()
+	if cfg.InFlight != nil {
+		var ctx context.Context
+		ctx, release = cfg.InFlight.track(cfg.Request.Context())
+		cfg.Request = cfg.Request.WithContext(ctx)
+		defer func() {
+			if release != nil {
+				release()
+			}
+		}()
+	}
+
+	// The call timeout is stopped when Execute returns, unless it is handed off
+	// to the body of a response which is read elsewhere.
+	var callTimer *time.Timer
+	var callCancel context.CancelCauseFunc
+	if cfg.CallTimeout > 0 {
+		var ctx context.Context
+		ctx, callCancel = context.WithCancelCause(cfg.Request.Context())
+		cause := fmt.Errorf("%w: call timeout of %s", context.DeadlineExceeded, cfg.CallTimeout)
+		callTimer = time.AfterFunc(cfg.CallTimeout, func() { callCancel(cause) })
+		cfg.Request = cfg.Request.WithContext(ctx)
+		defer func() {
+			if callCancel != nil {
+				callTimer.Stop()
+				callCancel(nil)
+			}
+		}()
+	}
+
+	var deadline time.Time
+	var deadlineCause error
+	if cfg.TotalDeadline > 0 {
+		deadline = time.Now().Add(cfg.TotalDeadline)
+		deadlineCause = fmt.Errorf("%w: total deadline of %s", context.DeadlineExceeded, cfg.TotalDeadline)
+	}
+
 	handler := cfg.HTTPClient.Do
 	if cfg.CustomHTTPDoer != nil {
 		handler = cfg.CustomHTTPDoer.Do
 	}
+	if cfg.CapturedRequest != nil {
+		handler = captureRequest(cfg.CapturedRequest, cfg.CaptureCredentials, handler)
+	}
+	if len(cfg.OmittedBetas) > 0 {
+		handler = omitBetas(cfg.OmittedBetas, handler)
+	}
+	if len(cfg.UserAgentMetadata) > 0 {
+		handler = appendUserAgentMetadata(cfg.UserAgentMetadata, handler)
+	}
 	for i := len(cfg.Middlewares) - 1; i >= 0; i -= 1 {
 		handler = applyMiddleware(cfg.Middlewares[i], handler)
 	}
@@ -426,8 +819,16 @@ func (cfg *RequestConfig) Execute() (err error) {
 	var cancel context.CancelFunc
 	for retryCount := 0; retryCount <= cfg.MaxRetries; retryCount += 1 {
 		ctx := cfg.Request.Context()
-		if cfg.RequestTimeout != time.Duration(0) && isBeforeContextDeadline(time.Now().Add(cfg.RequestTimeout), ctx) {
-			ctx, cancel = context.WithTimeout(ctx, cfg.RequestTimeout)
+		attemptStart := time.Now()
+		attemptDeadline, cause := time.Time{}, context.DeadlineExceeded
+		if cfg.RequestTimeout != time.Duration(0) {
+			attemptDeadline = attemptStart.Add(cfg.RequestTimeout)
+		}
+		if !deadline.IsZero() && (attemptDeadline.IsZero() || deadline.Before(attemptDeadline)) {
+			attemptDeadline, cause = deadline, deadlineCause
+		}
+		if !attemptDeadline.IsZero() && isBeforeContextDeadline(attemptDeadline, ctx) {
+			ctx, cancel = context.WithDeadlineCause(ctx, attemptDeadline, cause)
 			defer func() {
 				// The cancel function is nil if it was handed off to be handled in a different scope.
 				if cancel != nil {
@@ -443,11 +844,19 @@ func (cfg *RequestConfig) Execute() (err error) {
 
 		res, err = handler(req)
 		if ctx != nil && ctx.Err() != nil {
-			return ctx.Err()
+			return context.Cause(ctx)
+		}
+		if errors.Is(err, ErrNotRetryable) {
+			break
 		}
 		if !shouldRetry(cfg.Request, res) || retryCount >= cfg.MaxRetries {
 			break
 		}
+		// The next attempt is expected to take as long as this one.
+		delay := retryDelay(res, retryCount, cfg.RetryBackoff)
+		if !deadline.IsZero() && time.Now().Add(delay+time.Since(attemptStart)).After(deadline) {
+			break
+		}
 
 		// Prepare next request and wait for the retry delay
 		if cfg.Request.GetBody != nil {
@@ -467,7 +876,17 @@ func (cfg *RequestConfig) Execute() (err error) {
 			res.Body.Close()
 		}
 
-		time.Sleep(retryDelay(res, retryCount))
+		time.Sleep(delay)
+
+		if cfg.RetryModifier != nil {
+			lastErr := err
+			if lastErr == nil && res != nil {
+				lastErr = fmt.Errorf("received status code %d", res.StatusCode)
+			}
+			if err := cfg.modifyRetry(retryCount+1, lastErr); err != nil {
+				return err
+			}
+		}
 	}
 
 	// Save *http.Response if it is requested to, even if there was an error making the request. This is
@@ -511,16 +930,36 @@ func (cfg *RequestConfig) Execute() (err error) {
 		// We aren't reading the response body in this scope, but whoever is will need the
 		// cancel func from the context to observe request timeouts.
 		// Put the cancel function in the response body so it can be handled elsewhere.
+		if cfg.StreamIdleTimeout > 0 && strings.HasPrefix(res.Header.Get("content-type"), "text/event-stream") {
+			res.Body = newBodyWithIdleTimeout(res.Body, cfg.StreamIdleTimeout)
+		}
 		if cancel != nil {
 			res.Body = &bodyWithTimeout{rc: res.Body, stop: cancel}
 			cancel = nil
 		}
+		if callCancel != nil {
+			res.Body = &bodyWithCallTimeout{
+				rc:     res.Body,
+				ctx:    cfg.Request.Context(),
+				timer:  callTimer,
+				cancel: callCancel,
+				stream: strings.HasPrefix(res.Header.Get("content-type"), "text/event-stream"),
+			}
+			callCancel = nil
+		}
+		if release != nil {
+			res.Body = &bodyWithTimeout{rc: res.Body, stop: release}
+			release = nil
+		}
 		return nil
 	}
 
 	contents, err := io.ReadAll(res.Body)
 	res.Body.Close()
 	if err != nil {
+		if ctx := cfg.Request.Context(); ctx.Err() != nil {
+			err = context.Cause(ctx)
+		}
 		return fmt.Errorf("error reading response body: %w", err)
 	}
 
@@ -578,20 +1017,48 @@ func (cfg *RequestConfig) Clone(ctx context.Context) *RequestConfig {
 		return nil
 	}
 	new := &RequestConfig{
-		MaxRetries:     cfg.MaxRetries,
-		RequestTimeout: cfg.RequestTimeout,
-		Context:        ctx,
-		Request:        req,
-		BaseURL:        cfg.BaseURL,
-		HTTPClient:     cfg.HTTPClient,
-		Middlewares:    cfg.Middlewares,
-		APIKey:         cfg.APIKey,
-		AuthToken:      cfg.AuthToken,
+		MaxRetries:        cfg.MaxRetries,
+		RequestTimeout:    cfg.RequestTimeout,
+		Context:           ctx,
+		Request:           req,
+		BaseURL:           cfg.BaseURL,
+		HTTPClient:        cfg.HTTPClient,
+		Middlewares:       cfg.Middlewares,
+		APIKey:            cfg.APIKey,
+		AuthToken:         cfg.AuthToken,
+		CustomAuth:        cfg.CustomAuth,
+		OmittedBetas:      cfg.OmittedBetas,
+		StreamIdleTimeout: cfg.StreamIdleTimeout,
+		BodyWarnThreshold: cfg.BodyWarnThreshold,
+		BodyWarnFunc:      cfg.BodyWarnFunc,
+		BodyLimit:         cfg.BodyLimit,
+		UserAgentMetadata: cfg.UserAgentMetadata,
+		RetryModifier:     cfg.RetryModifier,
+		RetryBackoff:      cfg.RetryBackoff,
+		CallTimeout:       cfg.CallTimeout,
+		TotalDeadline:     cfg.TotalDeadline,
+		InFlight:          cfg.InFlight,
 	}
 
 	return new
 }
 
+// RewriteJSONBody applies fn to the serialized JSON body of the request. Requests
+// without a JSON body, such as GET requests or file uploads, are left untouched
+// so that options built on it can safely be supplied at the client level.
+func (cfg *RequestConfig) RewriteJSONBody(fn func(body []byte) ([]byte, error)) error {
+	buffer, ok := cfg.Body.(*bytes.Buffer)
+	if !ok || !gjson.ValidBytes(buffer.Bytes()) {
+		return nil
+	}
+	b, err := fn(buffer.Bytes())
+	if err != nil {
+		return err
+	}
+	cfg.Body = bytes.NewBuffer(b)
+	return nil
+}
+
 func (cfg *RequestConfig) Apply(opts ...RequestOption) error {
 	for _, opt := range opts {
 		err := opt.Apply(cfg)
@@ -2,10 +2,12 @@ package requestconfig
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/sofianhadi1983/anthropic-sdk-go/internal/apierror"
 	"github.com/stretchr/testify/assert"
@@ -59,3 +61,50 @@ func TestErrorWithRequestID(t *testing.T) {
 	errorMsg := apiErr.Error()
 	assert.Contains(t, errorMsg, "Request-ID: req_123456789", "Error message should contain request ID")
 }
+
+// stallingBody yields the chunks sent on data and blocks otherwise, until it is
+// closed. Closing it twice panics.
+type stallingBody struct {
+	data   chan []byte
+	closed chan struct{}
+}
+
+func (b *stallingBody) Read(p []byte) (int, error) {
+	select {
+	case chunk := <-b.data:
+		return copy(p, chunk), nil
+	case <-b.closed:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+func (b *stallingBody) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestBodyWithIdleTimeout(t *testing.T) {
+	body := &stallingBody{data: make(chan []byte, 1), closed: make(chan struct{})}
+	b := newBodyWithIdleTimeout(body, 20*time.Millisecond)
+	buf := make([]byte, 16)
+
+	body.data <- []byte("ping")
+	n, err := b.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf[:n]))
+
+	// A consumer slower than the timeout does not trip it.
+	time.Sleep(40 * time.Millisecond)
+	body.data <- []byte("pong")
+	n, err = b.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(buf[:n]))
+
+	_, err = b.Read(buf)
+	assert.ErrorIs(t, err, ErrStreamIdleTimeout)
+	_, err = b.Read(buf)
+	assert.ErrorIs(t, err, ErrStreamIdleTimeout)
+
+	// The body was closed by the timer, and is not closed again.
+	assert.NoError(t, b.Close())
+}
@@ -0,0 +1,183 @@
+// Package streamsplice joins the event streams of a streaming message request
+// which is sent again, with the text received so far as an assistant prefill,
+// after its connection breaks, so that they read as the stream of a single
+// message. It is shared by anthropic.ResilientStream and
+// option.WithStreamReconnect.
+package streamsplice
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Splicer adjusts the events of each connection to continue the message of
+// the previous ones. The zero value is ready for the first connection.
+type Splicer struct {
+	started bool
+	stopped bool
+	// usage is the usage of the message_start event of the first connection.
+	usage  gjson.Result
+	blocks []*block
+	// indexOffset is added to the content block indexes of the current
+	// connection.
+	indexOffset int64
+	// continueText is set when the current connection continues a text block
+	// left open by the previous one, and trimmed is the whitespace trimmed from
+	// the end of its prefill, which is not delivered again.
+	continueText bool
+	trimmed      string
+
+	// outputTokens is the number of output tokens of the previous connections.
+	// connOutputTokens is the last count reported by the current connection,
+	// and connText the text it delivered, from which its output tokens are
+	// estimated if it breaks before reporting them.
+	outputTokens     int64
+	connOutputTokens int64
+	connText         int
+	resumed          bool
+}
+
+type block struct {
+	typ  string
+	text strings.Builder
+	open bool
+}
+
+// Splice returns the data of the events to deliver in place of the event of
+// the current connection with the given data: none if it repeats an event
+// already delivered, or more than one if a block left open must be closed
+// first. The data is returned unchanged when it needs no adjusting.
+func (s *Splicer) Splice(data string) []string {
+	var out []string
+	event := gjson.Parse(data)
+	index := event.Get("index").Int() + s.indexOffset
+	switch event.Get("type").String() {
+	case "message_start":
+		s.connOutputTokens = event.Get("message.usage.output_tokens").Int()
+		if s.started {
+			return nil
+		}
+		s.started = true
+		s.usage = event.Get("message.usage")
+		return []string{data}
+	case "content_block_start":
+		if s.continueText {
+			s.continueText = false
+			if event.Get("content_block.type").String() == "text" {
+				return nil
+			}
+			// The model did not continue the text, so close that block first.
+			out = append(out, fmt.Sprintf(`{"type":"content_block_stop","index":%d}`, s.indexOffset))
+			s.blocks[s.indexOffset].open = false
+			s.trimmed = ""
+			s.indexOffset++
+			index++
+		}
+		s.blocks = append(s.blocks, &block{typ: event.Get("content_block.type").String(), open: true})
+	case "content_block_delta":
+		if int(index) >= len(s.blocks) || event.Get("delta.type").String() != "text_delta" {
+			break
+		}
+		text := event.Get("delta.text").String()
+		if s.trimmed != "" {
+			n := 0
+			for n < len(text) && n < len(s.trimmed) && text[n] == s.trimmed[n] {
+				n++
+			}
+			if text = text[n:]; text == "" {
+				return nil
+			}
+			s.trimmed = ""
+			data, _ = sjson.Set(data, "delta.text", text)
+		}
+		s.blocks[index].text.WriteString(text)
+		s.connText += utf8.RuneCountInString(text)
+	case "content_block_stop":
+		if int(index) < len(s.blocks) {
+			s.blocks[index].open = false
+		}
+	case "message_delta":
+		s.connOutputTokens = event.Get("usage.output_tokens").Int()
+		if !s.resumed {
+			return []string{data}
+		}
+		// The usage is reported as if the request had been sent once: the
+		// prefill is not counted as input, and the output of the previous
+		// connections is.
+		for _, field := range []string{"input_tokens", "cache_creation_input_tokens", "cache_read_input_tokens"} {
+			if !event.Get("usage." + field).Exists() {
+				continue
+			}
+			if v := s.usage.Get(field); v.Exists() {
+				data, _ = sjson.SetRaw(data, "usage."+field, v.Raw)
+			}
+		}
+		if event.Get("usage.output_tokens").Exists() {
+			data, _ = sjson.Set(data, "usage.output_tokens", s.outputTokens+s.connOutputTokens)
+		}
+		return []string{data}
+	case "message_stop", "error":
+		s.stopped = true
+		return []string{data}
+	default:
+		return []string{data}
+	}
+	if s.indexOffset != 0 {
+		data, _ = sjson.Set(data, "index", index)
+	}
+	return append(out, data)
+}
+
+// Stopped reports whether the message_stop event, or an error event, was
+// delivered, after which the stream must not be resumed.
+func (s *Splicer) Stopped() bool { return s.stopped }
+
+// Prefill returns the text of the blocks delivered so far, to send as the
+// content of an assistant prefill, with empty blocks left out. It reports
+// false if the stream cannot be resumed: when a block other than text was
+// started, or when thinking, which must start the assistant turn, is enabled
+// and content was delivered. The whitespace at the end of a text block cut
+// short is trimmed, as the API rejects it.
+func (s *Splicer) Prefill(thinking bool) ([]string, bool) {
+	if len(s.blocks) > 0 && thinking {
+		return nil, false
+	}
+	var prefill []string
+	for i, block := range s.blocks {
+		if block.typ != "text" || (block.open && i != len(s.blocks)-1) {
+			return nil, false
+		}
+		text := block.text.String()
+		if block.open {
+			text = strings.TrimRight(text, " \t\r\n")
+		}
+		if text != "" {
+			prefill = append(prefill, text)
+		}
+	}
+	return prefill, true
+}
+
+// Resume prepares the splicer for the events of a new connection, sent with
+// the prefill returned by Prefill.
+func (s *Splicer) Resume() {
+	// The output tokens reported so far may not cover the text delivered
+	// since, which is estimated at four characters per token, as
+	// anthropic.EstimateTokens does.
+	s.outputTokens += max(s.connOutputTokens, int64((s.connText+3)/4))
+	s.connOutputTokens, s.connText = 0, 0
+	s.resumed = true
+
+	s.indexOffset = int64(len(s.blocks))
+	s.continueText, s.trimmed = false, ""
+	if last := len(s.blocks) - 1; last >= 0 && s.blocks[last].open {
+		text := s.blocks[last].text.String()
+		s.continueText = true
+		s.trimmed = text[len(strings.TrimRight(text, " \t\r\n")):]
+		s.indexOffset--
+	}
+}
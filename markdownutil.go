@@ -0,0 +1,98 @@
+package anthropic
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	markdownFence      = regexp.MustCompile("^\\s*(```|~~~)")
+	markdownHeading    = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	markdownQuote      = regexp.MustCompile(`^\s{0,3}(>\s?)+`)
+	markdownBullet     = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	markdownRule       = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	markdownImage      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLink       = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	markdownInlineCode = regexp.MustCompile("`+([^`]+)`+")
+	markdownStrong     = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	markdownStrike     = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	markdownEmStar     = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	markdownEmUnder    = regexp.MustCompile(`(^|[^\w])_(\S(?:[^_]*?\S)?)_([^\w]|$)`)
+)
+
+// markdownStripper incrementally converts markdown text into plain text.
+//
+// Markdown syntax can be split arbitrarily across stream deltas, so input is
+// buffered until a full line is available before it is converted. Text inside
+// fenced code blocks is passed through verbatim, without the fences.
+type markdownStripper struct {
+	buf     strings.Builder
+	inFence bool
+}
+
+// Write adds a chunk of markdown and returns any plain text that is ready to
+// be emitted.
+func (m *markdownStripper) Write(chunk string) string {
+	m.buf.WriteString(chunk)
+	pending := m.buf.String()
+	idx := strings.LastIndexByte(pending, '\n')
+	if idx < 0 {
+		return ""
+	}
+
+	m.buf.Reset()
+	m.buf.WriteString(pending[idx+1:])
+
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(pending[:idx+1], "\n") {
+		if line == "" {
+			continue
+		}
+		out.WriteString(m.stripLine(line))
+	}
+	return out.String()
+}
+
+// Flush returns the plain text for any remaining buffered input.
+func (m *markdownStripper) Flush() string {
+	rest := m.buf.String()
+	m.buf.Reset()
+	if rest == "" {
+		return ""
+	}
+	return m.stripLine(rest)
+}
+
+func (m *markdownStripper) stripLine(line string) string {
+	body := strings.TrimRight(line, "\r\n")
+	newline := line[len(body):]
+
+	if markdownFence.MatchString(body) {
+		m.inFence = !m.inFence
+		return ""
+	}
+	if m.inFence {
+		return line
+	}
+	if markdownRule.MatchString(body) {
+		return newline
+	}
+
+	body = markdownHeading.ReplaceAllString(body, "")
+	body = markdownQuote.ReplaceAllString(body, "")
+	body = markdownBullet.ReplaceAllString(body, "$1")
+	return stripInlineMarkdown(body) + newline
+}
+
+// stripInlineMarkdown removes inline markdown formatting (links, images,
+// code spans and emphasis) from a single line of text.
+func stripInlineMarkdown(s string) string {
+	s = markdownImage.ReplaceAllString(s, "$1")
+	s = markdownLink.ReplaceAllString(s, "$1")
+	s = markdownInlineCode.ReplaceAllString(s, "$1")
+	s = markdownStrong.ReplaceAllString(s, "$2")
+	s = markdownStrike.ReplaceAllString(s, "$1")
+	s = markdownEmStar.ReplaceAllString(s, "$1")
+	s = markdownEmUnder.ReplaceAllString(s, "$1$2$3")
+	return s
+}
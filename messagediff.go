@@ -0,0 +1,200 @@
+package anthropic
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// MessageDiff describes the differences between two responses, typically to
+// the same prompt from different models or prompt versions. See [DiffMessages].
+type MessageDiff struct {
+	// Text is a word-level diff of the text content of the two messages.
+	Text []TextDiff
+	// ToolCalls lists the tool calls which differ, compared by position.
+	ToolCalls []ToolCallDiff
+	// StopReasons holds the stop reasons of the two messages if they differ.
+	StopReasons []StopReason
+	// Usage is the difference in token usage, b minus a.
+	Usage UsageDiff
+}
+
+// Equal reports whether the two messages have the same text, tool calls and
+// stop reason. Differences in token usage are ignored.
+func (d MessageDiff) Equal() bool {
+	for _, t := range d.Text {
+		if t.Op != TextDiffEqual {
+			return false
+		}
+	}
+	return len(d.ToolCalls) == 0 && len(d.StopReasons) == 0
+}
+
+// String renders the text diff with deleted words as [-word-] and inserted
+// words as {+word+}.
+func (d MessageDiff) String() string {
+	var b strings.Builder
+	for i, t := range d.Text {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		switch t.Op {
+		case TextDiffDelete:
+			b.WriteString("[-" + t.Text + "-]")
+		case TextDiffInsert:
+			b.WriteString("{+" + t.Text + "+}")
+		default:
+			b.WriteString(t.Text)
+		}
+	}
+	return b.String()
+}
+
+type TextDiffOp string
+
+const (
+	TextDiffEqual  TextDiffOp = "equal"
+	TextDiffDelete TextDiffOp = "delete"
+	TextDiffInsert TextDiffOp = "insert"
+)
+
+// TextDiff is a run of words which are equal in both messages, only in the
+// first (deleted) or only in the second (inserted). Words are separated by
+// single spaces.
+type TextDiff struct {
+	Op   TextDiffOp
+	Text string
+}
+
+// ToolCallDiff describes the tool call at Index in each message. A is nil if
+// only the second message has a tool call at that position, and B is nil if
+// only the first one does.
+type ToolCallDiff struct {
+	Index int
+	A, B  *ToolUseBlock
+}
+
+// UsageDiff is the difference in token usage between two messages.
+type UsageDiff struct {
+	InputTokens              int64
+	OutputTokens             int64
+	CacheCreationInputTokens int64
+	CacheReadInputTokens     int64
+}
+
+// DiffMessages compares two messages, reporting a word-level diff of their
+// text, the tool calls whose name or input differ, and the difference in token
+// usage. Tool inputs are compared as JSON values, so formatting and key order do
+// not matter.
+func DiffMessages(a, b *Message) MessageDiff {
+	var d MessageDiff
+	d.Text = diffWords(strings.Fields(messageText(a)), strings.Fields(messageText(b)))
+
+	toolsA, toolsB := messageToolUses(a), messageToolUses(b)
+	for i := range max(len(toolsA), len(toolsB)) {
+		var ta, tb *ToolUseBlock
+		if i < len(toolsA) {
+			ta = &toolsA[i]
+		}
+		if i < len(toolsB) {
+			tb = &toolsB[i]
+		}
+		if ta == nil || tb == nil || ta.Name != tb.Name || !jsonEqual(ta.Input, tb.Input) {
+			d.ToolCalls = append(d.ToolCalls, ToolCallDiff{Index: i, A: ta, B: tb})
+		}
+	}
+
+	if a.StopReason != b.StopReason {
+		d.StopReasons = []StopReason{a.StopReason, b.StopReason}
+	}
+
+	d.Usage = UsageDiff{
+		InputTokens:              b.Usage.InputTokens - a.Usage.InputTokens,
+		OutputTokens:             b.Usage.OutputTokens - a.Usage.OutputTokens,
+		CacheCreationInputTokens: b.Usage.CacheCreationInputTokens - a.Usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     b.Usage.CacheReadInputTokens - a.Usage.CacheReadInputTokens,
+	}
+	return d
+}
+
+func messageText(m *Message) string {
+	var texts []string
+	for _, block := range m.Content {
+		if block.Type == "text" {
+			texts = append(texts, block.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func messageToolUses(m *Message) []ToolUseBlock {
+	var toolUses []ToolUseBlock
+	for _, block := range m.Content {
+		if block.Type == "tool_use" {
+			toolUses = append(toolUses, block.AsToolUse())
+		}
+	}
+	return toolUses
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return bytes.Equal(ca, cb)
+}
+
+// diffWords computes a diff of two word sequences from their longest common
+// subsequence, merging adjacent words with the same operation.
+func diffWords(a, b []string) []TextDiff {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diff []TextDiff
+	add := func(op TextDiffOp, word string) {
+		if n := len(diff); n > 0 && diff[n-1].Op == op {
+			diff[n-1].Text += " " + word
+			return
+		}
+		diff = append(diff, TextDiff{Op: op, Text: word})
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			add(TextDiffEqual, a[i])
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(TextDiffDelete, a[i])
+			i++
+		default:
+			add(TextDiffInsert, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		add(TextDiffDelete, a[i])
+	}
+	for ; j < len(b); j++ {
+		add(TextDiffInsert, b[j])
+	}
+	return diff
+}
@@ -0,0 +1,48 @@
+package anthropic_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func unmarshalMessage(t *testing.T, raw string) *anthropic.Message {
+	t.Helper()
+	var message anthropic.Message
+	if err := json.Unmarshal([]byte(raw), &message); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return &message
+}
+
+func TestDiffMessages(t *testing.T) {
+	a := unmarshalMessage(t, `{"role":"assistant","stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":20},"content":[`+
+		`{"type":"text","text":"The weather in Paris is sunny today."},`+
+		`{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{"city":"Paris","units":"c"}}]}`)
+	b := unmarshalMessage(t, `{"role":"assistant","stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":25},"content":[`+
+		`{"type":"text","text":"The weather in Paris is cloudy today."},`+
+		`{"type":"tool_use","id":"toolu_02","name":"get_weather","input":{"units":"c","city":"Paris"}},`+
+		`{"type":"tool_use","id":"toolu_03","name":"get_time","input":{}}]}`)
+
+	diff := anthropic.DiffMessages(a, b)
+	if diff.Equal() {
+		t.Error("expected the messages to differ")
+	}
+	if expected := "The weather in Paris is [-sunny-] {+cloudy+} today."; diff.String() != expected {
+		t.Errorf("expected %q, got %q", expected, diff.String())
+	}
+	if len(diff.ToolCalls) != 1 || diff.ToolCalls[0].Index != 1 || diff.ToolCalls[0].A != nil || diff.ToolCalls[0].B.Name != "get_time" {
+		t.Errorf("expected only the added get_time call to differ, got %+v", diff.ToolCalls)
+	}
+	if diff.StopReasons != nil {
+		t.Errorf("expected equal stop reasons, got %v", diff.StopReasons)
+	}
+	if diff.Usage.OutputTokens != 5 || diff.Usage.InputTokens != 0 {
+		t.Errorf("unexpected usage diff: %+v", diff.Usage)
+	}
+
+	if !anthropic.DiffMessages(a, a).Equal() {
+		t.Error("expected a message to equal itself")
+	}
+}
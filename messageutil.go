@@ -1,20 +1,54 @@
 package anthropic
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/sofianhadi1983/anthropic-sdk-go/internal/paramutil"
 )
 
+// EventOrderError is returned by [Message.Accumulate] and
+// [BetaMessage.Accumulate] for an event received out of order, such as a
+// content_block_delta for a block which was never started, which means the
+// stream was corrupted, for example by a proxy, and the message is garbled.
+type EventOrderError struct {
+	// Type is the type of the event, such as content_block_delta.
+	Type string
+	// Index is the index of the content block of the event, or -1 for
+	// message_delta and message_stop events.
+	Index int64
+	// Reason says why the event is out of order.
+	Reason string
+}
+
+func (e *EventOrderError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("accumulate: %s event %s", e.Type, e.Reason)
+	}
+	return fmt.Sprintf("accumulate: %s event with index %d %s", e.Type, e.Index, e.Reason)
+}
+
 // Accumulate builds up the Message incrementally from a MessageStreamEvent. The Message then can be used as
 // any other Message, except with the caveat that the Message.JSON field which normally can be used to inspect
 // the JSON sent over the network may not be populated fully.
 //
+// An [*EventOrderError] is returned for an event received out of order: any event before message_start, a
+// content_block_start whose index does not follow the previous block, or a content_block_delta or
+// content_block_stop for a block which was not started. The stream should then be abandoned.
+//
 //	message := anthropic.Message{}
 //	for stream.Next() {
 //		event := stream.Current()
-//		message.Accumulate(event)
+//		if err := message.Accumulate(event); err != nil {
+//			return err
+//		}
 //	}
 func (acc *Message) Accumulate(event MessageStreamEventUnion) error {
 	if acc == nil {
@@ -25,20 +59,29 @@ func (acc *Message) Accumulate(event MessageStreamEventUnion) error {
 	case MessageStartEvent:
 		*acc = event.Message
 	case MessageDeltaEvent:
+		if acc.JSON.raw == "" {
+			return &EventOrderError{Type: string(event.Type), Index: -1, Reason: "before message_start"}
+		}
 		acc.StopReason = event.Delta.StopReason
 		acc.StopSequence = event.Delta.StopSequence
 		acc.Usage.OutputTokens = event.Usage.OutputTokens
 	case ContentBlockStartEvent:
+		if acc.JSON.raw == "" {
+			return &EventOrderError{Type: string(event.Type), Index: event.Index, Reason: "before message_start"}
+		}
+		if event.Index != int64(len(acc.Content)) {
+			return &EventOrderError{Type: string(event.Type), Index: event.Index, Reason: fmt.Sprintf("after %d content blocks", len(acc.Content))}
+		}
 		acc.Content = append(acc.Content, ContentBlockUnion{})
 		err := acc.Content[len(acc.Content)-1].UnmarshalJSON([]byte(event.ContentBlock.RawJSON()))
 		if err != nil {
 			return err
 		}
 	case ContentBlockDeltaEvent:
-		if len(acc.Content) == 0 {
-			return fmt.Errorf("received event of type %s but there was no content block", event.Type)
+		if event.Index < 0 || event.Index >= int64(len(acc.Content)) {
+			return &EventOrderError{Type: string(event.Type), Index: event.Index, Reason: "for a content block which was not started"}
 		}
-		cb := &acc.Content[len(acc.Content)-1]
+		cb := &acc.Content[event.Index]
 		switch delta := event.Delta.AsAny().(type) {
 		case TextDelta:
 			cb.Text += delta.Text
@@ -63,18 +106,34 @@ func (acc *Message) Accumulate(event MessageStreamEventUnion) error {
 			cb.Citations = append(cb.Citations, citation)
 		}
 	case MessageStopEvent:
-		accJson, err := json.Marshal(acc)
+		if acc.JSON.raw == "" {
+			return &EventOrderError{Type: string(event.Type), Index: -1, Reason: "before message_start"}
+		}
+		// The raw JSON of a tool use cut short by max_tokens holds its input
+		// repaired, while Input keeps the partial input. See
+		// [Message.TruncatedToolUses].
+		var inputs []*json.RawMessage
+		for i := range acc.Content {
+			if acc.Content[i].Type == "tool_use" {
+				inputs = append(inputs, &acc.Content[i].Input)
+			}
+		}
+		accJson, err := marshalRepaired(acc, inputs...)
 		if err != nil {
 			return fmt.Errorf("error converting content block to JSON: %w", err)
 		}
 		acc.JSON.raw = string(accJson)
 
 	case ContentBlockStopEvent:
-		if len(acc.Content) == 0 {
-			return fmt.Errorf("received event of type %s but there was no content block", event.Type)
+		if event.Index < 0 || event.Index >= int64(len(acc.Content)) {
+			return &EventOrderError{Type: string(event.Type), Index: event.Index, Reason: "for a content block which was not started"}
 		}
-		contentBlock := &acc.Content[len(acc.Content)-1]
-		cbJson, err := json.Marshal(contentBlock)
+		contentBlock := &acc.Content[event.Index]
+		var inputs []*json.RawMessage
+		if contentBlock.Type == "tool_use" {
+			inputs = append(inputs, &contentBlock.Input)
+		}
+		cbJson, err := marshalRepaired(contentBlock, inputs...)
 		if err != nil {
 			return fmt.Errorf("error converting content block to JSON: %w", err)
 		}
@@ -84,6 +143,391 @@ func (acc *Message) Accumulate(event MessageStreamEventUnion) error {
 	return nil
 }
 
+// Text returns the text of the message: the text blocks concatenated in order,
+// ignoring tool use, thinking and other blocks. It returns an empty string if
+// the message has no text blocks.
+func (r Message) Text() string {
+	var sb strings.Builder
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	return sb.String()
+}
+
+// HasText reports whether the message has a text block with any text. Empty
+// text blocks, which a stream may start before a tool_use block, do not count,
+// so that a turn which only calls tools can be told apart, for example to show
+// a placeholder instead of an empty reply.
+func (r Message) HasText() bool {
+	return slices.ContainsFunc(r.Content, func(block ContentBlockUnion) bool {
+		return block.Type == "text" && block.Text != ""
+	})
+}
+
+// EndedForToolUse reports whether generation stopped because the model called
+// tools, whose results must be sent back for it to continue.
+func (r Message) EndedForToolUse() bool {
+	return r.StopReason == StopReasonToolUse
+}
+
+// Coalesce returns a copy of the message in which consecutive text blocks with
+// the same citations are merged into a single block. Streaming can occasionally
+// split what is logically one text block in two, and merging them simplifies
+// rendering. The original message is left unchanged.
+func (r Message) Coalesce() Message {
+	merged := r
+	merged.Content = make([]ContentBlockUnion, 0, len(r.Content))
+	for _, block := range r.Content {
+		n := len(merged.Content)
+		if n == 0 || block.Type != "text" || merged.Content[n-1].Type != "text" || !sameCitations(merged.Content[n-1].Citations, block.Citations) {
+			merged.Content = append(merged.Content, block)
+			continue
+		}
+		prev := &merged.Content[n-1]
+		prev.Text += block.Text
+		if cbJson, err := json.Marshal(prev); err == nil {
+			prev.JSON.raw = string(cbJson)
+		}
+	}
+	if len(merged.Content) == len(r.Content) {
+		return r
+	}
+
+	if msgJson, err := json.Marshal(merged); err == nil {
+		merged.JSON.raw = string(msgJson)
+	}
+	return merged
+}
+
+// StripEchoes returns a copy of the message in which fragments of the system
+// prompt repeated verbatim in the text blocks are removed, for the occasional
+// response which echoes part of its instructions. Only exact matches of at
+// least 80 bytes are removed, so that ordinary phrases shared with the system
+// prompt are kept. Text blocks left empty are dropped. The original message is
+// left unchanged, and is returned as is if nothing was removed.
+func (r Message) StripEchoes(system string) Message {
+	stripped := r
+	stripped.Content = make([]ContentBlockUnion, 0, len(r.Content))
+	changed := false
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			if text, ok := stripEchoes(block.Text, system); ok {
+				changed = true
+				if text == "" {
+					continue
+				}
+				block.Text = text
+				if cbJson, err := json.Marshal(block); err == nil {
+					block.JSON.raw = string(cbJson)
+				}
+			}
+		}
+		stripped.Content = append(stripped.Content, block)
+	}
+	if !changed {
+		return r
+	}
+
+	if msgJson, err := json.Marshal(stripped); err == nil {
+		stripped.JSON.raw = string(msgJson)
+	}
+	return stripped
+}
+
+// minEchoLength is the length in bytes of the shortest fragment of a system
+// prompt removed by [Message.StripEchoes].
+const minEchoLength = 80
+
+// stripEchoes removes from text the fragments of at least minEchoLength bytes
+// which also appear in system, and reports whether any was removed.
+func stripEchoes(text, system string) (string, bool) {
+	if len(text) < minEchoLength || len(system) < minEchoLength {
+		return text, false
+	}
+	// Every fragment starts with one of the minEchoLength byte substrings of
+	// the system prompt, which are indexed by their offsets.
+	offsets := map[string][]int{}
+	for i := 0; i+minEchoLength <= len(system); i++ {
+		key := system[i : i+minEchoLength]
+		offsets[key] = append(offsets[key], i)
+	}
+
+	var sb strings.Builder
+	last := 0
+	for i := 0; i+minEchoLength <= len(text); {
+		matches := offsets[text[i:i+minEchoLength]]
+		if len(matches) == 0 || !utf8.RuneStart(text[i]) {
+			i++
+			continue
+		}
+		n := 0
+		for _, offset := range matches {
+			m := minEchoLength
+			for i+m < len(text) && offset+m < len(system) && text[i+m] == system[offset+m] {
+				m++
+			}
+			n = max(n, m)
+		}
+		for i+n < len(text) && !utf8.RuneStart(text[i+n]) {
+			n--
+		}
+		if n < minEchoLength {
+			i++
+			continue
+		}
+		// The text around the fragment is joined by the whitespace before it.
+		start := i + len(text[i:i+n]) - len(strings.TrimLeftFunc(text[i:i+n], unicode.IsSpace))
+		sb.WriteString(text[last:start])
+		i += n
+		i += len(text[i:]) - len(strings.TrimLeftFunc(text[i:], unicode.IsSpace))
+		last = i
+	}
+	if last == 0 {
+		return text, false
+	}
+	sb.WriteString(text[last:])
+	return strings.TrimSpace(sb.String()), true
+}
+
+func sameCitations(a, b []TextCitationUnion) bool {
+	return slices.EqualFunc(a, b, func(x, y TextCitationUnion) bool {
+		return x.RawJSON() == y.RawJSON()
+	})
+}
+
+// ErrIncompleteToolInput is returned when the input of a tool_use block is
+// truncated JSON, typically because a stream ended before the block finished.
+var ErrIncompleteToolInput = errors.New("tool input is incomplete")
+
+// validToolInput checks that input is a single complete JSON value.
+func validToolInput(input json.RawMessage) (json.RawMessage, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(input))
+	err := dec.Decode(&v)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrIncompleteToolInput
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid tool input: %w", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("invalid tool input: unexpected data after the JSON value")
+	}
+	return input, nil
+}
+
+// incompleteToolInput reports whether input is truncated JSON.
+func incompleteToolInput(input json.RawMessage) bool {
+	_, err := validToolInput(input)
+	return errors.Is(err, ErrIncompleteToolInput)
+}
+
+// repairToolInput returns the longest prefix of the truncated JSON object
+// input which can be completed into a valid object, completed by closing its
+// open string, arrays and objects. Members which were cut before their value
+// are dropped.
+func repairToolInput(input json.RawMessage) json.RawMessage {
+	type cut struct {
+		at       int
+		inString bool
+		open     []byte
+	}
+	var cuts []cut
+	var open []byte
+	inString, escaped := false, false
+	for i, c := range input {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			open = append(open, c)
+			cuts = append(cuts, cut{i + 1, false, slices.Clone(open)})
+		case c == '}' || c == ']':
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		case c == ',':
+			cuts = append(cuts, cut{i, false, slices.Clone(open)})
+		}
+	}
+	cuts = append(cuts, cut{len(input), inString && !escaped, open})
+	for i := len(cuts) - 1; i >= 0; i-- {
+		repaired := slices.Clone(input[:cuts[i].at])
+		if cuts[i].inString {
+			repaired = append(repaired, '"')
+		}
+		for j := len(cuts[i].open) - 1; j >= 0; j-- {
+			if cuts[i].open[j] == '{' {
+				repaired = append(repaired, '}')
+			} else {
+				repaired = append(repaired, ']')
+			}
+		}
+		if json.Valid(repaired) && bytes.HasPrefix(repaired, []byte("{")) {
+			return repaired
+		}
+	}
+	return json.RawMessage("{}")
+}
+
+// marshalRepaired encodes v with the truncated tool inputs among inputs
+// replaced by their repaired form, so that the raw JSON of a message cut short
+// by max_tokens can be sent back.
+func marshalRepaired(v any, inputs ...*json.RawMessage) ([]byte, error) {
+	for _, input := range inputs {
+		if partial := *input; incompleteToolInput(partial) {
+			*input = repairToolInput(partial)
+			defer func() { *input = partial }()
+		}
+	}
+	return json.Marshal(v)
+}
+
+// InputValid returns the input of the tool use if it is valid JSON. An error
+// wrapping [ErrIncompleteToolInput] is returned if the input was truncated, so
+// that tools are never executed with partial arguments.
+func (r ToolUseBlock) InputValid() (json.RawMessage, error) {
+	return validToolInput(r.Input)
+}
+
+// ToolUses returns the tool_use blocks of the message, with their inputs as
+// accumulated from a stream. Blocks whose input is not valid JSON, for example
+// because the stream ended early, are left out and reported in the returned
+// error, joined with [errors.Join].
+func (r Message) ToolUses() ([]ToolUseBlock, error) {
+	var toolUses []ToolUseBlock
+	var errs []error
+	for _, block := range r.Content {
+		if block.Type != "tool_use" {
+			continue
+		}
+		toolUse := block.AsToolUse()
+		// The raw JSON of a block is only updated when the block completes, so
+		// read the input accumulated so far.
+		toolUse.Input = block.Input
+		if _, err := toolUse.InputValid(); err != nil {
+			errs = append(errs, fmt.Errorf("tool_use %s: %w", toolUse.ID, err))
+			continue
+		}
+		toolUses = append(toolUses, toolUse)
+	}
+	return toolUses, errors.Join(errs...)
+}
+
+// TruncatedToolUses returns the tool_use blocks whose input was cut short
+// because the message reached max_tokens, with the partial input accumulated
+// from the stream. Such tool calls cannot be executed; retry the request with a
+// higher max_tokens instead. It returns nil unless the stop reason is
+// max_tokens.
+//
+// The raw JSON of the blocks and of the message, and so [Message.ToParam],
+// hold the input repaired into a valid object by closing what was left open
+// and dropping the member cut before its value.
+func (r Message) TruncatedToolUses() []ToolUseBlock {
+	if r.StopReason != StopReasonMaxTokens {
+		return nil
+	}
+	var truncated []ToolUseBlock
+	for _, block := range r.Content {
+		if block.Type != "tool_use" {
+			continue
+		}
+		if incompleteToolInput(block.Input) {
+			toolUse := block.AsToolUse()
+			toolUse.Input = block.Input
+			truncated = append(truncated, toolUse)
+		}
+	}
+	return truncated
+}
+
+// Extract decodes the input of the first tool_use block of message calling the
+// tool named toolName into a T. Unlike forcing the tool with tool_choice, this
+// works on responses where the model also answers in text, for "answer plus
+// structured metadata" patterns. An error is returned if the message has no
+// such block, or if its input is incomplete or does not decode into T.
+//
+//	meta, err := anthropic.Extract[AnswerMetadata](*message, "record_metadata")
+func Extract[T any](message Message, toolName string) (T, error) {
+	var v T
+	for _, block := range message.Content {
+		if block.Type != "tool_use" || block.Name != toolName {
+			continue
+		}
+		input, err := validToolInput(block.Input)
+		if err != nil {
+			return v, fmt.Errorf("tool_use %s: %w", block.ID, err)
+		}
+		if err := json.Unmarshal(input, &v); err != nil {
+			return v, fmt.Errorf("tool_use %s: decoding input of %s: %w", block.ID, toolName, err)
+		}
+		return v, nil
+	}
+	return v, fmt.Errorf("message has no tool_use block for %s", toolName)
+}
+
+// MustExtract is like [Extract], but panics if the input cannot be extracted.
+func MustExtract[T any](message Message, toolName string) T {
+	v, err := Extract[T](message, toolName)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// MessagesToJSON serializes a conversation for storage. The output is a JSON
+// array of messages in the format of the messages field of a Messages API
+// request, as documented in the API reference, so it does not depend on the Go
+// types of this SDK and can be read back with [MessagesFromJSON], by another
+// version of the SDK, or sent to the API as is.
+//
+// An error wrapping [*ToolPairingError] values is returned if a tool_result
+// refers to a tool_use which is not in the previous message, if a tool_use is
+// not answered in the next message, unless it is in the final message, or if a
+// tool_use ID is used twice, as the API would reject the saved conversation.
+func MessagesToJSON(messages []MessageParam) ([]byte, error) {
+	if err := checkToolUseIDs(messages); err != nil {
+		return nil, err
+	}
+	if messages == nil {
+		messages = []MessageParam{}
+	}
+	return json.Marshal(messages)
+}
+
+// MessagesFromJSON parses a conversation written by [MessagesToJSON], or any
+// JSON array of messages in the Messages API request format. All content
+// blocks round-trip, including tool uses and results, images, documents and
+// cache_control. Fields which are unknown to this version of the SDK are
+// dropped, and string content is read as a single text block. The tool_use_id
+// references are checked as by [MessagesToJSON].
+func MessagesFromJSON(data []byte) ([]MessageParam, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("messages: %w", err)
+	}
+	messages := make([]MessageParam, len(raw))
+	for i, m := range raw {
+		if err := messages[i].UnmarshalJSON(m); err != nil {
+			return nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		if role := messages[i].Role; role != MessageParamRoleUser && role != MessageParamRoleAssistant {
+			return nil, fmt.Errorf("messages[%d]: invalid role %q", i, role)
+		}
+	}
+	if err := checkToolUseIDs(messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
 // ToParam converters
 
 func (r Message) ToParam() MessageParam {
@@ -146,6 +590,17 @@ func (r ToolUseBlock) ToParam() ToolUseBlockParam {
 	return toolUse
 }
 
+// ResultBlock returns a tool_result block answering the tool use with content.
+func (r ToolUseBlock) ResultBlock(content string) ContentBlockParamUnion {
+	return NewToolResultBlock(r.ID, content, false)
+}
+
+// ErrorResultBlock returns a tool_result block reporting that the tool use
+// failed, with content describing the error.
+func (r ToolUseBlock) ErrorResultBlock(content string) ContentBlockParamUnion {
+	return NewToolResultBlock(r.ID, content, true)
+}
+
 func (citationVariant CitationCharLocation) toParamUnion() TextCitationParamUnion {
 	var citationParam CitationCharLocationParam
 	citationParam.Type = citationVariant.Type
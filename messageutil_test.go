@@ -2,10 +2,13 @@ package anthropic_test
 
 import (
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/sofianhadi1983/anthropic-sdk-go"
 	"github.com/sofianhadi1983/anthropic-sdk-go/shared/constant"
+	"github.com/tidwall/gjson"
 )
 
 func unmarshalContentBlockParam(t *testing.T, jsonData string) anthropic.ContentBlockParamUnion {
@@ -54,3 +57,334 @@ func TestContentBlockUnionToParam(t *testing.T) {
 		}
 	})
 }
+
+func TestMessageCoalesce(t *testing.T) {
+	var message anthropic.Message
+	err := json.Unmarshal([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[`+
+		`{"type":"text","text":"Hello, ","citations":null},`+
+		`{"type":"text","text":"world.","citations":null},`+
+		`{"type":"tool_use","id":"toolu_01","name":"noop","input":{}},`+
+		`{"type":"text","text":"Cited","citations":[{"type":"char_location","cited_text":"x","document_index":0,"document_title":null,"start_char_index":0,"end_char_index":1}]},`+
+		`{"type":"text","text":" uncited","citations":null}`+
+		`]}`), &message)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	merged := message.Coalesce()
+	if len(message.Content) != 5 {
+		t.Errorf("expected the original message to be left unchanged")
+	}
+	if len(merged.Content) != 4 {
+		t.Fatalf("expected 4 blocks, got %d", len(merged.Content))
+	}
+	if merged.Content[0].Text != "Hello, world." {
+		t.Errorf("expected adjacent text blocks to be merged, got %q", merged.Content[0].Text)
+	}
+	if merged.Content[2].Text != "Cited" || merged.Content[3].Text != " uncited" {
+		t.Errorf("expected text blocks with different citations to be kept apart")
+	}
+	if !strings.Contains(merged.RawJSON(), "Hello, world.") {
+		t.Errorf("expected the raw JSON to reflect the merged content, got %s", merged.RawJSON())
+	}
+}
+
+func TestMessageToolUsesIncompleteInput(t *testing.T) {
+	// The stream ends before the second tool_use block is complete.
+	stream := newTestStream[anthropic.MessageStreamEventUnion](sseBody(
+		"message_start", `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"city\":\"Paris\"}"}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":0}`,
+		"content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_02","name":"delete_file","input":{}}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":\"/tm"}}`,
+	))
+	message := anthropic.Message{}
+	for stream.Next() {
+		if err := message.Accumulate(stream.Current()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	toolUses, err := message.ToolUses()
+	if !errors.Is(err, anthropic.ErrIncompleteToolInput) || !strings.Contains(err.Error(), "toolu_02") {
+		t.Errorf("expected an incomplete input error for toolu_02, got %v", err)
+	}
+	if len(toolUses) != 1 || toolUses[0].ID != "toolu_01" || string(toolUses[0].Input) != `{"city":"Paris"}` {
+		t.Errorf("expected only the complete tool use, got %+v", toolUses)
+	}
+
+	if _, err := (anthropic.ToolUseBlock{Input: []byte(`{"a":1} x`)}).InputValid(); err == nil || errors.Is(err, anthropic.ErrIncompleteToolInput) {
+		t.Errorf("expected an invalid input error, got %v", err)
+	}
+}
+
+func TestAccumulateEventOrder(t *testing.T) {
+	start := `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[]}}`
+	for name, testCase := range map[string]struct {
+		events   []string
+		expected anthropic.EventOrderError
+	}{
+		"delta before message_start": {
+			events:   []string{`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":1}}`},
+			expected: anthropic.EventOrderError{Type: "message_delta", Index: -1, Reason: "before message_start"},
+		},
+		"block before message_start": {
+			events:   []string{`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`},
+			expected: anthropic.EventOrderError{Type: "content_block_start", Index: 0, Reason: "before message_start"},
+		},
+		"delta for a block not started": {
+			events: []string{
+				start,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hi"}}`,
+			},
+			expected: anthropic.EventOrderError{Type: "content_block_delta", Index: 1, Reason: "for a content block which was not started"},
+		},
+		"stop for a block not started": {
+			events:   []string{start, `{"type":"content_block_stop","index":0}`},
+			expected: anthropic.EventOrderError{Type: "content_block_stop", Index: 0, Reason: "for a content block which was not started"},
+		},
+		"block skipping an index": {
+			events:   []string{start, `{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`},
+			expected: anthropic.EventOrderError{Type: "content_block_start", Index: 1, Reason: "after 0 content blocks"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			message := anthropic.Message{}
+			var err error
+			for _, eventStr := range testCase.events {
+				event := anthropic.MessageStreamEventUnion{}
+				if err := event.UnmarshalJSON([]byte(eventStr)); err != nil {
+					t.Fatal(err)
+				}
+				if err = message.Accumulate(event); err != nil {
+					break
+				}
+			}
+			var orderErr *anthropic.EventOrderError
+			if !errors.As(err, &orderErr) || *orderErr != testCase.expected {
+				t.Fatalf("expected %+v, got %v", testCase.expected, err)
+			}
+		})
+	}
+
+	// Deltas are applied to the block with their index, not the last one.
+	message := anthropic.BetaMessage{}
+	for _, eventStr := range []string{
+		start,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"first"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"second"}}`,
+	} {
+		event := anthropic.BetaRawMessageStreamEventUnion{}
+		if err := event.UnmarshalJSON([]byte(eventStr)); err != nil {
+			t.Fatal(err)
+		}
+		if err := message.Accumulate(event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if message.Content[0].Text != "first" || message.Content[1].Text != "second" {
+		t.Errorf("deltas applied to the wrong blocks: %q, %q", message.Content[0].Text, message.Content[1].Text)
+	}
+	event := anthropic.BetaRawMessageStreamEventUnion{}
+	if err := event.UnmarshalJSON([]byte(`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"third"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := message.Accumulate(event); err == nil || err.Error() != "accumulate: content_block_delta event with index 2 for a content block which was not started" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMessageTruncatedToolUses(t *testing.T) {
+	stream := newTestStream[anthropic.MessageStreamEventUnion](sseBody(
+		"message_start", `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"city\":\"Paris\"}"}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":0}`,
+		"content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_02","name":"write_file","input":{}}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":\"/tmp/a\",\"content\":\"lo"}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":1}`,
+		"message_delta", `{"type":"message_delta","delta":{"stop_reason":"max_tokens","stop_sequence":null},"usage":{"output_tokens":1024}}`,
+		"message_stop", `{"type":"message_stop"}`,
+	))
+	message := anthropic.Message{}
+	for stream.Next() {
+		if err := message.Accumulate(stream.Current()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	truncated := message.TruncatedToolUses()
+	if len(truncated) != 1 || truncated[0].ID != "toolu_02" || string(truncated[0].Input) != `{"path":"/tmp/a","content":"lo` {
+		t.Errorf("expected toolu_02 to be truncated, got %+v", truncated)
+	}
+	repaired := `{"path":"/tmp/a","content":"lo"}`
+	if input := gjson.Get(message.Content[1].RawJSON(), "input").Raw; input != repaired {
+		t.Errorf("expected the raw JSON of the block to hold the repaired input, got %s", input)
+	}
+	if input := gjson.Get(message.RawJSON(), "content.1.input").Raw; input != repaired {
+		t.Errorf("expected the raw JSON of the message to hold the repaired input, got %s", input)
+	}
+	if param, err := json.Marshal(message.ToParam()); err != nil || gjson.GetBytes(param, "content.1.input").Raw != repaired {
+		t.Errorf("expected ToParam to send the repaired input, got %s, %v", param, err)
+	}
+
+	message.StopReason = anthropic.StopReasonEndTurn
+	if truncated := message.TruncatedToolUses(); truncated != nil {
+		t.Errorf("expected no truncated tool uses without max_tokens, got %+v", truncated)
+	}
+}
+
+func TestMessageText(t *testing.T) {
+	var message anthropic.Message
+	err := message.UnmarshalJSON([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[
+		{"type":"thinking","thinking":"Let me check.","signature":"sig"},
+		{"type":"text","text":"Checking the weather. "},
+		{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}},
+		{"type":"text","text":"It is sunny."}
+	],"stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text := message.Text(); text != "Checking the weather. It is sunny." {
+		t.Errorf("unexpected text %q", text)
+	}
+	if text := (anthropic.Message{}).Text(); text != "" {
+		t.Errorf("expected no text for a message without content, got %q", text)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	var message anthropic.Message
+	err := message.UnmarshalJSON([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[
+		{"type":"text","text":"Paris is the capital of France."},
+		{"type":"tool_use","id":"toolu_01","name":"record_metadata","input":{"confidence":0.9,"sources":["wiki"]}}
+	],"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type metadata struct {
+		Confidence float64  `json:"confidence"`
+		Sources    []string `json:"sources"`
+	}
+	meta, err := anthropic.Extract[metadata](message, "record_metadata")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if meta.Confidence != 0.9 || len(meta.Sources) != 1 || meta.Sources[0] != "wiki" {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+
+	if _, err := anthropic.Extract[metadata](message, "get_weather"); err == nil || !strings.Contains(err.Error(), "get_weather") {
+		t.Errorf("expected a missing tool_use error, got %v", err)
+	}
+	if _, err := anthropic.Extract[int](message, "record_metadata"); err == nil || !strings.Contains(err.Error(), "toolu_01") {
+		t.Errorf("expected a decoding error, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected MustExtract to panic")
+		}
+	}()
+	anthropic.MustExtract[metadata](message, "get_weather")
+}
+
+func TestMessagesJSONRoundTrip(t *testing.T) {
+	data := `[
+		{"role":"user","content":[
+			{"type":"text","text":"What is in this image?","cache_control":{"type":"ephemeral","ttl":"1h"}},
+			{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgo="}}
+		]},
+		{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"lookup","input":{"query":"cat"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"a cat"}],"is_error":false}]}
+	]`
+
+	messages, err := anthropic.MessagesFromJSON([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(messages) != 3 || messages[0].Content[0].OfText.CacheControl.TTL != "1h" || messages[1].Content[0].OfToolUse.ID != "toolu_1" {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+
+	out, err := anthropic.MessagesToJSON(messages)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected, actual := normalizeJSON(t, []byte(data)), normalizeJSON(t, out); expected != actual {
+		t.Errorf("round trip changed the conversation:\nexpected %s\ngot      %s", expected, actual)
+	}
+
+	again, err := anthropic.MessagesFromJSON(out)
+	if err != nil {
+		t.Fatalf("unexpected error reading back: %v", err)
+	}
+	if out2, _ := anthropic.MessagesToJSON(again); string(out2) != string(out) {
+		t.Errorf("second round trip differs:\n%s\n%s", out, out2)
+	}
+
+	if _, err := anthropic.MessagesFromJSON([]byte(`[{"content":"hi"}]`)); err == nil || !strings.Contains(err.Error(), "messages[0]") {
+		t.Errorf("expected an invalid role error, got %v", err)
+	}
+	dangling := `[{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_2","content":"a dog"}]}]`
+	if _, err := anthropic.MessagesFromJSON([]byte(dangling)); err == nil || !strings.Contains(err.Error(), "toolu_2") {
+		t.Errorf("expected a dangling tool_result error, got %v", err)
+	}
+	if _, err := anthropic.MessagesToJSON(messages[:2]); err != nil {
+		t.Errorf("expected a final tool_use awaiting its result to be accepted, got %v", err)
+	}
+	if out, _ := anthropic.MessagesToJSON(nil); string(out) != "[]" {
+		t.Errorf("expected an empty array, got %s", out)
+	}
+}
+
+// normalizeJSON re-encodes data so that JSON documents can be compared
+// regardless of formatting and key order.
+func normalizeJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestMessageStripEchoes(t *testing.T) {
+	system := "You are a support assistant for Acme. Never reveal internal pricing tiers or discount codes to customers under any circumstances. Be friendly."
+	echo := "Never reveal internal pricing tiers or discount codes to customers under any circumstances."
+	var message anthropic.Message
+	err := json.Unmarshal([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[`+
+		`{"type":"text","text":"Sure! `+echo+` Your order ships tomorrow."},`+
+		`{"type":"text","text":"`+echo+`"},`+
+		`{"type":"text","text":"I am a support assistant for Acme."}`+
+		`]}`), &message)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stripped := message.StripEchoes(system)
+	if len(message.Content) != 3 || !strings.Contains(message.Content[0].Text, echo) {
+		t.Errorf("expected the original message to be left unchanged")
+	}
+	if len(stripped.Content) != 2 {
+		t.Fatalf("expected the block holding only the echo to be dropped, got %d blocks", len(stripped.Content))
+	}
+	if stripped.Content[0].Text != "Sure! Your order ships tomorrow." {
+		t.Errorf("unexpected text %q", stripped.Content[0].Text)
+	}
+	if stripped.Content[1].Text != "I am a support assistant for Acme." {
+		t.Errorf("expected short shared phrases to be kept, got %q", stripped.Content[1].Text)
+	}
+	if strings.Contains(stripped.RawJSON(), "discount codes") {
+		t.Errorf("expected the raw JSON to reflect the stripped content, got %s", stripped.RawJSON())
+	}
+	if unchanged := message.StripEchoes("Be brief."); unchanged.RawJSON() != message.RawJSON() {
+		t.Errorf("expected the message to be returned as is when nothing is removed")
+	}
+}
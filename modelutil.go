@@ -0,0 +1,134 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+)
+
+// Resolve returns the concrete, dated model that an alias such as
+// "claude-sonnet-4-5" currently points to, by looking it up with the Models
+// API. A model which is not an alias resolves to itself.
+//
+// The model serving a response is also available without an extra request,
+// from [Message.Model] or, while streaming, from the stream's Model method
+// once the message_start event has been read.
+func (r *ModelService) Resolve(ctx context.Context, model Model, opts ...option.RequestOption) (Model, error) {
+	info, err := r.Get(ctx, string(model), ModelGetParams{}, opts...)
+	if err != nil {
+		return "", err
+	}
+	return Model(info.ID), nil
+}
+
+// DefaultContextWindow is the context window, in tokens, assumed for models
+// missing from [ModelContextWindows].
+const DefaultContextWindow = 200_000
+
+// ModelContextWindows holds the context windows, in tokens, of the models
+// known to this version of the SDK, without the 1M token context window beta.
+// Callers may extend the table for newer models. It must not be modified
+// while it is being read.
+var ModelContextWindows = map[Model]int64{
+	ModelClaudeOpus4_5:            200_000,
+	ModelClaudeOpus4_5_20251101:   200_000,
+	ModelClaudeOpus4_1_20250805:   200_000,
+	ModelClaudeOpus4_0:            200_000,
+	ModelClaudeOpus4_20250514:     200_000,
+	ModelClaude4Opus20250514:      200_000,
+	ModelClaude3OpusLatest:        200_000,
+	ModelClaude_3_Opus_20240229:   200_000,
+	ModelClaudeSonnet4_5:          200_000,
+	ModelClaudeSonnet4_5_20250929: 200_000,
+	ModelClaudeSonnet4_0:          200_000,
+	ModelClaudeSonnet4_20250514:   200_000,
+	ModelClaude4Sonnet20250514:    200_000,
+	ModelClaude3_7SonnetLatest:    200_000,
+	ModelClaude3_7Sonnet20250219:  200_000,
+	ModelClaudeHaiku4_5:           200_000,
+	ModelClaudeHaiku4_5_20251001:  200_000,
+	ModelClaude3_5HaikuLatest:     200_000,
+	ModelClaude3_5Haiku20241022:   200_000,
+	ModelClaude_3_Haiku_20240307:  200_000,
+}
+
+// ContextWindow returns the context window of model from
+// [ModelContextWindows], in tokens. A dated model which is not in the table
+// is looked up by its undated alias, and unknown models are assumed to have
+// [DefaultContextWindow].
+func ContextWindow(model Model) int64 {
+	if n, ok := ModelContextWindows[model]; ok {
+		return n
+	}
+	if n, ok := ModelContextWindows[Model(modelDateSuffix.ReplaceAllString(string(model), ""))]; ok {
+		return n
+	}
+	return DefaultContextWindow
+}
+
+// ContextUsage returns the fraction of the context window of model, from
+// [ContextWindow], taken up by the prompt of the message: its input tokens,
+// including those read from or written to the prompt cache, which are not
+// counted in input_tokens. model is usually the Model of the message. A usage
+// approaching 1 means the conversation must soon be shortened, for example
+// with [MessageService.SummarizeHistory].
+func (r Message) ContextUsage(model Model) float64 {
+	return contextUsage(model, r.Usage.InputTokens+r.Usage.CacheReadInputTokens+r.Usage.CacheCreationInputTokens)
+}
+
+// ContextUsage returns the fraction of the context window of model taken up by
+// the prompt of the message. See [Message.ContextUsage].
+func (r BetaMessage) ContextUsage(model Model) float64 {
+	return contextUsage(model, r.Usage.InputTokens+r.Usage.CacheReadInputTokens+r.Usage.CacheCreationInputTokens)
+}
+
+func contextUsage(model Model, inputTokens int64) float64 {
+	return float64(inputTokens) / float64(ContextWindow(model))
+}
+
+// WithContextUsageThreshold returns a RequestOption which calls fn with the
+// model and context usage of each response whose usage, as computed by
+// [Message.ContextUsage], is at least threshold, such as 0.8, to warn that the
+// conversation is approaching the limit of the context window.
+//
+// For a streaming response, fn is called as soon as the message_start event is
+// read from the connection, from the goroutine iterating the stream; otherwise
+// it is called before the request returns.
+func WithContextUsageThreshold(threshold float64, fn func(model Model, usage float64)) option.RequestOption {
+	check := func(model string, usage gjson.Result) {
+		inputTokens := usage.Get("input_tokens").Int() + usage.Get("cache_read_input_tokens").Int() + usage.Get("cache_creation_input_tokens").Int()
+		if u := contextUsage(Model(model), inputTokens); usage.Exists() && u >= threshold {
+			fn(Model(model), u)
+		}
+	}
+	return option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		res, err := next(req)
+		if err != nil || res.Body == nil || res.StatusCode >= 300 {
+			return res, err
+		}
+		switch contentType := res.Header.Get("content-type"); {
+		case strings.HasPrefix(contentType, "text/event-stream"):
+			res.Body = &scanningBody{rc: res.Body, scan: func(data []byte) bool {
+				event := gjson.ParseBytes(data)
+				if event.Get("type").String() != "message_start" {
+					return true
+				}
+				check(event.Get("message.model").String(), event.Get("message.usage"))
+				return false
+			}}
+		case strings.Contains(contentType, "json"):
+			body, readErr := io.ReadAll(res.Body)
+			res.Body.Close()
+			res.Body = io.NopCloser(bytes.NewReader(body))
+			if readErr == nil && gjson.GetBytes(body, "type").String() == "message" {
+				check(gjson.GetBytes(body, "model").String(), gjson.GetBytes(body, "usage"))
+			}
+		}
+		return res, nil
+	})
+}
@@ -0,0 +1,109 @@
+package anthropic_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+func TestModelResolve(t *testing.T) {
+	var path string
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					path = req.URL.Path
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(`{"id":"claude-sonnet-4-5-20250929","type":"model","display_name":"Claude Sonnet 4.5","created_at":"2025-09-29T00:00:00Z"}`)),
+					}, nil
+				},
+			},
+		}),
+	)
+
+	model, err := client.Models.Resolve(context.Background(), anthropic.ModelClaudeSonnet4_5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != "/v1/models/claude-sonnet-4-5" {
+		t.Errorf("unexpected request path %q", path)
+	}
+	if model != anthropic.ModelClaudeSonnet4_5_20250929 {
+		t.Errorf("expected %q, got %q", anthropic.ModelClaudeSonnet4_5_20250929, model)
+	}
+}
+
+func TestContextUsage(t *testing.T) {
+	message := anthropic.Message{Usage: anthropic.Usage{InputTokens: 100_000, CacheReadInputTokens: 50_000, CacheCreationInputTokens: 10_000}}
+	if usage := message.ContextUsage(anthropic.ModelClaudeSonnet4_5_20250929); usage != 0.8 {
+		t.Errorf("expected a usage of 0.8, got %v", usage)
+	}
+
+	calls := 0
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					calls++
+					if calls == 3 {
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Header:     http.Header{"Content-Type": {"text/event-stream"}},
+							Body: io.NopCloser(strings.NewReader(sseBody(
+								"message_start", `{"type":"message_start","message":{"id":"msg_3","type":"message","role":"assistant","content":[],"model":"claude-haiku-4-5-20251001","usage":{"input_tokens":190000,"output_tokens":1}}}`,
+								"message_stop", `{"type":"message_stop"}`,
+							))),
+						}, nil
+					}
+					inputTokens := map[int]string{1: "1000", 2: "170000"}[calls]
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body: io.NopCloser(strings.NewReader(`{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929",` +
+							`"usage":{"input_tokens":` + inputTokens + `,"output_tokens":1}}`)),
+					}, nil
+				},
+			},
+		}),
+	)
+
+	type warning struct {
+		model anthropic.Model
+		usage float64
+	}
+	var warnings []warning
+	opt := anthropic.WithContextUsageThreshold(0.8, func(model anthropic.Model, usage float64) {
+		warnings = append(warnings, warning{model, usage})
+	})
+	params := anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5,
+	}
+	for range 2 {
+		if _, err := client.Messages.New(context.Background(), params, opt); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	stream := client.Messages.NewStreaming(context.Background(), params, opt)
+	for stream.Next() {
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []warning{{anthropic.ModelClaudeSonnet4_5_20250929, 0.85}, {anthropic.ModelClaudeHaiku4_5_20251001, 0.95}}
+	if len(warnings) != 2 || warnings[0] != expected[0] || warnings[1] != expected[1] {
+		t.Errorf("expected warnings %v, got %v", expected, warnings)
+	}
+}
@@ -0,0 +1,268 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+// ErrOpaqueToken is returned by [DecodeToken] and [VerifyToken] when the
+// access token is not a JWT, so its claims cannot be read locally.
+var ErrOpaqueToken = errors.New("oauth: token is opaque, not a JWT")
+
+// Claims are the claims of an access token, as decoded by [DecodeToken].
+type Claims struct {
+	// Subject is the "sub" claim.
+	Subject string
+	// Issuer is the "iss" claim.
+	Issuer string
+	// Scopes are read from the space-separated "scope" claim, or from the
+	// "scp" claim if it is a list.
+	Scopes []string
+	// ExpiresAt is the "exp" claim, or zero if the token does not expire.
+	ExpiresAt time.Time
+	// IssuedAt is the "iat" claim, or zero if absent.
+	IssuedAt time.Time
+	// Raw holds all the claims of the token.
+	Raw map[string]any
+}
+
+// Expired reports whether the token expires before now plus leeway, so that a
+// token about to expire can be refreshed before it is sent.
+func (c Claims) Expired(leeway time.Duration) bool {
+	return !c.ExpiresAt.IsZero() && time.Now().Add(leeway).After(c.ExpiresAt)
+}
+
+// HasScope reports whether the token was granted scope.
+func (c Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// DecodeToken returns the claims of an access token which is a JWT, so that
+// its expiry and scopes can be checked before making requests. The signature
+// is not verified, so the claims must not be trusted for authorization; see
+// [VerifyToken]. [ErrOpaqueToken] is returned for tokens which are not JWTs.
+//
+//	claims, err := oauth.DecodeToken(accessToken)
+//	if err == nil && claims.Expired(time.Minute) {
+//		accessToken = refresh()
+//	}
+func DecodeToken(token string) (Claims, error) {
+	_, claims, err := decodeJWT(token)
+	return claims, err
+}
+
+// VerifyToken is like [DecodeToken], but also verifies the signature of the
+// token against the JSON Web Key Set at jwksURL, which is fetched on each call
+// with client, or an http.Client with a timeout of [DefaultHTTPTimeout] if
+// client is nil. RS256, RS384, RS512, ES256, ES384 and ES512 signatures are
+// supported. The expiry is not checked; see [Claims.Expired].
+func VerifyToken(ctx context.Context, token string, jwksURL string, client option.HTTPClient) (Claims, error) {
+	header, claims, err := decodeJWT(token)
+	if err != nil {
+		return Claims{}, err
+	}
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	key, err := fetchJWK(ctx, client, jwksURL, header.KeyID)
+	if err != nil {
+		return Claims{}, err
+	}
+	i := strings.LastIndexByte(token, '.')
+	signature, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil {
+		return Claims{}, fmt.Errorf("oauth: decoding token signature: %w", err)
+	}
+	if err := verifyJWS(header.Algorithm, key, []byte(token[:i]), signature); err != nil {
+		return Claims{}, err
+	}
+	return claims, nil
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+func decodeJWT(token string) (jwtHeader, Claims, error) {
+	var header jwtHeader
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return header, Claims{}, ErrOpaqueToken
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &header) != nil || header.Algorithm == "" {
+		return header, Claims{}, ErrOpaqueToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return header, Claims{}, fmt.Errorf("oauth: decoding token claims: %w", err)
+	}
+
+	var raw map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return header, Claims{}, fmt.Errorf("oauth: decoding token claims: %w", err)
+	}
+	claims := Claims{Raw: raw}
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Issuer, _ = raw["iss"].(string)
+	claims.ExpiresAt = numericDate(raw["exp"])
+	claims.IssuedAt = numericDate(raw["iat"])
+	if scope, ok := raw["scope"].(string); ok {
+		claims.Scopes = strings.Fields(scope)
+	} else if scp, ok := raw["scp"].([]any); ok {
+		for _, s := range scp {
+			if s, ok := s.(string); ok {
+				claims.Scopes = append(claims.Scopes, s)
+			}
+		}
+	}
+	return header, claims, nil
+}
+
+// numericDate converts a JWT NumericDate, in seconds since the epoch.
+func numericDate(v any) time.Time {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+type jwk struct {
+	KeyID string `json:"kid"`
+	Type  string `json:"kty"`
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC keys.
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// fetchJWK returns the public key with keyID from the key set at jwksURL, or
+// its only key if keyID is empty.
+func fetchJWK(ctx context.Context, client option.HTTPClient, jwksURL string, keyID string) (crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth: fetching key set: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth: fetching key set: %s", res.Status)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("oauth: decoding key set: %w", err)
+	}
+
+	i := slices.IndexFunc(set.Keys, func(k jwk) bool { return k.KeyID == keyID })
+	if i < 0 && keyID == "" && len(set.Keys) == 1 {
+		i = 0
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("oauth: no key %q in key set", keyID)
+	}
+	return set.Keys[i].publicKey()
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		return new(big.Int).SetBytes(b), err
+	}
+	switch k.Type {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("oauth: decoding key %q: %w", k.KeyID, err)
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("oauth: decoding key %q: invalid exponent", k.KeyID)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Curve]
+		if !ok {
+			return nil, fmt.Errorf("oauth: key %q has unsupported curve %q", k.KeyID, k.Curve)
+		}
+		x, errX := decode(k.X)
+		y, errY := decode(k.Y)
+		if err := errors.Join(errX, errY); err != nil {
+			return nil, fmt.Errorf("oauth: decoding key %q: %w", k.KeyID, err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("oauth: key %q has unsupported type %q", k.KeyID, k.Type)
+}
+
+// verifyJWS verifies the signature of signed, the header and payload of a JWT.
+func verifyJWS(algorithm string, key crypto.PublicKey, signed, signature []byte) error {
+	hashes := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+	hash, ok := hashes[strings.TrimLeft(algorithm, "RSE")]
+	if !ok || len(algorithm) != 5 {
+		return fmt.Errorf("oauth: unsupported token algorithm %q", algorithm)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(algorithm, "RS") {
+			break
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+			return fmt.Errorf("oauth: invalid token signature: %w", err)
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(algorithm, "ES") {
+			break
+		}
+		// JWS signatures are the concatenated r and s, not ASN.1.
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("oauth: invalid token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("oauth: invalid token signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("oauth: token algorithm %q does not match its key", algorithm)
+}
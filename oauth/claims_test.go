@@ -0,0 +1,143 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/oauth"
+)
+
+func signedJWT(t *testing.T, header, claims map[string]any, sign func(digest []byte) []byte) string {
+	t.Helper()
+	encode := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(digest[:]))
+}
+
+func TestDecodeToken(t *testing.T) {
+	expiry := time.Now().Add(30 * time.Second).Truncate(time.Second)
+	token := signedJWT(t, map[string]any{"alg": "RS256"}, map[string]any{
+		"sub": "user_1", "iss": "https://auth.example.com", "scope": "user:inference user:profile", "exp": expiry.Unix(),
+	}, func([]byte) []byte { return []byte("unverified") })
+
+	claims, err := oauth.DecodeToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user_1" || claims.Issuer != "https://auth.example.com" || !claims.ExpiresAt.Equal(expiry) {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if !claims.HasScope("user:inference") || claims.HasScope("admin") {
+		t.Errorf("unexpected scopes %v", claims.Scopes)
+	}
+	if claims.Expired(0) || !claims.Expired(time.Minute) {
+		t.Errorf("expected the token to expire within a minute, at %v", claims.ExpiresAt)
+	}
+
+	scp := signedJWT(t, map[string]any{"alg": "RS256"}, map[string]any{"scp": []string{"a", "b"}}, func([]byte) []byte { return nil })
+	if claims, err := oauth.DecodeToken(scp); err != nil || !claims.HasScope("b") || claims.Expired(time.Hour) {
+		t.Errorf("unexpected claims %+v, %v", claims, err)
+	}
+
+	for _, opaque := range []string{"sk-ant-oat01-abcdef", "a.b.c"} {
+		if _, err := oauth.DecodeToken(opaque); !errors.Is(err, oauth.ErrOpaqueToken) {
+			t.Errorf("expected ErrOpaqueToken for %q, got %v", opaque, err)
+		}
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks := map[string]any{"keys": []map[string]any{
+		{"kid": "rsa", "kty": "RSA", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kid": "ec", "kty": "EC", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+	}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+
+	signRSA := func(digest []byte) []byte {
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	signEC := func(digest []byte) []byte {
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	ctx := context.Background()
+	claims := map[string]any{"sub": "user_1"}
+	for name, token := range map[string]string{
+		"RS256": signedJWT(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claims, signRSA),
+		"ES256": signedJWT(t, map[string]any{"alg": "ES256", "kid": "ec"}, claims, signEC),
+	} {
+		if claims, err := oauth.VerifyToken(ctx, token, server.URL, nil); err != nil || claims.Subject != "user_1" {
+			t.Errorf("%s: unexpected result %+v, %v", name, claims, err)
+		}
+	}
+
+	forged := signedJWT(t, map[string]any{"alg": "RS256", "kid": "rsa"}, map[string]any{"sub": "admin"}, func([]byte) []byte { return make([]byte, 256) })
+	if _, err := oauth.VerifyToken(ctx, forged, server.URL, nil); err == nil {
+		t.Error("expected an error for a forged signature")
+	}
+	mismatch := signedJWT(t, map[string]any{"alg": "RS256", "kid": "ec"}, claims, signRSA)
+	if _, err := oauth.VerifyToken(ctx, mismatch, server.URL, nil); err == nil {
+		t.Error("expected an error for an algorithm which does not match the key")
+	}
+	unknown := signedJWT(t, map[string]any{"alg": "RS256", "kid": "other"}, claims, signRSA)
+	if _, err := oauth.VerifyToken(ctx, unknown, server.URL, nil); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+
+	client := &countingClient{client: server.Client()}
+	if _, err := oauth.VerifyToken(ctx, signedJWT(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claims, signRSA), server.URL, client); err != nil || client.requests.Load() != 1 {
+		t.Errorf("expected the key set to be fetched with the given client, got %d requests, %v", client.requests.Load(), err)
+	}
+}
+
+// countingClient counts the requests sent through it.
+type countingClient struct {
+	client   *http.Client
+	requests atomic.Int32
+}
+
+func (c *countingClient) Do(req *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return c.client.Do(req)
+}
@@ -26,17 +26,58 @@
 //	        UseBetaEndpoint: true,
 //	    }),
 //	)
+//
+// # Token Sources
+//
+// To fetch the token before every request, for example from a secrets manager
+// or a file rotated out-of-band, implement [TokenSource]:
+//
+//	client := anthropic.NewClient(oauth.WithTokenSource(vaultTokens))
+//
+// # Token Refresh
+//
+// If a RefreshToken is configured, a request rejected with 401 Unauthorized
+// refreshes the access token and is retried once with the new token:
+//
+//	client := anthropic.NewClient(
+//	    oauth.WithConfig(oauth.Config{
+//	        AccessToken:  accessToken,
+//	        RefreshToken: refreshToken,
+//	        OnTokenRefreshed: func(newAccess, newRefresh string) {
+//	            saveTokens(newAccess, newRefresh)
+//	        },
+//	    }),
+//	)
 package oauth
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/betacompat"
 	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
 	"github.com/sofianhadi1983/anthropic-sdk-go/option"
 )
 
+// DefaultTokenEndpoint is the endpoint used to refresh access tokens.
+const DefaultTokenEndpoint = "https://console.anthropic.com/v1/oauth/token"
+
+// DefaultHTTPTimeout is the timeout of the token refresh and key set requests
+// made by this package when no http client is given.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// defaultHTTPClient sends the requests of this package when no http client is
+// given. Unlike http.DefaultClient, it has a timeout.
+var defaultHTTPClient = &http.Client{Timeout: DefaultHTTPTimeout}
+
 // DefaultOAuthBetas are the beta features used with OAuth authentication.
 var DefaultOAuthBetas = []string{
 	"oauth-2025-04-20",
@@ -45,15 +86,53 @@ var DefaultOAuthBetas = []string{
 	"fine-grained-tool-streaming-2025-05-14",
 }
 
+// TokenSource supplies the OAuth access token. Its Token method is called
+// before every request, including retries, so it should cache the token
+// rather than fetch it each time. It may be called from several goroutines at
+// once.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticTokenSource returns a TokenSource which always returns token.
+func StaticTokenSource(token string) TokenSource {
+	return staticTokenSource(token)
+}
+
+type staticTokenSource string
+
+func (s staticTokenSource) Token(context.Context) (string, error) { return string(s), nil }
+
 // Config holds OAuth authentication configuration.
 type Config struct {
 	// AccessToken is the OAuth access token for authentication.
 	AccessToken string
 
-	// RefreshToken is stored for future token refresh support.
-	// Currently not used for automatic refresh.
+	// TokenSource, if set, supplies the access token of each request instead
+	// of AccessToken. RefreshToken is not used with a TokenSource.
+	TokenSource TokenSource
+
+	// RefreshToken is used to get a new access token when a request is
+	// rejected with 401 Unauthorized. If empty, tokens are not refreshed.
 	RefreshToken string
 
+	// TokenEndpoint is the URL the refresh token is sent to.
+	// Defaults to DefaultTokenEndpoint if not set.
+	TokenEndpoint string
+
+	// ClientID is the OAuth client ID sent with refresh requests, if set.
+	ClientID string
+
+	// HTTPClient sends the refresh requests. Defaults to an http.Client with a
+	// timeout of DefaultHTTPTimeout. Requests to the API use the client set
+	// with option.WithHTTPClient, which may be given here too.
+	HTTPClient option.HTTPClient
+
+	// OnTokenRefreshed is called with the new tokens after a refresh, so that
+	// they can be persisted. The refresh token is the previous one if the
+	// endpoint did not rotate it.
+	OnTokenRefreshed func(newAccess, newRefresh string)
+
 	// Betas specifies the beta features to enable.
 	// Defaults to DefaultOAuthBetas if not set.
 	Betas []string
@@ -64,6 +143,11 @@ type Config struct {
 
 	// UseBetaEndpoint adds ?beta=true query parameter to requests.
 	UseBetaEndpoint bool
+
+	// ValidateBetas checks the betas of each request, once merged with Betas,
+	// with anthropic.ValidateBetas, and fails the request without sending it
+	// if they are incompatible.
+	ValidateBetas bool
 }
 
 // WithConfig returns a RequestOption for OAuth authentication with full configuration.
@@ -82,15 +166,40 @@ func WithConfig(cfg Config) option.RequestOption {
 	if len(cfg.Betas) == 0 {
 		cfg.Betas = DefaultOAuthBetas
 	}
+	if cfg.TokenEndpoint == "" {
+		cfg.TokenEndpoint = DefaultTokenEndpoint
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = defaultHTTPClient
+	}
+	// The tokens are shared by every request made with the option.
+	tokens := &tokenStore{cfg: cfg, access: cfg.AccessToken, refresh: cfg.RefreshToken}
 
 	return requestconfig.RequestOptionFunc(func(rc *requestconfig.RequestConfig) error {
+		if cfg.TokenSource != nil {
+			rc.CustomAuth = true
+			return rc.Apply(option.WithMiddleware(oauthMiddleware(cfg, cfg.TokenSource)))
+		}
 		return rc.Apply(
 			option.WithAuthToken(cfg.AccessToken),
-			option.WithMiddleware(oauthMiddleware(cfg)),
+			option.WithMiddleware(oauthMiddleware(cfg, tokens)),
 		)
 	})
 }
 
+// WithTokenSource returns a RequestOption for OAuth authentication with the
+// access token returned by ts before every request. This uses the default beta
+// features from DefaultOAuthBetas.
+//
+// Example:
+//
+//	client := anthropic.NewClient(oauth.WithTokenSource(oauth.StaticTokenSource("your-oauth-token")))
+func WithTokenSource(ts TokenSource) option.RequestOption {
+	return WithConfig(Config{
+		TokenSource: ts,
+	})
+}
+
 // WithAccessToken returns a RequestOption for OAuth authentication with the given token.
 // This uses the default beta features from DefaultOAuthBetas.
 //
@@ -123,8 +232,9 @@ func WithLoadEnv() option.RequestOption {
 	})
 }
 
-// oauthMiddleware creates middleware that adds OAuth-specific headers and query parameters.
-func oauthMiddleware(cfg Config) option.Middleware {
+// oauthMiddleware creates middleware that adds OAuth-specific headers and query
+// parameters, and the access token from tokens.
+func oauthMiddleware(cfg Config, tokens TokenSource) option.Middleware {
 	return func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
 		// Set the anthropic-beta header with OAuth betas
 		if len(cfg.Betas) > 0 {
@@ -149,6 +259,11 @@ func oauthMiddleware(cfg Config) option.Middleware {
 				r.Header.Set("anthropic-beta", strings.Join(cfg.Betas, ","))
 			}
 		}
+		if cfg.ValidateBetas {
+			if err := betacompat.Validate(strings.Split(r.Header.Get("anthropic-beta"), ",")); err != nil {
+				return nil, requestconfig.NotRetryable(fmt.Errorf("oauth: %w", err))
+			}
+		}
 
 		// Set custom User-Agent if provided
 		if cfg.UserAgent != "" {
@@ -162,6 +277,116 @@ func oauthMiddleware(cfg Config) option.Middleware {
 			r.URL.RawQuery = q.Encode()
 		}
 
+		token, err := tokens.Token(r.Context())
+		if err != nil {
+			return nil, fmt.Errorf("oauth: getting access token: %w", err)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+		if store, ok := tokens.(*tokenStore); ok {
+			return store.sendWithRefresh(r, next, token)
+		}
 		return next(r)
 	}
 }
+
+// tokenStore is the TokenSource of a configured AccessToken and RefreshToken.
+// It holds the current tokens, shared by all requests of a client.
+type tokenStore struct {
+	cfg Config
+
+	// mu is held during a refresh, so that concurrent requests rejected with
+	// the same token wait for a single refresh.
+	mu      sync.Mutex
+	access  string
+	refresh string
+}
+
+func (s *tokenStore) Token(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.access, nil
+}
+
+// sendWithRefresh sends r, authenticated with sent, and when it is rejected
+// with 401 Unauthorized, refreshes the token and retries it once. If the
+// refresh fails, the 401 response is returned.
+func (s *tokenStore) sendWithRefresh(r *http.Request, next option.MiddlewareNext, sent string) (*http.Response, error) {
+	s.mu.Lock()
+	canRefresh := s.refresh != ""
+	s.mu.Unlock()
+
+	res, err := next(r)
+	if err != nil || res.StatusCode != http.StatusUnauthorized || !canRefresh {
+		return res, err
+	}
+	if r.Body != nil && r.GetBody == nil {
+		return res, err
+	}
+
+	access, refreshErr := s.refreshAfter(r.Context(), sent)
+	if refreshErr != nil {
+		return res, err
+	}
+	retry := r.Clone(r.Context())
+	if r.GetBody != nil {
+		if retry.Body, err = r.GetBody(); err != nil {
+			return res, nil
+		}
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	retry.Header.Set("Authorization", "Bearer "+access)
+	return next(retry)
+}
+
+// refreshAfter returns a new access token to replace failed. If the token has
+// already been replaced, for example by a concurrent request, the current one
+// is returned without refreshing again.
+func (s *tokenStore) refreshAfter(ctx context.Context, failed string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.access != failed {
+		return s.access, nil
+	}
+
+	form := map[string]string{"grant_type": "refresh_token", "refresh_token": s.refresh}
+	if s.cfg.ClientID != "" {
+		form["client_id"] = s.cfg.ClientID
+	}
+	body, err := json.Marshal(form)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oauth: refreshing token: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oauth: refreshing token: %s", res.Status)
+	}
+
+	var tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tokens); err != nil {
+		return "", fmt.Errorf("oauth: refreshing token: %w", err)
+	}
+	if tokens.AccessToken == "" {
+		return "", fmt.Errorf("oauth: refreshing token: no access token in response")
+	}
+	s.access = tokens.AccessToken
+	if tokens.RefreshToken != "" {
+		s.refresh = tokens.RefreshToken
+	}
+	if s.cfg.OnTokenRefreshed != nil {
+		s.cfg.OnTokenRefreshed(s.access, s.refresh)
+	}
+	return s.access, nil
+}
@@ -1,11 +1,18 @@
 package oauth_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/sofianhadi1983/anthropic-sdk-go"
 	"github.com/sofianhadi1983/anthropic-sdk-go/oauth"
@@ -96,6 +103,47 @@ func TestWithConfig(t *testing.T) {
 	}
 }
 
+func TestWithConfigValidateBetas(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_123","type":"message","role":"assistant","content":[],"model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5}}`))
+	}))
+	defer server.Close()
+
+	client := anthropic.NewClient(
+		oauth.WithConfig(oauth.Config{
+			AccessToken:   "custom-token",
+			Betas:         []string{"oauth-2025-04-20", "computer-use-2024-10-22"},
+			ValidateBetas: true,
+		}),
+		option.WithBaseURL(server.URL),
+	)
+
+	_, err := client.Beta.Messages.New(context.Background(), anthropic.BetaMessageNewParams{
+		MaxTokens: 256,
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		Messages:  []anthropic.BetaMessageParam{anthropic.NewBetaUserMessage(anthropic.NewBetaTextBlock("Hello"))},
+		Betas:     []anthropic.AnthropicBeta{anthropic.AnthropicBetaComputerUse2025_01_24},
+	})
+	if err == nil || !strings.Contains(err.Error(), "computer-use-2024-10-22 cannot be combined with computer-use-2025-01-24") {
+		t.Errorf("expected a beta conflict error, got %v", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("expected no request to be sent, got %d", n)
+	}
+
+	_, err = client.Messages.New(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 256,
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hello"))},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestWithConfigUseBetaEndpoint(t *testing.T) {
 	var capturedReq *http.Request
 
@@ -229,3 +277,131 @@ func TestDefaultOAuthBetas(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenRefresh(t *testing.T) {
+	var refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			refreshes.Add(1)
+			body, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(body), `"refresh_token":"refresh-1"`) {
+				t.Errorf("unexpected refresh request: %s", body)
+			}
+			// Give concurrent requests time to be rejected with the old token.
+			time.Sleep(50 * time.Millisecond)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"access-2","refresh_token":"refresh-2","expires_in":3600}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), "Hello") {
+			t.Errorf("expected the retried request to carry its body, got %s", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer access-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"expired"}}`))
+			return
+		}
+		w.Write([]byte(`{"id":"msg_123","type":"message","role":"assistant","content":[{"type":"text","text":"Hello!"}],"model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5}}`))
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	var persisted []string
+	httpClient := &countingClient{client: server.Client()}
+	client := anthropic.NewClient(
+		oauth.WithConfig(oauth.Config{
+			AccessToken:   "access-1",
+			RefreshToken:  "refresh-1",
+			TokenEndpoint: server.URL + "/token",
+			HTTPClient:    httpClient,
+			OnTokenRefreshed: func(newAccess, newRefresh string) {
+				mu.Lock()
+				defer mu.Unlock()
+				persisted = append(persisted, newAccess, newRefresh)
+			},
+		}),
+		option.WithBaseURL(server.URL),
+		option.WithMaxRetries(0),
+	)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Messages.New(context.Background(), anthropic.MessageNewParams{
+				MaxTokens: 256,
+				Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+				Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hello"))},
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("expected a single refresh, got %d", n)
+	}
+	if n := httpClient.requests.Load(); n != 1 {
+		t.Errorf("expected the refresh to be sent with the configured client, got %d requests", n)
+	}
+	if len(persisted) != 2 || persisted[0] != "access-2" || persisted[1] != "refresh-2" {
+		t.Errorf("expected the rotated tokens to be reported, got %v", persisted)
+	}
+}
+
+type rotatingTokenSource struct {
+	calls atomic.Int32
+}
+
+func (s *rotatingTokenSource) Token(ctx context.Context) (string, error) {
+	n := s.calls.Add(1)
+	if n > 2 {
+		return "", errors.New("vault unavailable")
+	}
+	return fmt.Sprintf("token-%d", n), nil
+}
+
+func TestWithTokenSource(t *testing.T) {
+	var authHeaders []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_123","type":"message","role":"assistant","content":[{"type":"text","text":"Hello!"}],"model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5}}`))
+	}))
+	defer server.Close()
+
+	client := anthropic.NewClient(
+		oauth.WithTokenSource(&rotatingTokenSource{}),
+		option.WithBaseURL(server.URL),
+		option.WithMaxRetries(0),
+	)
+	if err := client.Validate(); err != nil {
+		t.Errorf("expected a token source to count as credentials, got %v", err)
+	}
+
+	params := anthropic.MessageNewParams{
+		MaxTokens: 256,
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hello"))},
+	}
+	for range 2 {
+		if _, err := client.Messages.New(context.Background(), params); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(authHeaders) != 2 || authHeaders[0] != "Bearer token-1" || authHeaders[1] != "Bearer token-2" {
+		t.Errorf("expected a fresh token for each request, got %v", authHeaders)
+	}
+
+	if _, err := client.Messages.New(context.Background(), params); err == nil || !strings.Contains(err.Error(), "vault unavailable") {
+		t.Errorf("expected the token source error, got %v", err)
+	}
+	if len(authHeaders) != 2 {
+		t.Errorf("expected no request to be sent without a token")
+	}
+}
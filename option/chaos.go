@@ -0,0 +1,110 @@
+package option
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChaosOpts configures the faults injected by [WithChaos].
+type ChaosOpts struct {
+	// Enabled turns the injection on. WithChaos does nothing unless it is set,
+	// so the option can stay wired in and be switched on by a test flag.
+	Enabled bool
+	// ErrorRate is the probability, from 0 to 1, that an attempt fails with an
+	// injected error response instead of being sent.
+	ErrorRate float64
+	// LatencyJitter is the largest delay added before each attempt. Each delay
+	// is chosen at random between zero and LatencyJitter.
+	LatencyJitter time.Duration
+	// StatusCodes are the statuses of the injected errors, one picked at random
+	// for each. Defaults to 429, 500 and 529.
+	StatusCodes []int
+}
+
+// chaosErrorTypes are the API error types reported for injected statuses.
+var chaosErrorTypes = map[int]string{
+	400: "invalid_request_error",
+	401: "authentication_error",
+	403: "permission_error",
+	404: "not_found_error",
+	413: "request_too_large",
+	429: "rate_limit_error",
+	504: "timeout_error",
+	529: "overloaded_error",
+}
+
+// WithChaos returns a RequestOption which randomly delays requests and
+// replaces them with error responses, to exercise the retry, fallback and
+// error handling of an application against the real request plumbing. It is
+// meant for tests only and must not be used in production.
+//
+// Injection happens before each attempt, retries included, so an injected
+// error which is retryable, such as a 429 or 529, goes through the normal
+// retries and may succeed on a later attempt. When the retries are
+// exhausted, the error is returned as an *anthropic.Error with the injected
+// status, as for a real API error. A request whose context ends during an
+// injected delay fails with the context's error.
+//
+// WithChaos panics if ErrorRate is outside [0, 1] or LatencyJitter is negative.
+func WithChaos(opts ChaosOpts) RequestOption {
+	if opts.ErrorRate < 0 || opts.ErrorRate > 1 {
+		panic("option: chaos error rate must be between 0 and 1")
+	}
+	if opts.LatencyJitter < 0 {
+		panic("option: chaos latency jitter cannot be negative")
+	}
+	statuses := opts.StatusCodes
+	if len(statuses) == 0 {
+		statuses = []int{http.StatusTooManyRequests, http.StatusInternalServerError, statusOverloaded}
+	}
+	return WithMiddleware(func(req *http.Request, next MiddlewareNext) (*http.Response, error) {
+		if !opts.Enabled {
+			return next(req)
+		}
+		if opts.LatencyJitter > 0 {
+			timer := time.NewTimer(time.Duration(rand.Int63n(int64(opts.LatencyJitter) + 1)))
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			}
+		}
+		if opts.ErrorRate > 0 && rand.Float64() < opts.ErrorRate {
+			return chaosResponse(req, statuses[rand.Intn(len(statuses))]), nil
+		}
+		return next(req)
+	})
+}
+
+// chaosResponse returns an API error response with the given status.
+func chaosResponse(req *http.Request, status int) *http.Response {
+	typ, ok := chaosErrorTypes[status]
+	if !ok {
+		typ = "api_error"
+	}
+	body := fmt.Sprintf(`{"type":"error","error":{"type":%q,"message":"error injected by option.WithChaos"}}`, typ)
+	text := http.StatusText(status)
+	if status == statusOverloaded {
+		text = "Overloaded"
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, text),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Type":   {"application/json"},
+			"Content-Length": {strconv.Itoa(len(body))},
+		},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,52 @@
+package option
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/apierror"
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+)
+
+func TestWithChaos(t *testing.T) {
+	send := func(ctx context.Context, opts ChaosOpts) (int, error) {
+		var requests int
+		client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requests++
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{}`)),
+			}, nil
+		})}
+		cfg, err := requestconfig.NewRequestConfig(ctx, http.MethodPost, "v1/messages", []byte(`{"model":"m"}`), nil,
+			WithBaseURL("http://localhost/"), WithHTTPClient(client), WithRetryPolicy(RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond}),
+			WithChaos(opts))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = cfg.Execute()
+		return requests, err
+	}
+
+	if requests, err := send(context.Background(), ChaosOpts{ErrorRate: 1, LatencyJitter: time.Hour}); err != nil || requests != 1 {
+		t.Errorf("expected no injection unless enabled, got %d requests and %v", requests, err)
+	}
+
+	requests, err := send(context.Background(), ChaosOpts{Enabled: true, ErrorRate: 1, StatusCodes: []int{529}})
+	var apierr *apierror.Error
+	if !errors.As(err, &apierr) || apierr.StatusCode != 529 || !strings.Contains(apierr.RawJSON(), "overloaded_error") || requests != 0 {
+		t.Errorf("expected an injected overloaded error after the retries, got %d requests and %v", requests, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := send(ctx, ChaosOpts{Enabled: true, LatencyJitter: time.Hour}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the injected delay to end with the context, got %v", err)
+	}
+}
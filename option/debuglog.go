@@ -0,0 +1,106 @@
+package option
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+)
+
+// WithDebugLogging returns a RequestOption which writes a summary of each
+// attempt to w: the request line and headers, then the status of the response
+// and the time it took to arrive, or the error of the attempt. Credential
+// headers, such as X-Api-Key and Authorization, are masked but for their last
+// 4 characters, so the log is safe to ship. Bodies are not logged; see
+// [WithDebugLog] to dump them.
+//
+// The body of a streaming response is not buffered: its status is logged as
+// soon as the headers arrive, and a last line with the total duration once the
+// stream ends or is closed.
+//
+//	--> POST https://api.anthropic.com/v1/messages
+//	    X-Api-Key: ****Xy9z
+//	<-- 200 OK (412ms)
+//
+// Writes to w are serialized, so the option can be shared by concurrent
+// requests, but the lines of concurrent requests may interleave.
+func WithDebugLogging(w io.Writer) RequestOption {
+	var mu sync.Mutex
+	logf := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, format, args...)
+	}
+	return WithMiddleware(func(req *http.Request, next MiddlewareNext) (*http.Response, error) {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "--> %s %s\n", req.Method, req.URL)
+		names := make([]string, 0, len(req.Header))
+		for name := range req.Header {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			for _, value := range req.Header[name] {
+				if slices.ContainsFunc(requestconfig.CredentialHeaders, func(h string) bool { return strings.EqualFold(h, name) }) {
+					value = maskCredential(value)
+				}
+				fmt.Fprintf(&sb, "    %s: %s\n", name, value)
+			}
+		}
+		logf("%s", sb.String())
+
+		start := time.Now()
+		res, err := next(req)
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			logf("<-- %s %s: %v (%s)\n", req.Method, req.URL, err, elapsed)
+			return res, err
+		}
+		status := res.Status
+		if status == "" {
+			status = fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode))
+		}
+		logf("<-- %s (%s)\n", status, elapsed)
+		if isEventStream(res) {
+			res.Body = &loggedStreamBody{ReadCloser: res.Body, done: func() {
+				logf("<-- stream of %s %s ended (%s)\n", req.Method, req.URL, time.Since(start).Round(time.Millisecond))
+			}}
+		}
+		return res, nil
+	})
+}
+
+// maskCredential replaces all but the last 4 characters of a credential.
+// Values too short to keep any of them safely are masked entirely.
+func maskCredential(value string) string {
+	if len(value) < 12 {
+		return "****"
+	}
+	return "****" + value[len(value)-4:]
+}
+
+// loggedStreamBody calls done once, when the stream is read to its end or
+// closed.
+type loggedStreamBody struct {
+	io.ReadCloser
+	done func()
+	once sync.Once
+}
+
+func (b *loggedStreamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *loggedStreamBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
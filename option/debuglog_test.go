@@ -0,0 +1,55 @@
+package option
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+)
+
+func TestWithDebugLogging(t *testing.T) {
+	var log bytes.Buffer
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Header:     http.Header{"Content-Type": {"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader(sseEvent(`{"type":"message_stop"}`))),
+		}, nil
+	})}
+	var res *http.Response
+	cfg, err := requestconfig.NewRequestConfig(context.Background(), http.MethodPost, "v1/messages", []byte(`{"model":"m","stream":true}`), &res,
+		WithBaseURL("http://localhost/"), WithHTTPClient(client), WithAPIKey("sk-ant-api03-secretXy9z"),
+		WithHeader("Authorization", "Bearer abc"), WithDebugLogging(&log))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cfg.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(log.String(), "stream of") {
+		t.Errorf("expected the end of the stream to be logged once it is read, got:\n%s", log.String())
+	}
+	io.ReadAll(res.Body)
+	res.Body.Close()
+
+	out := log.String()
+	for _, want := range []string{
+		"--> POST http://localhost/v1/messages\n",
+		"    X-Api-Key: ****Xy9z\n",
+		"    Authorization: ****\n",
+		"<-- 200 OK (",
+		"<-- stream of POST http://localhost/v1/messages ended (",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected the log to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "secret") || strings.Contains(out, "abc") || strings.Count(out, "stream of") != 1 {
+		t.Errorf("unexpected log:\n%s", out)
+	}
+}
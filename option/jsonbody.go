@@ -0,0 +1,271 @@
+package option
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+	"unicode"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+	"github.com/sofianhadi1983/anthropic-sdk-go/packages/param"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// WithTrimAssistantWhitespace returns a RequestOption that removes trailing
+// whitespace from the final text block of every assistant message in the
+// request. The API rejects prefilled assistant turns that end in whitespace, and
+// trailing whitespace in earlier turns can subtly affect generation, so this is
+// useful when feeding responses back as history.
+//
+// Requests without a messages array are left untouched.
+func WithTrimAssistantWhitespace() RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		return r.RewriteJSONBody(trimAssistantWhitespace)
+	})
+}
+
+func trimAssistantWhitespace(body []byte) (_ []byte, err error) {
+	for i, message := range gjson.GetBytes(body, "messages").Array() {
+		if message.Get("role").String() != "assistant" {
+			continue
+		}
+
+		content := message.Get("content")
+		if content.Type == gjson.String {
+			body, err = sjson.SetBytes(body, fmt.Sprintf("messages.%d.content", i), strings.TrimRightFunc(content.String(), unicode.IsSpace))
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		blocks := content.Array()
+		for j := len(blocks) - 1; j >= 0; j-- {
+			if blocks[j].Get("type").String() != "text" {
+				continue
+			}
+			text := blocks[j].Get("text").String()
+			if trimmed := strings.TrimRightFunc(text, unicode.IsSpace); trimmed != text {
+				body, err = sjson.SetBytes(body, fmt.Sprintf("messages.%d.content.%d.text", i, j), trimmed)
+				if err != nil {
+					return nil, err
+				}
+			}
+			break
+		}
+	}
+	return body, nil
+}
+
+// WithTemperatureValidation returns a RequestOption that fails the request
+// before it is sent if its temperature is outside the range [0, 1]. It also
+// logs a warning when both temperature and top_p are set, since only one of
+// them should be adjusted at a time. If logger is nil, the default logger is used.
+func WithTemperatureValidation(logger *log.Logger) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		return r.RewriteJSONBody(func(body []byte) ([]byte, error) {
+			return checkTemperature(body, logger, false)
+		})
+	})
+}
+
+// WithTemperatureClamp returns a RequestOption that clamps a temperature
+// outside the range [0, 1] to the nearest bound and logs a warning. Like
+// [WithTemperatureValidation], it also warns when both temperature and top_p
+// are set. If logger is nil, the default logger is used.
+func WithTemperatureClamp(logger *log.Logger) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		return r.RewriteJSONBody(func(body []byte) ([]byte, error) {
+			return checkTemperature(body, logger, true)
+		})
+	})
+}
+
+func checkTemperature(body []byte, logger *log.Logger, clamp bool) ([]byte, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	temperature := gjson.GetBytes(body, "temperature")
+	if !temperature.Exists() {
+		return body, nil
+	}
+	if gjson.GetBytes(body, "top_p").Exists() {
+		logger.Printf("anthropic: both temperature and top_p are set, only one of them should be used")
+	}
+
+	t := temperature.Float()
+	if t >= 0 && t <= 1 {
+		return body, nil
+	}
+	if !clamp {
+		return nil, fmt.Errorf("temperature %v is outside the range [0, 1]", t)
+	}
+	clamped := min(max(t, 0), 1)
+	logger.Printf("anthropic: temperature %v is outside the range [0, 1], clamping to %v", t, clamped)
+	return sjson.SetBytes(body, "temperature", clamped)
+}
+
+// WithoutTools returns a RequestOption that disables tool use for a single
+// request, so that params shared with tool-using calls can be reused for a plain
+// text completion. The tools and tool_choice fields are removed from the request
+// body, leaving the caller's params untouched. If the conversation already holds
+// tool_use or tool_result blocks, which the API only accepts alongside tool
+// definitions, the tools are kept and tool_choice is set to none instead.
+func WithoutTools() RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		return r.RewriteJSONBody(withoutTools)
+	})
+}
+
+func withoutTools(body []byte) ([]byte, error) {
+	if !gjson.GetBytes(body, "tools").Exists() && !gjson.GetBytes(body, "tool_choice").Exists() {
+		return body, nil
+	}
+	if gjson.GetBytes(body, `messages.#.content.#(type=="tool_use")`).String() != "[]" || gjson.GetBytes(body, `messages.#.content.#(type=="tool_result")`).String() != "[]" {
+		return sjson.SetRawBytes(body, "tool_choice", []byte(`{"type":"none"}`))
+	}
+	body, err := sjson.DeleteBytes(body, "tools")
+	if err != nil {
+		return nil, err
+	}
+	return sjson.DeleteBytes(body, "tool_choice")
+}
+
+// WithOutgoingRedactor returns a RequestOption that passes the text sent to
+// the API through redact, for example to mask emails and other personal data
+// before anything leaves the process. It is applied to the system prompt, to
+// the text blocks of all messages, to the content of tool results and to plain
+// text documents. The caller's params are left untouched.
+//
+// Only text is redacted: the content of images, of base64 or URL documents and
+// the input of tool calls are sent as is.
+func WithOutgoingRedactor(redact func(text string) string) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		return r.RewriteJSONBody(func(body []byte) ([]byte, error) {
+			return redactOutgoing(body, redact)
+		})
+	})
+}
+
+func redactOutgoing(body []byte, redact func(string) string) (_ []byte, err error) {
+	updates := map[string]string{}
+	redactContent(updates, "system", gjson.GetBytes(body, "system"), redact)
+	for i, message := range gjson.GetBytes(body, "messages").Array() {
+		redactContent(updates, fmt.Sprintf("messages.%d.content", i), message.Get("content"), redact)
+	}
+	for path, text := range updates {
+		if body, err = sjson.SetBytes(body, path, text); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// redactContent adds to updates the redacted text of content, which is either
+// a string or an array of content blocks, at path.
+func redactContent(updates map[string]string, path string, content gjson.Result, redact func(string) string) {
+	update := func(path string, text gjson.Result) {
+		if redacted := redact(text.String()); redacted != text.String() {
+			updates[path] = redacted
+		}
+	}
+	if content.Type == gjson.String {
+		update(path, content)
+		return
+	}
+	for j, block := range content.Array() {
+		blockPath := fmt.Sprintf("%s.%d", path, j)
+		switch block.Get("type").String() {
+		case "text":
+			update(blockPath+".text", block.Get("text"))
+		case "tool_result":
+			redactContent(updates, blockPath+".content", block.Get("content"), redact)
+		case "document":
+			switch block.Get("source.type").String() {
+			case "text":
+				update(blockPath+".source.data", block.Get("source.data"))
+			case "content":
+				redactContent(updates, blockPath+".source.content", block.Get("source.content"), redact)
+			}
+		}
+	}
+}
+
+// SamplingDefaults are the defaults applied by [WithModelDefaults] to the
+// requests for a model.
+type SamplingDefaults struct {
+	// Temperature and TopP are applied together, to requests which set neither,
+	// since only one of them should be adjusted at a time.
+	Temperature param.Opt[float64]
+	TopP        param.Opt[float64]
+	// MaxTokens is applied to requests whose max_tokens is zero. Zero means no
+	// default.
+	MaxTokens int64
+}
+
+// WithModelDefaults returns a RequestOption that fills in the sampling
+// parameters left unset by a request from the defaults for its model, so that
+// they are kept in one place rather than repeated at each call site. Values set
+// in the params of a request always take precedence, and requests for models
+// without defaults are left untouched. Only message creation requests are
+// affected.
+//
+//	client := anthropic.NewClient(option.WithModelDefaults(map[anthropic.Model]option.SamplingDefaults{
+//		anthropic.ModelClaudeSonnet4_5: {Temperature: anthropic.Float(0.3), MaxTokens: 4096},
+//		anthropic.ModelClaudeHaiku4_5:  {TopP: anthropic.Float(0.9), MaxTokens: 1024},
+//	}))
+//
+// WithModelDefaults panics if a temperature is outside [0, 1], a top_p is
+// outside (0, 1] or max_tokens is negative.
+func WithModelDefaults[M ~string](defaults map[M]SamplingDefaults) RequestOption {
+	for model, d := range defaults {
+		if d.Temperature.Valid() && (d.Temperature.Value < 0 || d.Temperature.Value > 1) {
+			panic(fmt.Sprintf("option: default temperature %v for %s is outside [0, 1]", d.Temperature.Value, model))
+		}
+		if d.TopP.Valid() && (d.TopP.Value <= 0 || d.TopP.Value > 1) {
+			panic(fmt.Sprintf("option: default top_p %v for %s is outside (0, 1]", d.TopP.Value, model))
+		}
+		if d.MaxTokens < 0 {
+			panic(fmt.Sprintf("option: default max_tokens for %s cannot be negative", model))
+		}
+	}
+	defaults = maps.Clone(defaults)
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		// Other endpoints taking a model, such as token counting, reject the
+		// sampling parameters.
+		if !strings.HasSuffix(r.Request.URL.Path, "v1/messages") {
+			return nil
+		}
+		return r.RewriteJSONBody(func(body []byte) ([]byte, error) {
+			d, ok := defaults[M(gjson.GetBytes(body, "model").String())]
+			if !ok {
+				return body, nil
+			}
+			return applySamplingDefaults(body, d)
+		})
+	})
+}
+
+func applySamplingDefaults(body []byte, d SamplingDefaults) (_ []byte, err error) {
+	if d.MaxTokens > 0 && gjson.GetBytes(body, "max_tokens").Int() == 0 {
+		if body, err = sjson.SetBytes(body, "max_tokens", d.MaxTokens); err != nil {
+			return nil, err
+		}
+	}
+	if gjson.GetBytes(body, "temperature").Exists() || gjson.GetBytes(body, "top_p").Exists() {
+		return body, nil
+	}
+	if d.Temperature.Valid() {
+		if body, err = sjson.SetBytes(body, "temperature", d.Temperature.Value); err != nil {
+			return nil, err
+		}
+	}
+	if d.TopP.Valid() {
+		if body, err = sjson.SetBytes(body, "top_p", d.TopP.Value); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
@@ -0,0 +1,158 @@
+package option
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+	"github.com/sofianhadi1983/anthropic-sdk-go/packages/param"
+)
+
+// applyToBody applies opt to a request config holding body and returns the
+// resulting body.
+func applyToBody(t *testing.T, body string, opt RequestOption) string {
+	t.Helper()
+	cfg, err := requestconfig.NewRequestConfig(context.Background(), http.MethodPost, "v1/messages", []byte(body), nil, opt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return cfg.Body.(*bytes.Buffer).String()
+}
+
+func TestWithTrimAssistantWhitespace(t *testing.T) {
+	body := `{"messages":[` +
+		`{"role":"user","content":"hi  "},` +
+		`{"role":"assistant","content":[{"type":"text","text":"Hello \n"},{"type":"tool_use","id":"t","name":"n","input":{}}]},` +
+		`{"role":"assistant","content":"{  "}]}`
+
+	got := applyToBody(t, body, WithTrimAssistantWhitespace())
+
+	expected := `{"messages":[` +
+		`{"role":"user","content":"hi  "},` +
+		`{"role":"assistant","content":[{"type":"text","text":"Hello"},{"type":"tool_use","id":"t","name":"n","input":{}}]},` +
+		`{"role":"assistant","content":"{"}]}`
+	if got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	if got := applyToBody(t, `not json`, WithTrimAssistantWhitespace()); got != `not json` {
+		t.Errorf("expected non-JSON bodies to be left untouched, got %s", got)
+	}
+}
+
+func TestTemperatureGuards(t *testing.T) {
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+
+	if got := applyToBody(t, `{"temperature":1.5}`, WithTemperatureClamp(logger)); got != `{"temperature":1}` {
+		t.Errorf("expected the temperature to be clamped, got %s", got)
+	}
+	if !strings.Contains(logs.String(), "clamping to 1") {
+		t.Errorf("expected a clamp warning, got %q", logs.String())
+	}
+
+	_, err := requestconfig.NewRequestConfig(context.Background(), http.MethodPost, "v1/messages", []byte(`{"temperature":-0.1}`), nil, WithTemperatureValidation(logger))
+	if err == nil {
+		t.Error("expected an error for an out of range temperature")
+	}
+
+	logs.Reset()
+	if got := applyToBody(t, `{"temperature":0.5,"top_p":0.9}`, WithTemperatureValidation(logger)); got != `{"temperature":0.5,"top_p":0.9}` {
+		t.Errorf("expected the body to be left untouched, got %s", got)
+	}
+	if !strings.Contains(logs.String(), "top_p") {
+		t.Errorf("expected a warning about setting both temperature and top_p, got %q", logs.String())
+	}
+}
+
+func TestWithoutTools(t *testing.T) {
+	tools := `"tools":[{"name":"get_weather","input_schema":{"type":"object"}}],"tool_choice":{"type":"any"}`
+
+	got := applyToBody(t, `{"messages":[{"role":"user","content":"hi"}],`+tools+`}`, WithoutTools())
+	if expected := `{"messages":[{"role":"user","content":"hi"}]}`; got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	history := `{"messages":[` +
+		`{"role":"user","content":"hi"},` +
+		`{"role":"assistant","content":[{"type":"tool_use","id":"t","name":"get_weather","input":{}}]},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t","content":"sunny"}]}],`
+	got = applyToBody(t, history+tools+`}`, WithoutTools())
+	if expected := history + strings.Replace(tools, `{"type":"any"}`, `{"type":"none"}`, 1) + `}`; got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	if got := applyToBody(t, `{"messages":[]}`, WithoutTools()); got != `{"messages":[]}` {
+		t.Errorf("expected the body to be unchanged, got %s", got)
+	}
+}
+
+func TestWithOutgoingRedactor(t *testing.T) {
+	redact := WithOutgoingRedactor(func(text string) string {
+		return strings.ReplaceAll(text, "ada@example.com", "[email]")
+	})
+	body := `{"system":"Reply to ada@example.com","messages":[` +
+		`{"role":"user","content":"I am ada@example.com"},` +
+		`{"role":"assistant","content":[{"type":"text","text":"Hi ada@example.com"},{"type":"tool_use","id":"t","name":"lookup","input":{"email":"ada@example.com"}}]},` +
+		`{"role":"user","content":[` +
+		`{"type":"tool_result","tool_use_id":"t","content":[{"type":"text","text":"ada@example.com: found"}]},` +
+		`{"type":"document","source":{"type":"text","media_type":"text/plain","data":"From ada@example.com"}},` +
+		`{"type":"image","source":{"type":"url","url":"https://example.com/ada@example.com.png"}}]}]}`
+
+	got := applyToBody(t, body, redact)
+	expected := strings.NewReplacer(
+		"Reply to ada@example.com", "Reply to [email]",
+		"I am ada@example.com", "I am [email]",
+		"Hi ada@example.com", "Hi [email]",
+		`"ada@example.com: found"`, `"[email]: found"`,
+		"From ada@example.com", "From [email]",
+	).Replace(body)
+	if got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	system := `{"system":[{"type":"text","text":"ada@example.com"}],"messages":[]}`
+	if got := applyToBody(t, system, redact); got != `{"system":[{"type":"text","text":"[email]"}],"messages":[]}` {
+		t.Errorf("expected the system blocks to be redacted, got %s", got)
+	}
+}
+
+func TestWithModelDefaults(t *testing.T) {
+	type model string
+	opt := WithModelDefaults(map[model]SamplingDefaults{
+		"claude-a": {Temperature: param.NewOpt(0.3), MaxTokens: 4096},
+		"claude-b": {TopP: param.NewOpt(0.9)},
+	})
+
+	for body, expected := range map[string]string{
+		`{"model":"claude-a","max_tokens":0}`:                  `{"model":"claude-a","max_tokens":4096,"temperature":0.3}`,
+		`{"model":"claude-a","max_tokens":10,"temperature":1}`: `{"model":"claude-a","max_tokens":10,"temperature":1}`,
+		`{"model":"claude-a","max_tokens":10,"top_p":0.5}`:     `{"model":"claude-a","max_tokens":10,"top_p":0.5}`,
+		`{"model":"claude-b","max_tokens":10}`:                 `{"model":"claude-b","max_tokens":10,"top_p":0.9}`,
+		`{"model":"claude-c","max_tokens":0}`:                  `{"model":"claude-c","max_tokens":0}`,
+	} {
+		if got := applyToBody(t, body, opt); got != expected {
+			t.Errorf("for %s, expected %s, got %s", body, expected, got)
+		}
+	}
+
+	body := `{"model":"claude-a","messages":[]}`
+	cfg, err := requestconfig.NewRequestConfig(context.Background(), http.MethodPost, "v1/messages/count_tokens?beta=true", []byte(body), nil, opt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Body.(*bytes.Buffer).String(); got != body {
+		t.Errorf("expected token counting requests to be left untouched, got %s", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected an out of range default to panic")
+		}
+	}()
+	WithModelDefaults(map[string]SamplingDefaults{"claude-a": {Temperature: param.NewOpt(1.5)}})
+}
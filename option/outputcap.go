@@ -0,0 +1,121 @@
+package option
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+	"github.com/tidwall/gjson"
+)
+
+// WithHardOutputCap returns a RequestOption that ends a streaming response once
+// the output generated so far exceeds roughly n tokens. The stream is closed
+// and ends with a message_delta event whose stop reason is
+// anthropic.StopReasonClientOutputCap, followed by message_stop, so the
+// accumulated message is well formed.
+//
+// The API only reports output tokens at the end of a message, so the count is
+// estimated from the length of the text, thinking and tool input deltas. It is a
+// client-side safety net against runaway generation and does not replace
+// max_tokens. Non-streaming requests are unaffected.
+func WithHardOutputCap(n int) RequestOption {
+	return WithMiddleware(func(req *http.Request, next MiddlewareNext) (*http.Response, error) {
+		res, err := next(req)
+		if err != nil || res.Body == nil || !strings.HasPrefix(res.Header.Get("content-type"), "text/event-stream") {
+			return res, err
+		}
+		res.Body = &outputCapBody{rc: res.Body, br: bufio.NewReader(res.Body), limit: n, openIndex: -1}
+		return res, nil
+	})
+}
+
+// outputCapBody passes an event stream through event by event, counting the
+// characters of content deltas, and ends it once the estimated output tokens
+// exceed limit.
+type outputCapBody struct {
+	rc        io.ReadCloser
+	br        *bufio.Reader
+	limit     int
+	chars     int
+	openIndex int64
+	event     bytes.Buffer
+	pending   bytes.Buffer
+	done      bool
+	err       error
+}
+
+func (b *outputCapBody) tokens() int {
+	return (b.chars + 3) / 4
+}
+
+func (b *outputCapBody) Read(p []byte) (int, error) {
+	for b.pending.Len() == 0 && !b.done {
+		line, err := b.br.ReadBytes('\n')
+		b.event.Write(line)
+		if len(bytes.TrimRight(line, "\r\n")) == 0 && len(line) > 0 {
+			b.handleEvent()
+		}
+		if err != nil {
+			b.pending.Write(b.event.Bytes())
+			b.event.Reset()
+			b.done = true
+			b.err = err
+		}
+	}
+	if b.pending.Len() == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		return 0, io.EOF
+	}
+	return b.pending.Read(p)
+}
+
+// handleEvent forwards the buffered event and ends the stream if it pushes the
+// output over the cap.
+func (b *outputCapBody) handleEvent() {
+	raw := b.event.Bytes()
+	b.pending.Write(raw)
+	defer b.event.Reset()
+
+	var data []byte
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if v, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r"), []byte("data:")); ok {
+			data = append(data, bytes.TrimPrefix(v, []byte(" "))...)
+		}
+	}
+	event := gjson.ParseBytes(data)
+	switch event.Get("type").String() {
+	case "content_block_start":
+		b.openIndex = event.Get("index").Int()
+	case "content_block_stop":
+		b.openIndex = -1
+	case "content_block_delta":
+		delta := event.Get("delta")
+		for _, key := range []string{"text", "partial_json", "thinking"} {
+			b.chars += utf8.RuneCountInString(delta.Get(key).String())
+		}
+		if b.tokens() > b.limit {
+			b.capStream()
+		}
+	}
+}
+
+func (b *outputCapBody) capStream() {
+	if b.openIndex >= 0 {
+		fmt.Fprintf(&b.pending, "event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":%d}\n\n", b.openIndex)
+	}
+	fmt.Fprintf(&b.pending, "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":%q,\"stop_sequence\":null},\"usage\":{\"output_tokens\":%d}}\n\n", requestconfig.StopReasonClientOutputCap, b.tokens())
+	b.pending.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	b.done = true
+	b.rc.Close()
+}
+
+func (b *outputCapBody) Close() error {
+	return b.rc.Close()
+}
@@ -0,0 +1,148 @@
+package option
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// adaptiveThreshold is the fraction of a rate limit below which
+// [WithAdaptiveRateLimiter] starts spacing requests out.
+const adaptiveThreshold = 0.2
+
+// rateLimitKinds are the limits reported in the anthropic-ratelimit-* headers.
+var rateLimitKinds = []string{"requests", "tokens", "input-tokens", "output-tokens"}
+
+// WithRateLimiter returns a RequestOption which spaces requests out evenly so
+// that at most requestsPerMinute are sent per minute, waiting before sending a
+// request when needed. Retries count as requests. The limit is shared by all
+// requests made with the option, so pass it to the client rather than to each
+// request.
+//
+// WithRateLimiter panics when requestsPerMinute is not positive.
+func WithRateLimiter(requestsPerMinute int) RequestOption {
+	if requestsPerMinute <= 0 {
+		panic("option: requestsPerMinute must be positive")
+	}
+	l := &rateLimiter{interval: time.Minute / time.Duration(requestsPerMinute)}
+	return WithMiddleware(l.middleware)
+}
+
+// WithAdaptiveRateLimiter returns a RequestOption which paces requests based on
+// the anthropic-ratelimit-*-remaining, -limit and -reset headers of the
+// responses, to avoid 429 errors under load. Requests are not delayed while
+// plenty of every limit remains. As the remaining requests or tokens of a limit
+// fall below 20%, requests are spaced out further, up to waiting for the reset
+// once the limit is exhausted, and pacing returns to normal as the remaining
+// capacity recovers.
+//
+// Like [WithRateLimiter], the state is shared by all requests made with the
+// option, so pass it to the client. Both options can be combined.
+func WithAdaptiveRateLimiter() RequestOption {
+	l := &rateLimiter{adaptive: true}
+	return WithMiddleware(l.middleware)
+}
+
+type rateLimiter struct {
+	interval time.Duration
+	adaptive bool
+
+	mu sync.Mutex
+	// last is the time the last request was sent, or is due to be sent.
+	last time.Time
+	// spacing is the interval between requests derived from the last response.
+	spacing time.Duration
+	// pausedUntil is the reset time of an exhausted limit.
+	pausedUntil time.Time
+}
+
+func (l *rateLimiter) middleware(req *http.Request, next MiddlewareNext) (*http.Response, error) {
+	if err := l.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	res, err := next(req)
+	if l.adaptive && res != nil {
+		l.observe(res.Header, time.Now())
+	}
+	return res, err
+}
+
+// wait reserves the next slot for a request and sleeps until it.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	// The spacing is applied when the slot is taken, so that it can shrink
+	// again as soon as the remaining capacity recovers.
+	at := l.last.Add(max(l.interval, l.spacing))
+	if now.After(at) {
+		at = now
+	}
+	if l.pausedUntil.After(at) {
+		at = l.pausedUntil
+	}
+	l.last = at
+	l.mu.Unlock()
+
+	if !at.After(now) {
+		return nil
+	}
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// observe updates the pacing from the rate limit headers of a response.
+// Responses without them, such as some errors, leave it unchanged.
+func (l *rateLimiter) observe(header http.Header, now time.Time) {
+	var seen bool
+	var spacing time.Duration
+	var pausedUntil time.Time
+	for _, kind := range rateLimitKinds {
+		prefix := "anthropic-ratelimit-" + kind
+		limit, err := strconv.ParseFloat(header.Get(prefix+"-limit"), 64)
+		if err != nil || limit <= 0 {
+			continue
+		}
+		remaining, err := strconv.ParseFloat(header.Get(prefix+"-remaining"), 64)
+		if err != nil {
+			continue
+		}
+		reset, err := time.Parse(time.RFC3339, header.Get(prefix+"-reset"))
+		if err != nil {
+			continue
+		}
+		seen = true
+		untilReset := reset.Sub(now)
+		if untilReset <= 0 {
+			continue
+		}
+		if remaining <= 0 {
+			if reset.After(pausedUntil) {
+				pausedUntil = reset
+			}
+			continue
+		}
+		// Spacing grows linearly from nothing at the threshold to the whole
+		// time until the reset as the limit runs out.
+		if fraction := remaining / limit; fraction < adaptiveThreshold {
+			spacing = max(spacing, time.Duration(float64(untilReset)*(1-fraction/adaptiveThreshold)))
+		}
+	}
+	if !seen {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.spacing = spacing
+	if pausedUntil.After(l.pausedUntil) {
+		l.pausedUntil = pausedUntil
+	}
+}
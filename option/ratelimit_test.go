@@ -0,0 +1,70 @@
+package option
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func rateLimitHeader(kind string, limit, remaining string, reset time.Time) http.Header {
+	prefix := "anthropic-ratelimit-" + kind
+	return http.Header{
+		http.CanonicalHeaderKey(prefix + "-limit"):     {limit},
+		http.CanonicalHeaderKey(prefix + "-remaining"): {remaining},
+		http.CanonicalHeaderKey(prefix + "-reset"):     {reset.Format(time.RFC3339)},
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := &rateLimiter{interval: 20 * time.Millisecond}
+	start := time.Now()
+	for range 3 {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected 3 requests to take at least 40ms, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = &rateLimiter{interval: time.Hour}
+	l.wait(ctx)
+	if err := l.wait(ctx); err != context.Canceled {
+		t.Errorf("expected the wait to be canceled, got %v", err)
+	}
+}
+
+func TestAdaptiveRateLimiter(t *testing.T) {
+	now := time.Now()
+	reset := now.Add(100 * time.Second)
+	l := &rateLimiter{adaptive: true}
+
+	l.observe(rateLimitHeader("requests", "100", "50", reset), now)
+	if l.spacing != 0 {
+		t.Errorf("expected no spacing with half the limit remaining, got %s", l.spacing)
+	}
+
+	l.observe(rateLimitHeader("tokens", "1000", "100", reset), now)
+	if l.spacing < 49*time.Second || l.spacing > 51*time.Second {
+		t.Errorf("expected about 50s spacing with 10%% of tokens remaining, got %s", l.spacing)
+	}
+
+	l.observe(http.Header{}, now)
+	if l.spacing == 0 {
+		t.Errorf("expected a response without rate limit headers to keep the spacing")
+	}
+
+	l.observe(rateLimitHeader("requests", "100", "0", reset), now)
+	if !l.pausedUntil.Equal(reset.Truncate(time.Second)) || l.spacing != 0 {
+		t.Errorf("expected requests to pause until the reset, got %s and spacing %s", l.pausedUntil, l.spacing)
+	}
+
+	l = &rateLimiter{adaptive: true}
+	l.observe(rateLimitHeader("output-tokens", "1000", "0", now.Add(-time.Second)), now)
+	if !l.pausedUntil.IsZero() {
+		t.Errorf("expected a past reset to be ignored, got %s", l.pausedUntil)
+	}
+}
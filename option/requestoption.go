@@ -4,10 +4,16 @@ package option
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
+	"maps"
+	"net"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -73,6 +79,55 @@ func WithHTTPClient(client HTTPClient) RequestOption {
 	})
 }
 
+// WithHTTPTransport returns a RequestOption that sends requests with transport,
+// for example to tune its connection pool for many concurrent requests. It is
+// shorthand for [WithHTTPClient] with an [*http.Client] using transport.
+// Middleware and retries are applied on top of it as usual.
+func WithHTTPTransport(transport *http.Transport) RequestOption {
+	client := &http.Client{Transport: transport}
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		if transport == nil {
+			return fmt.Errorf("requestoption: custom http transport cannot be nil")
+		}
+		r.HTTPClient = client
+		r.CustomHTTPDoer = nil
+		return nil
+	})
+}
+
+// WithMaxIdleConnsPerHost returns a RequestOption that keeps up to n idle
+// connections to the API open for reuse, instead of the default of 2 of
+// [http.DefaultTransport], which makes many concurrent requests open and close
+// connections constantly.
+//
+// It applies to the transport of the http client in use, which must be an
+// [*http.Transport]. The transport is copied rather than modified, once per
+// transport, so that connections are pooled across requests, including when
+// the option is supplied to each request.
+func WithMaxIdleConnsPerHost(n int) RequestOption {
+	return withTransportSettings("WithMaxIdleConnsPerHost", strconv.Itoa(n), func(t *http.Transport) {
+		t.MaxIdleConnsPerHost = n
+		if t.MaxIdleConns != 0 && t.MaxIdleConns < n {
+			t.MaxIdleConns = n
+		}
+	})
+}
+
+// WithDisableHTTP2 returns a RequestOption that makes requests over HTTP/1.1
+// only, for example behind a proxy which does not support HTTP/2. Like
+// [WithMaxIdleConnsPerHost], it applies to a copy of the transport in use.
+func WithDisableHTTP2() RequestOption {
+	return withTransportSettings("WithDisableHTTP2", "", func(t *http.Transport) {
+		t.ForceAttemptHTTP2 = false
+		// A non-nil, empty map disables the HTTP/2 support of the transport.
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		if t.TLSClientConfig != nil {
+			t.TLSClientConfig = t.TLSClientConfig.Clone()
+			t.TLSClientConfig.NextProtos = slices.DeleteFunc(t.TLSClientConfig.NextProtos, func(proto string) bool { return proto == "h2" })
+		}
+	})
+}
+
 // MiddlewareNext is a function which is called by a middleware to pass an HTTP request
 // to the next stage in the middleware chain.
 type MiddlewareNext = func(*http.Request) (*http.Response, error)
@@ -132,6 +187,17 @@ func WithHeaderDel(key string) RequestOption {
 	})
 }
 
+// WithoutBeta returns a RequestOption that removes the given beta from the
+// anthropic-beta header of the request. The beta is removed after all
+// middleware has run, so it also subtracts betas merged in by other options,
+// such as the defaults added by the oauth package.
+func WithoutBeta(name string) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		r.OmittedBetas = append(r.OmittedBetas, name)
+		return nil
+	})
+}
+
 // WithQuery returns a RequestOption that sets the query value to the associated key. It overwrites
 // any value if there was one already present.
 func WithQuery(key, value string) RequestOption {
@@ -229,6 +295,32 @@ func WithResponseInto(dst **http.Response) RequestOption {
 	})
 }
 
+// WithCapturedRequest returns a RequestOption that copies the final
+// [*http.Request] sent over the wire into the given address, after all
+// middleware has run. The body of the copy is buffered and can be read again,
+// which is useful for inspecting exactly what was sent when a request fails.
+// If the request is retried, the last attempt is kept.
+//
+// Credential headers such as Authorization and X-Api-Key are redacted. Use
+// [WithCapturedRequestUnredacted] to keep them.
+func WithCapturedRequest(dst **http.Request) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		r.CapturedRequest = dst
+		r.CaptureCredentials = false
+		return nil
+	})
+}
+
+// WithCapturedRequestUnredacted is like [WithCapturedRequest] but does not
+// redact credential headers. Take care not to log the captured request.
+func WithCapturedRequestUnredacted(dst **http.Request) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		r.CapturedRequest = dst
+		r.CaptureCredentials = true
+		return nil
+	})
+}
+
 // WithRequestBody returns a RequestOption that provides a custom serialized body with the given
 // content type.
 //
@@ -261,6 +353,195 @@ func WithRequestTimeout(dur time.Duration) RequestOption {
 	})
 }
 
+// WithPerCallTimeout returns a RequestOption that bounds the duration of a
+// call, unlike [WithRequestTimeout], which bounds each attempt. For a request
+// which does not stream, the timeout spans every attempt, the retry delays and
+// reading the response. For a streaming request, it only covers connecting and
+// waiting for the first event, so that a stream can run for as long as the
+// model generates once it has started.
+//
+// The timeout is an addition to the deadline of the context of the call, and
+// whichever comes first ends the call. When the timeout expires, the call fails
+// with an error wrapping [context.DeadlineExceeded]. Set on the client, it can
+// be overridden for a call by passing WithPerCallTimeout again, with zero to
+// remove it:
+//
+//	client := anthropic.NewClient(option.WithPerCallTimeout(10 * time.Second))
+//	stream := client.Messages.NewStreaming(ctx, params, option.WithPerCallTimeout(30*time.Second))
+func WithPerCallTimeout(dur time.Duration) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		r.CallTimeout = dur
+		return nil
+	})
+}
+
+// WithTotalDeadline returns a RequestOption that bounds the total time of a
+// call, every attempt and the delays between retries included, to keep it
+// within a latency budget. A retry is not started when the budget left could
+// not fit the retry delay and another attempt as long as the last one: the
+// response or error of the last attempt is returned right away instead.
+//
+// An attempt still running when the budget is exhausted fails with an error
+// wrapping [context.DeadlineExceeded]. The budget also bounds reading the
+// response, including the events of a stream. Unlike [WithPerCallTimeout], it
+// shortens the retries rather than only cancelling the call.
+func WithTotalDeadline(d time.Duration) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		r.TotalDeadline = d
+		return nil
+	})
+}
+
+// ErrStreamIdleTimeout is returned by a stream's Err method when the stream was
+// closed by [WithStreamIdleTimeout].
+var ErrStreamIdleTimeout = requestconfig.ErrStreamIdleTimeout
+
+// WithStreamIdleTimeout returns a RequestOption that fails a streaming response
+// if no data arrives for the given duration. The API sends periodic ping events
+// while a response is being generated, so a stream which stays silent for longer
+// than that is most likely a dead connection. The stream's Err method then
+// returns an error wrapping [ErrStreamIdleTimeout].
+//
+// Only time spent waiting on the network counts towards the timeout, so slow
+// processing of events does not trip it. Non-streaming requests are unaffected.
+func WithStreamIdleTimeout(dur time.Duration) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		r.StreamIdleTimeout = dur
+		return nil
+	})
+}
+
+// ErrRequestBodyTooLarge is returned when a request body exceeds the limit set
+// by [WithRequestBodyLimit].
+var ErrRequestBodyTooLarge = requestconfig.ErrRequestBodyTooLarge
+
+// WithRequestBodyWarnThreshold returns a RequestOption that calls fn with the
+// size of the serialized request body, in bytes, when it exceeds threshold.
+// This helps catch accidentally enormous prompts, which are slow to send and may
+// be rejected by the API. fn is called once per request, before it is sent.
+func WithRequestBodyWarnThreshold(threshold int64, fn func(size int64)) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		r.BodyWarnThreshold = threshold
+		r.BodyWarnFunc = fn
+		return nil
+	})
+}
+
+// WithRequestBodyLimit returns a RequestOption that fails requests whose
+// serialized body exceeds limit bytes with an error wrapping
+// [ErrRequestBodyTooLarge], without sending them. A body of unknown size, such
+// as a reader passed to [WithRequestBody], fails while it is being sent, once
+// the limit is exceeded.
+func WithRequestBodyLimit(limit int64) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		r.BodyLimit = limit
+		return nil
+	})
+}
+
+// WithUserAgentMetadata returns a RequestOption that appends platform tags,
+// such as {"runtime": "lambda", "region": "us-east-1"}, to the User-Agent
+// header as "(region=us-east-1; runtime=lambda)". The tags are appended after
+// all middleware has run, so they are kept when the User-Agent is replaced, for
+// example by oauth.Config.UserAgent. Tags from multiple calls are merged.
+func WithUserAgentMetadata(metadata map[string]string) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		merged := make(map[string]string, len(r.UserAgentMetadata)+len(metadata))
+		maps.Copy(merged, r.UserAgentMetadata)
+		maps.Copy(merged, metadata)
+		r.UserAgentMetadata = merged
+		return nil
+	})
+}
+
+// WithRetryModifier returns a RequestOption that calls fn with the request before
+// each retry, after the retry delay. attempt is the number of the attempt about
+// to be made, starting at 1 for the first retry, and lastErr describes why the
+// previous attempt failed. fn may modify the request in place, for example to
+// change headers or to replace the body; a replaced body is buffered so that it
+// can be sent again on later retries.
+func WithRetryModifier(fn func(req *http.Request, attempt int, lastErr error)) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		r.RetryModifier = fn
+		return nil
+	})
+}
+
+// RetryPolicy configures how requests are retried. See [WithRetryPolicy].
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry, doubled for each
+	// following retry. Defaults to 500ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay before a retry, including a delay requested by
+	// a Retry-After header. Defaults to 8s.
+	MaxBackoff time.Duration
+	// Jitter is the largest fraction, from 0 to 1, by which each delay is
+	// randomly shortened, so that clients do not retry in lockstep.
+	Jitter float64
+}
+
+// WithRetryPolicy returns a RequestOption that sets the number of retries and
+// the backoff between them, in place of [WithMaxRetries] and the default delays.
+// When a response has a Retry-After or Retry-After-Ms header, its delay is used
+// instead, capped at MaxBackoff. Once the retries are exhausted, the error for
+// the last response is returned, an *anthropic.Error whose Response holds the
+// status and headers.
+//
+// WithRetryPolicy panics if MaxRetries is negative or Jitter is outside [0, 1].
+func WithRetryPolicy(policy RetryPolicy) RequestOption {
+	if policy.MaxRetries < 0 {
+		panic("option: cannot have fewer than 0 retries")
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		panic("option: jitter must be between 0 and 1")
+	}
+	backoff := &requestconfig.RetryBackoff{Initial: policy.InitialBackoff, Max: policy.MaxBackoff, Jitter: policy.Jitter}
+	if backoff.Initial <= 0 {
+		backoff.Initial = 500 * time.Millisecond
+	}
+	if backoff.Max <= 0 {
+		backoff.Max = 8 * time.Second
+	}
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		r.MaxRetries = policy.MaxRetries
+		r.RetryBackoff = backoff
+		return nil
+	})
+}
+
+// WithTimeouts returns a RequestOption that limits the time spent connecting to
+// the API, including the TLS handshake, to connect, and the time spent waiting
+// for the response headers once the request is sent to read. Unlike
+// [WithRequestTimeout] and context deadlines, they do not bound the time spent
+// reading the response body, so an unreachable endpoint fails fast while long
+// generations and streams are unaffected. A zero duration leaves the
+// corresponding limit of the transport unchanged.
+//
+// Like [WithMaxIdleConnsPerHost], it applies to a copy of the transport of the
+// http client in use, which must be an [*http.Transport], so that its proxy,
+// TLS and connection pool settings are kept.
+func WithTimeouts(connect, read time.Duration) RequestOption {
+	return withTransportSettings("WithTimeouts", connect.String()+", "+read.String(), func(t *http.Transport) {
+		if connect > 0 {
+			dial := t.DialContext
+			if dial == nil {
+				dial = (&net.Dialer{KeepAlive: 30 * time.Second}).DialContext
+			}
+			t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				ctx, cancel := context.WithTimeout(ctx, connect)
+				defer cancel()
+				return dial(ctx, network, addr)
+			}
+			t.TLSHandshakeTimeout = connect
+		}
+		if read > 0 {
+			t.ResponseHeaderTimeout = read
+		}
+	})
+}
+
 // WithEnvironmentProduction returns a RequestOption that sets the current
 // environment to be the "production" environment. An environment specifies which base URL
 // to use by default.
@@ -0,0 +1,108 @@
+package option
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/tidwall/gjson"
+)
+
+// statusOverloaded is the HTTP status of an overloaded_error.
+const statusOverloaded = 529
+
+// WithEarlyStreamErrorRetry returns a RequestOption that controls whether a
+// streaming request is retried from scratch when the API reports an
+// overloaded_error as an event of the stream, with a 200 status, before any
+// content. Such a response is turned into a 529 response with the error as its
+// body, so that it is retried like an overloaded error reported through the
+// status, following [WithMaxRetries] and the retry delays. If the retries are
+// exhausted, the request fails with that error.
+//
+// To detect the error, the start of the stream is held back until the first
+// content block event arrives. Errors later in the stream are not retried, as
+// part of the response has already been delivered. Requests which do not
+// stream are unaffected.
+func WithEarlyStreamErrorRetry(enabled bool) RequestOption {
+	return WithMiddleware(func(req *http.Request, next MiddlewareNext) (*http.Response, error) {
+		if !enabled || req.GetBody == nil {
+			return next(req)
+		}
+		body, err := readRequestBody(req)
+		if err != nil || !gjson.GetBytes(body, "stream").Bool() {
+			return next(req)
+		}
+		res, err := next(req)
+		if err != nil || !isEventStream(res) {
+			return res, err
+		}
+
+		var head bytes.Buffer
+		r := bufio.NewReader(res.Body)
+		for {
+			raw, data, err := readRawEvent(r)
+			head.Write(raw)
+			if err != nil {
+				break
+			}
+			event := gjson.Parse(data)
+			typ := event.Get("type").String()
+			if typ == "error" && event.Get("error.type").String() == "overloaded_error" {
+				res.Body.Close()
+				return overloadedResponse(req, res, data), nil
+			}
+			if typ == "content_block_start" || typ == "content_block_delta" {
+				break
+			}
+		}
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(&head, r), res.Body}
+		return res, nil
+	})
+}
+
+// readRawEvent reads the next event like readEvent, and returns its bytes as
+// read along with its data, so that it can be passed on unchanged. The bytes
+// read before an error are returned too.
+func readRawEvent(r *bufio.Reader) (raw []byte, data string, err error) {
+	hasData := false
+	for {
+		line, err := r.ReadBytes('\n')
+		raw = append(raw, line...)
+		if err != nil {
+			return raw, "", err
+		}
+		switch {
+		case bytes.HasPrefix(line, []byte("data:")):
+			hasData = true
+		case len(bytes.TrimRight(line, "\r\n")) == 0 && hasData:
+			_, data, err = readEvent(bufio.NewReader(bytes.NewReader(raw)))
+			return raw, data, err
+		}
+	}
+}
+
+// overloadedResponse returns a response reporting the error event in data
+// with the status of an overloaded_error.
+func overloadedResponse(req *http.Request, res *http.Response, data string) *http.Response {
+	header := res.Header.Clone()
+	header.Set("Content-Type", "application/json")
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	header.Del("X-Should-Retry")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusOverloaded, "Overloaded"),
+		StatusCode:    statusOverloaded,
+		Proto:         res.Proto,
+		ProtoMajor:    res.ProtoMajor,
+		ProtoMinor:    res.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(data))),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}
+}
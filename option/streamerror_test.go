@@ -0,0 +1,76 @@
+package option
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/apierror"
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+)
+
+func TestWithEarlyStreamErrorRetry(t *testing.T) {
+	overloaded := sseEvent(`{"type":"message_start","message":{"id":"msg_1","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`) +
+		sseEvent(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+	complete := sseEvent(`{"type":"message_start","message":{"id":"msg_2","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`) +
+		sseEvent(`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`) +
+		sseEvent(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`) +
+		sseEvent(`{"type":"content_block_stop","index":0}`) +
+		sseEvent(`{"type":"message_stop"}`)
+
+	send := func(enabled bool, streams ...string) (string, int, error) {
+		var requests int
+		client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requests++
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"text/event-stream"}},
+				Body:       io.NopCloser(strings.NewReader(streams[min(requests, len(streams))-1])),
+			}, nil
+		})}
+		var res *http.Response
+		cfg, err := requestconfig.NewRequestConfig(context.Background(), http.MethodPost, "v1/messages", []byte(`{"model":"m","stream":true}`), &res,
+			WithBaseURL("http://localhost/"), WithHTTPClient(client), WithRetryPolicy(RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond}),
+			WithEarlyStreamErrorRetry(enabled))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := cfg.Execute(); err != nil {
+			return "", requests, err
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		return string(body), requests, err
+	}
+
+	body, requests, err := send(true, overloaded, complete)
+	if err != nil || body != complete || requests != 2 {
+		t.Errorf("expected the request to be retried, got %d requests, error %v and:\n%s", requests, err, body)
+	}
+
+	_, requests, err = send(true, overloaded)
+	var apierr *apierror.Error
+	if !errors.As(err, &apierr) || apierr.StatusCode != 529 || requests != 3 {
+		t.Errorf("expected an overloaded error after 3 requests, got %d requests and %v", requests, err)
+	}
+
+	body, requests, err = send(false, overloaded, complete)
+	if err != nil || body != overloaded || requests != 1 {
+		t.Errorf("expected the error event to be delivered when disabled, got %d requests, error %v and:\n%s", requests, err, body)
+	}
+
+	// The events held back are passed on byte for byte, even when their data
+	// spans several lines.
+	multiline := "event: message_start\r\ndata: {\"type\":\"message_start\",\r\ndata: \"message\":{\"id\":\"msg_3\",\"content\":[]}}\r\n\r\n" +
+		": keep-alive\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\ndata: \"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+		sseEvent(`{"type":"message_stop"}`)
+	body, requests, err = send(true, multiline)
+	if err != nil || body != multiline || requests != 1 {
+		t.Errorf("expected the stream to be passed on unchanged, got %d requests, error %v and:\n%q", requests, err, body)
+	}
+}
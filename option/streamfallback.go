@@ -0,0 +1,223 @@
+package option
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// streamingFallbackTimeout is how long [WithStreamingFallback] waits for the
+// first bytes of a streaming response.
+var streamingFallbackTimeout = 30 * time.Second
+
+// WithStreamingFallback returns a RequestOption that retries a streaming
+// message request without streaming when streaming does not work, as happens
+// behind proxies which buffer server-sent events. The fallback is used if the
+// response to the streaming request is not a text/event-stream, or if none of
+// it arrives within 30 seconds. The complete message is then turned into the
+// events of an equivalent stream, with one delta per content block, so code
+// reading the stream works unchanged.
+//
+// Requests which do not stream are unaffected. A request which falls back is
+// sent twice and may be billed twice if the first one completes upstream.
+func WithStreamingFallback() RequestOption {
+	return WithMiddleware(func(req *http.Request, next MiddlewareNext) (*http.Response, error) {
+		if req.GetBody == nil {
+			return next(req)
+		}
+		body, err := readRequestBody(req)
+		if err != nil || !gjson.GetBytes(body, "stream").Bool() {
+			return next(req)
+		}
+
+		res, err := tryStreaming(req, next)
+		if res != nil || err != nil {
+			return res, err
+		}
+
+		body, err = sjson.DeleteBytes(body, "stream")
+		if err != nil {
+			return nil, err
+		}
+		fallback := req.Clone(req.Context())
+		fallback.Body = io.NopCloser(bytes.NewReader(body))
+		fallback.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		fallback.ContentLength = int64(len(body))
+		res, err = next(fallback)
+		if err != nil || res.StatusCode < 200 || res.StatusCode >= 300 {
+			return res, err
+		}
+		message, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		events := messageStreamEvents(message)
+		res.Header.Set("Content-Type", "text/event-stream")
+		res.Body = io.NopCloser(bytes.NewReader(events))
+		res.ContentLength = int64(len(events))
+		return res, nil
+	})
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// tryStreaming sends the streaming request and waits for the first bytes of
+// the event stream. It returns neither a response nor an error if the request
+// should be retried without streaming.
+func tryStreaming(req *http.Request, next MiddlewareNext) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+
+	type result struct {
+		res    *http.Response
+		stream bool
+		first  []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := next(req.WithContext(ctx))
+		if err != nil || res.StatusCode < 200 || res.StatusCode >= 300 || !strings.HasPrefix(res.Header.Get("content-type"), "text/event-stream") {
+			done <- result{res: res, err: err}
+			return
+		}
+		buf := make([]byte, 4096)
+		var n int
+		for n == 0 && err == nil {
+			n, err = res.Body.Read(buf)
+		}
+		done <- result{res: res, stream: true, first: buf[:n]}
+	}()
+
+	// abandon cancels the streaming request and closes its response, if any, once
+	// it arrives.
+	abandon := func() {
+		cancel()
+		go func() {
+			if r := <-done; r.res != nil && r.res.Body != nil {
+				r.res.Body.Close()
+			}
+		}()
+	}
+
+	timer := time.NewTimer(streamingFallbackTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		switch {
+		case r.err != nil:
+			cancel()
+			return nil, r.err
+		case r.res.StatusCode < 200 || r.res.StatusCode >= 300:
+			r.res.Body = &cancelOnClose{Reader: r.res.Body, rc: r.res.Body, cancel: cancel}
+			return r.res, nil
+		case !r.stream:
+			r.res.Body.Close()
+			cancel()
+			return nil, nil
+		}
+		r.res.Body = &cancelOnClose{Reader: io.MultiReader(bytes.NewReader(r.first), r.res.Body), rc: r.res.Body, cancel: cancel}
+		return r.res, nil
+	case <-timer.C:
+		abandon()
+		return nil, nil
+	case <-req.Context().Done():
+		abandon()
+		return nil, req.Context().Err()
+	}
+}
+
+// cancelOnClose keeps the context of a request alive until its response body
+// is closed.
+type cancelOnClose struct {
+	io.Reader
+	rc     io.Closer
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.rc.Close()
+}
+
+// messageStreamEvents renders a complete message as the server-sent events of a
+// stream producing the same message.
+func messageStreamEvents(message []byte) []byte {
+	var b bytes.Buffer
+	writeEvent := func(event string, data string) {
+		fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", event, data)
+	}
+
+	m := gjson.ParseBytes(message)
+	start := m.Raw
+	start, _ = sjson.SetRaw(start, "content", "[]")
+	start, _ = sjson.SetRaw(start, "stop_reason", "null")
+	start, _ = sjson.SetRaw(start, "stop_sequence", "null")
+	event, _ := sjson.SetRaw(`{"type":"message_start"}`, "message", start)
+	writeEvent("message_start", event)
+
+	for i, block := range m.Get("content").Array() {
+		contentBlock := block.Raw
+		var deltas []string
+		switch block.Get("type").String() {
+		case "text":
+			contentBlock, _ = sjson.Set(contentBlock, "text", "")
+			delta, _ := sjson.Set(`{"type":"text_delta"}`, "text", block.Get("text").String())
+			deltas = append(deltas, delta)
+			if citations := block.Get("citations"); citations.IsArray() {
+				contentBlock, _ = sjson.SetRaw(contentBlock, "citations", "[]")
+				for _, citation := range citations.Array() {
+					delta, _ := sjson.SetRaw(`{"type":"citations_delta"}`, "citation", citation.Raw)
+					deltas = append(deltas, delta)
+				}
+			}
+		case "tool_use", "server_tool_use":
+			contentBlock, _ = sjson.SetRaw(contentBlock, "input", "{}")
+			delta, _ := sjson.Set(`{"type":"input_json_delta"}`, "partial_json", block.Get("input").Raw)
+			deltas = append(deltas, delta)
+		case "thinking":
+			contentBlock, _ = sjson.Set(contentBlock, "thinking", "")
+			contentBlock, _ = sjson.Set(contentBlock, "signature", "")
+			delta, _ := sjson.Set(`{"type":"thinking_delta"}`, "thinking", block.Get("thinking").String())
+			deltas = append(deltas, delta)
+			delta, _ = sjson.Set(`{"type":"signature_delta"}`, "signature", block.Get("signature").String())
+			deltas = append(deltas, delta)
+		}
+
+		event, _ := sjson.SetRaw(fmt.Sprintf(`{"type":"content_block_start","index":%d}`, i), "content_block", contentBlock)
+		writeEvent("content_block_start", event)
+		for _, delta := range deltas {
+			event, _ := sjson.SetRaw(fmt.Sprintf(`{"type":"content_block_delta","index":%d}`, i), "delta", delta)
+			writeEvent("content_block_delta", event)
+		}
+		writeEvent("content_block_stop", fmt.Sprintf(`{"type":"content_block_stop","index":%d}`, i))
+	}
+
+	delta := `{"type":"message_delta","delta":{"stop_reason":null,"stop_sequence":null}}`
+	if v := m.Get("stop_reason"); v.Exists() {
+		delta, _ = sjson.SetRaw(delta, "delta.stop_reason", v.Raw)
+	}
+	if v := m.Get("stop_sequence"); v.Exists() {
+		delta, _ = sjson.SetRaw(delta, "delta.stop_sequence", v.Raw)
+	}
+	if v := m.Get("usage"); v.Exists() {
+		delta, _ = sjson.SetRaw(delta, "usage", v.Raw)
+	}
+	writeEvent("message_delta", delta)
+	writeEvent("message_stop", `{"type":"message_stop"}`)
+	return b.Bytes()
+}
@@ -0,0 +1,111 @@
+package option
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+const fallbackMessage = `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929",` +
+	`"content":[{"type":"text","text":"Hello"},{"type":"tool_use","id":"toolu_1","name":"lookup","input":{"q":"x"}}],` +
+	`"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5}}`
+
+// streamWithFallback sends a streaming request through [WithStreamingFallback]
+// and returns the response body along with the stream flag of each request sent.
+func streamWithFallback(t *testing.T, streamResponse func(req *http.Request) *http.Response) (string, []bool) {
+	t.Helper()
+	var streamed []bool
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		stream := strings.Contains(string(body), `"stream":true`)
+		streamed = append(streamed, stream)
+		if stream {
+			return streamResponse(req), nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(fallbackMessage)),
+		}, nil
+	})}
+
+	var res *http.Response
+	cfg, err := requestconfig.NewRequestConfig(context.Background(), http.MethodPost, "v1/messages", []byte(`{"model":"m","stream":true}`), &res,
+		WithBaseURL("http://localhost/"), WithHTTPClient(client), WithMaxRetries(0), WithStreamingFallback())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cfg.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected an event stream, got %q", ct)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return string(body), streamed
+}
+
+func TestWithStreamingFallback(t *testing.T) {
+	defer func(d time.Duration) { streamingFallbackTimeout = d }(streamingFallbackTimeout)
+	streamingFallbackTimeout = 50 * time.Millisecond
+
+	expected := "event: message_start\n" +
+		`data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5}}}` + "\n\n" +
+		"event: content_block_start\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}` + "\n\n" +
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}` + "\n\n" +
+		"event: content_block_stop\n" + `data: {"type":"content_block_stop","index":0}` + "\n\n" +
+		"event: content_block_start\n" + `data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}` + "\n\n" +
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"q\":\"x\"}"}}` + "\n\n" +
+		"event: content_block_stop\n" + `data: {"type":"content_block_stop","index":1}` + "\n\n" +
+		"event: message_delta\n" + `data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"input_tokens":10,"output_tokens":5}}` + "\n\n" +
+		"event: message_stop\n" + `data: {"type":"message_stop"}` + "\n\n"
+
+	t.Run("buffered", func(t *testing.T) {
+		body, streamed := streamWithFallback(t, func(req *http.Request) *http.Response {
+			pr, pw := io.Pipe()
+			go func() {
+				<-req.Context().Done()
+				pw.CloseWithError(req.Context().Err())
+			}()
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"text/event-stream"}}, Body: pr}
+		})
+		if len(streamed) != 2 || !streamed[0] || streamed[1] {
+			t.Errorf("expected a streaming request then a non-streaming one, got %v", streamed)
+		}
+		if body != expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", expected, body)
+		}
+	})
+
+	t.Run("not an event stream", func(t *testing.T) {
+		body, streamed := streamWithFallback(t, func(req *http.Request) *http.Response {
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"text/html"}}, Body: io.NopCloser(strings.NewReader("<html>"))}
+		})
+		if len(streamed) != 2 || body != expected {
+			t.Errorf("expected a fallback, got requests %v and body:\n%s", streamed, body)
+		}
+	})
+
+	t.Run("streaming works", func(t *testing.T) {
+		stream := "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+		body, streamed := streamWithFallback(t, func(req *http.Request) *http.Response {
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"text/event-stream"}}, Body: io.NopCloser(strings.NewReader(stream))}
+		})
+		if len(streamed) != 1 || body != stream {
+			t.Errorf("expected the stream to pass through, got requests %v and body:\n%s", streamed, body)
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package option
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/streamsplice"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// WithStreamReconnect returns a RequestOption which resumes a streaming message
+// request when the connection breaks before the message_stop event, as happens
+// when a proxy times out a long response. The request is sent again, up to
+// maxAttempts times and with the same backoff as retries, with the text
+// received so far as an assistant prefill, and
+// the new events are spliced into the stream: the repeated message_start event
+// is dropped and content block indexes continue from the blocks already
+// delivered, so a Message or BetaMessage accumulated from the stream is the
+// same as if the connection had not broken. The usage reported by a
+// later message_delta event keeps the input tokens of the original request,
+// rather than counting the prefill again, and adds the output tokens of the
+// connections which broke, estimated from the text they delivered if they
+// broke before reporting them.
+//
+// As with anthropic.ResilientStream, a stream can only be resumed while its
+// content is text: not once a tool use or thinking block has started, nor
+// after an error event. Requests which do not stream are unaffected.
+func WithStreamReconnect(maxAttempts int) RequestOption {
+	return WithMiddleware(func(req *http.Request, next MiddlewareNext) (*http.Response, error) {
+		if maxAttempts <= 0 || req.GetBody == nil {
+			return next(req)
+		}
+		body, err := readRequestBody(req)
+		if err != nil || !gjson.GetBytes(body, "stream").Bool() {
+			return next(req)
+		}
+		res, err := next(req)
+		if err != nil || !isEventStream(res) {
+			return res, err
+		}
+		res.Body = &reconnectingBody{
+			req:         req,
+			next:        next,
+			body:        body,
+			maxAttempts: maxAttempts,
+			rc:          res.Body,
+			r:           bufio.NewReader(res.Body),
+		}
+		res.ContentLength = -1
+		return res, nil
+	})
+}
+
+// reconnectBackoff sets the delays before the attempts to reconnect, the
+// same as the default delays between retries.
+var reconnectBackoff = &requestconfig.RetryBackoff{Initial: 500 * time.Millisecond, Max: 8 * time.Second, Jitter: 0.25}
+
+func isEventStream(res *http.Response) bool {
+	return res.StatusCode >= 200 && res.StatusCode < 300 && strings.HasPrefix(res.Header.Get("content-type"), "text/event-stream")
+}
+
+// reconnectingBody is the body of a streaming response which reconnects when
+// the underlying connection breaks.
+type reconnectingBody struct {
+	req         *http.Request
+	next        MiddlewareNext
+	body        []byte
+	maxAttempts int
+	attempts    int
+
+	mu     sync.Mutex
+	rc     io.ReadCloser
+	closed bool
+
+	r       *bufio.Reader
+	out     bytes.Buffer
+	err     error
+	splicer streamsplice.Splicer
+}
+
+func (b *reconnectingBody) Read(p []byte) (int, error) {
+	for b.out.Len() == 0 && b.err == nil {
+		_, data, err := readEvent(b.r)
+		if err != nil {
+			if b.splicer.Stopped() || !b.reconnect() {
+				b.err = err
+			}
+			continue
+		}
+		for _, data := range b.splicer.Splice(data) {
+			fmt.Fprintf(&b.out, "event: %s\ndata: %s\n\n", gjson.Get(data, "type").String(), data)
+		}
+	}
+	if b.out.Len() > 0 {
+		return b.out.Read(p)
+	}
+	return 0, b.err
+}
+
+func (b *reconnectingBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return b.rc.Close()
+}
+
+// readEvent reads the next event of a stream, skipping comments.
+func readEvent(r *bufio.Reader) (name string, data string, err error) {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if len(lines) > 0 {
+				return name, strings.Join(lines, "\n"), nil
+			}
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			lines = append(lines, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
+
+// reconnect sends the request again with the text received so far as a
+// prefill, and reports whether it could.
+func (b *reconnectingBody) reconnect() bool {
+	if b.req.Context().Err() != nil || b.attempts >= b.maxAttempts {
+		return false
+	}
+	texts, ok := b.splicer.Prefill(gjson.GetBytes(b.body, "thinking.type").String() == "enabled")
+	if !ok {
+		return false
+	}
+	body := b.body
+	if len(texts) > 0 {
+		prefill := `{"role":"assistant","content":[]}`
+		for _, text := range texts {
+			prefill, _ = sjson.Set(prefill, "content.-1", map[string]string{"type": "text", "text": text})
+		}
+		var err error
+		if body, err = sjson.SetRawBytes(body, "messages.-1", []byte(prefill)); err != nil {
+			return false
+		}
+	}
+
+	for b.attempts < b.maxAttempts {
+		timer := time.NewTimer(reconnectBackoff.Delay(b.attempts))
+		select {
+		case <-b.req.Context().Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		if ok, retry := b.resend(body); ok || !retry {
+			return ok
+		}
+	}
+	return false
+}
+
+// resend sends the request again with body, unless the response body was
+// closed meanwhile. It reports whether a stream was received, and otherwise
+// whether to try again.
+func (b *reconnectingBody) resend(body []byte) (ok, retry bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false, false
+	}
+	b.rc.Close()
+	b.attempts++
+	req := b.req.Clone(b.req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	req.ContentLength = int64(len(body))
+	res, err := b.next(req)
+	if err != nil {
+		return false, b.req.Context().Err() == nil
+	}
+	if !isEventStream(res) {
+		res.Body.Close()
+		return false, false
+	}
+	b.rc = res.Body
+	b.r = bufio.NewReader(res.Body)
+	b.splicer.Resume()
+	return true, false
+}
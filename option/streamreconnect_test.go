@@ -0,0 +1,118 @@
+package option
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+	"github.com/tidwall/gjson"
+)
+
+var errConnectionReset = errors.New("connection reset")
+
+// streamWithReconnect sends a streaming request through [WithStreamReconnect],
+// answering the nth request with the nth of streams followed by its error, and
+// returns the response body, the requests sent and the error reading the body.
+func streamWithReconnect(t *testing.T, streams []string, errs []error) (string, []string, error) {
+	t.Helper()
+	var requests []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		requests = append(requests, string(body))
+		n := len(requests) - 1
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/event-stream"}},
+			Body:       io.NopCloser(io.MultiReader(strings.NewReader(streams[n]), errReader{errs[n]})),
+		}, nil
+	})}
+
+	var res *http.Response
+	body := `{"model":"m","messages":[{"role":"user","content":"Hi"}],"stream":true}`
+	cfg, err := requestconfig.NewRequestConfig(context.Background(), http.MethodPost, "v1/messages", []byte(body), &res,
+		WithBaseURL("http://localhost/"), WithHTTPClient(client), WithMaxRetries(0), WithStreamReconnect(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cfg.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	return string(b), requests, err
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func sseEvent(data string) string {
+	return "event: " + gjson.Get(data, "type").String() + "\ndata: " + data + "\n\n"
+}
+
+func TestWithStreamReconnect(t *testing.T) {
+	defer func(backoff *requestconfig.RetryBackoff) { reconnectBackoff = backoff }(reconnectBackoff)
+	reconnectBackoff = &requestconfig.RetryBackoff{Initial: 50 * time.Millisecond, Max: 50 * time.Millisecond}
+	start := sseEvent(`{"type":"message_start","message":{"id":"msg_1","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`)
+	textStart := sseEvent(`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`)
+
+	t.Run("text", func(t *testing.T) {
+		began := time.Now()
+		body, requests, err := streamWithReconnect(t, []string{
+			start + textStart +
+				sseEvent(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello, "}}`),
+			sseEvent(`{"type":"message_start","message":{"id":"msg_2","content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`) +
+				textStart +
+				sseEvent(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`) +
+				sseEvent(`{"type":"content_block_stop","index":0}`) +
+				sseEvent(`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}`) +
+				sseEvent(`{"type":"content_block_stop","index":1}`) +
+				sseEvent(`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"input_tokens":12,"output_tokens":4}}`) +
+				sseEvent(`{"type":"message_stop"}`),
+		}, []error{errConnectionReset, io.EOF})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := start + textStart +
+			sseEvent(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello, "}}`) +
+			sseEvent(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"world"}}`) +
+			sseEvent(`{"type":"content_block_stop","index":0}`) +
+			sseEvent(`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}`) +
+			sseEvent(`{"type":"content_block_stop","index":1}`) +
+			// The 7 characters received before the break count as 2 output tokens.
+			sseEvent(`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"input_tokens":10,"output_tokens":6}}`) +
+			sseEvent(`{"type":"message_stop"}`)
+		if body != expected {
+			t.Errorf("expected the stream to be resumed, got:\n%s", body)
+		}
+		if len(requests) != 2 {
+			t.Fatalf("expected 2 requests, got %d", len(requests))
+		}
+		if elapsed := time.Since(began); elapsed < 50*time.Millisecond {
+			t.Errorf("expected a backoff before reconnecting, took %v", elapsed)
+		}
+		if prefill := gjson.Get(requests[1], "messages.1").Raw; prefill != `{"role":"assistant","content":[{"text":"Hello,","type":"text"}]}` {
+			t.Errorf("expected the text received to be sent as a prefill, got %s", prefill)
+		}
+	})
+
+	t.Run("tool use", func(t *testing.T) {
+		body, requests, err := streamWithReconnect(t, []string{
+			start + textStart +
+				sseEvent(`{"type":"content_block_stop","index":0}`) +
+				sseEvent(`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}`),
+		}, []error{errConnectionReset})
+		if !errors.Is(err, errConnectionReset) {
+			t.Errorf("expected the connection error, got %v", err)
+		}
+		if len(requests) != 1 || !strings.HasSuffix(body, `"input":{}}}`+"\n\n") {
+			t.Errorf("expected the stream not to be resumed, got %d requests and:\n%s", len(requests), body)
+		}
+	})
+}
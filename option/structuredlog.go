@@ -0,0 +1,204 @@
+package option
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// WithStructuredLogger returns a RequestOption which logs the lifecycle of each
+// request to logger, as distinct events with consistent attributes:
+//
+//   - request_start: the first attempt of a request is sent, with method,
+//     path, model, stream and attempt
+//   - retry: a later attempt is sent, with the same attributes, at level Warn
+//   - response: the response headers arrive, with status, request_id, latency
+//     and, for responses which do not stream, tokens or error_type; responses
+//     with a status of 400 or more are logged at level Warn, and attempts
+//     which received no response at level Error, with the error
+//   - stream_event_summary: a streaming response ends, with the number of
+//     events of each type, at level Debug
+//   - stream_complete: a streaming response ends, with stop_reason, tokens and
+//     the latency since the request was sent
+//
+// All events have request_id, once known, model and attempt, so that the
+// events of a request can be correlated. Headers and bodies are never logged,
+// so neither are credentials nor the content of messages.
+//
+//	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+//	client := anthropic.NewClient(option.WithStructuredLogger(logger))
+func WithStructuredLogger(logger *slog.Logger) RequestOption {
+	return WithMiddleware(func(req *http.Request, next MiddlewareNext) (*http.Response, error) {
+		ctx := req.Context()
+		attempt := 1
+		if n, err := strconv.Atoi(req.Header.Get("X-Stainless-Retry-Count")); err == nil {
+			attempt += n
+		}
+		var model string
+		var stream bool
+		if req.GetBody != nil {
+			if body, err := readRequestBody(req); err == nil {
+				model = gjson.GetBytes(body, "model").String()
+				stream = gjson.GetBytes(body, "stream").Bool()
+			}
+		}
+		attrs := []slog.Attr{slog.String("model", model), slog.Int("attempt", attempt)}
+
+		msg, level := "request_start", slog.LevelInfo
+		if attempt > 1 {
+			msg, level = "retry", slog.LevelWarn
+		}
+		logger.LogAttrs(ctx, level, msg, append(attrs,
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+			slog.Bool("stream", stream),
+		)...)
+
+		start := time.Now()
+		res, err := next(req)
+		latency := slog.Duration("latency", time.Since(start))
+		if err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "response", append(attrs, latency, slog.String("error", err.Error()))...)
+			return res, err
+		}
+		// attrs is clipped so that appending to it for one event does not
+		// overwrite the attributes of another.
+		attrs = slices.Clip(append([]slog.Attr{slog.String("request_id", res.Header.Get("Request-Id"))}, attrs...))
+		responseAttrs := append(attrs, slog.Int("status", res.StatusCode), latency)
+		level = slog.LevelInfo
+		if res.StatusCode >= 400 {
+			level = slog.LevelWarn
+		}
+
+		switch {
+		case res.Body == nil:
+		case isEventStream(res):
+			res.Body = &loggedEventStream{
+				rc:     res.Body,
+				br:     bufio.NewReader(res.Body),
+				ctx:    ctx,
+				logger: logger,
+				attrs:  attrs,
+				start:  start,
+				counts: map[string]int{},
+			}
+		case res.StatusCode >= 400 || strings.Contains(res.Header.Get("Content-Type"), "json"):
+			body, readErr := io.ReadAll(res.Body)
+			res.Body.Close()
+			res.Body = io.NopCloser(bytes.NewReader(body))
+			if readErr != nil {
+				break
+			}
+			if res.StatusCode >= 400 {
+				responseAttrs = append(responseAttrs, slog.String("error_type", gjson.GetBytes(body, "error.type").String()))
+			} else if usage := gjson.GetBytes(body, "usage"); usage.Exists() {
+				responseAttrs = append(responseAttrs, tokensAttr(usage, usage))
+			}
+		}
+		logger.LogAttrs(ctx, level, "response", responseAttrs...)
+		return res, nil
+	})
+}
+
+// tokensAttr groups the input tokens reported by start and the output tokens
+// reported by end, which are the same usage for a response which does not
+// stream.
+func tokensAttr(start, end gjson.Result) slog.Attr {
+	return slog.Group("tokens",
+		slog.Int64("input", start.Get("input_tokens").Int()),
+		slog.Int64("output", end.Get("output_tokens").Int()),
+		slog.Int64("cache_creation_input", start.Get("cache_creation_input_tokens").Int()),
+		slog.Int64("cache_read_input", start.Get("cache_read_input_tokens").Int()),
+	)
+}
+
+// loggedEventStream passes an event stream through, and logs a summary of it
+// once it is read to its end or closed.
+type loggedEventStream struct {
+	rc      io.ReadCloser
+	br      *bufio.Reader
+	ctx     context.Context
+	logger  *slog.Logger
+	attrs   []slog.Attr
+	start   time.Time
+	pending []byte
+	once    sync.Once
+
+	counts     map[string]int
+	startUsage gjson.Result
+	endUsage   gjson.Result
+	stopReason string
+	errorType  string
+}
+
+func (b *loggedEventStream) Read(p []byte) (int, error) {
+	if len(b.pending) == 0 {
+		line, err := b.br.ReadBytes('\n')
+		b.scanLine(line)
+		if len(line) == 0 {
+			if err != nil {
+				b.once.Do(b.log)
+			}
+			return 0, err
+		}
+		b.pending = line
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+func (b *loggedEventStream) scanLine(line []byte) {
+	data, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r\n"), []byte("data:"))
+	if !ok {
+		return
+	}
+	event := gjson.ParseBytes(data)
+	eventType := event.Get("type").String()
+	b.counts[eventType]++
+	switch eventType {
+	case "message_start":
+		b.startUsage = event.Get("message.usage")
+	case "message_delta":
+		b.endUsage = event.Get("usage")
+		b.stopReason = event.Get("delta.stop_reason").String()
+	case "error":
+		b.errorType = event.Get("error.type").String()
+	}
+}
+
+func (b *loggedEventStream) log() {
+	counts := make([]any, 0, len(b.counts))
+	for _, eventType := range slices.Sorted(maps.Keys(b.counts)) {
+		counts = append(counts, slog.Int(eventType, b.counts[eventType]))
+	}
+	b.logger.LogAttrs(b.ctx, slog.LevelDebug, "stream_event_summary", append(b.attrs, slog.Group("events", counts...))...)
+
+	attrs := append(b.attrs,
+		slog.String("stop_reason", b.stopReason),
+		tokensAttr(b.startUsage, b.endUsage),
+		slog.Duration("latency", time.Since(b.start)),
+	)
+	level := slog.LevelInfo
+	if b.errorType != "" {
+		attrs = append(attrs, slog.String("error_type", b.errorType))
+		level = slog.LevelWarn
+	}
+	b.logger.LogAttrs(b.ctx, level, "stream_complete", attrs...)
+}
+
+func (b *loggedEventStream) Close() error {
+	b.once.Do(b.log)
+	return b.rc.Close()
+}
@@ -0,0 +1,114 @@
+package option
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+)
+
+func TestWithStructuredLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	attempts := 0
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			return &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Content-Type": {"application/json"}, "Request-Id": {"req_1"}, "Retry-After-Ms": {"1"}},
+				Body:       io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)),
+			}, nil
+		}
+		if body, _ := readRequestBody(req); !bytes.Contains(body, []byte(`"stream":true`)) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}, "Request-Id": {"req_3"}},
+				Body:       io.NopCloser(strings.NewReader(`{"id":"msg_2","type":"message","content":[],"usage":{"input_tokens":7,"output_tokens":3}}`)),
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/event-stream"}, "Request-Id": {"req_2"}},
+			Body: io.NopCloser(strings.NewReader(
+				sseEvent(`{"type":"message_start","message":{"id":"msg_1","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`) +
+					sseEvent(`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`) +
+					sseEvent(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Secret plans"}}`) +
+					sseEvent(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"!"}}`) +
+					sseEvent(`{"type":"content_block_stop","index":0}`) +
+					sseEvent(`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`) +
+					sseEvent(`{"type":"message_stop"}`),
+			)),
+		}, nil
+	})}
+
+	var res *http.Response
+	body := []byte(`{"model":"claude-sonnet-4-5","stream":true,"messages":[{"role":"user","content":"Secret question"}]}`)
+	cfg, err := requestconfig.NewRequestConfig(context.Background(), http.MethodPost, "v1/messages", body, &res,
+		WithBaseURL("http://localhost/"), WithHTTPClient(client), WithAPIKey("sk-ant-api03-secretXy9z"),
+		WithMaxRetries(1), WithStructuredLogger(logger))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cfg.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	io.ReadAll(res.Body)
+	res.Body.Close()
+
+	cfg, err = requestconfig.NewRequestConfig(context.Background(), http.MethodPost, "v1/messages", []byte(`{"model":"claude-haiku-4-5"}`), &res,
+		WithBaseURL("http://localhost/"), WithHTTPClient(client), WithAPIKey("sk-ant-api03-secretXy9z"), WithStructuredLogger(logger))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cfg.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Contains(out.String(), "secret") || strings.Contains(out.String(), "Secret") {
+		t.Errorf("expected no secrets or content to be logged, got:\n%s", out.String())
+	}
+	var events []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("unexpected log line %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	var msgs []string
+	for _, event := range events {
+		msgs = append(msgs, event["msg"].(string))
+	}
+	if strings.Join(msgs, " ") != "request_start response retry response stream_event_summary stream_complete request_start response" {
+		t.Fatalf("unexpected events %q", msgs)
+	}
+
+	for i, want := range []map[string]any{
+		{"level": "INFO", "model": "claude-sonnet-4-5", "attempt": 1.0, "method": "POST", "path": "/v1/messages", "stream": true},
+		{"level": "WARN", "request_id": "req_1", "attempt": 1.0, "status": 429.0, "error_type": "rate_limit_error"},
+		{"level": "WARN", "model": "claude-sonnet-4-5", "attempt": 2.0},
+		{"level": "INFO", "request_id": "req_2", "attempt": 2.0, "status": 200.0},
+		{"level": "DEBUG", "request_id": "req_2", "events": map[string]any{"message_start": 1.0, "content_block_start": 1.0, "content_block_delta": 2.0, "content_block_stop": 1.0, "message_delta": 1.0, "message_stop": 1.0}},
+		{"level": "INFO", "request_id": "req_2", "model": "claude-sonnet-4-5", "stop_reason": "end_turn", "tokens": map[string]any{"input": 10.0, "output": 5.0, "cache_creation_input": 0.0, "cache_read_input": 0.0}},
+		{"level": "INFO", "model": "claude-haiku-4-5", "attempt": 1.0, "stream": false},
+		{"level": "INFO", "request_id": "req_3", "status": 200.0, "tokens": map[string]any{"input": 7.0, "output": 3.0, "cache_creation_input": 0.0, "cache_read_input": 0.0}},
+	} {
+		for key, value := range want {
+			got, _ := json.Marshal(events[i][key])
+			expected, _ := json.Marshal(value)
+			if !bytes.Equal(got, expected) {
+				t.Errorf("event %d (%s): expected %s to be %s, got %s", i, msgs[i], key, expected, got)
+			}
+		}
+	}
+	if _, ok := events[5]["latency"]; !ok {
+		t.Errorf("expected stream_complete to have a latency, got %v", events[5])
+	}
+}
@@ -0,0 +1,91 @@
+package option
+
+import (
+	"container/list"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+)
+
+// maxConfiguredTransports bounds the number of transports kept by
+// configuredTransports.
+const maxConfiguredTransports = 32
+
+// configuredTransport identifies a transport configured by
+// withTransportSettings: the transport it is cloned from and the setting
+// applied to it.
+type configuredTransport struct {
+	base    *http.Transport
+	setting string
+}
+
+// transportCache holds the most recently used configured transports, up to
+// maxConfiguredTransports. The idle connections of the transports it evicts
+// are closed, and requests still using them finish normally.
+type transportCache struct {
+	mu      sync.Mutex
+	entries map[configuredTransport]*list.Element
+	order   list.List // of *transportCacheEntry, most recently used first
+}
+
+type transportCacheEntry struct {
+	key       configuredTransport
+	transport *http.Transport
+}
+
+// get returns the transport cached for key, configuring a copy of the base
+// transport with configure if there is none.
+func (c *transportCache) get(key configuredTransport, configure func(*http.Transport)) *http.Transport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*transportCacheEntry).transport
+	}
+	if c.entries == nil {
+		c.entries = map[configuredTransport]*list.Element{}
+	}
+	transport := key.base.Clone()
+	configure(transport)
+	c.entries[key] = c.order.PushFront(&transportCacheEntry{key, transport})
+	if c.order.Len() > maxConfiguredTransports {
+		oldest := c.order.Remove(c.order.Back()).(*transportCacheEntry)
+		delete(c.entries, oldest.key)
+		oldest.transport.CloseIdleConnections()
+	}
+	return transport
+}
+
+// configuredTransports caches the transports configured by
+// withTransportSettings, so that requests sent through the same transport with
+// the same setting share a copy and its connections, whether the option is
+// supplied to the client or to each request, and whatever the http client
+// holding the transport. The cache is bounded, so that varying settings or
+// transports do not keep connection pools open for the life of the process.
+var configuredTransports transportCache
+
+// withTransportSettings returns a RequestOption which replaces the http client
+// with a copy whose transport is configured by configure. Transports are
+// identified by setting, which must describe everything configure changes.
+func withTransportSettings(name string, setting string, configure func(*http.Transport)) RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		if r.CustomHTTPDoer != nil {
+			return fmt.Errorf("requestoption: %s requires an *http.Client, not a custom HTTPClient", name)
+		}
+		base := r.HTTPClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		transport, ok := base.(*http.Transport)
+		if !ok {
+			return fmt.Errorf("requestoption: %s requires the http client to use an *http.Transport, not %T", name, base)
+		}
+		key := configuredTransport{base: transport, setting: name + "(" + setting + ")"}
+		client := *r.HTTPClient
+		client.Transport = configuredTransports.get(key, configure)
+		r.HTTPClient = &client
+		return nil
+	})
+}
@@ -0,0 +1,40 @@
+package option
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+)
+
+func TestTransportCacheBounded(t *testing.T) {
+	var cache transportCache
+	base := &http.Transport{}
+	first := cache.get(configuredTransport{base, "1"}, func(*http.Transport) {})
+	if again := cache.get(configuredTransport{base, "1"}, func(*http.Transport) {}); again != first {
+		t.Error("expected the same setting to share a transport")
+	}
+	for i := range maxConfiguredTransports * 2 {
+		cache.get(configuredTransport{&http.Transport{}, "1"}, func(*http.Transport) {})
+		if n := cache.order.Len(); n > maxConfiguredTransports || len(cache.entries) != n {
+			t.Fatalf("after %d transports, expected at most %d cached, got %d", i+1, maxConfiguredTransports, n)
+		}
+	}
+	if _, ok := cache.entries[configuredTransport{base, "1"}]; ok {
+		t.Error("expected the least recently used transport to be evicted")
+	}
+}
+
+func TestWithTimeoutsVaryingValues(t *testing.T) {
+	for i := range maxConfiguredTransports * 2 {
+		_, err := requestconfig.NewRequestConfig(context.Background(), http.MethodPost, "v1/messages", nil, nil, WithTimeouts(time.Duration(i+1)*time.Second, 0))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := configuredTransports.order.Len(); n > maxConfiguredTransports {
+		t.Errorf("expected at most %d cached transports, got %d", maxConfiguredTransports, n)
+	}
+}
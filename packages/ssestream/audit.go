@@ -0,0 +1,128 @@
+package ssestream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// AuditRecord is one line of an audit log written by [Stream.Audit].
+type AuditRecord struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	// Data is the event payload. It holds the JSON sent by the server as is, or a
+	// JSON string if the payload was not valid JSON.
+	Data json.RawMessage `json:"data"`
+}
+
+// Audit writes every raw event received by the stream, including pings, to w as
+// a line of JSON in the format of [AuditRecord], as the stream progresses.
+// Iterating the stream is otherwise unaffected. Events are written before they
+// are yielded by [Stream.Next], so the log is complete up to the current event.
+//
+// Audit must be called before the stream is iterated. If writing to w fails,
+// the stream stops and [Stream.Err] returns the write error, so that no event
+// is processed without being recorded. Use [NewAuditReplayDecoder] to replay the
+// log. Audit returns the stream to allow chaining.
+func (s *Stream[T]) Audit(w io.Writer) *Stream[T] {
+	if s.decoder != nil {
+		s.decoder = &auditDecoder{Decoder: s.decoder, w: w}
+	}
+	return s
+}
+
+type auditDecoder struct {
+	Decoder
+	w   io.Writer
+	err error
+}
+
+func (d *auditDecoder) Next() bool {
+	if d.err != nil || !d.Decoder.Next() {
+		return false
+	}
+	event := d.Decoder.Event()
+	record := AuditRecord{Time: time.Now().UTC(), Event: event.Type, Data: bytes.TrimSuffix(event.Data, []byte("\n"))}
+	if !json.Valid(record.Data) {
+		record.Data, _ = json.Marshal(string(record.Data))
+	}
+	line, err := json.Marshal(record)
+	if err == nil {
+		_, err = d.w.Write(append(line, '\n'))
+	}
+	if err != nil {
+		d.err = fmt.Errorf("ssestream: writing audit log: %w", err)
+		return false
+	}
+	return true
+}
+
+func (d *auditDecoder) unwrap() Decoder { return d.Decoder }
+
+func (d *auditDecoder) Err() error {
+	if d.err != nil {
+		return d.err
+	}
+	return d.Decoder.Err()
+}
+
+// NewAuditReplayDecoder returns a Decoder which replays the events of an audit
+// log written by [Stream.Audit], for example to reconstruct a message:
+//
+//	stream := ssestream.NewStream[anthropic.MessageStreamEventUnion](ssestream.NewAuditReplayDecoder(f), nil)
+//	message := anthropic.Message{}
+//	for stream.Next() {
+//		message.Accumulate(stream.Current())
+//	}
+func NewAuditReplayDecoder(r io.Reader) Decoder {
+	scn := bufio.NewScanner(r)
+	scn.Buffer(nil, bufio.MaxScanTokenSize<<9)
+	return &auditReplayDecoder{r: r, scn: scn}
+}
+
+type auditReplayDecoder struct {
+	r   io.Reader
+	scn *bufio.Scanner
+	evt Event
+	err error
+}
+
+func (d *auditReplayDecoder) Next() bool {
+	if d.err != nil {
+		return false
+	}
+	for d.scn.Scan() {
+		line := d.scn.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record AuditRecord
+		if d.err = json.Unmarshal(line, &record); d.err != nil {
+			d.err = fmt.Errorf("ssestream: invalid audit record: %w", d.err)
+			return false
+		}
+		data := []byte(record.Data)
+		var s string
+		if json.Unmarshal(record.Data, &s) == nil {
+			data = []byte(s)
+		}
+		d.evt = Event{Type: record.Event, Data: data}
+		return true
+	}
+	d.err = d.scn.Err()
+	return false
+}
+
+func (d *auditReplayDecoder) Event() Event { return d.evt }
+
+func (d *auditReplayDecoder) Err() error { return d.err }
+
+func (d *auditReplayDecoder) Close() error {
+	if c, ok := d.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package ssestream
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// WithInterrupt stops the stream as soon as a value is received from ch or ch
+// is closed, for example when the user of a chat interface asks to stop
+// generating, without cancelling the context of the request. The response body
+// is closed, which closes the connection, and [Stream.Next] returns false with
+// a nil [Stream.Err], so that a loop accumulating the events ends with the
+// partial message:
+//
+//	stream := client.Messages.NewStreaming(ctx, params).WithInterrupt(stop)
+//	message := anthropic.Message{}
+//	for stream.Next() {
+//		message.Accumulate(stream.Current())
+//	}
+//	if stream.Interrupted() { ... }
+//
+// WithInterrupt must be called before the stream is iterated. It starts a
+// goroutine which returns once the stream is interrupted, ends or is closed.
+// WithInterrupt returns the stream to allow chaining.
+func (s *Stream[T]) WithInterrupt(ch <-chan struct{}) *Stream[T] {
+	if s.decoder == nil {
+		return s
+	}
+	d := &interruptDecoder{Decoder: s.decoder, done: make(chan struct{})}
+	s.decoder = d
+	go func() {
+		select {
+		case <-ch:
+			d.interrupted.Store(true)
+			d.Decoder.Close()
+		case <-d.done:
+		}
+	}()
+	return s
+}
+
+// Interrupted reports whether the stream was stopped by the channel passed to
+// [Stream.WithInterrupt].
+func (s *Stream[T]) Interrupted() bool {
+	d, ok := findDecoder[*interruptDecoder](s.decoder)
+	return ok && d.interrupted.Load()
+}
+
+type interruptDecoder struct {
+	Decoder
+	interrupted atomic.Bool
+	done        chan struct{}
+	once        sync.Once
+}
+
+func (d *interruptDecoder) Next() bool {
+	// An event read just as the stream is interrupted is dropped, so that no
+	// event is yielded after the interruption.
+	if d.interrupted.Load() || !d.Decoder.Next() || d.interrupted.Load() {
+		d.stop()
+		return false
+	}
+	return true
+}
+
+func (d *interruptDecoder) Err() error {
+	// Reading the closed body fails, which is how the interruption ends the
+	// stream rather than an error.
+	if d.interrupted.Load() {
+		return nil
+	}
+	return d.Decoder.Err()
+}
+
+func (d *interruptDecoder) Close() error {
+	d.stop()
+	return d.Decoder.Close()
+}
+
+func (d *interruptDecoder) unwrap() Decoder { return d.Decoder }
+
+// stop ends the goroutine waiting for the interruption.
+func (d *interruptDecoder) stop() {
+	d.once.Do(func() { close(d.done) })
+}
@@ -0,0 +1,12 @@
+package ssestream
+
+// Model returns the model that is generating the streamed message, as reported
+// by its message_start event. This is the concrete model, so it may differ from
+// the model requested if that was an alias. Model returns an empty string until
+// the message_start event has been read by [Stream.Next].
+func (s *Stream[T]) Model() string {
+	if d, ok := findDecoder[*trackingDecoder](s.decoder); ok {
+		return d.model
+	}
+	return ""
+}
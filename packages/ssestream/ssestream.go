@@ -128,7 +128,7 @@ type Stream[T any] struct {
 
 func NewStream[T any](decoder Decoder, err error) *Stream[T] {
 	return &Stream[T]{
-		decoder: decoder,
+		decoder: newTrackingDecoder(decoder),
 		err:     err,
 	}
 }
@@ -0,0 +1,101 @@
+package ssestream
+
+import (
+	"time"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+// Throughput returns the generation speed of the streamed message so far, in
+// output tokens per second, measured from its message_start event to the latest
+// event read by [Stream.Next]. It can be called while iterating, to display
+// the speed or to detect slow responses.
+//
+// The API only reports the output token count in the usage of the
+// message_start and message_delta events, the latter at the end of the
+// message, so until then the count is estimated from the text, thinking and
+// tool input of the deltas, at four characters per token. Throughput returns
+// zero until two events have been read.
+func (s *Stream[T]) Throughput() float64 {
+	d, ok := findDecoder[*trackingDecoder](s.decoder)
+	if !ok {
+		return 0
+	}
+	elapsed := d.lastEvent.Sub(d.started).Seconds()
+	if d.started.IsZero() || elapsed <= 0 {
+		return 0
+	}
+	tokens := max(d.outputTokens, int64((d.deltaRunes+3)/4))
+	return float64(tokens) / elapsed
+}
+
+// trackingDecoder records the model, the times and the output of the message
+// events read, for [Stream.Model] and [Stream.Throughput].
+type trackingDecoder struct {
+	Decoder
+	model        string
+	started      time.Time
+	lastEvent    time.Time
+	outputTokens int64
+	deltaRunes   int
+}
+
+func newTrackingDecoder(decoder Decoder) Decoder {
+	if decoder == nil {
+		return nil
+	}
+	return &trackingDecoder{Decoder: decoder}
+}
+
+func (d *trackingDecoder) Next() bool {
+	if !d.Decoder.Next() {
+		return false
+	}
+	event := d.Decoder.Event()
+	now := time.Now()
+	switch event.Type {
+	case "message_start":
+		d.started, d.lastEvent = now, now
+		d.deltaRunes = 0
+		message := gjson.GetBytes(event.Data, "message")
+		d.model = message.Get("model").String()
+		d.outputTokens = message.Get("usage.output_tokens").Int()
+	case "content_block_delta":
+		d.lastEvent = now
+		delta := gjson.GetBytes(event.Data, "delta")
+		for _, field := range []string{"text", "thinking", "partial_json"} {
+			d.deltaRunes += utf8.RuneCountInString(delta.Get(field).String())
+		}
+	case "message_delta":
+		d.lastEvent = now
+		if tokens := gjson.GetBytes(event.Data, "usage.output_tokens"); tokens.Exists() {
+			d.outputTokens = tokens.Int()
+			// The count reported is exact, so the estimate no longer applies.
+			d.deltaRunes = 0
+		}
+	case "message_stop", "content_block_start", "content_block_stop":
+		d.lastEvent = now
+	}
+	return true
+}
+
+func (d *trackingDecoder) unwrap() Decoder { return d.Decoder }
+
+// findDecoder returns the decoder of type D among the decoders wrapping each
+// other from d, such as those installed by [Stream.WithInterrupt] and
+// [Stream.Audit].
+func findDecoder[D Decoder](d Decoder) (D, bool) {
+	for d != nil {
+		if found, ok := d.(D); ok {
+			return found, true
+		}
+		wrapper, ok := d.(interface{ unwrap() Decoder })
+		if !ok {
+			break
+		}
+		d = wrapper.unwrap()
+	}
+	var zero D
+	return zero, false
+}
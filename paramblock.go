@@ -0,0 +1,94 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/packages/param"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+var paramBlocks struct {
+	sync.RWMutex
+	marshal map[string]func(any) (json.RawMessage, error)
+}
+
+// RegisterParamBlock registers marshal as the serializer of the content
+// blocks of type typ built with [NewParamBlock] or [NewBetaParamBlock]. It is
+// meant for gateways whose proxy understands content blocks which the API does
+// not have: the API itself rejects such blocks.
+//
+// marshal is called with the value passed to [NewParamBlock] when the params
+// are encoded, and must return a JSON object. Its "type" field is set to typ.
+//
+// RegisterParamBlock is meant to be called from an init function. It panics if
+// typ is empty or already registered, if typ is a block type of the API, or if
+// marshal is nil.
+func RegisterParamBlock(typ string, marshal func(any) (json.RawMessage, error)) {
+	if typ == "" || marshal == nil {
+		panic("anthropic: RegisterParamBlock requires a type and a serializer")
+	}
+	if apiBlockTypes()[typ] {
+		panic(fmt.Sprintf("anthropic: RegisterParamBlock: %q is a content block type of the API", typ))
+	}
+	paramBlocks.Lock()
+	defer paramBlocks.Unlock()
+	if _, ok := paramBlocks.marshal[typ]; ok {
+		panic(fmt.Sprintf("anthropic: RegisterParamBlock: %q is already registered", typ))
+	}
+	if paramBlocks.marshal == nil {
+		paramBlocks.marshal = map[string]func(any) (json.RawMessage, error){}
+	}
+	paramBlocks.marshal[typ] = marshal
+}
+
+// NewParamBlock returns a content block of type typ which is serialized by
+// the serializer registered for typ with [RegisterParamBlock]. Encoding the
+// params fails if no serializer is registered for typ.
+//
+//	anthropic.NewUserMessage(anthropic.NewTextBlock("See the ticket."), anthropic.NewParamBlock("x_ticket", ticket))
+func NewParamBlock(typ string, value any) ContentBlockParamUnion {
+	return param.Override[ContentBlockParamUnion](customParamBlock{typ: typ, value: value})
+}
+
+// NewBetaParamBlock is the beta counterpart of [NewParamBlock].
+func NewBetaParamBlock(typ string, value any) BetaContentBlockParamUnion {
+	return param.Override[BetaContentBlockParamUnion](customParamBlock{typ: typ, value: value})
+}
+
+// customParamBlock is the override of a content block built with
+// [NewParamBlock].
+type customParamBlock struct {
+	typ   string
+	value any
+}
+
+func (b customParamBlock) MarshalJSON() ([]byte, error) {
+	paramBlocks.RLock()
+	marshal := paramBlocks.marshal[b.typ]
+	paramBlocks.RUnlock()
+	if marshal == nil {
+		return nil, fmt.Errorf("no serializer registered for content block type %q", b.typ)
+	}
+	raw, err := marshal(b.value)
+	if err != nil {
+		return nil, fmt.Errorf("serializing content block of type %q: %w", b.typ, err)
+	}
+	if !gjson.ValidBytes(raw) || !gjson.ParseBytes(raw).IsObject() {
+		return nil, fmt.Errorf("serializer of content block type %q returned %s, not a JSON object", b.typ, raw)
+	}
+	return sjson.SetBytes(raw, "type", b.typ)
+}
+
+// apiBlockTypes returns the types of the content blocks of the stable and beta
+// APIs.
+var apiBlockTypes = sync.OnceValue(func() map[string]bool {
+	types := unionVariantTypes(reflect.TypeFor[ContentBlockParamUnion]())
+	for typ := range unionVariantTypes(reflect.TypeFor[BetaContentBlockParamUnion]()) {
+		types[typ] = true
+	}
+	return types
+})
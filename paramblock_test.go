@@ -0,0 +1,62 @@
+package anthropic_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+type ticketRef struct {
+	ID string `json:"id"`
+}
+
+func init() {
+	anthropic.RegisterParamBlock("x_ticket", func(v any) (json.RawMessage, error) {
+		ticket, ok := v.(ticketRef)
+		if !ok {
+			return nil, errors.New("not a ticket")
+		}
+		return json.Marshal(ticket)
+	})
+}
+
+func TestNewParamBlock(t *testing.T) {
+	message := anthropic.NewUserMessage(anthropic.NewTextBlock("See the ticket."), anthropic.NewParamBlock("x_ticket", ticketRef{ID: "T-1"}))
+	got, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"content":[{"text":"See the ticket.","type":"text"},{"id":"T-1","type":"x_ticket"}],"role":"user"}`
+	if normalizeJSON(t, got) != normalizeJSON(t, []byte(want)) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	beta, err := json.Marshal(anthropic.NewBetaParamBlock("x_ticket", ticketRef{ID: "T-2"}))
+	if err != nil || normalizeJSON(t, beta) != normalizeJSON(t, []byte(`{"id":"T-2","type":"x_ticket"}`)) {
+		t.Errorf("got %s, %v", beta, err)
+	}
+
+	if _, err := json.Marshal(anthropic.NewParamBlock("x_ticket", "T-3")); err == nil || !strings.Contains(err.Error(), "not a ticket") {
+		t.Errorf("expected the serializer error, got %v", err)
+	}
+	if _, err := json.Marshal(anthropic.NewParamBlock("x_unknown", nil)); err == nil {
+		t.Error("expected an error for an unregistered type")
+	}
+}
+
+func TestRegisterParamBlockPanics(t *testing.T) {
+	marshal := func(any) (json.RawMessage, error) { return json.RawMessage(`{}`), nil }
+	for _, typ := range []string{"", "text", "x_ticket"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected registering %q to panic", typ)
+				}
+			}()
+			anthropic.RegisterParamBlock(typ, marshal)
+		}()
+	}
+}
@@ -0,0 +1,164 @@
+package anthropic
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/packages/param"
+)
+
+// validatePrefill checks the restrictions that apply to every assistant prefill.
+func validatePrefill(text string, lastRole string) error {
+	if text == "" {
+		return fmt.Errorf("prefill: text cannot be empty")
+	}
+	if strings.TrimRightFunc(text, unicode.IsSpace) != text {
+		return fmt.Errorf("prefill: text cannot end with whitespace")
+	}
+	if lastRole == "assistant" {
+		return fmt.Errorf("prefill: the final message is already an assistant turn")
+	}
+	return nil
+}
+
+// WithAssistantPrefill returns a copy of the params with an assistant turn
+// containing text appended to the messages. Claude continues its response from
+// the prefilled text, which can be used to steer the output, for example by
+// prefilling "{" to force a JSON object. Note that the returned message does not
+// include the prefill itself.
+//
+// An error is returned if the prefill ends in whitespace, if the conversation
+// already ends with an assistant turn, or if the params enable features that
+// cannot be combined with prefill, such as extended thinking or structured
+// outputs.
+func (r MessageNewParams) WithAssistantPrefill(text string) (MessageNewParams, error) {
+	var lastRole string
+	if n := len(r.Messages); n > 0 {
+		lastRole = string(r.Messages[n-1].Role)
+	}
+	if err := validatePrefill(text, lastRole); err != nil {
+		return r, err
+	}
+	if r.Thinking.OfEnabled != nil {
+		return r, fmt.Errorf("prefill: cannot be used with extended thinking")
+	}
+	if !param.IsOmitted(r.OutputConfig.Format) {
+		return r, fmt.Errorf("prefill: cannot be used with structured outputs")
+	}
+
+	r.Messages = append(slices.Clip(r.Messages), NewAssistantMessage(NewTextBlock(text)))
+	return r, nil
+}
+
+// WithAssistantPrefill returns a copy of the params with an assistant turn
+// containing text appended to the messages. See
+// [MessageNewParams.WithAssistantPrefill] for details.
+func (r BetaMessageNewParams) WithAssistantPrefill(text string) (BetaMessageNewParams, error) {
+	var lastRole string
+	if n := len(r.Messages); n > 0 {
+		lastRole = string(r.Messages[n-1].Role)
+	}
+	if err := validatePrefill(text, lastRole); err != nil {
+		return r, err
+	}
+	if r.Thinking.OfEnabled != nil {
+		return r, fmt.Errorf("prefill: cannot be used with extended thinking")
+	}
+	if !param.IsOmitted(r.OutputConfig.Format) || !param.IsOmitted(r.OutputFormat) {
+		return r, fmt.Errorf("prefill: cannot be used with structured outputs")
+	}
+
+	r.Messages = append(slices.Clip(r.Messages), BetaMessageParam{
+		Role:    BetaMessageParamRoleAssistant,
+		Content: []BetaContentBlockParamUnion{NewBetaTextBlock(text)},
+	})
+	return r, nil
+}
+
+// NewTextBlockWithCache returns a text block marked as a prompt caching
+// breakpoint: the prompt up to and including the block is cached for ttl, one
+// of [CacheControlEphemeralTTLTTL5m] or [CacheControlEphemeralTTLTTL1h]. An
+// empty ttl uses the API's default of 5 minutes.
+func NewTextBlockWithCache(text string, ttl CacheControlEphemeralTTL) ContentBlockParamUnion {
+	block := TextBlockParam{Text: text, CacheControl: NewCacheControlEphemeralParam()}
+	block.CacheControl.TTL = ttl
+	return ContentBlockParamUnion{OfText: &block}
+}
+
+// NewBetaTextBlockWithCache is the beta API counterpart of
+// [NewTextBlockWithCache].
+func NewBetaTextBlockWithCache(text string, ttl BetaCacheControlEphemeralTTL) BetaContentBlockParamUnion {
+	block := BetaTextBlockParam{Text: text, CacheControl: NewBetaCacheControlEphemeralParam()}
+	block.CacheControl.TTL = ttl
+	return BetaContentBlockParamUnion{OfText: &block}
+}
+
+// WithCacheControl returns a copy of the block with ephemeral cache control,
+// making it a prompt caching breakpoint. It is convenient for caching a long
+// system prompt:
+//
+//	System: []anthropic.TextBlockParam{
+//		anthropic.TextBlockParam{Text: instructions}.WithCacheControl(),
+//	},
+func (r TextBlockParam) WithCacheControl() TextBlockParam {
+	r.CacheControl = NewCacheControlEphemeralParam()
+	return r
+}
+
+// WithCacheControl returns a copy of the block with ephemeral cache control.
+// See [TextBlockParam.WithCacheControl].
+func (r BetaTextBlockParam) WithCacheControl() BetaTextBlockParam {
+	r.CacheControl = NewBetaCacheControlEphemeralParam()
+	return r
+}
+
+// SystemPrompt is a system prompt as a list of text blocks, which can be
+// assigned to [MessageNewParams.System].
+type SystemPrompt []TextBlockParam
+
+// MergeSystemPrompts concatenates system prompts assembled from several
+// sources, such as a base persona, feature-specific instructions and
+// session-specific context, in the order given, from the most stable to the
+// most specific:
+//
+//	params.System = anthropic.MergeSystemPrompts(persona, features, session)
+//
+// Empty blocks, and blocks with the same text as an earlier block, are dropped.
+// Only the last prompt is taken to change between requests, so the prompt
+// caching breakpoints of the blocks are replaced with a single one at the end
+// of the others, or at the end of the last prompt if there is only one. The
+// breakpoint keeps the TTL of the last breakpoint of those blocks, if any. The
+// prompts are not modified.
+func MergeSystemPrompts(prompts ...SystemPrompt) SystemPrompt {
+	var merged SystemPrompt
+	seen := map[string]bool{}
+	// stable is the number of blocks from all the prompts but the last.
+	stable := 0
+	var cacheControl CacheControlEphemeralParam
+	for i, prompt := range prompts {
+		isStable := i < len(prompts)-1 || len(prompts) == 1
+		for _, block := range prompt {
+			if block.Text == "" || seen[block.Text] {
+				continue
+			}
+			seen[block.Text] = true
+			if isStable && !param.IsOmitted(block.CacheControl) {
+				cacheControl = block.CacheControl
+			}
+			block.CacheControl = CacheControlEphemeralParam{}
+			merged = append(merged, block)
+		}
+		if isStable {
+			stable = len(merged)
+		}
+	}
+	if stable > 0 {
+		if param.IsOmitted(cacheControl) {
+			cacheControl = NewCacheControlEphemeralParam()
+		}
+		merged[stable-1].CacheControl = cacheControl
+	}
+	return merged
+}
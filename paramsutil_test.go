@@ -0,0 +1,123 @@
+package anthropic_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestWithAssistantPrefill(t *testing.T) {
+	params := anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("List three colors as JSON"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}
+
+	prefilled, err := params.WithAssistantPrefill("{")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(params.Messages) != 1 {
+		t.Errorf("expected the original params to be left unchanged")
+	}
+	if len(prefilled.Messages) != 2 {
+		t.Fatalf("expected a prefilled assistant turn, got %d messages", len(prefilled.Messages))
+	}
+	last := prefilled.Messages[1]
+	if last.Role != anthropic.MessageParamRoleAssistant || last.Content[0].OfText.Text != "{" {
+		t.Errorf("unexpected prefill turn: %+v", last)
+	}
+
+	if _, err := prefilled.WithAssistantPrefill("["); err == nil {
+		t.Error("expected an error when the conversation already ends with an assistant turn")
+	}
+	if _, err := params.WithAssistantPrefill("Answer: "); err == nil {
+		t.Error("expected an error for a prefill ending in whitespace")
+	}
+
+	params.Thinking = anthropic.ThinkingConfigParamOfEnabled(2048)
+	if _, err := params.WithAssistantPrefill("{"); err == nil {
+		t.Error("expected an error when extended thinking is enabled")
+	}
+}
+
+func TestBetaWithAssistantPrefill(t *testing.T) {
+	params := anthropic.BetaMessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.BetaMessageParam{anthropic.NewBetaUserMessage(anthropic.NewBetaTextBlock("hi"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}
+	prefilled, err := params.WithAssistantPrefill("Hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := len(prefilled.Messages); n != 2 || prefilled.Messages[1].Role != anthropic.BetaMessageParamRoleAssistant {
+		t.Fatalf("expected a prefilled assistant turn, got %+v", prefilled.Messages)
+	}
+
+	params.OutputFormat = anthropic.BetaJSONSchemaOutputFormat(map[string]any{"type": "object"})
+	if _, err := params.WithAssistantPrefill("{"); err == nil {
+		t.Error("expected an error when structured outputs are enabled")
+	}
+}
+
+func TestCacheControlHelpers(t *testing.T) {
+	for _, tc := range []struct {
+		block    any
+		expected string
+	}{
+		{anthropic.NewTextBlockWithCache("Hello", anthropic.CacheControlEphemeralTTLTTL1h), `{"text":"Hello","cache_control":{"ttl":"1h","type":"ephemeral"},"type":"text"}`},
+		{anthropic.NewBetaTextBlockWithCache("Hello", ""), `{"text":"Hello","cache_control":{"type":"ephemeral"},"type":"text"}`},
+		{anthropic.TextBlockParam{Text: "Hello"}.WithCacheControl(), `{"text":"Hello","cache_control":{"type":"ephemeral"},"type":"text"}`},
+		{anthropic.BetaTextBlockParam{Text: "Hello"}.WithCacheControl(), `{"text":"Hello","cache_control":{"type":"ephemeral"},"type":"text"}`},
+	} {
+		b, err := json.Marshal(tc.block)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(b) != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, b)
+		}
+	}
+}
+
+func TestMergeSystemPrompts(t *testing.T) {
+	persona := anthropic.SystemPrompt{{Text: "You are a helpful assistant.", CacheControl: anthropic.CacheControlEphemeralParam{TTL: anthropic.CacheControlEphemeralTTLTTL1h}}}
+	features := anthropic.SystemPrompt{
+		{Text: "Answer in English."},
+		{Text: "You are a helpful assistant."},
+		{Text: ""},
+	}
+	session := anthropic.SystemPrompt{anthropic.TextBlockParam{Text: "The user is in Paris."}.WithCacheControl()}
+
+	for _, tc := range []struct {
+		prompts  []anthropic.SystemPrompt
+		expected string
+	}{
+		{
+			[]anthropic.SystemPrompt{persona, features, session},
+			`[{"text":"You are a helpful assistant.","type":"text"},{"text":"Answer in English.","cache_control":{"ttl":"1h","type":"ephemeral"},"type":"text"},{"text":"The user is in Paris.","type":"text"}]`,
+		},
+		{
+			[]anthropic.SystemPrompt{features, session},
+			`[{"text":"Answer in English.","type":"text"},{"text":"You are a helpful assistant.","cache_control":{"type":"ephemeral"},"type":"text"},{"text":"The user is in Paris.","type":"text"}]`,
+		},
+		{
+			[]anthropic.SystemPrompt{session},
+			`[{"text":"The user is in Paris.","cache_control":{"type":"ephemeral"},"type":"text"}]`,
+		},
+		{nil, `null`},
+	} {
+		b, err := json.Marshal(anthropic.MergeSystemPrompts(tc.prompts...))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(b) != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, b)
+		}
+	}
+	if persona[0].CacheControl.TTL != anthropic.CacheControlEphemeralTTLTTL1h {
+		t.Error("expected the prompts not to be modified")
+	}
+}
@@ -0,0 +1,112 @@
+package anthropic
+
+// completePartialJSON turns a prefix of a JSON document, such as a tool input
+// which is still streaming, into valid JSON holding as much of it as possible.
+// Open objects and arrays are closed, a string value cut short is terminated,
+// and an object key without a value, or a number or literal which may still
+// be incomplete, is dropped.
+func completePartialJSON(data []byte) []byte {
+	var (
+		stack []byte // open containers, '{' or '['
+		// safe is the length of the longest prefix which ends with a complete
+		// value or an opened container, and safeStack the containers open there.
+		safe      int
+		safeStack []byte
+		// expectKey reports, for the innermost object, whether the next string
+		// is a key.
+		expectKey []bool
+
+		inString, isKey, escaped bool
+		unicodeDigits            int
+		escapeStart              int
+		inScalar                 bool
+	)
+	markSafe := func(n int) {
+		safe = n
+		safeStack = append(safeStack[:0], stack...)
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+				if c == 'u' {
+					unicodeDigits = 4
+				}
+			case unicodeDigits > 0:
+				unicodeDigits--
+			case c == '\\':
+				escaped = true
+				escapeStart = i
+			case c == '"':
+				inString = false
+				if !isKey {
+					markSafe(i + 1)
+				}
+			}
+			continue
+		}
+		if inScalar {
+			switch c {
+			case ',', ']', '}', ' ', '\t', '\r', '\n':
+				inScalar = false
+				markSafe(i)
+			default:
+				continue
+			}
+		}
+		switch c {
+		case '"':
+			inString = true
+			isKey = len(stack) > 0 && stack[len(stack)-1] == '{' && expectKey[len(expectKey)-1]
+		case '{', '[':
+			stack = append(stack, c)
+			if c == '{' {
+				expectKey = append(expectKey, true)
+			}
+			markSafe(i + 1)
+		case '}', ']':
+			if len(stack) == 0 {
+				return data[:safe]
+			}
+			if stack[len(stack)-1] == '{' {
+				expectKey = expectKey[:len(expectKey)-1]
+			}
+			stack = stack[:len(stack)-1]
+			markSafe(i + 1)
+		case ':', ',':
+			if len(stack) > 0 && stack[len(stack)-1] == '{' {
+				expectKey[len(expectKey)-1] = c == ','
+			}
+		case ' ', '\t', '\r', '\n':
+		default:
+			inScalar = true
+		}
+	}
+
+	var out []byte
+	switch {
+	case inScalar && len(stack) == 0:
+		// A complete top-level number or literal.
+		return data
+	case inString && !isKey:
+		end := len(data)
+		if escaped || unicodeDigits > 0 {
+			end = escapeStart
+		}
+		out = append(append(out, data[:end]...), '"')
+		safeStack = stack
+	default:
+		out = append(out, data[:safe]...)
+	}
+	for i := len(safeStack) - 1; i >= 0; i-- {
+		if safeStack[i] == '{' {
+			out = append(out, '}')
+		} else {
+			out = append(out, ']')
+		}
+	}
+	return out
+}
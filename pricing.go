@@ -0,0 +1,104 @@
+package anthropic
+
+import "regexp"
+
+// ModelPricing is the price of a model in US dollars per million tokens.
+type ModelPricing struct {
+	Input float64
+	// CacheWrite5m and CacheWrite1h are the prices of input tokens written to
+	// the cache with a 5 minute and a 1 hour time to live.
+	CacheWrite5m float64
+	CacheWrite1h float64
+	CacheRead    float64
+	Output       float64
+}
+
+var (
+	opus4Pricing   = ModelPricing{Input: 15, CacheWrite5m: 18.75, CacheWrite1h: 30, CacheRead: 1.5, Output: 75}
+	opus45Pricing  = ModelPricing{Input: 5, CacheWrite5m: 6.25, CacheWrite1h: 10, CacheRead: 0.5, Output: 25}
+	sonnetPricing  = ModelPricing{Input: 3, CacheWrite5m: 3.75, CacheWrite1h: 6, CacheRead: 0.3, Output: 15}
+	haiku45Pricing = ModelPricing{Input: 1, CacheWrite5m: 1.25, CacheWrite1h: 2, CacheRead: 0.1, Output: 5}
+	haiku35Pricing = ModelPricing{Input: 0.8, CacheWrite5m: 1, CacheWrite1h: 1.6, CacheRead: 0.08, Output: 4}
+	haiku3Pricing  = ModelPricing{Input: 0.25, CacheWrite5m: 0.3, CacheWrite1h: 0.5, CacheRead: 0.03, Output: 1.25}
+)
+
+// ModelPrices holds the standard list prices of the models known to this
+// version of the SDK. Prices change over time, so callers relying on exact
+// figures may update or extend the table. It must not be modified while
+// costs are being computed.
+var ModelPrices = map[Model]ModelPricing{
+	ModelClaudeOpus4_5:            opus45Pricing,
+	ModelClaudeOpus4_5_20251101:   opus45Pricing,
+	ModelClaudeOpus4_1_20250805:   opus4Pricing,
+	ModelClaudeOpus4_0:            opus4Pricing,
+	ModelClaudeOpus4_20250514:     opus4Pricing,
+	ModelClaude4Opus20250514:      opus4Pricing,
+	ModelClaude3OpusLatest:        opus4Pricing,
+	ModelClaude_3_Opus_20240229:   opus4Pricing,
+	ModelClaudeSonnet4_5:          sonnetPricing,
+	ModelClaudeSonnet4_5_20250929: sonnetPricing,
+	ModelClaudeSonnet4_0:          sonnetPricing,
+	ModelClaudeSonnet4_20250514:   sonnetPricing,
+	ModelClaude4Sonnet20250514:    sonnetPricing,
+	ModelClaude3_7SonnetLatest:    sonnetPricing,
+	ModelClaude3_7Sonnet20250219:  sonnetPricing,
+	ModelClaudeHaiku4_5:           haiku45Pricing,
+	ModelClaudeHaiku4_5_20251001:  haiku45Pricing,
+	ModelClaude3_5HaikuLatest:     haiku35Pricing,
+	ModelClaude3_5Haiku20241022:   haiku35Pricing,
+	ModelClaude_3_Haiku_20240307:  haiku3Pricing,
+}
+
+var modelDateSuffix = regexp.MustCompile(`-\d{8}$`)
+
+// LookupPricing returns the pricing of model from [ModelPrices]. A dated model
+// which is not in the table is priced like its undated alias.
+func LookupPricing(model Model) (ModelPricing, bool) {
+	if p, ok := ModelPrices[model]; ok {
+		return p, true
+	}
+	p, ok := ModelPrices[Model(modelDateSuffix.ReplaceAllString(string(model), ""))]
+	return p, ok
+}
+
+// Cost is an amount spent on tokens, in US dollars.
+type Cost struct {
+	Input      float64
+	CacheWrite float64
+	CacheRead  float64
+	Output     float64
+}
+
+// Total returns the sum of the cost of all kinds of tokens.
+func (c Cost) Total() float64 {
+	return c.Input + c.CacheWrite + c.CacheRead + c.Output
+}
+
+func (c Cost) add(o Cost) Cost {
+	return Cost{
+		Input:      c.Input + o.Input,
+		CacheWrite: c.CacheWrite + o.CacheWrite,
+		CacheRead:  c.CacheRead + o.CacheRead,
+		Output:     c.Output + o.Output,
+	}
+}
+
+// Cost returns the cost of the tokens in usage. Batch usage is charged at half
+// price. Server tool requests, such as web searches, are not included.
+func (p ModelPricing) Cost(usage Usage) Cost {
+	write5m, write1h := usage.CacheCreation.Ephemeral5mInputTokens, usage.CacheCreation.Ephemeral1hInputTokens
+	if write5m+write1h == 0 {
+		// Responses without the breakdown only use the 5 minute cache.
+		write5m = usage.CacheCreationInputTokens
+	}
+	scale := 1e-6
+	if usage.ServiceTier == UsageServiceTierBatch {
+		scale /= 2
+	}
+	return Cost{
+		Input:      float64(usage.InputTokens) * p.Input * scale,
+		CacheWrite: (float64(write5m)*p.CacheWrite5m + float64(write1h)*p.CacheWrite1h) * scale,
+		CacheRead:  float64(usage.CacheReadInputTokens) * p.CacheRead * scale,
+		Output:     float64(usage.OutputTokens) * p.Output * scale,
+	}
+}
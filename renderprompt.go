@@ -0,0 +1,188 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// RenderPrompt returns a human-readable rendering of the request built from
+// params, for inspecting a prompt without calling the API: the model and
+// token limit, the system prompt, a summary of each tool with its parameters,
+// the tool choice and every turn with its content blocks. Each cache_control
+// breakpoint is shown as a marker after the block it is set on, which makes
+// clear how much of the prompt it caches:
+//
+//	=== System ===
+//	You are a helpful travel assistant.
+//	--- cache breakpoint (ephemeral) ---
+//
+//	=== User ===
+//	What's the weather in Paris?
+//
+// Images and documents are summarized rather than included.
+func RenderPrompt(params MessageNewParams) string {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Sprintf("could not serialize params: %v\n", err)
+	}
+	request := gjson.ParseBytes(body)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Model: %s (max_tokens %d)\n", params.Model, params.MaxTokens)
+	if thinking := request.Get("thinking"); thinking.Get("type").String() == "enabled" {
+		fmt.Fprintf(&b, "Thinking: budget %d tokens\n", thinking.Get("budget_tokens").Int())
+	}
+
+	if system := request.Get("system"); system.Exists() {
+		b.WriteString("\n=== System ===\n")
+		if system.Type == gjson.String {
+			b.WriteString(system.String() + "\n")
+		}
+		for _, block := range system.Array() {
+			renderPromptBlock(&b, block, "")
+		}
+	}
+
+	if tools := request.Get("tools").Array(); len(tools) > 0 {
+		fmt.Fprintf(&b, "\n=== Tools (%d) ===\n", len(tools))
+		for _, tool := range tools {
+			renderPromptTool(&b, tool)
+		}
+	}
+	if choice := request.Get("tool_choice"); choice.Exists() {
+		fmt.Fprintf(&b, "Tool choice: %s", choice.Get("type").String())
+		if name := choice.Get("name"); name.Exists() {
+			fmt.Fprintf(&b, " %s", name.String())
+		}
+		b.WriteString("\n")
+	}
+
+	renderPromptMessages(&b, request.Get("messages"))
+	return b.String()
+}
+
+// renderPromptMessages writes each message of messages, a JSON array, under a
+// header naming its role.
+func renderPromptMessages(b *strings.Builder, messages gjson.Result) {
+	for _, message := range messages.Array() {
+		role := "User"
+		if message.Get("role").String() == "assistant" {
+			role = "Assistant"
+		}
+		fmt.Fprintf(b, "\n=== %s ===\n", role)
+		content := message.Get("content")
+		if content.Type == gjson.String {
+			b.WriteString(content.String() + "\n")
+		}
+		for _, block := range content.Array() {
+			renderPromptBlock(b, block, "")
+		}
+	}
+}
+
+// renderPromptTool writes a line for a tool, followed by a line for each of
+// its parameters, required ones marked with an asterisk.
+func renderPromptTool(b *strings.Builder, tool gjson.Result) {
+	fmt.Fprintf(b, "- %s", tool.Get("name").String())
+	if typ := tool.Get("type"); typ.Exists() && typ.String() != "custom" {
+		fmt.Fprintf(b, " (%s)", typ.String())
+	}
+	if description := tool.Get("description"); description.Exists() {
+		fmt.Fprintf(b, ": %s", firstLine(description.String()))
+	}
+	b.WriteString("\n")
+
+	required := map[string]bool{}
+	for _, name := range tool.Get("input_schema.required").Array() {
+		required[name.String()] = true
+	}
+	tool.Get("input_schema.properties").ForEach(func(name, schema gjson.Result) bool {
+		marker := ""
+		if required[name.String()] {
+			marker = "*"
+		}
+		fmt.Fprintf(b, "    %s%s %s", name.String(), marker, schema.Get("type").String())
+		if description := schema.Get("description"); description.Exists() {
+			fmt.Fprintf(b, ": %s", firstLine(description.String()))
+		}
+		b.WriteString("\n")
+		return true
+	})
+	renderCacheMarker(b, tool, "")
+}
+
+// renderPromptBlock writes a content block, indented by indent.
+func renderPromptBlock(b *strings.Builder, block gjson.Result, indent string) {
+	line := func(format string, args ...any) {
+		b.WriteString(indent + fmt.Sprintf(format, args...) + "\n")
+	}
+	switch block.Get("type").String() {
+	case "text":
+		for _, text := range strings.Split(block.Get("text").String(), "\n") {
+			line("%s", text)
+		}
+	case "image", "document":
+		source := block.Get("source")
+		description := source.Get("type").String()
+		switch {
+		case source.Get("url").Exists():
+			description += " " + source.Get("url").String()
+		case source.Get("media_type").Exists():
+			description += " " + source.Get("media_type").String()
+		}
+		if title := block.Get("title"); title.Exists() {
+			description = fmt.Sprintf("%q, %s", title.String(), description)
+		}
+		line("[%s: %s]", block.Get("type").String(), description)
+	case "tool_use", "server_tool_use":
+		line("[%s %s id=%s] %s", block.Get("type").String(), block.Get("name").String(), block.Get("id").String(), block.Get("input").Raw)
+	case "tool_result":
+		status := ""
+		if block.Get("is_error").Bool() {
+			status = " error"
+		}
+		line("[tool_result id=%s%s]", block.Get("tool_use_id").String(), status)
+		content := block.Get("content")
+		if content.Type == gjson.String {
+			for _, text := range strings.Split(content.String(), "\n") {
+				line("    %s", text)
+			}
+		}
+		for _, nested := range content.Array() {
+			renderPromptBlock(b, nested, indent+"    ")
+		}
+	case "thinking":
+		line("[thinking]")
+		for _, text := range strings.Split(block.Get("thinking").String(), "\n") {
+			line("    %s", text)
+		}
+	case "redacted_thinking":
+		line("[redacted thinking]")
+	default:
+		line("[%s] %s", block.Get("type").String(), block.Raw)
+	}
+	renderCacheMarker(b, block, indent)
+}
+
+// renderCacheMarker writes a cache breakpoint marker if v has cache control.
+func renderCacheMarker(b *strings.Builder, v gjson.Result, indent string) {
+	cache := v.Get("cache_control")
+	if !cache.Exists() {
+		return
+	}
+	kind := cache.Get("type").String()
+	if ttl := cache.Get("ttl"); ttl.Exists() {
+		kind += ", ttl " + ttl.String()
+	}
+	fmt.Fprintf(b, "%s--- cache breakpoint (%s) ---\n", indent, kind)
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " ..."
+	}
+	return s
+}
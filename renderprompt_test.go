@@ -0,0 +1,65 @@
+package anthropic_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestRenderPrompt(t *testing.T) {
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		MaxTokens: 1024,
+		System: []anthropic.TextBlockParam{{
+			Text:         "You are a helpful travel assistant.",
+			CacheControl: anthropic.NewCacheControlEphemeralParam(),
+		}},
+		Tools: []anthropic.ToolUnionParam{{
+			OfTool: &anthropic.ToolParam{
+				Name:        "get_weather",
+				Description: anthropic.String("Get the current weather."),
+				InputSchema: anthropic.ToolInputSchemaParam{
+					Properties: map[string]any{"city": map[string]any{"type": "string", "description": "The city name."}},
+					Required:   []string{"city"},
+				},
+			},
+		}},
+		ToolChoice: anthropic.ToolChoiceUnionParam{OfAuto: &anthropic.ToolChoiceAutoParam{}},
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock("What's the weather in Paris?")),
+			anthropic.NewAssistantMessage(anthropic.NewToolUseBlock("toolu_01", map[string]any{"city": "Paris"}, "get_weather")),
+			anthropic.NewUserMessage(anthropic.NewToolResultBlock("toolu_01", "Sunny", false)),
+		},
+	}
+
+	expected := `Model: claude-sonnet-4-5-20250929 (max_tokens 1024)
+
+=== System ===
+You are a helpful travel assistant.
+--- cache breakpoint (ephemeral) ---
+
+=== Tools (1) ===
+- get_weather: Get the current weather.
+    city* string: The city name.
+Tool choice: auto
+
+=== User ===
+What's the weather in Paris?
+
+=== Assistant ===
+[tool_use get_weather id=toolu_01] {"city":"Paris"}
+
+=== User ===
+[tool_result id=toolu_01]
+    Sunny
+`
+	if rendered := anthropic.RenderPrompt(params); rendered != expected {
+		t.Errorf("unexpected rendering:\n%s", rendered)
+	}
+
+	params.Messages[0].Content[0].OfText.CacheControl = anthropic.CacheControlEphemeralParam{TTL: anthropic.CacheControlEphemeralTTLTTL1h}
+	if rendered := anthropic.RenderPrompt(params); !strings.Contains(rendered, "What's the weather in Paris?\n--- cache breakpoint (ephemeral, ttl 1h) ---\n") {
+		t.Errorf("expected a cache breakpoint after the first message:\n%s", rendered)
+	}
+}
@@ -0,0 +1,180 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// reproRedacted replaces values in a repro which may identify end users.
+const reproRedacted = "[REDACTED]"
+
+// MinimalRepro returns a runnable Go program which sends the same request as
+// params, for attaching to bug reports. Models, token limits, sampling
+// parameters, the system prompt and plain text messages are written with the
+// SDK's builder functions; any other fields are restored verbatim from JSON.
+//
+// The program reads the API key from the environment, so no credentials are
+// included. The metadata user ID is redacted.
+func MinimalRepro(params MessageNewParams) string {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Sprintf("// could not serialize params: %v\n", err)
+	}
+	if gjson.GetBytes(body, "metadata.user_id").Exists() {
+		body, _ = sjson.SetBytes(body, "metadata.user_id", reproRedacted)
+	}
+
+	var b strings.Builder
+	usesJSON := false
+
+	b.WriteString("\tparams := anthropic.MessageNewParams{\n")
+	fmt.Fprintf(&b, "\t\tModel: anthropic.Model(%s),\n", strconv.Quote(string(params.Model)))
+	fmt.Fprintf(&b, "\t\tMaxTokens: %d,\n", params.MaxTokens)
+	body, _ = sjson.DeleteBytes(body, "model")
+	body, _ = sjson.DeleteBytes(body, "max_tokens")
+
+	for _, field := range []struct{ key, name, helper string }{
+		{"temperature", "Temperature", "anthropic.Float"},
+		{"top_k", "TopK", "anthropic.Int"},
+		{"top_p", "TopP", "anthropic.Float"},
+	} {
+		if v := gjson.GetBytes(body, field.key); v.Exists() {
+			fmt.Fprintf(&b, "\t\t%s: %s(%s),\n", field.name, field.helper, v.Raw)
+			body, _ = sjson.DeleteBytes(body, field.key)
+		}
+	}
+
+	if len(params.StopSequences) > 0 {
+		b.WriteString("\t\tStopSequences: []string{")
+		for i, s := range params.StopSequences {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(strconv.Quote(s))
+		}
+		b.WriteString("},\n")
+		body, _ = sjson.DeleteBytes(body, "stop_sequences")
+	}
+
+	if system := gjson.GetBytes(body, "system"); system.Exists() && isPlainTextBlocks(system) {
+		b.WriteString("\t\tSystem: []anthropic.TextBlockParam{\n")
+		for _, block := range system.Array() {
+			fmt.Fprintf(&b, "\t\t\t{Text: %s},\n", strconv.Quote(block.Get("text").String()))
+		}
+		b.WriteString("\t\t},\n")
+		body, _ = sjson.DeleteBytes(body, "system")
+	}
+
+	b.WriteString("\t\tMessages: []anthropic.MessageParam{\n")
+	for _, message := range gjson.GetBytes(body, "messages").Array() {
+		content := message.Get("content")
+		constructor := "anthropic.NewUserMessage"
+		if message.Get("role").String() == "assistant" {
+			constructor = "anthropic.NewAssistantMessage"
+		}
+		if !isPlainTextBlocks(content) {
+			fmt.Fprintf(&b, "\t\t\tmessageFromJSON(%s),\n", reproQuote(message.Raw))
+			usesJSON = true
+			continue
+		}
+		texts := []string{content.String()}
+		if content.IsArray() {
+			texts = texts[:0]
+			for _, block := range content.Array() {
+				texts = append(texts, block.Get("text").String())
+			}
+		}
+		fmt.Fprintf(&b, "\t\t\t%s(", constructor)
+		for i, text := range texts {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "anthropic.NewTextBlock(%s)", strconv.Quote(text))
+		}
+		b.WriteString("),\n")
+	}
+	b.WriteString("\t\t},\n")
+	b.WriteString("\t}\n\n")
+	body, _ = sjson.DeleteBytes(body, "messages")
+
+	// The remaining fields are set on the request body directly.
+	var opts []string
+	gjson.ParseBytes(body).ForEach(func(key, value gjson.Result) bool {
+		opts = append(opts, fmt.Sprintf("\t\toption.WithJSONSet(%s, json.RawMessage(%s)),\n", strconv.Quote(key.String()), reproQuote(value.Raw)))
+		return true
+	})
+
+	var src strings.Builder
+	src.WriteString("package main\n\nimport (\n\t\"context\"\n")
+	if usesJSON || len(opts) > 0 {
+		src.WriteString("\t\"encoding/json\"\n")
+	}
+	src.WriteString("\t\"fmt\"\n\n\t\"github.com/sofianhadi1983/anthropic-sdk-go\"\n")
+	if len(opts) > 0 {
+		src.WriteString("\t\"github.com/sofianhadi1983/anthropic-sdk-go/option\"\n")
+	}
+	src.WriteString(")\n\n")
+	src.WriteString("func main() {\n")
+	src.WriteString("\t// Reads the API key from the ANTHROPIC_API_KEY environment variable.\n")
+	src.WriteString("\tclient := anthropic.NewClient()\n\n")
+	src.WriteString(b.String())
+	if len(opts) > 0 {
+		src.WriteString("\tmessage, err := client.Messages.New(context.TODO(), params,\n")
+		for _, opt := range opts {
+			src.WriteString(opt)
+		}
+		src.WriteString("\t)\n")
+	} else {
+		src.WriteString("\tmessage, err := client.Messages.New(context.TODO(), params)\n")
+	}
+	src.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	src.WriteString("\tfmt.Println(message.RawJSON())\n")
+	src.WriteString("}\n")
+	if usesJSON {
+		src.WriteString("\nfunc messageFromJSON(raw string) (m anthropic.MessageParam) {\n")
+		src.WriteString("\tif err := json.Unmarshal([]byte(raw), &m); err != nil {\n\t\tpanic(err)\n\t}\n")
+		src.WriteString("\treturn m\n}\n")
+	}
+
+	formatted, err := format.Source([]byte(src.String()))
+	if err != nil {
+		return src.String()
+	}
+	return string(formatted)
+}
+
+// isPlainTextBlocks reports whether content is a string or an array of text
+// blocks without any additional fields, such as cache control or citations.
+func isPlainTextBlocks(content gjson.Result) bool {
+	if content.Type == gjson.String {
+		return true
+	}
+	if !content.IsArray() {
+		return false
+	}
+	plain := true
+	content.ForEach(func(_, block gjson.Result) bool {
+		block.ForEach(func(key, _ gjson.Result) bool {
+			plain = key.String() == "type" || key.String() == "text"
+			return plain
+		})
+		plain = plain && block.Get("type").String() == "text"
+		return plain
+	})
+	return plain
+}
+
+// reproQuote quotes s as a Go string literal, preferring a raw string literal
+// so that JSON stays readable.
+func reproQuote(s string) string {
+	if strconv.CanBackquote(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,48 @@
+package anthropic_test
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestMinimalRepro(t *testing.T) {
+	params := anthropic.MessageNewParams{
+		Model:         anthropic.ModelClaudeSonnet4_5_20250929,
+		MaxTokens:     1024,
+		Temperature:   anthropic.Float(0.5),
+		StopSequences: []string{"END"},
+		System:        []anthropic.TextBlockParam{{Text: "Be terse."}},
+		Metadata:      anthropic.MetadataParam{UserID: anthropic.String("user-1234")},
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock("What's the weather?")),
+			anthropic.NewAssistantMessage(anthropic.NewToolUseBlock("toolu_01", map[string]any{"city": "Paris"}, "get_weather")),
+			anthropic.NewUserMessage(anthropic.NewToolResultBlock("toolu_01", "Sunny", false)),
+		},
+		Tools: []anthropic.ToolUnionParam{{
+			OfTool: &anthropic.ToolParam{Name: "get_weather", InputSchema: anthropic.ToolInputSchemaParam{}},
+		}},
+	}
+
+	src := anthropic.MinimalRepro(params)
+	if _, err := parser.ParseFile(token.NewFileSet(), "main.go", src, 0); err != nil {
+		t.Fatalf("expected valid Go source, got %v:\n%s", err, src)
+	}
+	for _, expected := range []string{
+		`anthropic.Model("claude-sonnet-4-5-20250929")`,
+		`anthropic.Float(0.5)`,
+		`anthropic.NewUserMessage(anthropic.NewTextBlock("What's the weather?"))`,
+		`messageFromJSON(`,
+		`option.WithJSONSet("tools"`,
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("expected repro to contain %q:\n%s", expected, src)
+		}
+	}
+	if strings.Contains(src, "user-1234") {
+		t.Errorf("expected the metadata user ID to be redacted:\n%s", src)
+	}
+}
@@ -0,0 +1,171 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/streamsplice"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/sofianhadi1983/anthropic-sdk-go/packages/ssestream"
+)
+
+// DefaultMaxReconnects is the initial value of [ResilientStream.MaxReconnects].
+const DefaultMaxReconnects = 3
+
+// ResilientStream is a message stream which reconnects when the connection
+// drops before the message is complete. See [MessageService.NewResilientStream].
+type ResilientStream struct {
+	// MaxReconnects is the number of times the stream may reconnect. It may be
+	// changed before the first call to Next.
+	MaxReconnects int
+
+	ctx     context.Context
+	service *MessageService
+	params  MessageNewParams
+	opts    []option.RequestOption
+
+	stream     *ssestream.Stream[MessageStreamEventUnion]
+	splicer    streamsplice.Splicer
+	message    Message
+	cur        MessageStreamEventUnion
+	pending    []MessageStreamEventUnion
+	err        error
+	reconnects int
+	done       bool
+}
+
+// NewResilientStream streams a message like [MessageService.NewStreaming], but
+// if the connection drops before the message is complete, it sends the request
+// again with the content received so far as a prefilled assistant turn, so that
+// the model picks up where it left off. The events of the new connection are
+// adjusted to continue the same message: its message_start is skipped, block
+// indices carry on from the previous connection, a text block cut short is
+// continued rather than started again, and the usage of the final
+// message_delta counts the output of every connection but not the prefill. The
+// caller sees a single message, and [ResilientStream.Reconnects] reports how
+// many times this happened.
+//
+// A stream is only resumed while the content so far can be prefilled, that is
+// when it holds no tool use or, with extended thinking enabled, any content at
+// all, and when the connection did not drop part way through a block other
+// than text. Each reconnect waits for the client's delay between retries,
+// which [option.WithRetryPolicy] may change. Errors returned by the API are not
+// retried here; the client's own retries apply to them. The output tokens of a connection which dropped
+// before reporting them are estimated from the text it delivered.
+func (r *MessageService) NewResilientStream(ctx context.Context, params MessageNewParams, opts ...option.RequestOption) *ResilientStream {
+	return &ResilientStream{
+		MaxReconnects: DefaultMaxReconnects,
+		ctx:           ctx,
+		service:       r,
+		params:        params,
+		opts:          opts,
+		stream:        r.NewStreaming(ctx, params, opts...),
+	}
+}
+
+// Next advances the stream, reconnecting if needed. It returns false once the
+// message is complete or an error occurred.
+func (s *ResilientStream) Next() bool {
+	for s.err == nil && !s.done {
+		if len(s.pending) > 0 {
+			s.cur, s.pending = s.pending[0], s.pending[1:]
+			if s.err = s.message.Accumulate(s.cur); s.err != nil {
+				return false
+			}
+			s.done = s.cur.Type == "message_stop"
+			return true
+		}
+		if s.stream.Next() {
+			event := s.stream.Current()
+			for _, data := range s.splicer.Splice(event.RawJSON()) {
+				if data != event.RawJSON() {
+					event = MessageStreamEventUnion{}
+					if s.err = event.UnmarshalJSON([]byte(data)); s.err != nil {
+						return false
+					}
+				}
+				s.pending = append(s.pending, event)
+			}
+			continue
+		}
+
+		err := s.stream.Err()
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		if !s.reconnect() {
+			s.err = err
+		}
+	}
+	return false
+}
+
+// reconnect sends the request again with the content received so far as a
+// prefill, and reports whether it could.
+func (s *ResilientStream) reconnect() bool {
+	var apierr *Error
+	if s.ctx.Err() != nil || errors.As(s.stream.Err(), &apierr) || s.reconnects >= s.MaxReconnects {
+		return false
+	}
+	texts, ok := s.splicer.Prefill(s.params.Thinking.OfEnabled != nil)
+	if !ok {
+		return false
+	}
+
+	if !s.wait() {
+		return false
+	}
+
+	params := s.params
+	params.Messages = slices.Clone(params.Messages)
+	if len(texts) > 0 {
+		prefill := make([]ContentBlockParamUnion, len(texts))
+		for i, text := range texts {
+			prefill[i] = NewTextBlock(text)
+		}
+		params.Messages = append(params.Messages, NewAssistantMessage(prefill...))
+	}
+
+	s.stream.Close()
+	s.stream = s.service.NewStreaming(s.ctx, params, s.opts...)
+	s.reconnects++
+	s.splicer.Resume()
+	return true
+}
+
+// wait waits for the client's delay before the next reconnect, and reports
+// whether the context was still live afterwards.
+func (s *ResilientStream) wait() bool {
+	cfg, err := requestconfig.NewRequestConfig(s.ctx, http.MethodPost, "", nil, nil, slices.Concat(s.service.Options, s.opts)...)
+	if err != nil {
+		return false
+	}
+	timer := time.NewTimer(cfg.RetryBackoff.Delay(s.reconnects))
+	defer timer.Stop()
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Current returns the current event.
+func (s *ResilientStream) Current() MessageStreamEventUnion { return s.cur }
+
+// Err returns the error which ended the stream, if any.
+func (s *ResilientStream) Err() error { return s.err }
+
+// Message returns the message accumulated from all connections so far.
+func (s *ResilientStream) Message() Message { return s.message }
+
+// Reconnects returns the number of times the stream has reconnected.
+func (s *ResilientStream) Reconnects() int { return s.reconnects }
+
+// Close closes the underlying connection.
+func (s *ResilientStream) Close() error { return s.stream.Close() }
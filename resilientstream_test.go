@@ -0,0 +1,192 @@
+package anthropic_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+)
+
+// droppingClient returns a client which serves the given stream bodies in
+// turn, each followed by a connection error except the last, and records the
+// request bodies. It waits a millisecond before each reconnect.
+func droppingClient(bodies []string, requests *[]string) anthropic.Client {
+	return anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithRetryPolicy(option.RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					reqBody, _ := io.ReadAll(req.Body)
+					*requests = append(*requests, string(reqBody))
+					body := bodies[len(*requests)-1]
+					var r io.Reader = strings.NewReader(body)
+					if len(*requests) < len(bodies) {
+						r = io.MultiReader(r, iotest.ErrReader(errors.New("connection reset by peer")))
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"text/event-stream"}},
+						Body:       io.NopCloser(r),
+					}, nil
+				},
+			},
+		}),
+	)
+}
+
+func TestResilientStream(t *testing.T) {
+	first := textStreamEvents("Hello wor", "ld! ", "How are")
+	// Drop the connection part way through the text block.
+	dropped := sseBody(first[:10]...)
+
+	var requests []string
+	client := droppingClient([]string{dropped, sseBody(textStreamEvents(" you?")...)}, &requests)
+	stream := client.Messages.NewResilientStream(context.Background(), anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hi"))},
+	})
+	defer stream.Close()
+
+	var types []string
+	message := anthropic.Message{}
+	for stream.Next() {
+		event := stream.Current()
+		types = append(types, event.Type)
+		if event.Index != 0 {
+			t.Errorf("unexpected index %d in %s", event.Index, event.RawJSON())
+		}
+		if err := message.Accumulate(event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedTypes := "message_start content_block_start content_block_delta content_block_delta content_block_delta content_block_delta content_block_stop message_delta message_stop"
+	if strings.Join(types, " ") != expectedTypes {
+		t.Errorf("expected events %s, got %s", expectedTypes, strings.Join(types, " "))
+	}
+	if stream.Reconnects() != 1 || len(requests) != 2 {
+		t.Fatalf("expected one reconnect, got %d after %d requests", stream.Reconnects(), len(requests))
+	}
+	if prefill := gjson.Get(requests[1], "messages.1"); prefill.Get("role").String() != "assistant" || prefill.Get("content.0.text").String() != "Hello world! How are" {
+		t.Errorf("unexpected prefill %s", prefill.Raw)
+	}
+	for _, m := range []anthropic.Message{message, stream.Message()} {
+		if len(m.Content) != 1 || m.Content[0].Text != "Hello world! How are you?" || m.StopReason != anthropic.StopReasonEndTurn {
+			t.Errorf("unexpected message %+v", m.Content)
+		}
+		// The 20 characters received before the drop count as 5 output tokens.
+		if m.Usage.InputTokens != 10 || m.Usage.OutputTokens != 17 {
+			t.Errorf("expected 10 input and 17 output tokens, got %d and %d", m.Usage.InputTokens, m.Usage.OutputTokens)
+		}
+	}
+}
+
+func TestResilientStreamMaxReconnects(t *testing.T) {
+	dropped := sseBody(textStreamEvents("Hello")[:4]...)
+
+	var requests []string
+	client := droppingClient([]string{dropped, dropped, dropped}, &requests)
+	stream := client.Messages.NewResilientStream(context.Background(), anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hi"))},
+	})
+	stream.MaxReconnects = 1
+	for stream.Next() {
+	}
+	if err := stream.Err(); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("expected the connection error, got %v", err)
+	}
+	if stream.Reconnects() != 1 || len(requests) != 2 {
+		t.Errorf("expected one reconnect, got %d after %d requests", stream.Reconnects(), len(requests))
+	}
+}
+
+func TestResilientStreamBackoff(t *testing.T) {
+	dropped := sseBody(textStreamEvents("Hello")[:4]...)
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hi"))},
+	}
+
+	var requests []string
+	client := droppingClient([]string{dropped, dropped, sseBody(textStreamEvents(" there")...)}, &requests)
+	start := time.Now()
+	stream := client.Messages.NewResilientStream(context.Background(), params,
+		option.WithRetryPolicy(option.RetryPolicy{InitialBackoff: 50 * time.Millisecond, MaxBackoff: time.Second}))
+	for stream.Next() {
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The reconnects wait 50ms and then 100ms.
+	if elapsed := time.Since(start); stream.Reconnects() != 2 || elapsed < 150*time.Millisecond {
+		t.Errorf("expected two reconnects after backing off, got %d after %v", stream.Reconnects(), elapsed)
+	}
+
+	requests = nil
+	client = droppingClient([]string{dropped, dropped}, &requests)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	stream = client.Messages.NewResilientStream(ctx, params,
+		option.WithRetryPolicy(option.RetryPolicy{InitialBackoff: time.Minute, MaxBackoff: time.Minute}))
+	for stream.Next() {
+	}
+	if err := stream.Err(); err == nil || len(requests) != 1 {
+		t.Errorf("expected the connection error without reconnecting, got %v after %d requests", err, len(requests))
+	}
+}
+
+func TestResilientStreamNewBlockAfterReconnect(t *testing.T) {
+	dropped := sseBody(textStreamEvents("Let me check.")[:6]...)
+	resumed := sseBody(
+		"message_start", `{"type":"message_start","message":{"id":"msg_2","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":20,"output_tokens":1}}}`,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"q\":\"x\"}"}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":0}`,
+		"message_delta", `{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":12}}`,
+		"message_stop", `{"type":"message_stop"}`,
+	)
+
+	var requests []string
+	client := droppingClient([]string{dropped, resumed}, &requests)
+	stream := client.Messages.NewResilientStream(context.Background(), anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hi"))},
+	})
+
+	var events []string
+	for stream.Next() {
+		event := stream.Current()
+		if strings.HasPrefix(event.Type, "content_block") {
+			events = append(events, event.Type+":"+gjson.Get(event.RawJSON(), "index").String())
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "content_block_start:0 content_block_delta:0 content_block_stop:0 content_block_start:1 content_block_delta:1 content_block_stop:1"
+	if strings.Join(events, " ") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(events, " "))
+	}
+	message := stream.Message()
+	if len(message.Content) != 2 || message.Content[0].Text != "Let me check." || message.Content[1].Name != "lookup" || string(message.Content[1].Input) != `{"q":"x"}` {
+		t.Errorf("unexpected message content %+v", message.Content)
+	}
+}
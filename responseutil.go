@@ -0,0 +1,37 @@
+package anthropic
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+// NewWithResponse is like [MessageService.New], but also returns the HTTP
+// response which produced the message, for its status and headers, such as
+// request-id to correlate with server logs and the anthropic-ratelimit-*
+// counters:
+//
+//	message, res, err := client.Messages.NewWithResponse(ctx, params)
+//	if err == nil {
+//		log.Printf("%s: %s requests remaining", res.Header.Get("request-id"),
+//			res.Header.Get("anthropic-ratelimit-requests-remaining"))
+//	}
+//
+// The body of the response has already been read and closed. The response is
+// returned along with an error when the request failed after a response was
+// received, and is nil when none was. This is equivalent to passing
+// [option.WithResponseInto].
+func (r *MessageService) NewWithResponse(ctx context.Context, body MessageNewParams, opts ...option.RequestOption) (*Message, *http.Response, error) {
+	var res *http.Response
+	message, err := r.New(ctx, body, append(opts, option.WithResponseInto(&res))...)
+	return message, res, err
+}
+
+// NewWithResponse is like [BetaMessageService.New], but also returns the HTTP
+// response which produced the message. See [MessageService.NewWithResponse].
+func (r *BetaMessageService) NewWithResponse(ctx context.Context, body BetaMessageNewParams, opts ...option.RequestOption) (*BetaMessage, *http.Response, error) {
+	var res *http.Response
+	message, err := r.New(ctx, body, append(opts, option.WithResponseInto(&res))...)
+	return message, res, err
+}
@@ -0,0 +1,49 @@
+package anthropic_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+func TestMessageNewWithResponse(t *testing.T) {
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header: http.Header{
+							"Content-Type":                           {"application/json"},
+							"Request-Id":                             {"req_123"},
+							"Anthropic-Ratelimit-Requests-Remaining": {"49"},
+						},
+						Body: io.NopCloser(strings.NewReader(`{"id":"msg_1","type":"message","role":"assistant","content":[]}`)),
+					}, nil
+				},
+			},
+		}),
+	)
+
+	message, res, err := client.Messages.NewWithResponse(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if message.ID != "msg_1" {
+		t.Errorf("unexpected message %s", message.ID)
+	}
+	if res.Header.Get("request-id") != "req_123" || res.Header.Get("anthropic-ratelimit-requests-remaining") != "49" {
+		t.Errorf("unexpected response headers: %v", res.Header)
+	}
+}
@@ -1,11 +1,15 @@
 package anthropic
 
 import (
+	"encoding"
+	"encoding/json"
 	"fmt"
 	"maps"
+	"reflect"
 	"slices"
 	"sort"
 	"strings"
+	"time"
 )
 
 // BetaJSONSchemaOutputFormat creates a BetaJSONOutputFormatParam from a JSON schema map.
@@ -242,3 +246,160 @@ func transformSchema(jsonSchema map[string]any) map[string]any {
 
 	return strictSchema
 }
+
+// ToolFromSchema returns a tool named name whose input schema is reflected from
+// the Go type T, usually a struct, so that the input of its tool_use blocks can
+// be decoded back into a T with [Extract]. This makes a forced tool a typed
+// structured output:
+//
+//	type Weather struct {
+//		City  string  `json:"city" jsonschema_description:"The city name"`
+//		TempC float64 `json:"temp_c"`
+//		Notes string  `json:"notes,omitempty"`
+//	}
+//
+//	params.Tools = []anthropic.ToolUnionParam{anthropic.ToolFromSchema[Weather]("record_weather", "Record the weather")}
+//	params.ToolChoice = anthropic.ToolChoiceParamOfTool("record_weather")
+//	...
+//	weather, err := anthropic.Extract[Weather](*message, "record_weather")
+//
+// The schema follows encoding/json: fields are named by their json tag, fields
+// tagged "-" and unexported fields are skipped, and fields without omitempty
+// or omitzero are required. A jsonschema_description tag sets the description
+// of a field. Pointers have the schema of the type they point to, and
+// time.Time is a date-time string.
+func ToolFromSchema[T any](name, description string) ToolUnionParam {
+	schema := reflectSchema(reflect.TypeFor[T](), map[reflect.Type]bool{})
+	tool := ToolUnionParamOfTool(ToolInputSchemaParam{
+		Properties: schema["properties"],
+		Required:   schemaRequired(schema),
+	}, name)
+	if description != "" {
+		tool.OfTool.Description = String(description)
+	}
+	return tool
+}
+
+// BetaToolFromSchema is like [ToolFromSchema], for the beta API. Decode the
+// input of its tool_use blocks with [Unmarshal].
+func BetaToolFromSchema[T any](name, description string) BetaToolUnionParam {
+	schema := reflectSchema(reflect.TypeFor[T](), map[reflect.Type]bool{})
+	tool := BetaToolUnionParamOfTool(BetaToolInputSchemaParam{
+		Properties: schema["properties"],
+		Required:   schemaRequired(schema),
+	}, name)
+	if description != "" {
+		tool.OfTool.Description = String(description)
+	}
+	return tool
+}
+
+func schemaRequired(schema map[string]any) []string {
+	required, _ := schema["required"].([]string)
+	return required
+}
+
+var (
+	timeType        = reflect.TypeFor[time.Time]()
+	rawMessageType  = reflect.TypeFor[json.RawMessage]()
+	jsonNumberType  = reflect.TypeFor[json.Number]()
+	textMarshalType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// reflectSchema returns the JSON schema of the values of type t, as encoded by
+// encoding/json. Types which are being reflected, seen, have an object schema
+// without properties when they recur.
+func reflectSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t == jsonNumberType:
+		return map[string]any{"type": "number"}
+	case t.Kind() != reflect.String && t.Implements(textMarshalType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string.
+			return map[string]any{"type": "string"}
+		}
+		return map[string]any{"type": "array", "items": reflectSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": reflectSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]any{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		properties := map[string]any{}
+		required := []string{}
+		reflectFields(t, seen, properties, &required)
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		// Interfaces accept any value.
+		return map[string]any{}
+	}
+}
+
+// reflectFields adds the schemas of the fields of the struct type t to
+// properties, including the fields of embedded structs.
+func reflectFields(t reflect.Type, seen map[reflect.Type]bool, properties map[string]any, required *[]string) {
+	for _, field := range reflect.VisibleFields(t) {
+		if len(field.Index) > 1 {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		options := strings.Split(opts, ",")
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			reflectFields(fieldType, seen, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := reflectSchema(field.Type, seen)
+		if slices.Contains(options, "string") {
+			schema = map[string]any{"type": "string"}
+		}
+		if description := field.Tag.Get("jsonschema_description"); description != "" {
+			schema["description"] = description
+		}
+		properties[name] = schema
+		if !slices.Contains(options, "omitempty") && !slices.Contains(options, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package anthropic
 import (
 	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestTransformSchema(t *testing.T) {
@@ -232,3 +234,45 @@ func TestTransformSchema(t *testing.T) {
 		})
 	}
 }
+
+func TestToolFromSchema(t *testing.T) {
+	type Location struct {
+		City    string `json:"city" jsonschema_description:"The city name"`
+		Country string `json:"country,omitempty"`
+	}
+	type Forecast struct {
+		Location
+		Days    int               `json:"days"`
+		Summary *string           `json:"summary,omitzero"`
+		Temps   []float64         `json:"temps"`
+		Tags    map[string]string `json:"tags,omitempty"`
+		When    time.Time         `json:"when"`
+		Next    *Forecast         `json:"next,omitempty"`
+		Ignored string            `json:"-"`
+		hidden  string
+	}
+
+	tool := ToolFromSchema[Forecast]("record_forecast", "Record a forecast")
+	got, err := json.Marshal(tool)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"input_schema":{"properties":{` +
+		`"city":{"description":"The city name","type":"string"},"country":{"type":"string"},` +
+		`"days":{"type":"integer"},"next":{"type":"object"},"summary":{"type":"string"},` +
+		`"tags":{"additionalProperties":{"type":"string"},"type":"object"},` +
+		`"temps":{"items":{"type":"number"},"type":"array"},"when":{"format":"date-time","type":"string"}},` +
+		`"required":["city","days","temps","when"],"type":"object"},` +
+		`"name":"record_forecast","description":"Record a forecast"}`
+	if string(got) != want {
+		t.Errorf("unexpected tool:\n%s\nwant:\n%s", got, want)
+	}
+
+	beta, err := json.Marshal(BetaToolFromSchema[Forecast]("record_forecast", ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(beta), `"required":["city","days","temps","when"]`) || strings.Contains(string(beta), "description\":\"Record") {
+		t.Errorf("unexpected beta tool: %s", beta)
+	}
+}
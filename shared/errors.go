@@ -0,0 +1,41 @@
+package shared
+
+// Error implements the error interface, so that a *BillingError can be
+// extracted from an API error with errors.As.
+func (r *BillingError) Error() string {
+	if r.Message == "" {
+		return "billing error"
+	}
+	return "billing error: " + r.Message
+}
+
+// Error implements the error interface, so that a *RateLimitError can be
+// extracted from an API error with errors.As.
+func (r *RateLimitError) Error() string {
+	return apiErrorString("rate limit error", r.Message)
+}
+
+// Error implements the error interface, so that an *OverloadedError can be
+// extracted from an API error with errors.As.
+func (r *OverloadedError) Error() string {
+	return apiErrorString("overloaded error", r.Message)
+}
+
+// Error implements the error interface, so that an *AuthenticationError can be
+// extracted from an API error with errors.As.
+func (r *AuthenticationError) Error() string {
+	return apiErrorString("authentication error", r.Message)
+}
+
+// Error implements the error interface, so that an *InvalidRequestError can be
+// extracted from an API error with errors.As.
+func (r *InvalidRequestError) Error() string {
+	return apiErrorString("invalid request error", r.Message)
+}
+
+func apiErrorString(kind string, message string) string {
+	if message == "" {
+		return kind
+	}
+	return kind + ": " + message
+}
@@ -0,0 +1,174 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/packages/ssestream"
+	"github.com/tidwall/gjson"
+)
+
+// sseKeepAliveInterval is how long [WriteSSE] waits for an event before
+// sending a comment, so that proxies do not close an idle connection.
+var sseKeepAliveInterval = 15 * time.Second
+
+// WriteSSE consumes the stream and re-emits it to w as Server-Sent Events for a
+// browser, for example through an EventSource. It sets the response headers,
+// flushes after every event and sends a keep-alive comment when the model is
+// slow to respond. The events are simplified for a client:
+//
+//	event: start      data: {"id":"msg_...","model":"..."}
+//	event: text       data: {"index":0,"text":"..."}
+//	event: thinking   data: {"index":0,"thinking":"..."}
+//	event: tool_use   data: {"index":1,"id":"toolu_...","name":"...","input":{...}}
+//	event: done       data: {"stop_reason":"end_turn"}
+//	event: error      data: {"message":"..."}
+//
+// A tool_use event is sent once the input of the tool use is complete. If the
+// stream fails, an error event is sent and the stream's error is returned.
+// Otherwise WriteSSE returns the error writing to w, if any, typically because
+// the browser went away. The stream must not be iterated elsewhere, and is not
+// closed by WriteSSE.
+//
+//	func chat(w http.ResponseWriter, r *http.Request) {
+//		stream := client.Messages.NewStreaming(r.Context(), params)
+//		defer stream.Close()
+//		anthropic.WriteSSE(w, stream)
+//	}
+func WriteSSE[T StreamEvent](w http.ResponseWriter, stream *ssestream.Stream[T]) error {
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// Disable response buffering in nginx.
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sse := &sseWriter{
+		w:          w,
+		rc:         http.NewResponseController(w),
+		toolUses:   map[int64]gjson.Result{},
+		toolInputs: map[int64][]byte{},
+	}
+	if err := sse.rc.Flush(); err != nil {
+		return err
+	}
+
+	events := make(chan gjson.Result)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(events)
+		for stream.Next() {
+			select {
+			case events <- gjson.Parse(any(stream.Current()).(interface{ RawJSON() string }).RawJSON()):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				if err := stream.Err(); err != nil {
+					sse.write("error", map[string]any{"message": err.Error()})
+					return err
+				}
+				return sse.err
+			}
+			sse.translate(event)
+			keepAlive.Reset(sseKeepAliveInterval)
+		case <-keepAlive.C:
+			sse.comment("keep-alive")
+		}
+		if sse.err != nil {
+			return sse.err
+		}
+	}
+}
+
+// sseWriter writes browser events to a response, keeping the first error.
+type sseWriter struct {
+	w          http.ResponseWriter
+	rc         *http.ResponseController
+	err        error
+	stopReason string
+	// toolUses and toolInputs hold the open tool_use blocks and their partial
+	// input, by index.
+	toolUses   map[int64]gjson.Result
+	toolInputs map[int64][]byte
+}
+
+// translate writes the browser event for a stream event, if any.
+func (s *sseWriter) translate(event gjson.Result) {
+	index := event.Get("index").Int()
+	switch event.Get("type").String() {
+	case "message_start":
+		s.write("start", map[string]any{"id": event.Get("message.id").String(), "model": event.Get("message.model").String()})
+	case "content_block_start":
+		if block := event.Get("content_block"); block.Get("type").String() == "tool_use" {
+			s.toolUses[index] = block
+			s.toolInputs[index] = nil
+		}
+	case "content_block_delta":
+		delta := event.Get("delta")
+		switch delta.Get("type").String() {
+		case "text_delta":
+			s.write("text", map[string]any{"index": index, "text": delta.Get("text").String()})
+		case "thinking_delta":
+			s.write("thinking", map[string]any{"index": index, "thinking": delta.Get("thinking").String()})
+		case "input_json_delta":
+			s.toolInputs[index] = append(s.toolInputs[index], delta.Get("partial_json").String()...)
+		}
+	case "content_block_stop":
+		block, ok := s.toolUses[index]
+		if !ok {
+			return
+		}
+		input := json.RawMessage(s.toolInputs[index])
+		if len(input) == 0 {
+			input = json.RawMessage(block.Get("input").Raw)
+		}
+		if !json.Valid(input) {
+			input = json.RawMessage("{}")
+		}
+		delete(s.toolUses, index)
+		delete(s.toolInputs, index)
+		s.write("tool_use", map[string]any{"index": index, "id": block.Get("id").String(), "name": block.Get("name").String(), "input": input})
+	case "message_delta":
+		if stopReason := event.Get("delta.stop_reason"); stopReason.Type == gjson.String {
+			s.stopReason = stopReason.String()
+		}
+	case "message_stop":
+		s.write("done", map[string]any{"stop_reason": s.stopReason})
+	}
+}
+
+func (s *sseWriter) write(name string, data map[string]any) {
+	if s.err != nil {
+		return
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.send("event: " + name + "\ndata: " + string(b) + "\n\n")
+}
+
+func (s *sseWriter) comment(text string) {
+	if s.err == nil {
+		s.send(": " + text + "\n\n")
+	}
+}
+
+func (s *sseWriter) send(frame string) {
+	if _, s.err = s.w.Write([]byte(frame)); s.err == nil {
+		s.err = s.rc.Flush()
+	}
+}
@@ -0,0 +1,42 @@
+package anthropic_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestWriteSSE(t *testing.T) {
+	stream := newTestStream[anthropic.MessageStreamEventUnion](sseBody(
+		"message_start", `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Line one\nline two"}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":0}`,
+		"content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":1}`,
+		"message_delta", `{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":12}}`,
+		"message_stop", `{"type":"message_stop"}`,
+	))
+
+	w := httptest.NewRecorder()
+	if err := anthropic.WriteSSE(w, stream); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" || !w.Flushed {
+		t.Errorf("expected a flushed event stream, got %q", ct)
+	}
+
+	expected := strings.Join([]string{
+		"event: start\ndata: {\"id\":\"msg_1\",\"model\":\"claude-sonnet-4-5-20250929\"}\n\n",
+		"event: text\ndata: {\"index\":0,\"text\":\"Line one\\nline two\"}\n\n",
+		"event: tool_use\ndata: {\"id\":\"toolu_01\",\"index\":1,\"input\":{\"city\":\"Paris\"},\"name\":\"get_weather\"}\n\n",
+		"event: done\ndata: {\"stop_reason\":\"tool_use\"}\n\n",
+	}, "")
+	if w.Body.String() != expected {
+		t.Errorf("unexpected events:\n%s", w.Body.String())
+	}
+}
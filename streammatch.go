@@ -0,0 +1,110 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+// streamMatchTextTolerance is the fraction of words which may differ between
+// the texts compared by [MessageService.AssertStreamMatchesNonStream].
+const streamMatchTextTolerance = 0.05
+
+// StreamMismatchError is returned by
+// [MessageService.AssertStreamMatchesNonStream] when the streamed and
+// non-streamed responses differ beyond its tolerances.
+type StreamMismatchError struct {
+	// Streamed is the message accumulated from the stream.
+	Streamed *Message
+	// NonStreamed is the message returned by [MessageService.New].
+	NonStreamed *Message
+	// Diff compares NonStreamed with Streamed.
+	Diff MessageDiff
+	// Reasons lists the differences beyond the tolerances.
+	Reasons []string
+}
+
+func (e *StreamMismatchError) Error() string {
+	return "streamed and non-streamed responses differ: " + strings.Join(e.Reasons, "; ")
+}
+
+// AssertStreamMatchesNonStream sends params with both [MessageService.New] and
+// [MessageService.NewStreaming], accumulates the stream with
+// [Message.Accumulate], and returns a [*StreamMismatchError] if the two
+// messages differ. It is meant for tests, as a regression guard for the
+// accumulation of streams and for the determinism of a prompt, and makes two
+// billed requests.
+//
+// Even with a temperature of 0, the responses are not guaranteed to be
+// identical, so the comparison has tolerances:
+//   - the kinds of content blocks, tool calls, stop reason and input tokens
+//     must match exactly;
+//   - up to 5% of the words of the text may differ, and the output tokens are
+//     only compared when the text is identical;
+//   - thinking and cache tokens are ignored, since the first request may
+//     write the cache which the second one reads.
+//
+// An error is returned as is if either request fails.
+func (r *MessageService) AssertStreamMatchesNonStream(ctx context.Context, params MessageNewParams, opts ...option.RequestOption) error {
+	nonStreamed, err := r.New(ctx, params, opts...)
+	if err != nil {
+		return err
+	}
+	stream := r.NewStreaming(ctx, params, opts...)
+	defer stream.Close()
+	streamed := &Message{}
+	for stream.Next() {
+		if err := streamed.Accumulate(stream.Current()); err != nil {
+			return err
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return err
+	}
+
+	diff := DiffMessages(nonStreamed, streamed)
+	var reasons []string
+	if a, b := contentBlockTypes(nonStreamed), contentBlockTypes(streamed); a != b {
+		reasons = append(reasons, fmt.Sprintf("content blocks [%s] and [%s]", a, b))
+	}
+	for _, call := range diff.ToolCalls {
+		reasons = append(reasons, fmt.Sprintf("tool call %d", call.Index))
+	}
+	if len(diff.StopReasons) > 0 {
+		reasons = append(reasons, fmt.Sprintf("stop reasons %s and %s", diff.StopReasons[0], diff.StopReasons[1]))
+	}
+	if diff.Usage.InputTokens != 0 {
+		reasons = append(reasons, fmt.Sprintf("input tokens %d and %d", nonStreamed.Usage.InputTokens, streamed.Usage.InputTokens))
+	}
+	// A replaced word is both deleted and inserted, but counts once.
+	words := map[TextDiffOp]int{}
+	for _, t := range diff.Text {
+		words[t.Op] += len(strings.Fields(t.Text))
+	}
+	changed := max(words[TextDiffDelete], words[TextDiffInsert])
+	total := words[TextDiffEqual] + changed
+	switch {
+	case changed > 0 && float64(changed) > streamMatchTextTolerance*float64(total):
+		reasons = append(reasons, fmt.Sprintf("%d of %d words of text", changed, total))
+	case changed == 0 && diff.Usage.OutputTokens != 0:
+		reasons = append(reasons, fmt.Sprintf("output tokens %d and %d for the same text", nonStreamed.Usage.OutputTokens, streamed.Usage.OutputTokens))
+	}
+	if len(reasons) > 0 {
+		return &StreamMismatchError{Streamed: streamed, NonStreamed: nonStreamed, Diff: diff, Reasons: reasons}
+	}
+	return nil
+}
+
+// contentBlockTypes lists the types of the content blocks of a message, but
+// for thinking blocks, which are sampled even with a temperature of 0.
+func contentBlockTypes(m *Message) string {
+	var types []string
+	for _, block := range m.Content {
+		if block.Type != "thinking" && block.Type != "redacted_thinking" {
+			types = append(types, block.Type)
+		}
+	}
+	return strings.Join(types, " ")
+}
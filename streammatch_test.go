@@ -0,0 +1,59 @@
+package anthropic_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+func TestAssertStreamMatchesNonStream(t *testing.T) {
+	const text = "The quick brown fox jumps over the lazy dog."
+	newClient := func(streamed ...string) anthropic.Client {
+		return anthropic.NewClient(
+			option.WithAPIKey("my-anthropic-api-key"),
+			option.WithMaxRetries(0),
+			option.WithHTTPClient(&http.Client{Transport: &closureTransport{fn: func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}}
+				if strings.Contains(string(body), `"stream":true`) {
+					res.Header.Set("Content-Type", "text/event-stream")
+					res.Body = io.NopCloser(strings.NewReader(sseBody(textStreamEvents(streamed...)...)))
+				} else {
+					res.Body = io.NopCloser(strings.NewReader(fmt.Sprintf(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[{"type":"text","text":%q}],"stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":12}}`, text)))
+				}
+				return res, nil
+			}}}),
+		)
+	}
+	params := anthropic.MessageNewParams{
+		MaxTokens:   1024,
+		Model:       anthropic.ModelClaudeSonnet4_5_20250929,
+		Temperature: anthropic.Float(0),
+		Messages:    []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Write a pangram."))},
+	}
+
+	client := newClient("The quick brown ", "fox jumps over", " the lazy dog.")
+	if err := client.Messages.AssertStreamMatchesNonStream(context.Background(), params); err != nil {
+		t.Errorf("expected the responses to match, got %v", err)
+	}
+
+	client = newClient("The slow brown cat jumps over the lazy dog.")
+	err := client.Messages.AssertStreamMatchesNonStream(context.Background(), params)
+	var mismatch *anthropic.StreamMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected a mismatch, got %v", err)
+	}
+	if want := "streamed and non-streamed responses differ: 2 of 9 words of text"; err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+	if mismatch.Streamed.Text() != "The slow brown cat jumps over the lazy dog." {
+		t.Errorf("unexpected streamed text %q", mismatch.Streamed.Text())
+	}
+}
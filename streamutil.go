@@ -0,0 +1,721 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/sofianhadi1983/anthropic-sdk-go/packages/ssestream"
+	"github.com/tidwall/gjson"
+)
+
+// StopReasonClientOutputCap is the stop reason of streamed messages ended by
+// [option.WithHardOutputCap]. It is never returned by the API.
+const StopReasonClientOutputCap StopReason = requestconfig.StopReasonClientOutputCap
+
+// StreamEvent is satisfied by the event types yielded by
+// [MessageService.NewStreaming] and [BetaMessageService.NewStreaming], so that
+// the stream helpers in this package work with either API.
+type StreamEvent interface {
+	MessageStreamEventUnion | BetaRawMessageStreamEventUnion
+}
+
+// streamTextDelta returns the text carried by a text delta event.
+func streamTextDelta[T StreamEvent](event T) (string, bool) {
+	switch event := any(event).(type) {
+	case MessageStreamEventUnion:
+		if delta, ok := event.AsAny().(ContentBlockDeltaEvent); ok {
+			if text, ok := delta.Delta.AsAny().(TextDelta); ok {
+				return text.Text, true
+			}
+		}
+	case BetaRawMessageStreamEventUnion:
+		if delta, ok := event.AsAny().(BetaRawContentBlockDeltaEvent); ok {
+			if text, ok := delta.Delta.AsAny().(BetaTextDelta); ok {
+				return text.Text, true
+			}
+		}
+	}
+	return "", false
+}
+
+// sendOrDone sends v on ch unless done is closed first, reporting whether v
+// was sent, so that the goroutines feeding the channels returned by the stream
+// helpers do not block forever once the receiver stops reading.
+func sendOrDone[V any](ch chan<- V, v V, done <-chan struct{}) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// StreamPlainText consumes the stream and returns a channel of the assistant's
+// text with markdown formatting (headings, emphasis, code fences, links, list
+// markers) removed, which is useful for text-to-speech or plain log output.
+//
+// Because markdown syntax may be split across deltas, text is emitted one line
+// at a time. The channel is closed when the stream ends; check stream.Err()
+// afterwards. To stop reading the channel early, cancel ctx, which closes the
+// stream and the channel. The stream must not be iterated elsewhere while the
+// channel is being drained.
+//
+//	for text := range anthropic.StreamPlainText(ctx, stream) {
+//		speak(text)
+//	}
+//	if stream.Err() != nil { ... }
+func StreamPlainText[T StreamEvent](ctx context.Context, stream *ssestream.Stream[T]) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		defer context.AfterFunc(ctx, func() { stream.Close() })()
+		var stripper markdownStripper
+		for stream.Next() {
+			delta, ok := streamTextDelta(stream.Current())
+			if !ok {
+				continue
+			}
+			if text := stripper.Write(delta); text != "" && !sendOrDone(out, text, ctx.Done()) {
+				return
+			}
+		}
+		if text := stripper.Flush(); text != "" {
+			sendOrDone(out, text, ctx.Done())
+		}
+	}()
+	return out
+}
+
+// StreamThrottled consumes the stream and returns a channel of the assistant's
+// text which emits at most once per interval, so that a UI is not redrawn for
+// every token. Each value holds the text received since the previous one. The
+// first delta is emitted right away, and any remainder when the stream ends. The
+// channel is closed when the stream ends; check stream.Err() afterwards. To stop
+// reading the channel early, cancel ctx, which closes the stream and the
+// channel. The stream must not be iterated elsewhere while the channel is being
+// drained.
+//
+//	for text := range anthropic.StreamThrottled(ctx, stream, 50*time.Millisecond) {
+//		view.Append(text)
+//	}
+func StreamThrottled[T StreamEvent](ctx context.Context, stream *ssestream.Stream[T], interval time.Duration) <-chan string {
+	deltas := make(chan string)
+	go func() {
+		defer close(deltas)
+		defer context.AfterFunc(ctx, func() { stream.Close() })()
+		for stream.Next() {
+			if delta, ok := streamTextDelta(stream.Current()); ok && delta != "" && !sendOrDone(deltas, delta, ctx.Done()) {
+				return
+			}
+		}
+	}()
+
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		var pending strings.Builder
+		var lastEmit time.Time
+		var timer <-chan time.Time
+		emit := func() bool {
+			if !sendOrDone(out, pending.String(), ctx.Done()) {
+				return false
+			}
+			pending.Reset()
+			lastEmit = time.Now()
+			return true
+		}
+		for {
+			select {
+			case delta, ok := <-deltas:
+				if !ok {
+					if pending.Len() > 0 {
+						emit()
+					}
+					return
+				}
+				pending.WriteString(delta)
+				if timer != nil {
+					continue
+				}
+				if wait := interval - time.Since(lastEmit); wait > 0 {
+					timer = time.After(wait)
+				} else if !emit() {
+					return
+				}
+			case <-timer:
+				timer = nil
+				if !emit() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WithStopReasonCallback returns a RequestOption which calls fn with the stop
+// reason of a streamed message as soon as the message_delta event carrying it is
+// read from the connection. This happens before the stream yields that event and
+// before message_stop, so callers can react, for example by starting tool
+// execution, without waiting for the stream to drain.
+//
+// fn is called at most once per request, from the goroutine iterating the
+// stream. Non-streaming requests are unaffected.
+func WithStopReasonCallback(fn func(StopReason)) option.RequestOption {
+	return withStreamScanner(func() func(data []byte) bool {
+		return func(data []byte) bool {
+			if !bytes.Contains(data, []byte("message_delta")) {
+				return true
+			}
+			event := gjson.ParseBytes(data)
+			stopReason := event.Get("delta.stop_reason")
+			if event.Get("type").String() != "message_delta" || stopReason.Type != gjson.String {
+				return true
+			}
+			fn(StopReason(stopReason.String()))
+			return false
+		}
+	})
+}
+
+// WithToolUseStartCallback returns a RequestOption which calls fn with the name
+// of each tool as soon as the content_block_start event of its tool_use block
+// is read from the connection. afterText reports whether any text was streamed
+// before it in the message, so that a UI can show a placeholder such as "Using
+// tools..." for turns which call tools without saying anything. Text blocks
+// which stay empty do not count as text.
+//
+// fn is called from the goroutine iterating the stream. Non-streaming requests
+// are unaffected.
+func WithToolUseStartCallback(fn func(toolName string, afterText bool)) option.RequestOption {
+	return withStreamScanner(func() func(data []byte) bool {
+		var afterText bool
+		return func(data []byte) bool {
+			event := gjson.ParseBytes(data)
+			switch event.Get("type").String() {
+			case "content_block_delta":
+				if event.Get("delta.type").String() == "text_delta" && event.Get("delta.text").String() != "" {
+					afterText = true
+				}
+			case "content_block_start":
+				if block := event.Get("content_block"); block.Get("type").String() == "tool_use" {
+					fn(block.Get("name").String(), afterText)
+				}
+			}
+			return true
+		}
+	})
+}
+
+// withStreamScanner returns a RequestOption which passes the data of each event
+// of a streamed response to a scanner as the event is read from the
+// connection, until the scanner returns false. newScanner is called for each
+// response.
+func withStreamScanner(newScanner func() func(data []byte) bool) option.RequestOption {
+	return option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		res, err := next(req)
+		if err != nil || res.Body == nil || !strings.HasPrefix(res.Header.Get("content-type"), "text/event-stream") {
+			return res, err
+		}
+		res.Body = &scanningBody{rc: res.Body, scan: newScanner()}
+		return res, nil
+	})
+}
+
+// scanningBody scans the lines of an event stream as they are read, passing
+// the data lines to scan.
+type scanningBody struct {
+	rc   io.ReadCloser
+	scan func(data []byte) bool
+	line []byte
+	done bool
+}
+
+func (b *scanningBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	for chunk := p[:n]; !b.done && len(chunk) > 0; {
+		i := bytes.IndexByte(chunk, '\n')
+		if i < 0 {
+			b.line = append(b.line, chunk...)
+			break
+		}
+		b.line = append(b.line, chunk[:i]...)
+		chunk = chunk[i+1:]
+		if data, ok := bytes.CutPrefix(bytes.TrimRight(b.line, "\r"), []byte("data:")); ok {
+			b.done = !b.scan(data)
+		}
+		b.line = b.line[:0]
+	}
+	return n, err
+}
+
+func (b *scanningBody) Close() error {
+	return b.rc.Close()
+}
+
+// streamCloser stops the goroutine of a stream helper which sends on a channel,
+// closing done and the stream the goroutine reads from.
+type streamCloser struct {
+	done  chan struct{}
+	close func() error
+}
+
+func newStreamCloser(stream interface{ Close() error }) streamCloser {
+	done := make(chan struct{})
+	return streamCloser{done: done, close: sync.OnceValue(func() error {
+		close(done)
+		return stream.Close()
+	})}
+}
+
+func (c streamCloser) Close() error { return c.close() }
+
+// ToolUseStream consumes a message stream and yields each tool_use block as
+// soon as it is complete, so that tools can be executed while the rest of the
+// message is still streaming. See [NewToolUseStream].
+type ToolUseStream struct {
+	toolUses chan ToolUseBlock
+	message  Message
+	err      error
+	closer   streamCloser
+}
+
+// NewToolUseStream starts consuming stream in the background, accumulating the
+// full message. Each tool_use block is sent on [ToolUseStream.ToolUses] when its
+// content_block_stop event arrives, with its input fully assembled, in the
+// order the blocks appear in the message.
+//
+// The channel is closed once the stream ends. Only then are
+// [ToolUseStream.Message] and [ToolUseStream.Err] valid. The stream is not read
+// further until each tool use has been received, so the channel must be drained
+// or [ToolUseStream.Close] called, and the stream must not be iterated
+// elsewhere.
+//
+//	toolUses := anthropic.NewToolUseStream(client.Messages.NewStreaming(ctx, params))
+//	for toolUse := range toolUses.ToolUses() {
+//		go execute(toolUse)
+//	}
+//	if err := toolUses.Err(); err != nil { ... }
+//	message := toolUses.Message()
+func NewToolUseStream(stream *ssestream.Stream[MessageStreamEventUnion]) *ToolUseStream {
+	s := &ToolUseStream{toolUses: make(chan ToolUseBlock), closer: newStreamCloser(stream)}
+	go func() {
+		defer close(s.toolUses)
+		for stream.Next() {
+			event := stream.Current()
+			if s.err = s.message.Accumulate(event); s.err != nil {
+				return
+			}
+			if _, ok := event.AsAny().(ContentBlockStopEvent); !ok {
+				continue
+			}
+			if block := s.message.Content[len(s.message.Content)-1]; block.Type == "tool_use" && !sendOrDone(s.toolUses, block.AsToolUse(), s.closer.done) {
+				return
+			}
+		}
+		s.err = stream.Err()
+	}()
+	return s
+}
+
+// ToolUses returns the channel of completed tool_use blocks.
+func (s *ToolUseStream) ToolUses() <-chan ToolUseBlock { return s.toolUses }
+
+// Close stops consuming the stream and closes it, for callers which stop
+// reading the tool uses before the channel is closed.
+func (s *ToolUseStream) Close() error { return s.closer.Close() }
+
+// Message returns the accumulated message, once the tool uses channel is closed.
+func (s *ToolUseStream) Message() Message { return s.message }
+
+// Err returns the error which ended the stream, if any, once the tool uses
+// channel is closed.
+func (s *ToolUseStream) Err() error { return s.err }
+
+// BetaToolUseStream is like [ToolUseStream], for streams from the beta API.
+type BetaToolUseStream struct {
+	toolUses chan BetaToolUseBlock
+	message  BetaMessage
+	err      error
+	closer   streamCloser
+}
+
+// NewBetaToolUseStream starts consuming stream in the background. See
+// [NewToolUseStream] for the ordering and completion semantics.
+func NewBetaToolUseStream(stream *ssestream.Stream[BetaRawMessageStreamEventUnion]) *BetaToolUseStream {
+	s := &BetaToolUseStream{toolUses: make(chan BetaToolUseBlock), closer: newStreamCloser(stream)}
+	go func() {
+		defer close(s.toolUses)
+		for stream.Next() {
+			event := stream.Current()
+			if s.err = s.message.Accumulate(event); s.err != nil {
+				return
+			}
+			if _, ok := event.AsAny().(BetaRawContentBlockStopEvent); !ok {
+				continue
+			}
+			if block := s.message.Content[len(s.message.Content)-1]; block.Type == "tool_use" && !sendOrDone(s.toolUses, block.AsToolUse(), s.closer.done) {
+				return
+			}
+		}
+		s.err = stream.Err()
+	}()
+	return s
+}
+
+// ToolUses returns the channel of completed tool_use blocks.
+func (s *BetaToolUseStream) ToolUses() <-chan BetaToolUseBlock { return s.toolUses }
+
+// Close stops consuming the stream and closes it. See [ToolUseStream.Close].
+func (s *BetaToolUseStream) Close() error { return s.closer.Close() }
+
+// Message returns the accumulated message, once the tool uses channel is closed.
+func (s *BetaToolUseStream) Message() BetaMessage { return s.message }
+
+// Err returns the error which ended the stream, if any, once the tool uses
+// channel is closed.
+func (s *BetaToolUseStream) Err() error { return s.err }
+
+// toolInputEvent holds the fields of a stream event used by
+// [StreamToolInputInto].
+type toolInputEvent struct {
+	eventType, blockType, name, deltaType, partialJSON string
+}
+
+func streamToolInputEvent[E StreamEvent](event E) toolInputEvent {
+	switch event := any(event).(type) {
+	case MessageStreamEventUnion:
+		return toolInputEvent{event.Type, event.ContentBlock.Type, event.ContentBlock.Name, event.Delta.Type, event.Delta.PartialJSON}
+	case BetaRawMessageStreamEventUnion:
+		return toolInputEvent{event.Type, event.ContentBlock.Type, event.ContentBlock.Name, event.Delta.Type, event.Delta.PartialJSON}
+	}
+	return toolInputEvent{}
+}
+
+// StreamToolInputInto consumes the stream and, while the input of a tool_use
+// block for the tool named toolName streams in, calls onUpdate after every
+// delta with the input received so far decoded into a new T. The partial JSON
+// is completed on a best-effort basis: strings cut short are included, while
+// object keys without a value and numbers which may be incomplete are left
+// out until more arrives. Fields not received yet hold their zero value.
+//
+// Once a block completes, onUpdate is called one last time with the full
+// input. If the message holds several tool uses of that tool, each starts
+// again from a zero T. StreamToolInputInto returns when the stream ends, with
+// the stream's error, or the error decoding the complete input of a block.
+//
+//	err := anthropic.StreamToolInputInto(stream, "create_recipe", func(r Recipe) {
+//		render(r)
+//	})
+func StreamToolInputInto[T any, E StreamEvent](stream *ssestream.Stream[E], toolName string, onUpdate func(T)) error {
+	var input []byte
+	var inBlock bool
+	for stream.Next() {
+		event := streamToolInputEvent(stream.Current())
+		switch {
+		case event.eventType == "content_block_start":
+			inBlock = event.blockType == "tool_use" && event.name == toolName
+			input = input[:0]
+		case !inBlock:
+		case event.eventType == "content_block_delta" && event.deltaType == "input_json_delta":
+			input = append(input, event.partialJSON...)
+			var v T
+			if json.Unmarshal(completePartialJSON(input), &v) == nil {
+				onUpdate(v)
+			}
+		case event.eventType == "content_block_stop":
+			inBlock = false
+			if len(bytes.TrimSpace(input)) == 0 {
+				input = append(input[:0], "{}"...)
+			}
+			var v T
+			if err := json.Unmarshal(input, &v); err != nil {
+				return fmt.Errorf("decoding input of %s: %w", toolName, err)
+			}
+			onUpdate(v)
+		}
+	}
+	return stream.Err()
+}
+
+// StreamCitation is a citation yielded by [CitationStream].
+type StreamCitation struct {
+	// BlockIndex is the index in the message of the text block the citation
+	// belongs to.
+	BlockIndex int64
+	// Citation holds the cited text, the index of the cited document and the
+	// location cited within it, such as a character or page range.
+	Citation TextCitationUnion
+}
+
+// CitationStream consumes a message stream and yields each citation as soon as
+// its citations_delta event arrives, so that a UI can highlight sources while
+// the response is still streaming. See [NewCitationStream].
+type CitationStream struct {
+	citations chan StreamCitation
+	message   Message
+	err       error
+	closer    streamCloser
+}
+
+// NewCitationStream starts consuming stream in the background, accumulating the
+// full message. Each citation is sent on [CitationStream.Citations] along with
+// the index of the text block it belongs to, in the order the citations
+// arrive.
+//
+// The channel is closed once the stream ends. Only then are
+// [CitationStream.Message] and [CitationStream.Err] valid. The stream is not
+// read further until each citation has been received, so the channel must be
+// drained or [CitationStream.Close] called, and the stream must not be iterated
+// elsewhere.
+//
+//	citations := anthropic.NewCitationStream(client.Messages.NewStreaming(ctx, params))
+//	for c := range citations.Citations() {
+//		view.Highlight(c.BlockIndex, c.Citation.DocumentIndex, c.Citation.CitedText)
+//	}
+//	if err := citations.Err(); err != nil { ... }
+func NewCitationStream(stream *ssestream.Stream[MessageStreamEventUnion]) *CitationStream {
+	s := &CitationStream{citations: make(chan StreamCitation), closer: newStreamCloser(stream)}
+	go func() {
+		defer close(s.citations)
+		for stream.Next() {
+			event := stream.Current()
+			if s.err = s.message.Accumulate(event); s.err != nil {
+				return
+			}
+			delta, ok := event.AsAny().(ContentBlockDeltaEvent)
+			if !ok || delta.Delta.Type != "citations_delta" {
+				continue
+			}
+			citation := StreamCitation{BlockIndex: delta.Index}
+			if s.err = citation.Citation.UnmarshalJSON([]byte(delta.Delta.Citation.RawJSON())); s.err != nil {
+				return
+			}
+			if !sendOrDone(s.citations, citation, s.closer.done) {
+				return
+			}
+		}
+		s.err = stream.Err()
+	}()
+	return s
+}
+
+// Citations returns the channel of citations.
+func (s *CitationStream) Citations() <-chan StreamCitation { return s.citations }
+
+// Close stops consuming the stream and closes it, for callers which stop
+// reading the citations before the channel is closed.
+func (s *CitationStream) Close() error { return s.closer.Close() }
+
+// Message returns the accumulated message, once the citations channel is
+// closed.
+func (s *CitationStream) Message() Message { return s.message }
+
+// Err returns the error which ended the stream, if any, once the citations
+// channel is closed.
+func (s *CitationStream) Err() error { return s.err }
+
+// BetaStreamCitation is like [StreamCitation], for streams from the beta API.
+type BetaStreamCitation struct {
+	BlockIndex int64
+	Citation   BetaTextCitationUnion
+}
+
+// BetaCitationStream is like [CitationStream], for streams from the beta API.
+type BetaCitationStream struct {
+	citations chan BetaStreamCitation
+	message   BetaMessage
+	err       error
+	closer    streamCloser
+}
+
+// NewBetaCitationStream starts consuming stream in the background. See
+// [NewCitationStream] for the ordering and completion semantics.
+func NewBetaCitationStream(stream *ssestream.Stream[BetaRawMessageStreamEventUnion]) *BetaCitationStream {
+	s := &BetaCitationStream{citations: make(chan BetaStreamCitation), closer: newStreamCloser(stream)}
+	go func() {
+		defer close(s.citations)
+		for stream.Next() {
+			event := stream.Current()
+			if s.err = s.message.Accumulate(event); s.err != nil {
+				return
+			}
+			delta, ok := event.AsAny().(BetaRawContentBlockDeltaEvent)
+			if !ok || delta.Delta.Type != "citations_delta" {
+				continue
+			}
+			citation := BetaStreamCitation{BlockIndex: delta.Index}
+			if s.err = citation.Citation.UnmarshalJSON([]byte(delta.Delta.Citation.RawJSON())); s.err != nil {
+				return
+			}
+			if !sendOrDone(s.citations, citation, s.closer.done) {
+				return
+			}
+		}
+		s.err = stream.Err()
+	}()
+	return s
+}
+
+// Citations returns the channel of citations.
+func (s *BetaCitationStream) Citations() <-chan BetaStreamCitation { return s.citations }
+
+// Close stops consuming the stream and closes it. See [CitationStream.Close].
+func (s *BetaCitationStream) Close() error { return s.closer.Close() }
+
+// Message returns the accumulated message, once the citations channel is
+// closed.
+func (s *BetaCitationStream) Message() BetaMessage { return s.message }
+
+// Err returns the error which ended the stream, if any, once the citations
+// channel is closed.
+func (s *BetaCitationStream) Err() error { return s.err }
+
+// TextStream reads a message stream as the text it generates, hiding the
+// event types. See [NewTextStream].
+type TextStream struct {
+	stream  *ssestream.Stream[MessageStreamEventUnion]
+	message Message
+	err     error
+}
+
+// NewTextStream wraps stream so that its text can be ranged over with
+// [TextStream.TextDeltas], while the full message is accumulated. The stream
+// must not be iterated elsewhere.
+//
+//	text := anthropic.NewTextStream(client.Messages.NewStreaming(ctx, params))
+//	for delta := range text.TextDeltas() {
+//		fmt.Print(delta)
+//	}
+//	if err := text.Err(); err != nil { ... }
+//	message := text.Accumulated()
+func NewTextStream(stream *ssestream.Stream[MessageStreamEventUnion]) *TextStream {
+	return &TextStream{stream: stream}
+}
+
+// TextDeltas yields the text of each text delta event of the stream, in order.
+// Other events are only accumulated. Breaking out of the loop stops reading the
+// stream, which can be resumed by ranging over TextDeltas again.
+func (s *TextStream) TextDeltas() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for s.err == nil && s.stream.Next() {
+			event := s.stream.Current()
+			if s.err = s.message.Accumulate(event); s.err != nil {
+				return
+			}
+			if text, ok := streamTextDelta(event); ok && !yield(text) {
+				return
+			}
+		}
+		if s.err == nil {
+			s.err = s.stream.Err()
+		}
+	}
+}
+
+// WriteTextTo drains the stream, writing the text of each text delta to w as it
+// arrives, and returns the number of bytes written. If w has a Flush method, as
+// [bufio.Writer] and [http.ResponseWriter] do, it is flushed after each delta.
+// Writing stops at the first error writing to w, which is returned; otherwise
+// the error which ended the stream is returned, as by [TextStream.Err].
+//
+//	text := anthropic.NewTextStream(client.Messages.NewStreaming(ctx, params))
+//	if _, err := text.WriteTextTo(os.Stdout); err != nil { ... }
+func (s *TextStream) WriteTextTo(w io.Writer) (int64, error) {
+	return writeTextDeltas(w, s.TextDeltas(), s.Err)
+}
+
+// Accumulated returns the message accumulated from the events read so far,
+// which is the full message once [TextStream.TextDeltas] has been ranged over
+// to the end.
+func (s *TextStream) Accumulated() Message { return s.message }
+
+// Err returns the error which ended the stream, if any.
+func (s *TextStream) Err() error { return s.err }
+
+// Close closes the underlying stream.
+func (s *TextStream) Close() error { return s.stream.Close() }
+
+// BetaTextStream is like [TextStream], for streams from the beta API.
+type BetaTextStream struct {
+	stream  *ssestream.Stream[BetaRawMessageStreamEventUnion]
+	message BetaMessage
+	err     error
+}
+
+// NewBetaTextStream wraps stream. See [NewTextStream].
+func NewBetaTextStream(stream *ssestream.Stream[BetaRawMessageStreamEventUnion]) *BetaTextStream {
+	return &BetaTextStream{stream: stream}
+}
+
+// TextDeltas yields the text of each text delta event of the stream. See
+// [TextStream.TextDeltas].
+func (s *BetaTextStream) TextDeltas() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for s.err == nil && s.stream.Next() {
+			event := s.stream.Current()
+			if s.err = s.message.Accumulate(event); s.err != nil {
+				return
+			}
+			if text, ok := streamTextDelta(event); ok && !yield(text) {
+				return
+			}
+		}
+		if s.err == nil {
+			s.err = s.stream.Err()
+		}
+	}
+}
+
+// WriteTextTo drains the stream, writing the text of each text delta to w. See
+// [TextStream.WriteTextTo].
+func (s *BetaTextStream) WriteTextTo(w io.Writer) (int64, error) {
+	return writeTextDeltas(w, s.TextDeltas(), s.Err)
+}
+
+// Accumulated returns the message accumulated from the events read so far.
+func (s *BetaTextStream) Accumulated() BetaMessage { return s.message }
+
+// Err returns the error which ended the stream, if any.
+func (s *BetaTextStream) Err() error { return s.err }
+
+// Close closes the underlying stream.
+func (s *BetaTextStream) Close() error { return s.stream.Close() }
+
+func writeTextDeltas(w io.Writer, deltas iter.Seq[string], streamErr func() error) (int64, error) {
+	var n int64
+	for delta := range deltas {
+		written, err := io.WriteString(w, delta)
+		n += int64(written)
+		if err == nil {
+			err = flushWriter(w)
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, streamErr()
+}
+
+// flushWriter flushes w if it buffers its output.
+func flushWriter(w io.Writer) error {
+	switch w := w.(type) {
+	case interface{ Flush() error }:
+		return w.Flush()
+	case http.Flusher:
+		w.Flush()
+	}
+	return nil
+}
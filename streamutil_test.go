@@ -0,0 +1,650 @@
+package anthropic_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/sofianhadi1983/anthropic-sdk-go/packages/ssestream"
+)
+
+// sseBody renders events, given as alternating event types and JSON payloads,
+// as a text/event-stream body.
+func sseBody(events ...string) string {
+	var sb strings.Builder
+	for i := 0; i+1 < len(events); i += 2 {
+		fmt.Fprintf(&sb, "event: %s\ndata: %s\n\n", events[i], events[i+1])
+	}
+	return sb.String()
+}
+
+func newTestStream[T anthropic.StreamEvent](body string) *ssestream.Stream[T] {
+	res := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+	return ssestream.NewStream[T](ssestream.NewDecoder(res), nil)
+}
+
+// textStreamEvents returns the events of a streamed message whose single text
+// block is made up of the given deltas.
+func textStreamEvents(deltas ...string) []string {
+	events := []string{
+		"message_start", `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	}
+	for _, d := range deltas {
+		events = append(events, "content_block_delta", fmt.Sprintf(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":%q}}`, d))
+	}
+	return append(events,
+		"content_block_stop", `{"type":"content_block_stop","index":0}`,
+		"message_delta", `{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":12}}`,
+		"message_stop", `{"type":"message_stop"}`,
+	)
+}
+
+func TestStreamPlainText(t *testing.T) {
+	body := sseBody(textStreamEvents(
+		"# Tit", "le\n\nSome **bo", "ld** and _it_ text with a [li", "nk](https://example.com).\n",
+		"- item `code`\n", "```go\nx := *p\n```\n", "> quoted",
+	)...)
+	stream := newTestStream[anthropic.MessageStreamEventUnion](body)
+
+	var sb strings.Builder
+	for text := range anthropic.StreamPlainText(context.Background(), stream) {
+		sb.WriteString(text)
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+
+	expected := "Title\n\nSome bold and it text with a link.\nitem code\nx := *p\nquoted"
+	if sb.String() != expected {
+		t.Errorf("expected %q, got %q", expected, sb.String())
+	}
+}
+
+func TestStreamPlainTextBeta(t *testing.T) {
+	stream := newTestStream[anthropic.BetaRawMessageStreamEventUnion](sseBody(textStreamEvents("**Hello**", " world")...))
+
+	var sb strings.Builder
+	for text := range anthropic.StreamPlainText(context.Background(), stream) {
+		sb.WriteString(text)
+	}
+	if sb.String() != "Hello world" {
+		t.Errorf("expected %q, got %q", "Hello world", sb.String())
+	}
+}
+
+func TestWithStopReasonCallback(t *testing.T) {
+	var stopReasons []anthropic.StopReason
+	var eventsBeforeCallback int
+	events := 0
+
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"text/event-stream"}},
+						Body:       io.NopCloser(iotest.OneByteReader(strings.NewReader(sseBody(textStreamEvents("Hi")...)))),
+					}, nil
+				},
+			},
+		}),
+	)
+	stream := client.Messages.NewStreaming(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}, anthropic.WithStopReasonCallback(func(reason anthropic.StopReason) {
+		stopReasons = append(stopReasons, reason)
+		eventsBeforeCallback = events
+	}))
+	for stream.Next() {
+		events++
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+
+	if len(stopReasons) != 1 || stopReasons[0] != anthropic.StopReasonEndTurn {
+		t.Fatalf("expected a single end_turn callback, got %v", stopReasons)
+	}
+	// message_start, content_block_start, content_block_delta and
+	// content_block_stop precede message_delta.
+	if eventsBeforeCallback != 4 {
+		t.Errorf("expected the callback to fire before message_delta was yielded, got %d events first", eventsBeforeCallback)
+	}
+}
+
+func TestWithToolUseStartCallback(t *testing.T) {
+	body := sseBody(
+		"message_start", `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":0}`,
+		"content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{}"}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":1}`,
+		"message_delta", `{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":12}}`,
+		"message_stop", `{"type":"message_stop"}`,
+	)
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"text/event-stream"}},
+						Body:       io.NopCloser(strings.NewReader(body)),
+					}, nil
+				},
+			},
+		}),
+	)
+
+	var calls []string
+	stream := client.Messages.NewStreaming(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}, anthropic.WithToolUseStartCallback(func(toolName string, afterText bool) {
+		calls = append(calls, fmt.Sprintf("%s %v", toolName, afterText))
+	}))
+	message := anthropic.Message{}
+	for stream.Next() {
+		if err := message.Accumulate(stream.Current()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+
+	if len(calls) != 1 || calls[0] != "get_weather false" {
+		t.Errorf("expected a tool-only callback for get_weather, got %v", calls)
+	}
+	// The empty text block is kept, but does not count as text.
+	if len(message.Content) != 2 || message.Content[0].Type != "text" || message.HasText() {
+		t.Errorf("expected an empty text block and no text, got %s", message.RawJSON())
+	}
+	message.Content[0].Text = "Let me check."
+	if !message.HasText() {
+		t.Errorf("expected the message to have text")
+	}
+}
+
+func TestBetaToolUseStream(t *testing.T) {
+	pr, pw := io.Pipe()
+	res := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       pr,
+	}
+	stream := ssestream.NewStream[anthropic.BetaRawMessageStreamEventUnion](ssestream.NewDecoder(res), nil)
+	toolUses := anthropic.NewBetaToolUseStream(stream)
+
+	io.WriteString(pw, sseBody(
+		"message_start", `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":0}`,
+	))
+
+	// The tool use must be delivered before the rest of the message is sent.
+	toolUse := <-toolUses.ToolUses()
+	if input, _ := json.Marshal(toolUse.Input); toolUse.ID != "toolu_01" || string(input) != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool use: %s %s", toolUse.ID, input)
+	}
+
+	go func() {
+		io.WriteString(pw, sseBody(
+			"content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+			"content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Checking."}}`,
+			"content_block_stop", `{"type":"content_block_stop","index":1}`,
+			"message_delta", `{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":12}}`,
+			"message_stop", `{"type":"message_stop"}`,
+		))
+		pw.Close()
+	}()
+
+	for toolUse := range toolUses.ToolUses() {
+		t.Errorf("unexpected tool use: %s", toolUse.ID)
+	}
+	if err := toolUses.Err(); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	message := toolUses.Message()
+	if len(message.Content) != 2 || message.StopReason != anthropic.BetaStopReasonToolUse {
+		t.Errorf("expected the full message to be accumulated, got %s", message.RawJSON())
+	}
+}
+
+func TestStreamAuditReplay(t *testing.T) {
+	body := sseBody(append([]string{"ping", `{"type":"ping"}`}, textStreamEvents("Hello", " world")...)...)
+
+	var log bytes.Buffer
+	stream := newTestStream[anthropic.MessageStreamEventUnion](body).Audit(&log)
+	message := anthropic.Message{}
+	for stream.Next() {
+		if err := message.Accumulate(stream.Current()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("expected 8 audit records, got %d:\n%s", len(lines), log.String())
+	}
+	var record ssestream.AuditRecord
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil || record.Event != "ping" || record.Time.IsZero() {
+		t.Errorf("unexpected first record %s: %v", lines[0], err)
+	}
+
+	replay := ssestream.NewStream[anthropic.MessageStreamEventUnion](ssestream.NewAuditReplayDecoder(&log), nil)
+	replayed := anthropic.Message{}
+	for replay.Next() {
+		if err := replayed.Accumulate(replay.Current()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := replay.Err(); err != nil {
+		t.Fatalf("unexpected replay error: %v", err)
+	}
+	if replayed.Content[0].Text != "Hello world" || replayed.Content[0].Text != message.Content[0].Text {
+		t.Errorf("expected the replayed message to match, got %q", replayed.Content[0].Text)
+	}
+}
+
+func TestWithHardOutputCap(t *testing.T) {
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					deltas := make([]string, 100)
+					for i := range deltas {
+						deltas[i] = "word word "
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"text/event-stream"}},
+						Body:       io.NopCloser(strings.NewReader(sseBody(textStreamEvents(deltas...)...))),
+					}, nil
+				},
+			},
+		}),
+	)
+	stream := client.Messages.NewStreaming(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+	}, option.WithHardOutputCap(20))
+
+	message := anthropic.Message{}
+	for stream.Next() {
+		if err := message.Accumulate(stream.Current()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+
+	if message.StopReason != anthropic.StopReasonClientOutputCap {
+		t.Errorf("expected the client cap stop reason, got %q", message.StopReason)
+	}
+	// 20 tokens are roughly 80 characters, or 9 deltas of 10 characters.
+	if n := len(message.Content[0].Text); n != 90 {
+		t.Errorf("expected the stream to end after 90 characters, got %d", n)
+	}
+}
+
+func TestStreamModel(t *testing.T) {
+	stream := newTestStream[anthropic.MessageStreamEventUnion](sseBody(textStreamEvents("Hi")...))
+	if model := stream.Model(); model != "" {
+		t.Fatalf("expected no model before message_start, got %q", model)
+	}
+
+	message := anthropic.Message{}
+	for stream.Next() {
+		if stream.Model() != "claude-sonnet-4-5-20250929" {
+			t.Fatalf("expected model after message_start, got %q", stream.Model())
+		}
+		message.Accumulate(stream.Current())
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	if string(message.Model) != stream.Model() {
+		t.Errorf("expected message model %q to match stream model %q", message.Model, stream.Model())
+	}
+}
+
+func TestStreamToolInputInto(t *testing.T) {
+	type recipe struct {
+		Title       string   `json:"title"`
+		Servings    int      `json:"servings"`
+		Ingredients []string `json:"ingredients"`
+	}
+	deltas := []string{`{"ti`, `tle": "Pan\u00e`, `9 caké", "serv`, `ings": 1`, `2, "ingredients": ["eg`, `gs", "fl`, `our"]}`}
+
+	events := []string{
+		"message_start", `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_0","name":"other_tool","input":{}}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"title\":\"ignored\"}"}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":0}`,
+		"content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"create_recipe","input":{}}}`,
+	}
+	for _, d := range deltas {
+		partial, _ := json.Marshal(d)
+		events = append(events, "content_block_delta", fmt.Sprintf(`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":%s}}`, partial))
+	}
+	events = append(events,
+		"content_block_stop", `{"type":"content_block_stop","index":1}`,
+		"message_delta", `{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":12}}`,
+		"message_stop", `{"type":"message_stop"}`,
+	)
+
+	var updates []recipe
+	err := anthropic.StreamToolInputInto(newTestStream[anthropic.BetaRawMessageStreamEventUnion](sseBody(events...)), "create_recipe", func(r recipe) {
+		updates = append(updates, r)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []recipe{
+		{},
+		{Title: "Pan"},
+		{Title: "Pané caké"},
+		{Title: "Pané caké"},
+		{Title: "Pané caké", Servings: 12, Ingredients: []string{"eg"}},
+		{Title: "Pané caké", Servings: 12, Ingredients: []string{"eggs", "fl"}},
+		{Title: "Pané caké", Servings: 12, Ingredients: []string{"eggs", "flour"}},
+		{Title: "Pané caké", Servings: 12, Ingredients: []string{"eggs", "flour"}},
+	}
+	if len(updates) != len(expected) {
+		t.Fatalf("expected %d updates, got %d: %+v", len(expected), len(updates), updates)
+	}
+	for i := range expected {
+		if fmt.Sprint(updates[i]) != fmt.Sprint(expected[i]) {
+			t.Errorf("update %d: expected %+v, got %+v", i, expected[i], updates[i])
+		}
+	}
+}
+
+func TestStreamThrottled(t *testing.T) {
+	body := sseBody(textStreamEvents("Hel", "lo", " wor", "ld")...)
+
+	var chunks []string
+	for text := range anthropic.StreamThrottled(context.Background(), newTestStream[anthropic.MessageStreamEventUnion](body), time.Hour) {
+		chunks = append(chunks, text)
+	}
+	if len(chunks) != 2 || chunks[0] != "Hel" || chunks[1] != "lo world" {
+		t.Errorf("expected the first delta then the rest at the end, got %q", chunks)
+	}
+
+	chunks = nil
+	for text := range anthropic.StreamThrottled(context.Background(), newTestStream[anthropic.BetaRawMessageStreamEventUnion](body), 0) {
+		chunks = append(chunks, text)
+	}
+	if strings.Join(chunks, "|") != "Hel|lo| wor|ld" {
+		t.Errorf("expected every delta without throttling, got %q", chunks)
+	}
+}
+
+func TestCitationStream(t *testing.T) {
+	stream := newTestStream[anthropic.MessageStreamEventUnion](sseBody(
+		"message_start", `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":"","citations":[]}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"citations_delta","citation":{"type":"char_location","cited_text":"The sky is blue.","document_index":0,"document_title":"Sky","start_char_index":0,"end_char_index":16}}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"The sky is blue."}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":0}`,
+		"content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"text","text":"","citations":[]}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"citations_delta","citation":{"type":"page_location","cited_text":"Grass is green.","document_index":1,"document_title":"Grass","start_page_number":2,"end_page_number":3}}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":" Grass is green."}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":1}`,
+		"message_delta", `{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":12}}`,
+		"message_stop", `{"type":"message_stop"}`,
+	))
+	citations := anthropic.NewCitationStream(stream)
+
+	var got []anthropic.StreamCitation
+	for c := range citations.Citations() {
+		got = append(got, c)
+	}
+	if err := citations.Err(); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 citations, got %d", len(got))
+	}
+	if c := got[0]; c.BlockIndex != 0 || c.Citation.DocumentIndex != 0 || c.Citation.AsCharLocation().EndCharIndex != 16 {
+		t.Errorf("unexpected first citation: %+v", c)
+	}
+	if c := got[1]; c.BlockIndex != 1 || c.Citation.DocumentIndex != 1 || c.Citation.AsPageLocation().StartPageNumber != 2 {
+		t.Errorf("unexpected second citation: %+v", c)
+	}
+	if message := citations.Message(); len(message.Content) != 2 || len(message.Content[1].Citations) != 1 {
+		t.Errorf("expected the full message to be accumulated, got %s", message.RawJSON())
+	}
+}
+
+func TestStreamHelpersAbandoned(t *testing.T) {
+	// openStream streams body over a connection which stays open, so that the
+	// helpers only stop when they are told to.
+	openStream := func(body string) *ssestream.Stream[anthropic.MessageStreamEventUnion] {
+		pr, pw := io.Pipe()
+		go io.WriteString(pw, body)
+		res := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       pr,
+		}
+		return ssestream.NewStream[anthropic.MessageStreamEventUnion](ssestream.NewDecoder(res), nil)
+	}
+	text := sseBody(textStreamEvents("one\n", "two\n", "three\n")...)
+	toolUses := sseBody(
+		"message_start", `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":10,"output_tokens":1}}}`,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":0}`,
+		"content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_02","name":"get_weather","input":{}}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":1}`,
+	)
+	citation := `{"type":"content_block_delta","index":0,"delta":{"type":"citations_delta","citation":{"type":"char_location","cited_text":"x","document_index":0,"document_title":"Doc","start_char_index":0,"end_char_index":1}}}`
+	citations := sseBody(
+		"message_start", `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":10,"output_tokens":1}}}`,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":"","citations":[]}}`,
+		"content_block_delta", citation,
+		"content_block_delta", citation,
+	)
+
+	for name, abandon := range map[string]func() (stop func()){
+		"StreamPlainText": func() func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			<-anthropic.StreamPlainText(ctx, openStream(text))
+			return cancel
+		},
+		"StreamThrottled": func() func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			<-anthropic.StreamThrottled(ctx, openStream(text), 0)
+			return cancel
+		},
+		"ToolUseStream": func() func() {
+			s := anthropic.NewToolUseStream(openStream(toolUses))
+			<-s.ToolUses()
+			return func() { s.Close() }
+		},
+		"CitationStream": func() func() {
+			s := anthropic.NewCitationStream(openStream(citations))
+			<-s.Citations()
+			return func() { s.Close() }
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			stop := abandon()
+			stop()
+			for deadline := time.Now().Add(5 * time.Second); runtime.NumGoroutine() > before; {
+				if time.Now().After(deadline) {
+					t.Fatalf("expected the goroutines of the helper to exit, %d are left running", runtime.NumGoroutine()-before)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestStreamWithInterrupt(t *testing.T) {
+	events := textStreamEvents("Hello", " world")
+	pr, pw := io.Pipe()
+	go pw.Write([]byte(sseBody(events[:6]...)))
+
+	stop := make(chan struct{})
+	res := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       pr,
+	}
+	stream := ssestream.NewStream[anthropic.MessageStreamEventUnion](ssestream.NewDecoder(res), nil).WithInterrupt(stop)
+	message := anthropic.Message{}
+	for stream.Next() {
+		if err := message.Accumulate(stream.Current()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stream.Current().Type == "content_block_delta" {
+			close(stop)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	if !stream.Interrupted() || len(message.Content) != 1 || message.Content[0].Text != "Hello" {
+		t.Errorf("expected the partial message after the interruption, got %s", message.RawJSON())
+	}
+	if _, err := pw.Write([]byte(sseBody(events[6:]...))); err != io.ErrClosedPipe {
+		t.Errorf("expected the response body to be closed, got %v", err)
+	}
+}
+
+func TestStreamThroughput(t *testing.T) {
+	events := textStreamEvents(strings.Repeat("word ", 40), strings.Repeat("word ", 40))
+	stream := newTestStream[anthropic.MessageStreamEventUnion](sseBody(events...))
+	if stream.Throughput() != 0 {
+		t.Errorf("expected no throughput before the stream starts")
+	}
+	var estimated float64
+	for stream.Next() {
+		time.Sleep(10 * time.Millisecond)
+		if stream.Current().Type == "content_block_delta" {
+			estimated = stream.Throughput()
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	// 400 characters are estimated at 100 tokens, while the final usage
+	// reports 12 output tokens, over at least two sleeps.
+	if estimated <= 0 || estimated > 100/0.02 {
+		t.Errorf("unexpected estimated throughput %v", estimated)
+	}
+	if final := stream.Throughput(); final <= 0 || final >= estimated {
+		t.Errorf("expected the reported usage to replace the estimate, got %v after %v", final, estimated)
+	}
+}
+
+func TestTextStream(t *testing.T) {
+	text := anthropic.NewBetaTextStream(newTestStream[anthropic.BetaRawMessageStreamEventUnion](sseBody(textStreamEvents("Hello", ", ", "world")...)))
+	var deltas []string
+	for delta := range text.TextDeltas() {
+		deltas = append(deltas, delta)
+		if len(deltas) == 2 {
+			break
+		}
+	}
+	if got := text.Accumulated().Content[0].Text; got != "Hello, " {
+		t.Errorf("expected the text read so far, got %q", got)
+	}
+	for delta := range text.TextDeltas() {
+		deltas = append(deltas, delta)
+	}
+	if err := text.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(deltas, "|") != "Hello|, |world" {
+		t.Errorf("unexpected deltas %q", deltas)
+	}
+	if message := text.Accumulated(); message.Content[0].Text != "Hello, world" || message.StopReason != "end_turn" || message.Usage.OutputTokens != 12 {
+		t.Errorf("unexpected message %+v", message)
+	}
+
+	stable := anthropic.NewTextStream(newTestStream[anthropic.MessageStreamEventUnion](sseBody(textStreamEvents("Hi")...)))
+	for range stable.TextDeltas() {
+	}
+	if stable.Err() != nil || stable.Accumulated().Text() != "Hi" {
+		t.Errorf("unexpected stable stream result %q, %v", stable.Accumulated().Text(), stable.Err())
+	}
+}
+
+type flushRecorder struct {
+	out     strings.Builder
+	flushed []string
+	failAt  int
+}
+
+func (w *flushRecorder) Write(p []byte) (int, error) {
+	if w.failAt > 0 && len(w.flushed) == w.failAt {
+		return 0, errors.New("broken pipe")
+	}
+	return w.out.Write(p)
+}
+
+func (w *flushRecorder) Flush() error {
+	w.flushed = append(w.flushed, w.out.String())
+	return nil
+}
+
+func TestTextStreamWriteTextTo(t *testing.T) {
+	w := &flushRecorder{}
+	text := anthropic.NewTextStream(newTestStream[anthropic.MessageStreamEventUnion](sseBody(textStreamEvents("Hello", ", ", "world")...)))
+	n, err := text.WriteTextTo(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 12 || w.out.String() != "Hello, world" {
+		t.Errorf("unexpected output %q, %d bytes", w.out.String(), n)
+	}
+	if strings.Join(w.flushed, "|") != "Hello|Hello, |Hello, world" {
+		t.Errorf("expected a flush after each delta, got %q", w.flushed)
+	}
+	if text.Accumulated().Text() != "Hello, world" {
+		t.Errorf("unexpected message %q", text.Accumulated().Text())
+	}
+
+	w = &flushRecorder{failAt: 1}
+	beta := anthropic.NewBetaTextStream(newTestStream[anthropic.BetaRawMessageStreamEventUnion](sseBody(textStreamEvents("Hello", ", ", "world")...)))
+	n, err = beta.WriteTextTo(w)
+	if err == nil || err.Error() != "broken pipe" || n != 5 || w.out.String() != "Hello" {
+		t.Errorf("expected writing to stop at the error, got %q, %d bytes, %v", w.out.String(), n, err)
+	}
+}
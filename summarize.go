@@ -0,0 +1,74 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+)
+
+// summaryMaxTokens is the max_tokens of the request made by
+// [MessageService.SummarizeHistory].
+const summaryMaxTokens = 2048
+
+// summaryPrompt asks for the summary of the transcript which follows it.
+const summaryPrompt = "Summarize the conversation below so that it can be continued without it. " +
+	"Keep the facts, decisions, open questions, tool results and anything the user asked to remember. " +
+	"Reply with the summary only."
+
+// SummaryPrefix starts the note which replaces the older messages in the
+// history returned by [MessageService.SummarizeHistory].
+const SummaryPrefix = "Summary of the earlier conversation:\n\n"
+
+// SummarizeHistory compacts a long conversation to stay within the context
+// window: it asks model to summarize all but the keepRecent most recent
+// messages, and returns a history in which they are replaced by a note with the
+// summary, starting with [SummaryPrefix], followed by the recent messages
+// verbatim. The note is added to the first recent message if it is a user
+// message, or else sent as a user message of its own.
+//
+// More messages are kept than keepRecent when needed so that no tool result is
+// separated from its tool use. If there is nothing to summarize, a copy of
+// messages is returned without making a request. The input is not modified.
+//
+//	if count.InputTokens > budget {
+//		history, err = client.Messages.SummarizeHistory(ctx, history, 6, anthropic.ModelClaudeHaiku4_5)
+//	}
+func (r *MessageService) SummarizeHistory(ctx context.Context, messages []MessageParam, keepRecent int, model Model, opts ...option.RequestOption) ([]MessageParam, error) {
+	split := max(len(messages)-max(keepRecent, 0), 0)
+	for split > 0 && split < len(messages) && slices.ContainsFunc(messages[split].Content, func(block ContentBlockParamUnion) bool {
+		return block.OfToolResult != nil
+	}) {
+		split--
+	}
+	if split == 0 {
+		return slices.Clone(messages), nil
+	}
+
+	older, err := json.Marshal(messages[:split])
+	if err != nil {
+		return nil, fmt.Errorf("summarize history: %w", err)
+	}
+	var transcript strings.Builder
+	renderPromptMessages(&transcript, gjson.ParseBytes(older))
+	message, err := r.New(ctx, MessageNewParams{
+		Model:     model,
+		MaxTokens: summaryMaxTokens,
+		Messages:  []MessageParam{NewUserMessage(NewTextBlock(summaryPrompt + "\n" + transcript.String()))},
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("summarize history: %w", err)
+	}
+
+	note := NewTextBlock(SummaryPrefix + strings.TrimSpace(message.Text()))
+	recent := slices.Clone(messages[split:])
+	if len(recent) > 0 && recent[0].Role == MessageParamRoleUser {
+		recent[0].Content = append([]ContentBlockParamUnion{note}, recent[0].Content...)
+		return recent, nil
+	}
+	return append([]MessageParam{NewUserMessage(note)}, recent...), nil
+}
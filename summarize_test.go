@@ -0,0 +1,60 @@
+package anthropic_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/tidwall/gjson"
+)
+
+func TestSummarizeHistory(t *testing.T) {
+	history := []anthropic.MessageParam{
+		anthropic.NewUserMessage(anthropic.NewTextBlock("My name is Ada.")),
+		anthropic.NewAssistantMessage(anthropic.NewTextBlock("Nice to meet you, Ada.")),
+		anthropic.NewUserMessage(anthropic.NewTextBlock("What is the weather in Paris?")),
+		anthropic.NewAssistantMessage(anthropic.NewToolUseBlock("toolu_01", map[string]any{"city": "Paris"}, "get_weather")),
+		anthropic.NewUserMessage(anthropic.NewToolResultBlock("toolu_01", "Sunny", false)),
+		anthropic.NewAssistantMessage(anthropic.NewTextBlock("It is sunny in Paris.")),
+	}
+
+	var requests []string
+	client := scriptedClient([]string{
+		`[{"type":"text","text":"The user is Ada."}]`,
+		`[{"type":"text","text":"The user is Ada."}]`,
+	}, &requests)
+	compacted, err := client.Messages.SummarizeHistory(context.Background(), history, 2, anthropic.ModelClaudeHaiku4_5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The tool result is kept with its tool use, which is preceded by the
+	// summary as a user message of its own.
+	if len(compacted) != 4 || compacted[1].Content[0].OfToolUse == nil {
+		t.Fatalf("expected the summary followed by the tool use and what follows, got %d messages", len(compacted))
+	}
+	if note := compacted[0].Content; compacted[0].Role != anthropic.MessageParamRoleUser || len(note) != 1 ||
+		note[0].OfText.Text != anthropic.SummaryPrefix+"The user is Ada." {
+		t.Errorf("unexpected summary note %+v", note)
+	}
+	prompt := gjson.Get(requests[0], "messages.0.content.0.text").String()
+	if !strings.Contains(prompt, "My name is Ada.") || strings.Contains(prompt, "Sunny") {
+		t.Errorf("expected only the older messages to be summarized, got:\n%s", prompt)
+	}
+
+	compacted, err = client.Messages.SummarizeHistory(context.Background(), history, 4, anthropic.ModelClaudeHaiku4_5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if note := compacted[0].Content; len(compacted) != 4 || len(note) != 2 || note[1].OfText.Text != "What is the weather in Paris?" {
+		t.Errorf("expected the summary to be added to the first kept user message, got %+v", note)
+	}
+	if len(history[2].Content) != 1 {
+		t.Errorf("expected the input to be left unmodified")
+	}
+
+	unchanged, err := client.Messages.SummarizeHistory(context.Background(), history[:2], 2, anthropic.ModelClaudeHaiku4_5)
+	if err != nil || len(unchanged) != 2 || len(requests) != 2 {
+		t.Errorf("expected a short history to be returned without a request, got %d messages and %v", len(unchanged), err)
+	}
+}
@@ -0,0 +1,233 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// TextEditorCommand is the input of a text editor tool call, as issued by the
+// model for the text_editor_20250124, text_editor_20250429 and
+// text_editor_20250728 tools.
+type TextEditorCommand struct {
+	// Any of "view", "create", "str_replace", "insert", "undo_edit".
+	Command string `json:"command"`
+	Path    string `json:"path"`
+	// FileText is set for "create".
+	FileText string `json:"file_text,omitempty"`
+	// OldStr and NewStr are set for "str_replace". NewStr is also the text to
+	// insert for "insert".
+	OldStr string `json:"old_str,omitempty"`
+	NewStr string `json:"new_str,omitempty"`
+	// InsertLine is set for "insert". The text is inserted after this line, where
+	// 0 inserts at the beginning of the file.
+	InsertLine int64 `json:"insert_line,omitempty"`
+	// ViewRange optionally limits "view" to the inclusive range of 1-indexed
+	// lines [start, end]. An end of -1 reads to the end of the file.
+	ViewRange []int64 `json:"view_range,omitempty"`
+}
+
+// NewTextEditorViewResult returns the tool result for a "view" command, with each
+// line of content prefixed by its line number, starting at startLine.
+func NewTextEditorViewResult(toolUseID string, content string, startLine int) ContentBlockParamUnion {
+	return NewToolResultBlock(toolUseID, numberLines(content, startLine), false)
+}
+
+// NewTextEditorSuccessResult returns the tool result for a command that
+// modified a file, such as "create", "str_replace", "insert" or "undo_edit".
+func NewTextEditorSuccessResult(toolUseID string, message string) ContentBlockParamUnion {
+	return NewToolResultBlock(toolUseID, message, false)
+}
+
+// NewTextEditorErrorResult returns the tool result for a command that failed.
+// The error message is shown to the model so that it can correct the command.
+func NewTextEditorErrorResult(toolUseID string, err error) ContentBlockParamUnion {
+	return NewToolResultBlock(toolUseID, "Error: "+err.Error(), true)
+}
+
+func numberLines(content string, startLine int) string {
+	var b strings.Builder
+	for i, line := range strings.Split(content, "\n") {
+		fmt.Fprintf(&b, "%6d\t%s\n", startLine+i, line)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// TextEditorFiles implements the text editor tool against an in-memory set of
+// files, keeping their contents across turns. It is useful for hosting the
+// tool in tests or sandboxes, or as a staging area whose files are written to
+// disk once the conversation finishes.
+//
+//	files := anthropic.NewTextEditorFiles()
+//	for _, block := range message.Content {
+//		if block.Type == "tool_use" && block.Name == "str_replace_based_edit_tool" {
+//			results = append(results, files.HandleToolUse(block.AsToolUse()))
+//		}
+//	}
+//
+// It is safe for concurrent use.
+type TextEditorFiles struct {
+	mu      sync.Mutex
+	files   map[string]string
+	history map[string][]textEditorSnapshot
+}
+
+// textEditorSnapshot is the state of a file before an edit.
+type textEditorSnapshot struct {
+	content string
+	existed bool
+}
+
+// NewTextEditorFiles returns a TextEditorFiles with no files.
+func NewTextEditorFiles() *TextEditorFiles {
+	return &TextEditorFiles{files: map[string]string{}, history: map[string][]textEditorSnapshot{}}
+}
+
+// Set sets the contents of the file at path, without recording an edit.
+func (f *TextEditorFiles) Set(path string, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[path] = content
+}
+
+// Get returns the contents of the file at path.
+func (f *TextEditorFiles) Get(path string) (content string, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	content, ok = f.files[path]
+	return content, ok
+}
+
+// Paths returns the paths of all files, sorted.
+func (f *TextEditorFiles) Paths() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	paths := make([]string, 0, len(f.files))
+	for path := range f.files {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// HandleToolUse runs the text editor command in block and returns the tool
+// result to send back to the model. Invalid commands produce an error result.
+func (f *TextEditorFiles) HandleToolUse(block ToolUseBlock) ContentBlockParamUnion {
+	var cmd TextEditorCommand
+	if err := json.Unmarshal(block.Input, &cmd); err != nil {
+		return NewTextEditorErrorResult(block.ID, fmt.Errorf("invalid input: %w", err))
+	}
+	if cmd.Command == "view" {
+		content, start, err := f.View(cmd)
+		if err != nil {
+			return NewTextEditorErrorResult(block.ID, err)
+		}
+		return NewTextEditorViewResult(block.ID, content, start)
+	}
+	message, err := f.Run(cmd)
+	if err != nil {
+		return NewTextEditorErrorResult(block.ID, err)
+	}
+	return NewTextEditorSuccessResult(block.ID, message)
+}
+
+// View returns the contents of the file at cmd.Path, limited to cmd.ViewRange,
+// along with the line number of the first line returned. Viewing a path which
+// is not a file lists the files below it.
+func (f *TextEditorFiles) View(cmd TextEditorCommand) (content string, startLine int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, ok := f.files[cmd.Path]
+	if !ok {
+		prefix := strings.TrimSuffix(cmd.Path, "/") + "/"
+		var paths []string
+		for path := range f.files {
+			if strings.HasPrefix(path, prefix) {
+				paths = append(paths, path)
+			}
+		}
+		if len(paths) == 0 {
+			return "", 0, fmt.Errorf("the path %s does not exist", cmd.Path)
+		}
+		slices.Sort(paths)
+		return strings.Join(paths, "\n"), 1, nil
+	}
+
+	if len(cmd.ViewRange) == 0 {
+		return content, 1, nil
+	}
+	if len(cmd.ViewRange) != 2 {
+		return "", 0, fmt.Errorf("view_range must contain exactly two line numbers")
+	}
+	lines := strings.Split(content, "\n")
+	start, end := int(cmd.ViewRange[0]), int(cmd.ViewRange[1])
+	if end == -1 {
+		end = len(lines)
+	}
+	if start < 1 || start > len(lines) || end < start || end > len(lines) {
+		return "", 0, fmt.Errorf("invalid view_range %v for a file with %d lines", cmd.ViewRange, len(lines))
+	}
+	return strings.Join(lines[start-1:end], "\n"), start, nil
+}
+
+// Run applies a modifying command ("create", "str_replace", "insert" or
+// "undo_edit") and returns the message to report to the model.
+func (f *TextEditorFiles) Run(cmd TextEditorCommand) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, exists := f.files[cmd.Path]
+	switch cmd.Command {
+	case "create":
+		f.edit(cmd.Path, cmd.FileText)
+		return fmt.Sprintf("File created successfully at: %s", cmd.Path), nil
+	case "str_replace":
+		if !exists {
+			return "", fmt.Errorf("the file %s does not exist", cmd.Path)
+		}
+		switch n := strings.Count(content, cmd.OldStr); {
+		case cmd.OldStr == "" || n == 0:
+			return "", fmt.Errorf("no match found for replacement text in %s", cmd.Path)
+		case n > 1:
+			return "", fmt.Errorf("found %d matches for replacement text in %s, provide more context to make a unique match", n, cmd.Path)
+		}
+		f.edit(cmd.Path, strings.Replace(content, cmd.OldStr, cmd.NewStr, 1))
+		return "Successfully replaced text at exactly one location.", nil
+	case "insert":
+		if !exists {
+			return "", fmt.Errorf("the file %s does not exist", cmd.Path)
+		}
+		lines := strings.Split(content, "\n")
+		if cmd.InsertLine < 0 || int(cmd.InsertLine) > len(lines) {
+			return "", fmt.Errorf("insert_line %d is out of range for a file with %d lines", cmd.InsertLine, len(lines))
+		}
+		lines = slices.Insert(lines, int(cmd.InsertLine), strings.Split(cmd.NewStr, "\n")...)
+		f.edit(cmd.Path, strings.Join(lines, "\n"))
+		return fmt.Sprintf("Text inserted after line %d of %s.", cmd.InsertLine, cmd.Path), nil
+	case "undo_edit":
+		history := f.history[cmd.Path]
+		if len(history) == 0 {
+			return "", fmt.Errorf("no edits to undo for %s", cmd.Path)
+		}
+		previous := history[len(history)-1]
+		f.history[cmd.Path] = history[:len(history)-1]
+		if previous.existed {
+			f.files[cmd.Path] = previous.content
+		} else {
+			delete(f.files, cmd.Path)
+		}
+		return fmt.Sprintf("Last edit to %s undone successfully.", cmd.Path), nil
+	default:
+		return "", fmt.Errorf("unknown command %q", cmd.Command)
+	}
+}
+
+// edit records the current contents of path for undo and replaces them.
+func (f *TextEditorFiles) edit(path string, content string) {
+	previous, existed := f.files[path]
+	f.history[path] = append(f.history[path], textEditorSnapshot{content: previous, existed: existed})
+	f.files[path] = content
+}
@@ -0,0 +1,60 @@
+package anthropic_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func textEditorToolUse(t *testing.T, id string, input map[string]any) anthropic.ToolUseBlock {
+	t.Helper()
+	raw, err := json.Marshal(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return anthropic.ToolUseBlock{ID: id, Name: "str_replace_based_edit_tool", Input: raw}
+}
+
+func toolResultText(block anthropic.ContentBlockParamUnion) (string, bool) {
+	result := block.OfToolResult
+	return result.Content[0].OfText.Text, result.IsError.Value
+}
+
+func TestTextEditorFiles(t *testing.T) {
+	files := anthropic.NewTextEditorFiles()
+
+	steps := []struct {
+		input   map[string]any
+		isError bool
+		content string
+	}{
+		{map[string]any{"command": "create", "path": "/main.go", "file_text": "package main\n\nfunc main() {}"}, false, "package main\n\nfunc main() {}"},
+		{map[string]any{"command": "str_replace", "path": "/main.go", "old_str": "func main() {}", "new_str": "func main() {\n}"}, false, "package main\n\nfunc main() {\n}"},
+		{map[string]any{"command": "str_replace", "path": "/main.go", "old_str": "missing", "new_str": ""}, true, "package main\n\nfunc main() {\n}"},
+		{map[string]any{"command": "insert", "path": "/main.go", "insert_line": 1, "new_str": "// comment"}, false, "package main\n// comment\n\nfunc main() {\n}"},
+		{map[string]any{"command": "undo_edit", "path": "/main.go"}, false, "package main\n\nfunc main() {\n}"},
+	}
+	for i, step := range steps {
+		text, isError := toolResultText(files.HandleToolUse(textEditorToolUse(t, "toolu_01", step.input)))
+		if isError != step.isError {
+			t.Fatalf("step %d: expected isError=%v, got %v (%s)", i, step.isError, isError, text)
+		}
+		if content, _ := files.Get("/main.go"); content != step.content {
+			t.Fatalf("step %d: expected %q, got %q", i, step.content, content)
+		}
+	}
+
+	text, isError := toolResultText(files.HandleToolUse(textEditorToolUse(t, "toolu_02", map[string]any{
+		"command": "view", "path": "/main.go", "view_range": []int{3, -1},
+	})))
+	if expected := "     3\tfunc main() {\n     4\t}"; isError || text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+
+	files.HandleToolUse(textEditorToolUse(t, "toolu_03", map[string]any{"command": "undo_edit", "path": "/main.go"}))
+	files.HandleToolUse(textEditorToolUse(t, "toolu_04", map[string]any{"command": "undo_edit", "path": "/main.go"}))
+	if _, ok := files.Get("/main.go"); ok {
+		t.Error("expected undoing the create to remove the file")
+	}
+}
@@ -0,0 +1,275 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+// TokenBreakdown splits the input token count of a request by component.
+type TokenBreakdown struct {
+	System   int64
+	Tools    int64
+	Messages int64
+	// Total is the token count of the whole request. For breakdowns computed
+	// by [MessageService.CountTokensBreakdown] it can differ slightly from the
+	// sum of the components, since the API adds a small fixed overhead for tools.
+	Total int64
+}
+
+// EstimateTokens returns a rough local estimate of the number of tokens in
+// text, without calling the API. English prose averages about four characters
+// per token; code and non-Latin scripts usually use more tokens. Use
+// [MessageService.CountTokens] when an exact count is needed.
+func EstimateTokens(text string) int64 {
+	return int64((utf8.RuneCountInString(text) + 3) / 4)
+}
+
+// maxImageTokens is roughly the cost of an image at the largest size the API
+// processes without downscaling.
+const maxImageTokens = 1600
+
+// estimateImageTokens estimates the cost of an image from its dimensions. The
+// API downscales images whose long edge exceeds 1568 pixels.
+func estimateImageTokens(width, height int) int64 {
+	if width <= 0 || height <= 0 {
+		return maxImageTokens
+	}
+	if long := max(width, height); long > 1568 {
+		scale := 1568 / float64(long)
+		width = int(float64(width) * scale)
+		height = int(float64(height) * scale)
+	}
+	return min(int64(math.Ceil(float64(width*height)/750)), maxImageTokens)
+}
+
+func estimateBase64ImageTokens(data string) int64 {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return maxImageTokens
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(decoded))
+	if err != nil {
+		return maxImageTokens
+	}
+	return estimateImageTokens(cfg.Width, cfg.Height)
+}
+
+// estimateJSONTokens estimates the tokens of a value from its JSON encoding.
+func estimateJSONTokens(v any) int64 {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return EstimateTokens(string(b))
+}
+
+func estimateContentBlockTokens(block ContentBlockParamUnion) int64 {
+	switch {
+	case block.OfText != nil:
+		return EstimateTokens(block.OfText.Text)
+	case block.OfImage != nil:
+		if block.OfImage.Source.OfBase64 != nil {
+			return estimateBase64ImageTokens(block.OfImage.Source.OfBase64.Data)
+		}
+		return maxImageTokens
+	default:
+		return estimateJSONTokens(block)
+	}
+}
+
+// EstimateTokenBreakdown estimates locally how the input tokens of a request
+// are split between the system prompt, the tool definitions and the messages.
+// It makes no API calls and is based on [EstimateTokens], so the numbers are
+// approximate, but they are useful to find which component dominates a prompt.
+func EstimateTokenBreakdown(params MessageCountTokensParams) TokenBreakdown {
+	var b TokenBreakdown
+	if params.System.OfString.Valid() {
+		b.System = EstimateTokens(params.System.OfString.Value)
+	}
+	for _, block := range params.System.OfTextBlockArray {
+		b.System += EstimateTokens(block.Text)
+	}
+	for _, tool := range params.Tools {
+		b.Tools += estimateJSONTokens(tool)
+	}
+	for _, message := range params.Messages {
+		for _, block := range message.Content {
+			b.Messages += estimateContentBlockTokens(block)
+		}
+	}
+	b.Total = b.System + b.Tools + b.Messages
+	return b
+}
+
+// CountTokensBreakdown uses the token counting API to break the input tokens of
+// a request down by component. It counts the full request, the request without
+// tools and the request without a system prompt, so up to three requests are
+// made. Use [EstimateTokenBreakdown] for a local estimate instead.
+func (r *MessageService) CountTokensBreakdown(ctx context.Context, params MessageCountTokensParams, opts ...option.RequestOption) (TokenBreakdown, error) {
+	var b TokenBreakdown
+
+	total, err := r.CountTokens(ctx, params, opts...)
+	if err != nil {
+		return b, err
+	}
+	b.Total = total.InputTokens
+
+	if len(params.Tools) > 0 {
+		withoutTools := params
+		withoutTools.Tools = nil
+		withoutTools.ToolChoice = ToolChoiceUnionParam{}
+		count, err := r.CountTokens(ctx, withoutTools, opts...)
+		if err != nil {
+			return b, err
+		}
+		b.Tools = b.Total - count.InputTokens
+	}
+
+	if params.System.OfString.Valid() || len(params.System.OfTextBlockArray) > 0 {
+		withoutSystem := params
+		withoutSystem.System = MessageCountTokensParamsSystemUnion{}
+		count, err := r.CountTokens(ctx, withoutSystem, opts...)
+		if err != nil {
+			return b, err
+		}
+		b.System = b.Total - count.InputTokens
+	}
+
+	b.Messages = max(b.Total-b.Tools-b.System, 0)
+	return b, nil
+}
+
+// CountTokensParams returns the params for counting the input tokens of the
+// request, with [MessageService.CountTokens], before sending it. The messages,
+// model, system prompt, tools, tool choice, thinking and output configuration
+// are kept; fields which only affect the output, such as MaxTokens, are not.
+//
+//	count, err := client.Messages.CountTokens(ctx, params.CountTokensParams())
+//	if err == nil && count.InputTokens > budget {
+//		return errors.New("prompt too large")
+//	}
+func (r MessageNewParams) CountTokensParams() MessageCountTokensParams {
+	p := MessageCountTokensParams{
+		Messages:     r.Messages,
+		Model:        r.Model,
+		OutputConfig: r.OutputConfig,
+		Thinking:     r.Thinking,
+		ToolChoice:   r.ToolChoice,
+	}
+	if len(r.System) > 0 {
+		p.System.OfTextBlockArray = r.System
+	}
+	for _, tool := range r.Tools {
+		p.Tools = append(p.Tools, MessageCountTokensToolUnionParam(tool))
+	}
+	return p
+}
+
+// CountTokensParams returns the params for counting the input tokens of the
+// request with [BetaMessageService.CountTokens], including its betas. See
+// [MessageNewParams.CountTokensParams].
+func (r BetaMessageNewParams) CountTokensParams() BetaMessageCountTokensParams {
+	p := BetaMessageCountTokensParams{
+		Messages:          r.Messages,
+		Model:             r.Model,
+		ContextManagement: r.ContextManagement,
+		MCPServers:        r.MCPServers,
+		OutputConfig:      r.OutputConfig,
+		OutputFormat:      r.OutputFormat,
+		Thinking:          r.Thinking,
+		ToolChoice:        r.ToolChoice,
+		Betas:             r.Betas,
+	}
+	if len(r.System) > 0 {
+		p.System.OfBetaTextBlockArray = r.System
+	}
+	for _, tool := range r.Tools {
+		p.Tools = append(p.Tools, BetaMessageCountTokensParamsToolUnion(tool))
+	}
+	return p
+}
+
+// splitMarkerTokens is the room left in each part by [SplitLongInput] for its
+// continuation markers.
+const splitMarkerTokens = 20
+
+// SplitLongInput returns text as a single user message if its estimated size,
+// from [EstimateTokens], fits in the context window of model less reserve
+// tokens, which should cover the output and the rest of the request. Otherwise
+// the text is split into several user messages which each fit, at paragraph
+// boundaries where possible and else between words, marked with "[Part i of
+// n]" and a note that the input continues in the next message.
+//
+// Since a conversation as a whole must fit the context window, the parts are
+// meant to be sent one request at a time, for example asking the model to
+// take notes on each. If reserve leaves no room in the context window, the
+// text is returned as a single message.
+func SplitLongInput(text string, model Model, reserve int) []MessageParam {
+	budget := ContextWindow(model) - int64(reserve)
+	if EstimateTokens(text) <= budget || budget <= splitMarkerTokens {
+		return []MessageParam{NewUserMessage(NewTextBlock(text))}
+	}
+
+	// EstimateTokens counts four characters per token.
+	parts := splitText(text, int(budget-splitMarkerTokens)*4)
+	messages := make([]MessageParam, len(parts))
+	for i, part := range parts {
+		part = fmt.Sprintf("[Part %d of %d]\n\n%s", i+1, len(parts), part)
+		if i < len(parts)-1 {
+			part += "\n\n[The input continues in the next message.]"
+		}
+		messages[i] = NewUserMessage(NewTextBlock(part))
+	}
+	return messages
+}
+
+// splitText splits text into parts of at most maxRunes characters, preferring
+// to split between paragraphs, then between words.
+func splitText(text string, maxRunes int) []string {
+	var parts []string
+	var current strings.Builder
+	var currentRunes int
+	flush := func() {
+		if part := strings.TrimSpace(current.String()); part != "" {
+			parts = append(parts, part)
+		}
+		current.Reset()
+		currentRunes = 0
+	}
+	add := func(piece string, n int) {
+		if currentRunes+n > maxRunes {
+			flush()
+		}
+		current.WriteString(piece)
+		currentRunes += n
+	}
+
+	for _, paragraph := range strings.SplitAfter(text, "\n\n") {
+		if n := utf8.RuneCountInString(paragraph); n <= maxRunes {
+			add(paragraph, n)
+			continue
+		}
+		for _, word := range strings.SplitAfter(paragraph, " ") {
+			// A single word longer than a part is cut where it must.
+			for runes := []rune(word); len(runes) > 0; {
+				n := min(len(runes), maxRunes)
+				add(string(runes[:n]), n)
+				runes = runes[n:]
+			}
+		}
+	}
+	flush()
+	return parts
+}
@@ -0,0 +1,167 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+)
+
+func TestEstimateTokenBreakdown(t *testing.T) {
+	params := anthropic.MessageCountTokensParams{
+		Model: anthropic.ModelClaudeSonnet4_5_20250929,
+		System: anthropic.MessageCountTokensParamsSystemUnion{
+			OfString: anthropic.String(strings.Repeat("a", 400)),
+		},
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock(strings.Repeat("b", 40))),
+		},
+		Tools: []anthropic.MessageCountTokensToolUnionParam{{
+			OfTool: &anthropic.ToolParam{
+				Name:        "get_weather",
+				InputSchema: anthropic.ToolInputSchemaParam{Properties: map[string]any{"city": map[string]any{"type": "string"}}},
+			},
+		}},
+	}
+
+	b := anthropic.EstimateTokenBreakdown(params)
+	if b.System != 100 {
+		t.Errorf("expected 100 system tokens, got %d", b.System)
+	}
+	if b.Messages != 10 {
+		t.Errorf("expected 10 message tokens, got %d", b.Messages)
+	}
+	if b.Tools == 0 {
+		t.Error("expected a non-zero tools estimate")
+	}
+	if b.Total != b.System+b.Tools+b.Messages {
+		t.Errorf("expected the total to be the sum of the components, got %+v", b)
+	}
+}
+
+func TestCountTokensBreakdown(t *testing.T) {
+	requests := 0
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					requests++
+					body, _ := io.ReadAll(req.Body)
+					tokens := 10
+					if gjson.GetBytes(body, "system").Exists() {
+						tokens += 200
+					}
+					if gjson.GetBytes(body, "tools").Exists() {
+						tokens += 50
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(fmt.Sprintf(`{"input_tokens":%d}`, tokens))),
+					}, nil
+				},
+			},
+		}),
+	)
+
+	b, err := client.Messages.CountTokensBreakdown(context.Background(), anthropic.MessageCountTokensParams{
+		Model:    anthropic.ModelClaudeSonnet4_5_20250929,
+		System:   anthropic.MessageCountTokensParamsSystemUnion{OfString: anthropic.String("You are terse.")},
+		Messages: []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("hi"))},
+		Tools: []anthropic.MessageCountTokensToolUnionParam{{
+			OfTool: &anthropic.ToolParam{Name: "noop", InputSchema: anthropic.ToolInputSchemaParam{}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := anthropic.TokenBreakdown{System: 200, Tools: 50, Messages: 10, Total: 260}
+	if b != expected {
+		t.Errorf("expected %+v, got %+v", expected, b)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
+
+func TestSplitLongInput(t *testing.T) {
+	const model = anthropic.Model("claude-test-small")
+	anthropic.ModelContextWindows[model] = 1000
+	defer delete(anthropic.ModelContextWindows, model)
+
+	if n := anthropic.ContextWindow("claude-sonnet-4-5-20991231"); n != 200_000 {
+		t.Errorf("expected a dated model to use its alias, got %d", n)
+	}
+	if messages := anthropic.SplitLongInput("short text", model, 200); len(messages) != 1 || messages[0].Content[0].OfText.Text != "short text" {
+		t.Errorf("expected short text to be a single message, got %+v", messages)
+	}
+
+	paragraph := strings.TrimSpace(strings.Repeat("word ", 200))
+	for _, text := range []string{
+		strings.Repeat(paragraph+"\n\n", 4) + paragraph,
+		strings.Repeat("word ", 1000),
+	} {
+		messages := anthropic.SplitLongInput(text, model, 200)
+		if len(messages) != 2 {
+			t.Fatalf("expected 2 parts, got %d", len(messages))
+		}
+		var words []string
+		for i, message := range messages {
+			part := message.Content[0].OfText.Text
+			if message.Role != anthropic.MessageParamRoleUser || anthropic.EstimateTokens(part) > 800 {
+				t.Errorf("expected part %d to be a user message within the budget, got %d tokens", i, anthropic.EstimateTokens(part))
+			}
+			if !strings.HasPrefix(part, fmt.Sprintf("[Part %d of 2]", i+1)) {
+				t.Errorf("expected a part marker, got %q", part[:20])
+			}
+			if continues := strings.HasSuffix(part, "[The input continues in the next message.]"); continues != (i == 0) {
+				t.Errorf("unexpected continuation marker on part %d", i)
+			}
+			words = append(words, strings.Fields(part)...)
+		}
+		if n := len(words) - 2*4 - 7; n != len(strings.Fields(text)) {
+			t.Errorf("expected every word to be kept whole, got %d words", n)
+		}
+	}
+}
+
+func TestCountTokensParams(t *testing.T) {
+	params := anthropic.MessageNewParams{
+		Model:       anthropic.ModelClaudeSonnet4_5_20250929,
+		MaxTokens:   1024,
+		Temperature: anthropic.Float(0.5),
+		System:      []anthropic.TextBlockParam{{Text: "You are terse."}},
+		Messages:    []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("hi"))},
+		Tools: []anthropic.ToolUnionParam{{
+			OfTool: &anthropic.ToolParam{Name: "noop", InputSchema: anthropic.ToolInputSchemaParam{Required: []string{"x"}}},
+		}},
+	}
+	count := params.CountTokensParams()
+	b, err := json.Marshal(count)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"messages":[{"content":[{"text":"hi","type":"text"}],"role":"user"}],"model":"claude-sonnet-4-5-20250929",` +
+		`"system":[{"text":"You are terse.","type":"text"}],"tools":[{"input_schema":{"required":["x"],"type":"object"},"name":"noop"}]}`
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+
+	beta := anthropic.BetaMessageNewParams{
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		MaxTokens: 1024,
+		Messages:  []anthropic.BetaMessageParam{anthropic.NewBetaUserMessage(anthropic.NewBetaTextBlock("hi"))},
+		Betas:     []anthropic.AnthropicBeta{anthropic.AnthropicBetaContext1m2025_08_07},
+	}
+	if count := beta.CountTokensParams(); len(count.Messages) != 1 || count.Model != beta.Model || len(count.Betas) != 1 {
+		t.Errorf("expected the messages, model and betas to be kept, got %+v", count)
+	}
+}
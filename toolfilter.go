@@ -0,0 +1,82 @@
+package anthropic
+
+import (
+	"encoding/json"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/internal/requestconfig"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// WithToolFilter returns a RequestOption which removes the tools for which keep
+// returns false from the request. This allows a single shared set of tools to be
+// scoped per tenant or session, for example when set at the client level:
+//
+//	client.Messages.New(ctx, params, anthropic.WithToolFilter(func(tool anthropic.ToolUnionParam) bool {
+//		name := tool.GetName()
+//		return name != nil && allowed[*name]
+//	}))
+//
+// The filter is applied to the serialized request, after all params have been
+// set. If every tool is removed, or the tool named by the tool_choice is, so is
+// the tool_choice. Requests without tools are left untouched.
+func WithToolFilter(keep func(ToolUnionParam) bool) option.RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		return filterTools(r, func(raw []byte) (bool, error) {
+			var tool ToolUnionParam
+			if err := json.Unmarshal(raw, &tool); err != nil {
+				return false, err
+			}
+			return keep(tool), nil
+		})
+	})
+}
+
+// WithBetaToolFilter is like [WithToolFilter], for requests to the beta API.
+func WithBetaToolFilter(keep func(BetaToolUnionParam) bool) option.RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		return filterTools(r, func(raw []byte) (bool, error) {
+			var tool BetaToolUnionParam
+			if err := json.Unmarshal(raw, &tool); err != nil {
+				return false, err
+			}
+			return keep(tool), nil
+		})
+	})
+}
+
+func filterTools(r *requestconfig.RequestConfig, keep func(raw []byte) (bool, error)) error {
+	return r.RewriteJSONBody(func(body []byte) ([]byte, error) {
+		tools := gjson.GetBytes(body, "tools")
+		if !tools.IsArray() {
+			return body, nil
+		}
+
+		kept := []json.RawMessage{}
+		chosenKept := false
+		chosen := gjson.GetBytes(body, "tool_choice")
+		for _, tool := range tools.Array() {
+			ok, err := keep([]byte(tool.Raw))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				kept = append(kept, json.RawMessage(tool.Raw))
+				chosenKept = chosenKept || tool.Get("name").String() == chosen.Get("name").String()
+			}
+		}
+		// The API rejects a tool_choice without tools, or naming a tool
+		// which is not in the request.
+		var err error
+		if len(kept) == 0 || chosen.Get("type").String() == "tool" && !chosenKept {
+			if body, err = sjson.DeleteBytes(body, "tool_choice"); err != nil {
+				return nil, err
+			}
+		}
+		if len(kept) == 0 {
+			return sjson.DeleteBytes(body, "tools")
+		}
+		return sjson.SetBytes(body, "tools", kept)
+	})
+}
@@ -0,0 +1,91 @@
+package anthropic_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+)
+
+func TestWithToolFilter(t *testing.T) {
+	var body []byte
+	client := anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					body, _ = io.ReadAll(req.Body)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(`{}`)),
+					}, nil
+				},
+			},
+		}),
+		anthropic.WithToolFilter(func(tool anthropic.ToolUnionParam) bool {
+			name := tool.GetName()
+			return name != nil && *name != "delete_account"
+		}),
+	)
+
+	_, err := client.Messages.New(context.Background(), anthropic.MessageNewParams{
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		Tools: []anthropic.ToolUnionParam{
+			{OfTool: &anthropic.ToolParam{Name: "get_weather", InputSchema: anthropic.ToolInputSchemaParam{}}},
+			{OfTool: &anthropic.ToolParam{Name: "delete_account", InputSchema: anthropic.ToolInputSchemaParam{}}},
+			{OfBashTool20250124: &anthropic.ToolBash20250124Param{}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names []string
+	for _, tool := range gjson.GetBytes(body, "tools").Array() {
+		names = append(names, tool.Get("name").String())
+	}
+	if strings.Join(names, ",") != "get_weather,bash" {
+		t.Errorf("expected get_weather and bash to be kept, got %v", names)
+	}
+
+	_, err = client.Messages.New(context.Background(), anthropic.MessageNewParams{
+		MaxTokens:  1024,
+		Messages:   []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+		Model:      anthropic.ModelClaudeSonnet4_5_20250929,
+		Tools:      []anthropic.ToolUnionParam{{OfTool: &anthropic.ToolParam{Name: "delete_account", InputSchema: anthropic.ToolInputSchemaParam{}}}},
+		ToolChoice: anthropic.ToolChoiceUnionParam{OfAny: &anthropic.ToolChoiceAnyParam{}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gjson.GetBytes(body, "tools").Exists() || gjson.GetBytes(body, "tool_choice").Exists() {
+		t.Errorf("expected tools and tool_choice to be removed, got %s", body)
+	}
+
+	for name, removed := range map[string]bool{"delete_account": true, "get_weather": false} {
+		_, err = client.Messages.New(context.Background(), anthropic.MessageNewParams{
+			MaxTokens: 1024,
+			Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("x"))},
+			Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+			Tools: []anthropic.ToolUnionParam{
+				{OfTool: &anthropic.ToolParam{Name: "get_weather", InputSchema: anthropic.ToolInputSchemaParam{}}},
+				{OfTool: &anthropic.ToolParam{Name: "delete_account", InputSchema: anthropic.ToolInputSchemaParam{}}},
+			},
+			ToolChoice: anthropic.ToolChoiceParamOfTool(name),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if gjson.GetBytes(body, "tool_choice").Exists() == removed {
+			t.Errorf("choosing %s, expected the tool_choice to be removed: %v, got %s", name, removed, body)
+		}
+	}
+}
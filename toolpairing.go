@@ -0,0 +1,180 @@
+package anthropic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ToolPairingError describes a tool_use block without a matching tool_result,
+// or a tool_result without a matching tool_use.
+type ToolPairingError struct {
+	// MessageIndex is the index of the message containing the unpaired block.
+	MessageIndex int
+	ToolUseID    string
+	Reason       string
+}
+
+func (e *ToolPairingError) Error() string {
+	return fmt.Sprintf("messages[%d]: %s %s", e.MessageIndex, e.ToolUseID, e.Reason)
+}
+
+// toolPairing returns the IDs of the tool_use blocks of an assistant message and
+// of the tool_result blocks of a user message.
+func toolPairing(message MessageParam) (toolUses, toolResults []string) {
+	for _, block := range message.Content {
+		switch {
+		case message.Role == MessageParamRoleAssistant && block.OfToolUse != nil:
+			toolUses = append(toolUses, block.OfToolUse.ID)
+		case message.Role == MessageParamRoleUser && block.OfToolResult != nil:
+			toolResults = append(toolResults, block.OfToolResult.ToolUseID)
+		}
+	}
+	return toolUses, toolResults
+}
+
+// ValidateToolPairing checks that every tool_use block in an assistant message
+// is answered by a tool_result block in the user message immediately after it,
+// and that every tool_result answers a tool_use in the assistant message
+// immediately before it. The API rejects conversations that break either rule.
+//
+// All problems found are reported, joined with [errors.Join], as
+// [*ToolPairingError] values. Use [RepairToolPairing] to fix them instead.
+func ValidateToolPairing(messages []MessageParam) error {
+	return errors.Join(toolPairingErrors(messages)...)
+}
+
+func toolPairingErrors(messages []MessageParam) []error {
+	var errs []error
+	for i, message := range messages {
+		toolUses, toolResults := toolPairing(message)
+
+		var nextResults []string
+		if i+1 < len(messages) {
+			_, nextResults = toolPairing(messages[i+1])
+		}
+		for _, id := range toolUses {
+			if !slices.Contains(nextResults, id) {
+				errs = append(errs, &ToolPairingError{MessageIndex: i, ToolUseID: id, Reason: "has no tool_result in the next message"})
+			}
+		}
+
+		var prevUses []string
+		if i > 0 {
+			prevUses, _ = toolPairing(messages[i-1])
+		}
+		for _, id := range toolResults {
+			if !slices.Contains(prevUses, id) {
+				errs = append(errs, &ToolPairingError{MessageIndex: i, ToolUseID: id, Reason: "is a tool_result without a tool_use in the previous message"})
+			}
+		}
+	}
+	return errs
+}
+
+// checkToolUseIDs checks the tool_use_id references of a conversation being
+// saved or loaded. It is like [ValidateToolPairing], except that the tool uses
+// of a final assistant message may still be waiting for their results, and it
+// also reports tool_use IDs which appear more than once, since a tool_result
+// would be ambiguous.
+func checkToolUseIDs(messages []MessageParam) error {
+	var errs []error
+	for _, err := range toolPairingErrors(messages) {
+		if err := err.(*ToolPairingError); err.MessageIndex == len(messages)-1 && messages[err.MessageIndex].Role == MessageParamRoleAssistant {
+			continue
+		}
+		errs = append(errs, err)
+	}
+	seen := map[string]bool{}
+	for i, message := range messages {
+		toolUses, _ := toolPairing(message)
+		for _, id := range toolUses {
+			if seen[id] {
+				errs = append(errs, &ToolPairingError{MessageIndex: i, ToolUseID: id, Reason: "is a duplicate tool_use ID"})
+			}
+			seen[id] = true
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RemapToolUseIDs returns a copy of messages in which the IDs of tool_use
+// blocks, and the tool_use_id of the tool_result blocks referring to them, are
+// replaced according to mapping, from old to new ID. IDs missing from mapping
+// are kept. This is useful when IDs must be regenerated, for example to merge
+// conversations which reuse the same IDs. The input is not modified.
+func RemapToolUseIDs(messages []MessageParam, mapping map[string]string) []MessageParam {
+	remapped := slices.Clone(messages)
+	for i, message := range remapped {
+		content := slices.Clone(message.Content)
+		for j, block := range content {
+			switch {
+			case block.OfToolUse != nil:
+				if id, ok := mapping[block.OfToolUse.ID]; ok {
+					toolUse := *block.OfToolUse
+					toolUse.ID = id
+					content[j].OfToolUse = &toolUse
+				}
+			case block.OfToolResult != nil:
+				if id, ok := mapping[block.OfToolResult.ToolUseID]; ok {
+					toolResult := *block.OfToolResult
+					toolResult.ToolUseID = id
+					content[j].OfToolResult = &toolResult
+				}
+			}
+		}
+		remapped[i].Content = content
+	}
+	return remapped
+}
+
+// RepairToolPairing returns a copy of messages which passes
+// [ValidateToolPairing]. Tool uses without a result are answered with an error
+// tool_result saying the tool was not run, added to the following user message
+// or to a new one, and tool results without a matching tool use are removed.
+// User messages left without content are dropped. The input is not modified.
+func RepairToolPairing(messages []MessageParam) []MessageParam {
+	repaired := make([]MessageParam, 0, len(messages))
+	// pending holds the error results for the tool uses of the last assistant
+	// message which are not answered by the next message.
+	var pending []ContentBlockParamUnion
+	for i, message := range messages {
+		if message.Role == MessageParamRoleUser {
+			var prevUses []string
+			if n := len(repaired); n > 0 {
+				prevUses, _ = toolPairing(repaired[n-1])
+			}
+			content := slices.DeleteFunc(slices.Clone(message.Content), func(block ContentBlockParamUnion) bool {
+				return block.OfToolResult != nil && !slices.Contains(prevUses, block.OfToolResult.ToolUseID)
+			})
+			// Tool results must come before any other content in a user message.
+			message.Content = append(pending, content...)
+			pending = nil
+			if len(message.Content) > 0 {
+				repaired = append(repaired, message)
+			}
+			continue
+		}
+
+		if len(pending) > 0 {
+			repaired = append(repaired, NewUserMessage(pending...))
+			pending = nil
+		}
+		repaired = append(repaired, message)
+
+		var nextResults []string
+		if i+1 < len(messages) {
+			_, nextResults = toolPairing(messages[i+1])
+		}
+		toolUses, _ := toolPairing(message)
+		for _, id := range toolUses {
+			if !slices.Contains(nextResults, id) {
+				pending = append(pending, NewToolResultBlock(id, "Error: the tool was not run.", true))
+			}
+		}
+	}
+	if len(pending) > 0 {
+		repaired = append(repaired, NewUserMessage(pending...))
+	}
+	return repaired
+}
@@ -0,0 +1,82 @@
+package anthropic_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestToolPairing(t *testing.T) {
+	messages := []anthropic.MessageParam{
+		anthropic.NewUserMessage(anthropic.NewTextBlock("What's the weather in Paris and Rome?")),
+		anthropic.NewAssistantMessage(
+			anthropic.NewToolUseBlock("toolu_01", map[string]any{"city": "Paris"}, "get_weather"),
+			anthropic.NewToolUseBlock("toolu_02", map[string]any{"city": "Rome"}, "get_weather"),
+		),
+		anthropic.NewUserMessage(
+			anthropic.NewToolResultBlock("toolu_01", "Sunny", false),
+			anthropic.NewToolResultBlock("toolu_99", "Rainy", false),
+		),
+		anthropic.NewAssistantMessage(anthropic.NewToolUseBlock("toolu_03", map[string]any{}, "get_time")),
+	}
+
+	err := anthropic.ValidateToolPairing(messages)
+	var unpaired []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var pairingErr *anthropic.ToolPairingError
+		if !errors.As(err, &pairingErr) {
+			t.Fatalf("expected a ToolPairingError, got %v", err)
+		}
+		unpaired = append(unpaired, pairingErr.ToolUseID)
+	}
+	if len(unpaired) != 3 || unpaired[0] != "toolu_02" || unpaired[1] != "toolu_99" || unpaired[2] != "toolu_03" {
+		t.Errorf("expected toolu_02, toolu_99 and toolu_03 to be reported, got %v", unpaired)
+	}
+
+	repaired := anthropic.RepairToolPairing(messages)
+	if err := anthropic.ValidateToolPairing(repaired); err != nil {
+		t.Errorf("expected the repaired messages to be valid, got %v", err)
+	}
+	if len(repaired) != 5 {
+		t.Fatalf("expected a user message to be added for the final tool use, got %d messages", len(repaired))
+	}
+	if len(messages[2].Content) != 2 || messages[2].Content[1].OfToolResult.ToolUseID != "toolu_99" {
+		t.Errorf("expected the original messages to be left unchanged")
+	}
+	if result := repaired[2].Content[0].OfToolResult; result.ToolUseID != "toolu_02" || !result.IsError.Value {
+		t.Errorf("expected an error result for toolu_02 first, got %+v", result)
+	}
+}
+
+func TestRemapToolUseIDs(t *testing.T) {
+	messages := []anthropic.MessageParam{
+		anthropic.NewAssistantMessage(
+			anthropic.NewToolUseBlock("toolu_01", map[string]any{}, "get_time"),
+			anthropic.NewToolUseBlock("toolu_02", map[string]any{}, "get_time"),
+		),
+		anthropic.NewUserMessage(
+			anthropic.NewToolResultBlock("toolu_01", "noon", false),
+			anthropic.NewToolResultBlock("toolu_02", "midnight", false),
+		),
+	}
+
+	merged := append(slices.Clone(messages), anthropic.RemapToolUseIDs(messages, map[string]string{"toolu_01": "toolu_03"})...)
+	if _, err := anthropic.MessagesToJSON(merged); err == nil || !strings.Contains(err.Error(), "toolu_02 is a duplicate tool_use ID") {
+		t.Errorf("expected a duplicate ID error, got %v", err)
+	}
+
+	mapping := map[string]string{"toolu_01": "toolu_03", "toolu_02": "toolu_04"}
+	merged = append(slices.Clone(messages), anthropic.RemapToolUseIDs(messages, mapping)...)
+	if _, err := anthropic.MessagesToJSON(merged); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged[2].Content[1].OfToolUse.ID != "toolu_04" || merged[3].Content[0].OfToolResult.ToolUseID != "toolu_03" {
+		t.Errorf("expected the IDs to be remapped, got %+v", merged[2:])
+	}
+	if messages[0].Content[0].OfToolUse.ID != "toolu_01" || messages[1].Content[1].OfToolResult.ToolUseID != "toolu_02" {
+		t.Errorf("expected the original messages to be left unchanged")
+	}
+}
@@ -0,0 +1,105 @@
+package anthropic
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxToolResultTextSize is the size, in bytes, beyond which the text of a
+// response is truncated by [ToolResultFromHTTPResponse], to keep tool results
+// from filling the context window.
+const MaxToolResultTextSize = 100 << 10
+
+// ToolResultFromHTTPResponse reads and closes the body of resp, the response to
+// an HTTP request made by a tool, and returns the tool_result for toolUseID
+// which feeds it back to the model:
+//
+//   - JPEG, PNG, GIF and WebP images are sent as an image, if they are within
+//     [MaxImageSize];
+//   - text, JSON, XML and JavaScript are sent as text, truncated to
+//     [MaxToolResultTextSize] with a note saying how much was left out.
+//
+// The content type is taken from the Content-Type header, or sniffed from the
+// body if there is none. A response with a status of 400 or more is sent with
+// is_error set, its text prefixed with the status. An error is returned if the
+// body cannot be read, or if it is neither text nor a supported image.
+func ToolResultFromHTTPResponse(toolUseID string, resp *http.Response) (ContentBlockParamUnion, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, max(MaxImageSize, MaxToolResultTextSize)+1))
+	if err != nil {
+		return ContentBlockParamUnion{}, fmt.Errorf("tool result: reading response: %w", err)
+	}
+	// The body may be longer than what was read, as the reader was limited.
+	truncated := len(body) > max(MaxImageSize, MaxToolResultTextSize)
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	isError := resp.StatusCode >= http.StatusBadRequest
+
+	var content ToolResultBlockParamContentUnion
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		detected := DetectImageMediaType(body)
+		if detected == "" {
+			return ContentBlockParamUnion{}, fmt.Errorf("tool result: unsupported image type %s", mediaType)
+		}
+		if truncated || len(body) > MaxImageSize {
+			return ContentBlockParamUnion{}, fmt.Errorf("tool result: %w", ErrImageTooLarge)
+		}
+		content.OfImage = &ImageBlockParam{Source: ImageBlockParamSourceUnion{OfBase64: &Base64ImageSourceParam{
+			Data:      base64.StdEncoding.EncodeToString(body),
+			MediaType: detected,
+		}}}
+	case isTextMediaType(mediaType):
+		text := string(body)
+		if len(body) > MaxToolResultTextSize {
+			cut := MaxToolResultTextSize
+			for cut > 0 && !utf8.RuneStart(body[cut]) {
+				cut--
+			}
+			total := fmt.Sprintf("%d", len(body))
+			if truncated {
+				total = fmt.Sprintf("more than %d", len(body)-1)
+				if resp.ContentLength > 0 {
+					total = fmt.Sprintf("%d", resp.ContentLength)
+				}
+			}
+			text = fmt.Sprintf("%s\n\n[truncated to the first %d of %s bytes]", body[:cut], cut, total)
+		}
+		if isError {
+			text = fmt.Sprintf("HTTP %s\n\n%s", httpStatus(resp), text)
+		}
+		content.OfText = &TextBlockParam{Text: text}
+	default:
+		return ContentBlockParamUnion{}, fmt.Errorf("tool result: unsupported content type %s", contentType)
+	}
+
+	return ContentBlockParamUnion{OfToolResult: &ToolResultBlockParam{
+		ToolUseID: toolUseID,
+		Content:   []ToolResultBlockParamContentUnion{content},
+		IsError:   Bool(isError),
+	}}, nil
+}
+
+func isTextMediaType(mediaType string) bool {
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-www-form-urlencoded":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+func httpStatus(resp *http.Response) string {
+	if resp.Status != "" {
+		return resp.Status
+	}
+	return fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+}
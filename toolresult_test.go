@@ -0,0 +1,66 @@
+package anthropic_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestToolResultFromHTTPResponse(t *testing.T) {
+	response := func(status int, contentType string, body []byte) *http.Response {
+		header := http.Header{}
+		if contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+		return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(bytes.NewReader(body)), ContentLength: int64(len(body))}
+	}
+
+	block, err := anthropic.ToolResultFromHTTPResponse("toolu_1", response(http.StatusOK, "application/json; charset=utf-8", []byte(`{"temp":21}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := block.OfToolResult
+	if result.ToolUseID != "toolu_1" || result.IsError.Value || result.Content[0].OfText.Text != `{"temp":21}` {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	block, err = anthropic.ToolResultFromHTTPResponse("toolu_2", response(http.StatusNotFound, "text/plain", []byte("no such page")))
+	if err != nil || !block.OfToolResult.IsError.Value || block.OfToolResult.Content[0].OfText.Text != "HTTP 404 Not Found\n\nno such page" {
+		t.Errorf("expected an error result, got %+v, %v", block.OfToolResult, err)
+	}
+
+	long := strings.Repeat("é", anthropic.MaxToolResultTextSize)
+	block, err = anthropic.ToolResultFromHTTPResponse("toolu_3", response(http.StatusOK, "text/html", []byte(long)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := block.OfToolResult.Content[0].OfText.Text
+	if !strings.HasSuffix(text, "[truncated to the first 102400 of 204800 bytes]") || !strings.HasPrefix(text, "éé") {
+		t.Errorf("unexpected truncated text ending %q", text[len(text)-60:])
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	// Without a Content-Type header, the type is sniffed from the body.
+	block, err = anthropic.ToolResultFromHTTPResponse("toolu_4", response(http.StatusOK, "", buf.Bytes()))
+	if err != nil || block.OfToolResult.Content[0].OfImage.Source.OfBase64.MediaType != anthropic.Base64ImageSourceMediaTypeImagePNG {
+		t.Errorf("expected a png image result, got %v", err)
+	}
+
+	if _, err := anthropic.ToolResultFromHTTPResponse("toolu_5", response(http.StatusOK, "application/zip", []byte("PK\x03\x04"))); err == nil {
+		t.Error("expected an error for a binary body")
+	}
+	large := append(bytes.Clone(buf.Bytes()), make([]byte, anthropic.MaxImageSize)...)
+	if _, err := anthropic.ToolResultFromHTTPResponse("toolu_6", response(http.StatusOK, "image/png", large)); !errors.Is(err, anthropic.ErrImageTooLarge) {
+		t.Errorf("expected ErrImageTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,293 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+)
+
+// ToolFunc executes a tool with the input chosen by the model and returns the
+// content of the tool result. By default an error is reported to the model as
+// an error tool result, so it can recover, rather than ending the run; see
+// [ToolRunnerOpts.ToolErrors].
+type ToolFunc func(ctx context.Context, input json.RawMessage) (string, error)
+
+// DefaultMaxToolIterations is the number of requests a [ToolRunner] run makes
+// when [ToolRunnerOpts.MaxIterations] is not set.
+const DefaultMaxToolIterations = 10
+
+// ErrMaxToolIterations is returned by [ToolRunner.Run] when the model is still
+// calling tools after the maximum number of iterations.
+var ErrMaxToolIterations = errors.New("tool runner: maximum number of iterations reached")
+
+// ToolErrorPolicy is how a [ToolRunner] handles the errors returned by tools.
+type ToolErrorPolicy int
+
+const (
+	// ToolErrorsAsResults reports each error to the model as an error tool
+	// result, so it can recover, and continues the run.
+	ToolErrorsAsResults ToolErrorPolicy = iota
+	// ToolErrorsFirst ends the run with the first error, as a [*ToolError].
+	// The other tools of the turn which are still running are cancelled
+	// through their context, and those not started yet are not run.
+	ToolErrorsFirst
+	// ToolErrorsJoined runs all the tools of the turn, then ends the run if any
+	// failed, returning their errors as [*ToolError] values joined with
+	// [errors.Join], in the order of the tool uses.
+	ToolErrorsJoined
+)
+
+// ToolError is an error returned by a tool, which ends a run under the
+// [ToolErrorsFirst] and [ToolErrorsJoined] policies.
+type ToolError struct {
+	ToolUseID string
+	Name      string
+	Err       error
+}
+
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("tool %s (%s): %v", e.Name, e.ToolUseID, e.Err)
+}
+
+func (e *ToolError) Unwrap() error { return e.Err }
+
+// ToolRunnerOpts configures a [ToolRunner].
+type ToolRunnerOpts struct {
+	// MaxIterations caps the number of requests made by a run, to prevent
+	// infinite loops. Zero means [DefaultMaxToolIterations], and a negative
+	// value removes the limit.
+	MaxIterations int
+	// OnIteration, if set, is called with each message received, numbered
+	// from 1, before its tools are executed. Returning true stops the run,
+	// which then returns the message and the transcript so far, without an
+	// error.
+	OnIteration func(i int, msg *Message) (stop bool)
+	// MemoizeTools reuses the result of a previous call, within the same run,
+	// when a tool is called again with the same input. Inputs are compared as
+	// JSON values, so formatting and key order do not matter. Errors are reused
+	// as well. Identical calls made concurrently under ParallelTools run the
+	// tool once and share its result.
+	MemoizeTools bool
+	// ParallelTools executes the tools called in one turn concurrently rather
+	// than one after the other, for tools which are independent. Their results
+	// are sent in the order of the tool uses either way.
+	ParallelTools bool
+	// MaxToolConcurrency bounds the number of tools executed at once when
+	// ParallelTools is set. Zero means no bound.
+	MaxToolConcurrency int
+	// ToolErrors is how errors returned by tools are handled. Defaults to
+	// [ToolErrorsAsResults].
+	ToolErrors ToolErrorPolicy
+}
+
+// ToolRunner runs the tool-execution loop of a conversation: it sends a
+// request, executes the tools the model asks for, sends their results back and
+// repeats until the model stops calling tools. See
+// [MessageService.NewToolRunner].
+type ToolRunner struct {
+	service *MessageService
+	tools   map[string]ToolFunc
+	opts    ToolRunnerOpts
+}
+
+// NewToolRunner returns a ToolRunner which executes tools by name with the
+// functions in tools. The definitions of the tools are sent with the request
+// passed to [ToolRunner.Run], in the Tools field of its params.
+func (r *MessageService) NewToolRunner(tools map[string]ToolFunc, opts ToolRunnerOpts) *ToolRunner {
+	return &ToolRunner{service: r, tools: tools, opts: opts}
+}
+
+type toolCallResult struct {
+	content string
+	err     error
+}
+
+// Run sends params and loops until the model's response does not call a tool.
+// It returns the final message and the transcript, which is params.Messages
+// followed by every assistant turn and tool result turn of the run. If a
+// request fails, Run returns the transcript so far along with the error.
+//
+// If the run is stopped by [ToolRunnerOpts.OnIteration] or reaches
+// [ToolRunnerOpts.MaxIterations], in which case [ErrMaxToolIterations] is
+// returned, the last message and the transcript end with tool uses which were
+// not executed. The same is true when a tool fails under the [ToolErrorsFirst]
+// or [ToolErrorsJoined] policies, which return the error of the tool.
+func (t *ToolRunner) Run(ctx context.Context, params MessageNewParams, opts ...option.RequestOption) (*Message, []MessageParam, error) {
+	maxIterations := t.opts.MaxIterations
+	if maxIterations == 0 {
+		maxIterations = DefaultMaxToolIterations
+	}
+	transcript := slices.Clone(params.Messages)
+	memo := &toolMemo{results: map[string]*memoEntry{}}
+	for i := 1; ; i++ {
+		params.Messages = transcript
+		message, err := t.service.New(ctx, params, opts...)
+		if err != nil {
+			return nil, transcript, err
+		}
+		transcript = append(transcript, message.ToParam())
+		if t.opts.OnIteration != nil && t.opts.OnIteration(i, message) {
+			return message, transcript, nil
+		}
+		callsTools := slices.ContainsFunc(message.Content, func(block ContentBlockUnion) bool { return block.Type == "tool_use" })
+		if maxIterations > 0 && i >= maxIterations && callsTools {
+			return message, transcript, ErrMaxToolIterations
+		}
+
+		var toolUses []ToolUseBlock
+		for _, block := range message.Content {
+			if block.Type == "tool_use" {
+				toolUses = append(toolUses, block.AsToolUse())
+			}
+		}
+		if len(toolUses) == 0 {
+			return message, transcript, nil
+		}
+		results, err := t.callAll(ctx, toolUses, memo)
+		if err != nil {
+			return message, transcript, err
+		}
+		transcript = append(transcript, NewUserMessage(results...))
+	}
+}
+
+// callAll executes the tool uses of a turn and returns their results, or the
+// error ending the run under the configured [ToolErrorPolicy].
+func (t *ToolRunner) callAll(parent context.Context, toolUses []ToolUseBlock, memo *toolMemo) ([]ContentBlockParamUnion, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	calls := make([]toolCallResult, len(toolUses))
+	var mu sync.Mutex
+	var first error
+	call := func(i int) {
+		calls[i] = t.call(ctx, toolUses[i], memo)
+		if calls[i].err != nil && t.opts.ToolErrors == ToolErrorsFirst {
+			mu.Lock()
+			if first == nil {
+				first = &ToolError{ToolUseID: toolUses[i].ID, Name: toolUses[i].Name, Err: calls[i].err}
+				cancel()
+			}
+			mu.Unlock()
+		}
+	}
+
+	if t.opts.ParallelTools && len(toolUses) > 1 {
+		limit := t.opts.MaxToolConcurrency
+		if limit <= 0 {
+			limit = len(toolUses)
+		}
+		sem := make(chan struct{}, limit)
+		var wg sync.WaitGroup
+	loop:
+		for i := range toolUses {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				break loop
+			}
+			// select picks at random when both cases are ready, so check
+			// again that no tool failed while waiting for a slot.
+			if ctx.Err() != nil {
+				<-sem
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer func() { <-sem; wg.Done() }()
+				call(i)
+			}()
+		}
+		wg.Wait()
+	} else {
+		for i := range toolUses {
+			if call(i); first != nil {
+				break
+			}
+		}
+	}
+	if first != nil {
+		return nil, first
+	}
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+
+	var results []ContentBlockParamUnion
+	var errs []error
+	for i, toolUse := range toolUses {
+		if err := calls[i].err; err != nil {
+			errs = append(errs, &ToolError{ToolUseID: toolUse.ID, Name: toolUse.Name, Err: err})
+			results = append(results, NewToolResultBlock(toolUse.ID, "Error: "+err.Error(), true))
+		} else {
+			results = append(results, NewToolResultBlock(toolUse.ID, calls[i].content, false))
+		}
+	}
+	if len(errs) > 0 && t.opts.ToolErrors == ToolErrorsJoined {
+		return nil, errors.Join(errs...)
+	}
+	return results, nil
+}
+
+// toolMemo holds the results of the tool calls of a run, for
+// [ToolRunnerOpts.MemoizeTools].
+type toolMemo struct {
+	mu      sync.Mutex
+	results map[string]*memoEntry
+}
+
+// memoEntry is the result of a memoized call, available once done is closed.
+type memoEntry struct {
+	done   chan struct{}
+	result toolCallResult
+}
+
+// call executes a tool use, or returns the memoized result of an identical
+// earlier or concurrent call.
+func (t *ToolRunner) call(ctx context.Context, toolUse ToolUseBlock, memo *toolMemo) toolCallResult {
+	fn, ok := t.tools[toolUse.Name]
+	if !ok {
+		return toolCallResult{err: fmt.Errorf("unknown tool %q", toolUse.Name)}
+	}
+	if !t.opts.MemoizeTools {
+		content, err := fn(ctx, toolUse.Input)
+		return toolCallResult{content, err}
+	}
+
+	key := toolUse.Name + "\x00" + string(toolUse.Input)
+	// Numbers are kept as written, since large integers do not survive a
+	// float64.
+	dec := json.NewDecoder(bytes.NewReader(toolUse.Input))
+	dec.UseNumber()
+	var v any
+	if dec.Decode(&v) == nil {
+		if canonical, err := json.Marshal(v); err == nil {
+			key = toolUse.Name + "\x00" + string(canonical)
+		}
+	}
+	memo.mu.Lock()
+	entry, ok := memo.results[key]
+	if !ok {
+		entry = &memoEntry{done: make(chan struct{})}
+		memo.results[key] = entry
+	}
+	memo.mu.Unlock()
+	if ok {
+		select {
+		case <-entry.done:
+			return entry.result
+		case <-ctx.Done():
+			return toolCallResult{err: ctx.Err()}
+		}
+	}
+	content, err := fn(ctx, toolUse.Input)
+	entry.result = toolCallResult{content, err}
+	close(entry.done)
+	return entry.result
+}
@@ -0,0 +1,341 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+	"github.com/sofianhadi1983/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+)
+
+// scriptedClient returns a client which answers each request with the next of
+// the given message contents, a JSON array of content blocks, and records the
+// request bodies. A response calling a tool has the tool_use stop reason.
+func scriptedClient(contents []string, requests *[]string) anthropic.Client {
+	return anthropic.NewClient(
+		option.WithAPIKey("my-anthropic-api-key"),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(&http.Client{
+			Transport: &closureTransport{
+				fn: func(req *http.Request) (*http.Response, error) {
+					body, _ := io.ReadAll(req.Body)
+					*requests = append(*requests, string(body))
+					content := contents[len(*requests)-1]
+					stopReason := "end_turn"
+					if strings.Contains(content, `"tool_use"`) {
+						stopReason = "tool_use"
+					}
+					message := fmt.Sprintf(`{"id":"msg_%d","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":%s,"stop_reason":%q,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5}}`, len(*requests), content, stopReason)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(message)),
+					}, nil
+				},
+			},
+		}),
+	)
+}
+
+func toolRunnerParams() anthropic.MessageNewParams {
+	return anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("What is the weather?"))},
+		Tools: []anthropic.ToolUnionParam{{OfTool: &anthropic.ToolParam{
+			Name:        "get_weather",
+			InputSchema: anthropic.ToolInputSchemaParam{Properties: map[string]any{"city": map[string]any{"type": "string"}}},
+		}}},
+	}
+}
+
+func TestToolRunner(t *testing.T) {
+	var requests []string
+	client := scriptedClient([]string{
+		`[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}},{"type":"tool_use","id":"toolu_2","name":"get_time","input":{}}]`,
+		`[{"type":"tool_use","id":"toolu_3","name":"get_weather","input":{"city":"Oslo"}}]`,
+		`[{"type":"text","text":"Sunny in Paris, snowing in Oslo."}]`,
+	}, &requests)
+
+	runner := client.Messages.NewToolRunner(map[string]anthropic.ToolFunc{
+		"get_weather": func(ctx context.Context, input json.RawMessage) (string, error) {
+			city := gjson.GetBytes(input, "city").String()
+			if city == "Oslo" {
+				return "", errors.New("no data")
+			}
+			return "sunny in " + city, nil
+		},
+	}, anthropic.ToolRunnerOpts{})
+
+	message, transcript, err := runner.Run(context.Background(), toolRunnerParams())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if message.Content[0].Text != "Sunny in Paris, snowing in Oslo." || len(transcript) != 6 || len(requests) != 3 {
+		t.Fatalf("unexpected result after %d requests: %+v, %d messages", len(requests), message.Content, len(transcript))
+	}
+
+	results := gjson.Get(requests[1], "messages.2.content")
+	if results.Get("0.tool_use_id").String() != "toolu_1" || results.Get("0.content.0.text").String() != "sunny in Paris" || results.Get("0.is_error").Bool() {
+		t.Errorf("unexpected tool result %s", results.Get("0").Raw)
+	}
+	if results.Get("1.tool_use_id").String() != "toolu_2" || !results.Get("1.is_error").Bool() || !strings.Contains(results.Get("1.content.0.text").String(), "unknown tool") {
+		t.Errorf("unexpected tool result %s", results.Get("1").Raw)
+	}
+	if result := gjson.Get(requests[2], "messages.4.content.0"); !result.Get("is_error").Bool() || result.Get("content.0.text").String() != "Error: no data" {
+		t.Errorf("unexpected tool result %s", result.Raw)
+	}
+}
+
+func TestToolRunnerMemoizeTools(t *testing.T) {
+	for _, memoize := range []bool{false, true} {
+		var requests []string
+		client := scriptedClient([]string{
+			`[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris","days":1}}]`,
+			`[{"type":"tool_use","id":"toolu_2","name":"get_weather","input":{"days":1, "city":"Paris"}}]`,
+			`[{"type":"text","text":"Sunny."}]`,
+		}, &requests)
+
+		calls := 0
+		runner := client.Messages.NewToolRunner(map[string]anthropic.ToolFunc{
+			"get_weather": func(ctx context.Context, input json.RawMessage) (string, error) {
+				calls++
+				return fmt.Sprintf("sunny (call %d)", calls), nil
+			},
+		}, anthropic.ToolRunnerOpts{MemoizeTools: memoize})
+
+		if _, _, err := runner.Run(context.Background(), toolRunnerParams()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectedCalls := 2
+		if memoize {
+			expectedCalls = 1
+		}
+		if calls != expectedCalls {
+			t.Errorf("memoize %v: expected %d calls, got %d", memoize, expectedCalls, calls)
+		}
+		if result := gjson.Get(requests[2], "messages.4.content.0.content.0.text").String(); memoize && result != "sunny (call 1)" {
+			t.Errorf("expected the memoized result, got %q", result)
+		}
+	}
+}
+
+func TestToolRunnerMemoizeToolsLargeNumbers(t *testing.T) {
+	var requests []string
+	client := scriptedClient([]string{
+		`[{"type":"tool_use","id":"toolu_1","name":"get_order","input":{"id":9007199254740993}}]`,
+		`[{"type":"tool_use","id":"toolu_2","name":"get_order","input":{"id":9007199254740992}}]`,
+		`[{"type":"text","text":"Done."}]`,
+	}, &requests)
+
+	calls := 0
+	runner := client.Messages.NewToolRunner(map[string]anthropic.ToolFunc{
+		"get_order": func(ctx context.Context, input json.RawMessage) (string, error) {
+			calls++
+			return "order " + gjson.GetBytes(input, "id").Raw, nil
+		},
+	}, anthropic.ToolRunnerOpts{MemoizeTools: true})
+
+	if _, _, err := runner.Run(context.Background(), toolRunnerParams()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected distinct large integers to be distinct calls, got %d calls", calls)
+	}
+	if result := gjson.Get(requests[2], "messages.4.content.0.content.0.text").String(); result != "order 9007199254740992" {
+		t.Errorf("expected the result of the second call, got %q", result)
+	}
+}
+
+func TestToolRunnerIterations(t *testing.T) {
+	toolUse := `[{"type":"tool_use","id":"toolu_%d","name":"get_weather","input":{"city":"Paris"}}]`
+	var contents []string
+	for i := range 3 {
+		contents = append(contents, fmt.Sprintf(toolUse, i))
+	}
+	tools := map[string]anthropic.ToolFunc{
+		"get_weather": func(ctx context.Context, input json.RawMessage) (string, error) { return "sunny", nil },
+	}
+
+	var requests []string
+	client := scriptedClient(contents, &requests)
+	message, transcript, err := client.Messages.NewToolRunner(tools, anthropic.ToolRunnerOpts{MaxIterations: 2}).
+		Run(context.Background(), toolRunnerParams())
+	if !errors.Is(err, anthropic.ErrMaxToolIterations) {
+		t.Fatalf("expected ErrMaxToolIterations, got %v", err)
+	}
+	if len(requests) != 2 || message.ID != "msg_2" || len(transcript) != 4 {
+		t.Errorf("expected the run to stop after 2 requests, got %d requests and %d messages", len(requests), len(transcript))
+	}
+
+	requests = nil
+	client = scriptedClient(contents, &requests)
+	var seen []int
+	message, transcript, err = client.Messages.NewToolRunner(tools, anthropic.ToolRunnerOpts{
+		OnIteration: func(i int, msg *anthropic.Message) bool {
+			seen = append(seen, i)
+			return msg.ID == "msg_2"
+		},
+	}).Run(context.Background(), toolRunnerParams())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != 2 || seen[1] != 2 || message.ID != "msg_2" || len(transcript) != 4 {
+		t.Errorf("expected the run to stop at the second message, got iterations %v and %d messages", seen, len(transcript))
+	}
+	if last := transcript[len(transcript)-1]; last.Role != anthropic.MessageParamRoleAssistant {
+		t.Errorf("expected the transcript to end with the stopped assistant turn, got %s", last.Role)
+	}
+}
+
+func TestToolRunnerParallelTools(t *testing.T) {
+	contents := []string{
+		`[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}},` +
+			`{"type":"tool_use","id":"toolu_2","name":"get_weather","input":{"city":"Rome"}},` +
+			`{"type":"tool_use","id":"toolu_3","name":"get_weather","input":{"city":"Oslo"}}]`,
+		`[{"type":"text","text":"Sunny everywhere."}]`,
+	}
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	var once sync.Once
+	release := make(chan struct{})
+	tools := map[string]anthropic.ToolFunc{
+		"get_weather": func(ctx context.Context, input json.RawMessage) (string, error) {
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			if running == 2 {
+				once.Do(func() { close(release) })
+			}
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return "sunny in " + gjson.GetBytes(input, "city").String(), nil
+		},
+	}
+
+	var requests []string
+	client := scriptedClient(contents, &requests)
+	_, _, err := client.Messages.NewToolRunner(tools, anthropic.ToolRunnerOpts{ParallelTools: true, MaxToolConcurrency: 2}).
+		Run(context.Background(), toolRunnerParams())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if maxRunning != 2 {
+		t.Errorf("expected 2 tools to run at once, got %d", maxRunning)
+	}
+	results := gjson.Get(requests[1], "messages.2.content")
+	for i, city := range []string{"Paris", "Rome", "Oslo"} {
+		result := results.Get(fmt.Sprintf("%d", i))
+		if id := result.Get("tool_use_id").String(); id != fmt.Sprintf("toolu_%d", i+1) || result.Get("content.0.text").String() != "sunny in "+city {
+			t.Errorf("expected the results in the order of the tool uses, got %s", results.Raw)
+		}
+	}
+
+	failing := map[string]anthropic.ToolFunc{
+		"get_weather": func(ctx context.Context, input json.RawMessage) (string, error) {
+			if city := gjson.GetBytes(input, "city").String(); city != "Rome" {
+				return "", fmt.Errorf("no forecast for %s", city)
+			}
+			return "sunny", nil
+		},
+	}
+	for _, parallel := range []bool{false, true} {
+		requests = nil
+		client = scriptedClient(contents, &requests)
+		_, transcript, err := client.Messages.NewToolRunner(failing, anthropic.ToolRunnerOpts{ParallelTools: parallel, ToolErrors: anthropic.ToolErrorsFirst}).
+			Run(context.Background(), toolRunnerParams())
+		var toolErr *anthropic.ToolError
+		if !errors.As(err, &toolErr) || toolErr.Name != "get_weather" {
+			t.Errorf("parallel %v: expected a ToolError, got %v", parallel, err)
+		}
+		if len(requests) != 1 || len(transcript) != 2 {
+			t.Errorf("parallel %v: expected the run to stop at the first error, got %d requests and %d messages", parallel, len(requests), len(transcript))
+		}
+
+		requests = nil
+		client = scriptedClient(contents, &requests)
+		_, _, err = client.Messages.NewToolRunner(failing, anthropic.ToolRunnerOpts{ParallelTools: parallel, ToolErrors: anthropic.ToolErrorsJoined}).
+			Run(context.Background(), toolRunnerParams())
+		if err == nil || err.Error() != "tool get_weather (toolu_1): no forecast for Paris\ntool get_weather (toolu_3): no forecast for Oslo" {
+			t.Errorf("parallel %v: expected both errors, got %v", parallel, err)
+		}
+	}
+}
+
+func TestToolRunnerParallelToolsStopAfterError(t *testing.T) {
+	contents := []string{
+		`[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}},` +
+			`{"type":"tool_use","id":"toolu_2","name":"get_weather","input":{"city":"Rome"}},` +
+			`{"type":"tool_use","id":"toolu_3","name":"get_weather","input":{"city":"Oslo"}}]`,
+		`[{"type":"text","text":"Sunny everywhere."}]`,
+	}
+	// The loop repeats the run since a tool starting after the failure
+	// depends on the scheduling.
+	for range 50 {
+		var calls atomic.Int32
+		tools := map[string]anthropic.ToolFunc{
+			"get_weather": func(ctx context.Context, input json.RawMessage) (string, error) {
+				calls.Add(1)
+				return "", fmt.Errorf("no forecast for %s", gjson.GetBytes(input, "city").String())
+			},
+		}
+		var requests []string
+		client := scriptedClient(contents, &requests)
+		_, _, err := client.Messages.NewToolRunner(tools, anthropic.ToolRunnerOpts{ParallelTools: true, MaxToolConcurrency: 1, ToolErrors: anthropic.ToolErrorsFirst}).
+			Run(context.Background(), toolRunnerParams())
+		var toolErr *anthropic.ToolError
+		if !errors.As(err, &toolErr) || toolErr.ToolUseID != "toolu_1" {
+			t.Fatalf("expected the error of the first tool, got %v", err)
+		}
+		if n := calls.Load(); n != 1 {
+			t.Fatalf("expected no tool to start after the failure, got %d calls", n)
+		}
+	}
+}
+
+func TestToolRunnerParallelMemoizeTools(t *testing.T) {
+	var requests []string
+	client := scriptedClient([]string{
+		`[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}},` +
+			`{"type":"tool_use","id":"toolu_2","name":"get_weather","input":{"city":"Paris"}},` +
+			`{"type":"tool_use","id":"toolu_3","name":"get_weather","input":{"city":"Paris"}}]`,
+		`[{"type":"text","text":"Sunny."}]`,
+	}, &requests)
+
+	var calls atomic.Int32
+	tools := map[string]anthropic.ToolFunc{
+		"get_weather": func(ctx context.Context, input json.RawMessage) (string, error) {
+			calls.Add(1)
+			time.Sleep(20 * time.Millisecond)
+			return "sunny", nil
+		},
+	}
+	_, _, err := client.Messages.NewToolRunner(tools, anthropic.ToolRunnerOpts{ParallelTools: true, MemoizeTools: true}).
+		Run(context.Background(), toolRunnerParams())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected identical concurrent calls to run the tool once, got %d calls", n)
+	}
+	results := gjson.Get(requests[1], "messages.2.content")
+	for i := range 3 {
+		if text := results.Get(fmt.Sprintf("%d.content.0.text", i)).String(); text != "sunny" {
+			t.Errorf("expected every tool use to get the shared result, got %s", results.Raw)
+		}
+	}
+}
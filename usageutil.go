@@ -0,0 +1,61 @@
+package anthropic
+
+import (
+	"slices"
+	"sync"
+)
+
+// UsageAggregator keeps a running total of the token usage and cost of many
+// requests, such as the turns of an agent loop. Costs are computed with
+// [LookupPricing]. The zero value is ready to use, and an aggregator is safe
+// for concurrent use.
+//
+//	var usage anthropic.UsageAggregator
+//	for ... {
+//		message, err := client.Messages.New(ctx, params)
+//		...
+//		usage.Add(message.Usage, message.Model)
+//	}
+//	total, cost := usage.Total()
+type UsageAggregator struct {
+	mu       sync.Mutex
+	usage    Usage
+	cost     Cost
+	unpriced []Model
+}
+
+// Add adds the usage of a response from model to the total.
+func (a *UsageAggregator) Add(usage Usage, model Model) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.usage.InputTokens += usage.InputTokens
+	a.usage.OutputTokens += usage.OutputTokens
+	a.usage.CacheReadInputTokens += usage.CacheReadInputTokens
+	a.usage.CacheCreationInputTokens += usage.CacheCreationInputTokens
+	a.usage.CacheCreation.Ephemeral5mInputTokens += usage.CacheCreation.Ephemeral5mInputTokens
+	a.usage.CacheCreation.Ephemeral1hInputTokens += usage.CacheCreation.Ephemeral1hInputTokens
+	a.usage.ServerToolUse.WebSearchRequests += usage.ServerToolUse.WebSearchRequests
+
+	if pricing, ok := LookupPricing(model); ok {
+		a.cost = a.cost.add(pricing.Cost(usage))
+	} else if !slices.Contains(a.unpriced, model) {
+		a.unpriced = append(a.unpriced, model)
+	}
+}
+
+// Total returns the usage added so far and its cost. The cost leaves out the
+// usage of models reported by [UsageAggregator.UnpricedModels].
+func (a *UsageAggregator) Total() (Usage, Cost) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.usage, a.cost
+}
+
+// UnpricedModels returns the models added which have no price in
+// [ModelPrices], and whose usage is therefore not included in the cost.
+func (a *UsageAggregator) UnpricedModels() []Model {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.unpriced)
+}
@@ -0,0 +1,52 @@
+package anthropic_test
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/sofianhadi1983/anthropic-sdk-go"
+)
+
+func TestUsageAggregator(t *testing.T) {
+	var agg anthropic.UsageAggregator
+	agg.Add(anthropic.Usage{InputTokens: 1_000_000, OutputTokens: 100_000, CacheReadInputTokens: 2_000_000}, anthropic.ModelClaudeSonnet4_5_20250929)
+	agg.Add(anthropic.Usage{
+		InputTokens:              1_000_000,
+		CacheCreationInputTokens: 300_000,
+		CacheCreation:            anthropic.CacheCreation{Ephemeral5mInputTokens: 200_000, Ephemeral1hInputTokens: 100_000},
+		ServiceTier:              anthropic.UsageServiceTierBatch,
+	}, anthropic.ModelClaudeHaiku4_5)
+	agg.Add(anthropic.Usage{InputTokens: 5}, "claude-unknown")
+	// A newer snapshot of a known alias is priced like the alias.
+	agg.Add(anthropic.Usage{OutputTokens: 1_000_000}, "claude-opus-4-5-20990101")
+
+	usage, cost := agg.Total()
+	if usage.InputTokens != 2_000_005 || usage.OutputTokens != 1_100_000 || usage.CacheReadInputTokens != 2_000_000 ||
+		usage.CacheCreationInputTokens != 300_000 || usage.CacheCreation.Ephemeral1hInputTokens != 100_000 {
+		t.Errorf("unexpected total usage: %+v", usage)
+	}
+
+	expected := anthropic.Cost{
+		Input:      3 + 0.5,
+		CacheWrite: (0.2*1.25 + 0.1*2) / 2,
+		CacheRead:  2 * 0.3,
+		Output:     0.1*15 + 25,
+	}
+	for _, c := range [][2]float64{
+		{cost.Input, expected.Input},
+		{cost.CacheWrite, expected.CacheWrite},
+		{cost.CacheRead, expected.CacheRead},
+		{cost.Output, expected.Output},
+		{cost.Total(), expected.Total()},
+	} {
+		if math.Abs(c[0]-c[1]) > 1e-9 {
+			t.Errorf("expected cost %+v, got %+v", expected, cost)
+			break
+		}
+	}
+
+	if unpriced := agg.UnpricedModels(); !slices.Equal(unpriced, []anthropic.Model{"claude-unknown"}) {
+		t.Errorf("unexpected unpriced models: %v", unpriced)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/option"
@@ -19,6 +20,34 @@ import (
 
 const DefaultVersion = "vertex-2023-10-16"
 
+// Config configures [WithConfig].
+type Config struct {
+	// ProjectID is the Google Cloud project which is billed for the requests.
+	ProjectID string
+	// Region is the region of the endpoint, such as us-east5, or global.
+	Region string
+	// Credentials authenticate the requests. If nil, the Application Default
+	// Credentials are used, as with [WithGoogleAuth].
+	Credentials *google.Credentials
+}
+
+// WithConfig returns a request option which sends requests to the Messages API
+// to Google Vertex AI, as configured by cfg. Like [WithGoogleAuth], it panics if
+// the region is missing or no credentials can be found.
+//
+// Models are named as in the Anthropic API, so the anthropic.Model constants
+// can be used: the date of a model version is mapped to the Vertex AI form,
+// such as claude-sonnet-4-5@20250929.
+func WithConfig(cfg Config) sdkoption.RequestOption {
+	if cfg.Credentials == nil {
+		return WithGoogleAuth(context.Background(), cfg.Region, cfg.ProjectID, "https://www.googleapis.com/auth/cloud-platform")
+	}
+	if cfg.Region == "" {
+		panic("region must be provided")
+	}
+	return WithCredentials(context.Background(), cfg.Region, cfg.ProjectID, cfg.Credentials)
+}
+
 // WithGoogleAuth returns a request option which loads the [Application Default Credentials] for Google Vertex AI and registers
 // middleware that intercepts requests to the Messages API.
 //
@@ -53,6 +82,7 @@ func WithCredentials(ctx context.Context, region string, projectID string, creds
 	}
 
 	return requestconfig.RequestOptionFunc(func(rc *requestconfig.RequestConfig) error {
+		rc.CustomAuth = true
 		return rc.Apply(
 			sdkoption.WithBaseURL(baseURL),
 			sdkoption.WithMiddleware(middleware),
@@ -79,7 +109,7 @@ func vertexMiddleware(region, projectID string) sdkoption.Middleware {
 					return nil, fmt.Errorf("no projectId was given and it could not be resolved from credentials")
 				}
 
-				model := gjson.GetBytes(body, "model").String()
+				model := vertexModel(gjson.GetBytes(body, "model").String())
 				stream := gjson.GetBytes(body, "stream").Bool()
 
 				body, _ = sjson.DeleteBytes(body, "model")
@@ -97,6 +127,12 @@ func vertexMiddleware(region, projectID string) sdkoption.Middleware {
 					return nil, fmt.Errorf("no projectId was given and it could not be resolved from credentials")
 				}
 
+				// Unlike message creation, token counting takes the model in the
+				// body, in its Vertex AI form.
+				if model := gjson.GetBytes(body, "model"); model.Exists() {
+					body, _ = sjson.SetBytes(body, "model", vertexModel(model.String()))
+				}
+
 				r.URL.Path = fmt.Sprintf("/v1/projects/%s/locations/%s/publishers/anthropic/models/count-tokens:rawPredict", projectID, region)
 			}
 
@@ -112,3 +148,27 @@ func vertexMiddleware(region, projectID string) sdkoption.Middleware {
 		return next(r)
 	}
 }
+
+// vertexModels maps the models whose name on Vertex AI differs from the
+// Anthropic API by more than the form of the version.
+var vertexModels = map[string]string{
+	"claude-3-5-sonnet-20241022": "claude-3-5-sonnet-v2@20241022",
+}
+
+// vertexModel returns the name of model on Vertex AI, where the date of a
+// model version follows an @ rather than a dash.
+func vertexModel(model string) string {
+	if name, ok := vertexModels[model]; ok {
+		return name
+	}
+	i := len(model) - len("-20060102")
+	if i <= 0 || model[i] != '-' || strings.ContainsRune(model, '@') {
+		return model
+	}
+	for _, c := range model[i+1:] {
+		if c < '0' || c > '9' {
+			return model
+		}
+	}
+	return model[:i] + "@" + model[i+1:]
+}
@@ -0,0 +1,58 @@
+package vertex
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestVertexModelPath(t *testing.T) {
+	cases := map[string]string{
+		"claude-sonnet-4-5-20250929": "claude-sonnet-4-5@20250929",
+		"claude-sonnet-4-5@20250929": "claude-sonnet-4-5@20250929",
+		"claude-sonnet-4-5":          "claude-sonnet-4-5",
+		"claude-3-5-sonnet-20241022": "claude-3-5-sonnet-v2@20241022",
+	}
+	middleware := vertexMiddleware("us-east5", "my-project")
+	for model, want := range cases {
+		body := []byte(`{"model":"` + model + `","max_tokens":1024,"messages":[]}`)
+		req, err := http.NewRequest(http.MethodPost, "https://us-east5-aiplatform.googleapis.com/v1/messages", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = middleware(req, func(r *http.Request) (*http.Response, error) {
+			if path := "/v1/projects/my-project/locations/us-east5/publishers/anthropic/models/" + want + ":rawPredict"; r.URL.Path != path {
+				t.Errorf("%s: expected path %s, got %s", model, path, r.URL.Path)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVertexCountTokensModel(t *testing.T) {
+	middleware := vertexMiddleware("us-east5", "my-project")
+	body := []byte(`{"model":"claude-sonnet-4-5-20250929","messages":[]}`)
+	req, err := http.NewRequest(http.MethodPost, "https://us-east5-aiplatform.googleapis.com/v1/messages/count_tokens", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = middleware(req, func(r *http.Request) (*http.Response, error) {
+		if path := "/v1/projects/my-project/locations/us-east5/publishers/anthropic/models/count-tokens:rawPredict"; r.URL.Path != path {
+			t.Errorf("expected path %s, got %s", path, r.URL.Path)
+		}
+		sent, _ := io.ReadAll(r.Body)
+		if model := gjson.GetBytes(sent, "model").String(); model != "claude-sonnet-4-5@20250929" {
+			t.Errorf("expected the Vertex AI model name in the body, got %q", model)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}